@@ -2,20 +2,141 @@ package app
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
+	"github.com/corverroos/dvstore/ipfs"
+	"github.com/corverroos/dvstore/notify"
+	"github.com/corverroos/dvstore/objstore"
 	"github.com/corverroos/dvstore/router"
 	"github.com/corverroos/dvstore/service"
+	_ "github.com/lib/pq" // Registers the postgres sql driver.
 	"github.com/obolnetwork/charon/app/errors"
 	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	// joinDeadlineInterval is the interval at which draft definitions whose join deadline passed are cancelled.
+	joinDeadlineInterval = time.Minute
+	// alarmInterval is the interval at which the quota and retention alarms are evaluated.
+	alarmInterval = 5 * time.Minute
+	// definitionMetricsInterval is the interval at which the definition count metrics are sampled.
+	definitionMetricsInterval = time.Minute
+	// staleDefinitionInterval is the interval at which definitions exceeding the definition TTL are deleted.
+	staleDefinitionInterval = 10 * time.Minute
+	// trashPurgeInterval is the interval at which deleted definitions past the trash retention are purged.
+	trashPurgeInterval = time.Hour
+)
+
 type Config struct {
-	Log         log.Config
-	HTTPAddress string
-	MongoURL    string
+	Log                  log.Config
+	HTTPAddress          string
+	DrainTimeout         time.Duration
+	StorageDriver        string
+	MigrateOnStart       bool
+	MongoURL             string
+	PostgresURL          string
+	MetricsPushAddress   string
+	MetricsPushProtocol  string
+	MetricsPushInterval  time.Duration
+	MonitoringAddress    string
+	DebugAddress         string
+	TermsHash            string
+	DraftExpiry          time.Duration
+	DefinitionTTL        time.Duration
+	BlockExpired         bool
+	DeleteApproval       bool
+	DeleteCoolOff        time.Duration
+	TrashRetention       time.Duration
+	RegistrationExpiry   time.Duration
+	RequestWindow        time.Duration
+	RejectIncompatible   bool
+	RequireInvites       bool
+	InviteTTL            time.Duration
+	AutoFinalize         bool
+	BFTThreshold         bool
+	RequireCreator       bool
+	MinThresholdRatio    float64
+	Notify               notify.Config
+	IPFS                 ipfs.Config
+	ObjectStorage        objstore.Config
+	CacheSize            int
+	CacheSync            bool
+	CompressValidators   int
+	ShardValidators      int
+	Networks             []string
+	MemoryLimit          string
+	ExportSigningKey     string
+	ResponseSigningKey   string
+	ImportTrustedKeys    []string
+	DBTimeouts           service.DBTimeouts
+	SlowRequest          time.Duration
+	RequestTimeout       time.Duration
+	EndpointTimeouts     []string
+	Lenient              bool
+	ReadOnly             bool
+	MongoHosts           []string
+	MongoReplicaSet      string
+	MongoRetryWrites     bool
+	MongoRetryReads      bool
+	MongoCompat          bool
+	MongoMaxPoolSize     uint64
+	MongoMinPoolSize     uint64
+	MongoSelectTimeout   time.Duration
+	MongoReadConcern     string
+	MongoWriteConcern    string
+	MongoRetry           service.RetryPolicy
+	MongoTransactions    bool
+	StableAPI            bool
+	StableAPIStrict      bool
+	Abuse                router.AbuseConfig
+	CORS                 router.CORSConfig
+	Auth                 router.AuthConfig
+	APIKeys              []string
+	TenantAPIKeys        []string
+	ScopedAPIKeys        []string
+	TenantQuota          int
+	CreatorQuota         int
+	OIDCGroupRoles       []string
+	HMACKeys             []string
+	SIWERoles            []string
+	MTLSRoles            []string
+	TLSCertFile          string
+	TLSKeyFile           string
+	TLSClientCAFile      string
+	TLSRequireClientCert bool
+	LegacySunset         string
+	ValidateSchemas      bool
+	MaxBodyBytes         int64
+	JSONLimits           router.JSONLimits
+	RateLimit            router.RateLimitConfig
+	Redactions           []string
+	CaptureFailures      bool
+	CaptureSize          int64
+	UploadTTL            time.Duration
+	UI                   bool
+	SwaggerUI            bool
+	RequireIfMatch       bool
+	Debug                router.DebugConfig
+	Scheduler            SchedulerConfig
+	Alarms               service.AlarmConfig
+	Tracing              TracingConfig
+	// Reloads are the reloadable settings applied when the config file changes. Settings are not reloaded if nil.
+	Reloads <-chan ReloadConfig
 }
 
 func Run(ctx context.Context, conf Config) (err error) {
@@ -28,36 +149,503 @@ func Run(ctx context.Context, conf Config) (err error) {
 
 	log.Info(ctx, "Starting dvstore")
 
-	client, err := mongo.NewClient(options.Client().ApplyURI(conf.MongoURL))
+	if conf.MetricsPushAddress != "" {
+		if conf.MetricsPushInterval <= 0 {
+			return errors.New("invalid metrics push interval, expected positive duration",
+				z.Str("interval", conf.MetricsPushInterval.String()))
+		}
+
+		pusher, err := newMetricsPusher(conf.MetricsPushProtocol, conf.MetricsPushAddress)
+		if err != nil {
+			return err
+		}
+
+		pushCtx, cancel := context.WithCancel(ctx)
+		pushDone := make(chan struct{})
+		go func() {
+			defer close(pushDone)
+			if err := pushMetrics(pushCtx, pusher, conf.MetricsPushInterval); err != nil {
+				log.Warn(ctx, "Failed pushing metrics", err)
+			}
+		}()
+		defer func() {
+			cancel()
+			<-pushDone // Wait for the final push.
+		}()
+	}
+
+	shutdownTracing, err := initTracing(ctx, conf.Tracing)
 	if err != nil {
-		return errors.Wrap(err, "failed to create mongo client")
+		return err
 	}
-	err = client.Connect(ctx)
+	defer func() {
+		// Pending spans are flushed after the storage is closed, using a fresh context since ctx is done.
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(flushCtx); err != nil {
+			log.Warn(ctx, "Failed flushing traces", err)
+		}
+	}()
+
+	memoryLimit, err := parseMemoryLimit(conf.MemoryLimit)
 	if err != nil {
-		return errors.Wrap(err, "failed to connect to mongo")
+		return err
+	} else if memoryLimit > 0 {
+		conf.CacheSize = applyMemoryLimit(ctx, memoryLimit, conf.CacheSize)
+	}
+
+	if conf.MonitoringAddress != "" {
+		go func() {
+			if err := serveMetrics(ctx, conf.MonitoringAddress, conf.DebugAddress == conf.MonitoringAddress); err != nil {
+				log.Warn(ctx, "Failed serving metrics", err)
+			}
+		}()
+	}
+
+	// The debug endpoints share the monitoring server if configured with the same address.
+	if conf.DebugAddress != "" && conf.DebugAddress != conf.MonitoringAddress {
+		go func() {
+			if err := serveDebug(ctx, conf.DebugAddress); err != nil {
+				log.Warn(ctx, "Failed serving debug endpoints", err)
+			}
+		}()
+	}
+
+	if conf.ReadOnly {
+		log.Info(ctx, "Read-only mode, write endpoints disabled")
+	}
+
+	var (
+		storage service.Storage
+		db      *mongo.Database // Nil unless using the mongo storage driver.
+	)
+	switch service.StorageDriver(conf.StorageDriver) {
+	case service.StorageMongo:
+		db, err = connectMongo(ctx, conf)
+		if err != nil {
+			return err
+		}
+		storage = service.NewMongoStorage(db)
+	case service.StorageMemory:
+		log.Warn(ctx, "In-memory storage, data is lost on restart", nil)
+		storage = service.NewMemoryStorage()
+	case service.StoragePostgres:
+		pg, err := connectPostgres(ctx, conf)
+		if err != nil {
+			return err
+		}
+		storage = service.NewPostgresStorage(pg)
+	default:
+		return errors.New("unknown storage driver", z.Str("driver", conf.StorageDriver))
+	}
+	defer func() {
+		// The storage is closed after the server drained in-flight requests, using a fresh context since ctx is done.
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := storage.Close(closeCtx); err != nil {
+			log.Warn(ctx, "Failed closing storage", err)
+		}
+	}()
+
+	var queue *notify.Queue
+	if !conf.ReadOnly && db != nil {
+		queue = notify.NewQueue(conf.Notify, db.Collection("deliveries"), db.Collection("dead_letters"))
+	}
+
+	audit := storage.Audit()
+
+	var exportKey ed25519.PrivateKey
+	if conf.ExportSigningKey != "" {
+		seed, err := hex.DecodeString(strings.TrimPrefix(conf.ExportSigningKey, "0x"))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return errors.New("invalid export signing key, expected 32 byte hex ed25519 seed")
+		}
+		exportKey = ed25519.NewKeyFromSeed(seed)
+	}
+
+	var responseKey ed25519.PrivateKey
+	if conf.ResponseSigningKey != "" {
+		seed, err := hex.DecodeString(strings.TrimPrefix(conf.ResponseSigningKey, "0x"))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return errors.New("invalid response signing key, expected 32 byte hex ed25519 seed")
+		}
+		responseKey = ed25519.NewKeyFromSeed(seed)
+	}
+
+	var trustedKeys []ed25519.PublicKey
+	for _, trustedKey := range conf.ImportTrustedKeys {
+		pubkey, err := hex.DecodeString(strings.TrimPrefix(trustedKey, "0x"))
+		if err != nil || len(pubkey) != ed25519.PublicKeySize {
+			return errors.New("invalid import trusted key, expected 32 byte hex ed25519 public key")
+		}
+		trustedKeys = append(trustedKeys, pubkey)
+	}
+
+	if err := service.VerifyNetworks(conf.Networks); err != nil {
+		return err
+	}
+
+	publisher, err := ipfs.New(conf.IPFS)
+	if err != nil {
+		return err
+	}
+
+	archiver, err := objstore.New(conf.ObjectStorage)
+	if err != nil {
+		return err
+	}
+
+	notifier := notify.New(conf.Notify, queue)
+
+	defConf := service.DefinitionConfig{
+		DraftExpiry:        conf.DraftExpiry,
+		DefinitionTTL:      conf.DefinitionTTL,
+		RegistrationExpiry: conf.RegistrationExpiry,
+		BlockExpired:       conf.BlockExpired,
+		DeleteApproval:     conf.DeleteApproval,
+		DeleteCoolOff:      conf.DeleteCoolOff,
+		TrashRetention:     conf.TrashRetention,
+		RequestWindow:      conf.RequestWindow,
+		RejectIncompatible: conf.RejectIncompatible,
+		RequireInvites:     conf.RequireInvites,
+		InviteTTL:          conf.InviteTTL,
+		AutoFinalize:       conf.AutoFinalize,
+		BFTThreshold:       conf.BFTThreshold,
+		RequireCreator:     conf.RequireCreator,
+		MinThresholdRatio:  conf.MinThresholdRatio,
+		Notifier:           notifier,
+		Publisher:          publisher,
+		Archiver:           archiver,
+		Audit:              audit,
+		CacheSize:          conf.CacheSize,
+		DBTimeouts:         conf.DBTimeouts,
+		Retry:              conf.MongoRetry,
+		Transactions:       conf.MongoTransactions,
+		Alarms:             conf.Alarms,
+		TenantQuota:        conf.TenantQuota,
+		CreatorQuota:       conf.CreatorQuota,
+		ExportKey:          exportKey,
+		ImportTrustedKeys:  trustedKeys,
+		CompressValidators: conf.CompressValidators,
+		ShardValidators:    conf.ShardValidators,
+		Networks:           conf.Networks,
+	}
+	defSvc := storage.Definition(defConf)
+	tmplSvc := storage.Template()
+
+	if conf.CacheSync && db != nil && conf.CacheSize > 0 {
+		go func() {
+			if err := defSvc.Sync(log.WithTopic(ctx, "sync")); err != nil {
+				log.Warn(ctx, "Failed syncing caches", err)
+			}
+		}()
+	}
+
+	var legacySunset time.Time
+	if conf.LegacySunset != "" {
+		legacySunset, err = time.Parse("2006-01-02", conf.LegacySunset)
+		if err != nil {
+			return errors.Wrap(err, "invalid legacy sunset date")
+		}
+	}
+
+	conf.Auth.APIKeys = make(map[string]router.Role)
+	for _, apiKey := range conf.APIKeys {
+		split := strings.SplitN(apiKey, ":", 2)
+		if len(split) != 2 {
+			return errors.New("invalid api key, expected role:key")
+		}
+		conf.Auth.APIKeys[split[1]] = router.Role(split[0])
+	}
+
+	conf.Auth.TenantAPIKeys = make(map[string]router.TenantKey)
+	for _, tenantKey := range conf.TenantAPIKeys {
+		split := strings.SplitN(tenantKey, ":", 3)
+		if len(split) != 3 {
+			return errors.New("invalid tenant api key, expected tenant:role:key")
+		}
+		conf.Auth.TenantAPIKeys[split[2]] = router.TenantKey{Tenant: split[0], Role: router.Role(split[1])}
+	}
+
+	conf.Auth.ScopedAPIKeys = make(map[string]router.ScopedKey)
+	for _, scopedKey := range conf.ScopedAPIKeys {
+		split := strings.SplitN(scopedKey, ":", 4)
+		if len(split) != 4 {
+			return errors.New("invalid scoped api key, expected role:endpoints:config_hashes:key")
+		}
+
+		scope := router.Scope{Endpoints: splitScope(split[1])}
+		for _, hash := range splitScope(split[2]) {
+			b, err := hex.DecodeString(strings.TrimPrefix(hash, "0x"))
+			if err != nil {
+				return errors.Wrap(err, "invalid scoped api key config hash", z.Str("config_hash", hash))
+			}
+			scope.ConfigHashes = append(scope.ConfigHashes, b)
+		}
+		conf.Auth.ScopedAPIKeys[split[3]] = router.ScopedKey{Role: router.Role(split[0]), Scope: scope}
+	}
+
+	conf.Auth.OIDC.GroupRoles = make(map[string]router.Role)
+	for _, groupRole := range conf.OIDCGroupRoles {
+		split := strings.SplitN(groupRole, ":", 2)
+		if len(split) != 2 {
+			return errors.New("invalid oidc group role, expected group:role")
+		}
+		conf.Auth.OIDC.GroupRoles[split[0]] = router.Role(split[1])
+	}
+
+	conf.Auth.HMACKeys = make(map[string]router.HMACKey)
+	for _, hmacKey := range conf.HMACKeys {
+		split := strings.SplitN(hmacKey, ":", 3)
+		if len(split) != 3 {
+			return errors.New("invalid hmac key, expected id:role:secret")
+		}
+		conf.Auth.HMACKeys[split[0]] = router.HMACKey{Role: router.Role(split[1]), Secret: split[2]}
+	}
+
+	conf.Auth.SIWE.Roles = make(map[string]router.Role)
+	for _, addressRole := range conf.SIWERoles {
+		split := strings.SplitN(addressRole, ":", 2)
+		if len(split) != 2 {
+			return errors.New("invalid siwe role, expected address:role")
+		}
+		conf.Auth.SIWE.Roles[split[0]] = router.Role(split[1])
+	}
+
+	conf.Auth.MTLSRoles = make(map[string]router.Role)
+	for _, nameRole := range conf.MTLSRoles {
+		split := strings.SplitN(nameRole, ":", 2)
+		if len(split) != 2 {
+			return errors.New("invalid mtls role, expected common_name:role")
+		}
+		conf.Auth.MTLSRoles[split[0]] = router.Role(split[1])
+	}
+
+	endpointTimeouts := make(map[string]time.Duration)
+	for _, endpointTimeout := range conf.EndpointTimeouts {
+		split := strings.SplitN(endpointTimeout, ":", 2)
+		if len(split) != 2 {
+			return errors.New("invalid endpoint timeout, expected endpoint:duration")
+		}
+		timeout, err := time.ParseDuration(split[1])
+		if err != nil {
+			return errors.Wrap(err, "invalid endpoint timeout duration", z.Str("endpoint", split[0]))
+		}
+		endpointTimeouts[split[0]] = timeout
+	}
+
+	redaction := make(router.RedactionPolicy)
+	for _, redact := range conf.Redactions {
+		split := strings.SplitN(redact, ":", 2)
+		if len(split) != 2 {
+			return errors.New("invalid redaction, expected caller:field")
+		}
+		redaction[split[0]] = append(redaction[split[0]], split[1])
+	}
+
+	health := storage.Health()
+	admin := storage.Admin(defConf)
+
+	// Rate limit overrides, failed request captures, chunked uploads and scheduler leases require mongo.
+	var limits service.RateLimits
+	if db != nil {
+		limits = service.NewRateLimits(db.Collection("rate_limits"))
+	}
+
+	var captures service.Captures
+	if conf.CaptureFailures && !conf.ReadOnly && db != nil {
+		captures, err = service.NewCaptures(ctx, db, "captures", conf.CaptureSize)
+		if err != nil {
+			return err
+		}
+		log.Info(ctx, "Capturing failed requests", z.Int("size_bytes", int(conf.CaptureSize)))
 	}
-	defer client.Disconnect(ctx)
 
-	defSvc := service.NewDefinition(client.Database("dvstore").Collection("definitions"))
+	var uploads service.Uploads
+	if !conf.ReadOnly && db != nil {
+		uploads, err = service.NewUploads(ctx, db.Collection("uploads"), conf.UploadTTL)
+		if err != nil {
+			return err
+		}
+	}
 
-	mux, err := router.NewRouter(defSvc)
+	var leases *mongo.Collection
+	if db != nil {
+		leases = db.Collection("leases")
+	}
+
+	sched := newScheduler(conf.Scheduler, leases)
+	sched.Register(job{
+		Name:     "memory_sampler",
+		Interval: memorySampleInterval,
+		Enabled:  memoryLimit > 0,
+		Run:      sampleMemory(memoryLimit),
+	})
+	sched.Register(job{
+		Name:     "definition_metrics",
+		Interval: definitionMetricsInterval,
+		Enabled:  conf.MonitoringAddress != "" || conf.MetricsPushAddress != "",
+		Run:      sampleDefinitions(defSvc),
+	})
+	sched.Register(job{
+		Name:     "pending_operator_metrics",
+		Interval: definitionMetricsInterval,
+		Enabled:  (conf.MonitoringAddress != "" || conf.MetricsPushAddress != "") && admin != nil,
+		Run: func(ctx context.Context) error {
+			return admin.SamplePendingOperators(ctx)
+		},
+	})
+	sched.Register(job{
+		Name:     "notify_retries",
+		Interval: notify.RetryInterval,
+		Enabled:  conf.Scheduler.NotifyRetries && queue != nil,
+		Run:      queue.RetryDue, // Deliveries are claimed, so all instances retry concurrently.
+	})
+	sched.Register(job{
+		Name:       "join_deadlines",
+		Interval:   joinDeadlineInterval,
+		Enabled:    conf.Scheduler.JoinDeadlines && !conf.ReadOnly && admin != nil,
+		LeaderOnly: true,
+		Run: func(ctx context.Context) error {
+			cancelled, err := admin.CancelOverdue(ctx)
+			if err != nil {
+				return err
+			} else if len(cancelled) > 0 {
+				log.Info(ctx, "Cancelled overdue definitions", z.Any("config_hashes", cancelled))
+			}
+
+			return nil
+		},
+	})
+	sched.Register(job{
+		Name:       "definition_ttl",
+		Interval:   staleDefinitionInterval,
+		Enabled:    conf.DefinitionTTL > 0 && !conf.ReadOnly && admin != nil,
+		LeaderOnly: true,
+		Run: func(ctx context.Context) error {
+			expired, err := admin.ExpireStale(ctx)
+			if err != nil {
+				return err
+			} else if len(expired) > 0 {
+				log.Info(ctx, "Deleted stale definitions", z.Any("config_hashes", expired))
+			}
+
+			return nil
+		},
+	})
+	sched.Register(job{
+		Name:       "trash_purge",
+		Interval:   trashPurgeInterval,
+		Enabled:    conf.TrashRetention > 0 && !conf.ReadOnly && admin != nil,
+		LeaderOnly: true,
+		Run: func(ctx context.Context) error {
+			purged, err := admin.PurgeTrash(ctx)
+			if err != nil {
+				return err
+			} else if purged > 0 {
+				log.Info(ctx, "Purged deleted definitions", z.Int("count", purged))
+			}
+
+			return nil
+		},
+	})
+	sched.Register(job{
+		Name:       "cleanup",
+		Interval:   conf.Scheduler.CleanupInterval,
+		Enabled:    conf.Scheduler.Cleanup && !conf.ReadOnly && admin != nil,
+		LeaderOnly: true,
+		Run: func(ctx context.Context) error {
+			_, err := admin.Cleanup(ctx, service.CleanupOptions{})
+			return err
+		},
+	})
+	sched.Register(job{
+		Name:       "alarms",
+		Interval:   alarmInterval,
+		Enabled:    conf.Scheduler.Alarms && admin != nil,
+		LeaderOnly: true,
+		Run: func(ctx context.Context) error {
+			return admin.CheckAlarms(ctx)
+		},
+	})
+	sched.Run(ctx)
+
+	var (
+		settings     *router.Settings
+		activeConfig func() interface{}
+	)
+	if conf.Reloads != nil {
+		settings = router.NewSettings(conf.RateLimit, conf.CORS.AllowedOrigins)
+		reloader := newReloader(conf, settings, notifier)
+		activeConfig = reloader.Active
+		go reloader.Run(ctx, conf.Reloads)
+	}
+
+	mux, err := router.NewRouter(defSvc, tmplSvc, health, admin, limits, captures, audit, uploads, queue, router.Config{
+		TermsHash:        conf.TermsHash,
+		SlowRequest:      conf.SlowRequest,
+		RequestTimeout:   conf.RequestTimeout,
+		EndpointTimeouts: endpointTimeouts,
+		Lenient:          conf.Lenient,
+		ReadOnly:         conf.ReadOnly,
+		Abuse:            conf.Abuse,
+		CORS:             conf.CORS,
+		Auth:             conf.Auth,
+		LegacySunset:     legacySunset,
+		ValidateSchemas:  conf.ValidateSchemas,
+		MaxBodyBytes:     conf.MaxBodyBytes,
+		JSONLimits:       conf.JSONLimits,
+		RateLimit:        conf.RateLimit,
+		Settings:         settings,
+		ActiveConfig:     activeConfig,
+		Redaction:        redaction,
+		UI:               conf.UI,
+		SwaggerUI:        conf.SwaggerUI,
+		RequireIfMatch:   conf.RequireIfMatch,
+		SigningKey:       responseKey,
+		Debug:            conf.Debug,
+	})
 	if err != nil {
 		return errors.Wrap(err, "failed to create router")
 	}
 
 	server := http.Server{Addr: conf.HTTPAddress, Handler: mux, ReadHeaderTimeout: time.Second}
+	if (conf.TLSClientCAFile != "" || len(conf.MTLSRoles) > 0) && conf.TLSCertFile == "" {
+		return errors.New("client certificate authentication requires a tls certificate")
+	} else if (conf.TLSCertFile == "") != (conf.TLSKeyFile == "") {
+		return errors.New("tls requires both a certificate and a key")
+	} else if (len(conf.MTLSRoles) > 0 || conf.TLSRequireClientCert) && conf.TLSClientCAFile == "" {
+		return errors.New("mtls roles and required client certificates require a tls client ca")
+	} else if conf.TLSClientCAFile != "" {
+		server.TLSConfig, err = clientCATLSConfig(conf.TLSClientCAFile, conf.TLSRequireClientCert)
+		if err != nil {
+			return err
+		}
+	}
+
 	serverErr := make(chan error, 1)
 	go func() {
+		if conf.TLSCertFile != "" {
+			serverErr <- server.ListenAndServeTLS(conf.TLSCertFile, conf.TLSKeyFile)
+			return
+		}
 		serverErr <- server.ListenAndServe()
 	}()
 
 	select {
 	case <-ctx.Done():
-		log.Info(ctx, "Shutdown detected")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second) // Fresh shutdown context.
+		// Shutdown stops accepting new requests and waits for in-flight requests up to the drain timeout.
+		log.Info(ctx, "Shutdown detected, draining in-flight requests",
+			z.I64("inflight", router.Inflight()), z.Str("timeout", conf.DrainTimeout.String()))
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), conf.DrainTimeout) // Fresh shutdown context.
 		defer cancel()
 		err = server.Shutdown(shutdownCtx)
-		if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Warn(ctx, "Drain timeout exceeded, aborting in-flight requests", nil, z.I64("inflight", router.Inflight()))
+			_ = server.Close()
+		} else if err != nil {
 			return errors.Wrap(err, "failed to shutdown server")
 		}
 	case err := <-serverErr:
@@ -68,3 +656,199 @@ func Run(ctx context.Context, conf Config) (err error) {
 
 	return nil
 }
+
+// splitScope returns the |-separated values of a scoped api key field, none if empty.
+func splitScope(field string) []string {
+	if field == "" {
+		return nil
+	}
+
+	return strings.Split(field, "|")
+}
+
+// clientCATLSConfig returns the server TLS config verifying client certificates signed by the CA. Clients without
+// certificates are rejected if required, otherwise they may authenticate by other means.
+func clientCATLSConfig(caFile string, require bool) (*tls.Config, error) {
+	b, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "read tls client ca")
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New("invalid tls client ca pem")
+	}
+
+	clientAuth := tls.VerifyClientCertIfGiven
+	if require {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: clientAuth,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// NewMongoStorage connects to the dvstore mongo database and returns its storage driver, for tools operating
+// on the data directly rather than via the API.
+func NewMongoStorage(ctx context.Context, conf Config) (service.Storage, error) {
+	db, err := connectMongo(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.NewMongoStorage(db), nil
+}
+
+// connectMongo connects to the dvstore mongo database, applying its pending migrations if enabled unless read-only.
+func connectMongo(ctx context.Context, conf Config) (*mongo.Database, error) {
+	monitor := service.NewCommandMonitor()
+	if conf.Tracing.Exporter != "" {
+		// Mongo commands are traced as child spans of the request span of their context.
+		monitor = chainMonitors(monitor, otelmongo.NewMonitor())
+	}
+
+	clientOpts := options.Client().ApplyURI(conf.MongoURL).SetMonitor(monitor).
+		SetRetryWrites(conf.MongoRetryWrites).
+		SetRetryReads(conf.MongoRetryReads)
+	if len(conf.MongoHosts) > 0 {
+		// The seed list overrides the hosts of the url, for HA deployments.
+		clientOpts.SetHosts(conf.MongoHosts)
+	}
+	if conf.MongoReplicaSet != "" {
+		clientOpts.SetReplicaSet(conf.MongoReplicaSet)
+	}
+	if conf.ReadOnly {
+		// Allow reading from secondaries since writes are disabled.
+		clientOpts.SetReadPreference(readpref.SecondaryPreferred())
+	}
+	if conf.MongoCompat {
+		// DocumentDB and Cosmos DB don't support retryable writes.
+		clientOpts.SetRetryWrites(false)
+	}
+	if conf.StableAPI {
+		// Pin the API version so driver and server upgrades don't change behaviour.
+		clientOpts.SetServerAPIOptions(options.ServerAPI(options.ServerAPIVersion1).SetStrict(conf.StableAPIStrict))
+	}
+	if conf.MongoMaxPoolSize > 0 {
+		clientOpts.SetMaxPoolSize(conf.MongoMaxPoolSize)
+	}
+	if conf.MongoMinPoolSize > 0 {
+		clientOpts.SetMinPoolSize(conf.MongoMinPoolSize)
+	}
+	if conf.MongoSelectTimeout > 0 {
+		clientOpts.SetServerSelectionTimeout(conf.MongoSelectTimeout)
+	}
+	switch conf.MongoReadConcern {
+	case "":
+	case "local", "available", "majority", "linearizable", "snapshot":
+		clientOpts.SetReadConcern(readconcern.New(readconcern.Level(conf.MongoReadConcern)))
+	default:
+		return nil, errors.New("invalid mongo read concern", z.Str("level", conf.MongoReadConcern))
+	}
+	if conf.MongoWriteConcern != "" {
+		wc, err := parseWriteConcern(conf.MongoWriteConcern)
+		if err != nil {
+			return nil, err
+		}
+		clientOpts.SetWriteConcern(wc)
+	}
+
+	client, err := mongo.NewClient(clientOpts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create mongo client")
+	}
+	err = client.Connect(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to mongo")
+	}
+
+	db := client.Database("dvstore")
+	table := db.Collection("definitions")
+
+	caps := service.DetectCapabilities(ctx, table)
+	log.Info(ctx, "Detected mongo capabilities", z.Bool("change_streams", caps.ChangeStreams))
+	if !caps.ChangeStreams && !conf.MongoCompat {
+		log.Warn(ctx, "Mongo doesn't support change streams, enable --mongo-compat if using DocumentDB or Cosmos DB", nil)
+	}
+
+	if !conf.ReadOnly && conf.MigrateOnStart {
+		applied, err := service.Migrate(ctx, db)
+		if err != nil {
+			_ = client.Disconnect(ctx)
+			return nil, err
+		}
+		for _, m := range applied {
+			log.Info(ctx, "Applied migration", z.Int("version", m.Version), z.Str("name", m.Name))
+		}
+	}
+
+	return db, nil
+}
+
+// connectPostgres connects to the dvstore postgres database, creating its tables if enabled unless read-only.
+func connectPostgres(ctx context.Context, conf Config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", conf.PostgresURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open postgres")
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, errors.Wrap(err, "failed to connect to postgres")
+	}
+
+	if !conf.ReadOnly && conf.MigrateOnStart {
+		if err := service.MigratePostgres(ctx, db); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// MigratePostgres connects to the dvstore postgres database and creates its tables if they don't exist.
+func MigratePostgres(ctx context.Context, conf Config) error {
+	conf.MigrateOnStart = false
+	db, err := connectPostgres(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return service.MigratePostgres(ctx, db)
+}
+
+// parseWriteConcern returns the write concern of the number of acknowledging members or "majority".
+func parseWriteConcern(w string) (*writeconcern.WriteConcern, error) {
+	if w == "majority" {
+		return writeconcern.New(writeconcern.WMajority()), nil
+	}
+
+	n, err := strconv.Atoi(w)
+	if err != nil || n < 0 {
+		return nil, errors.New("invalid mongo write concern, expected majority or number of members", z.Str("w", w))
+	}
+
+	return writeconcern.New(writeconcern.W(n)), nil
+}
+
+// Migrate connects to the dvstore mongo database and applies its pending migrations, returning the applied
+// migrations, or only returns the pending migrations if dry run.
+func Migrate(ctx context.Context, conf Config, dryRun bool) ([]service.Migration, error) {
+	conf.MigrateOnStart = false
+	db, err := connectMongo(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Client().Disconnect(ctx)
+
+	if dryRun {
+		return service.PendingMigrations(ctx, db)
+	}
+
+	return service.Migrate(ctx, db)
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/obolnetwork/charon/app/errors"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// startTime is the process start time, approximated by package initialization.
+var startTime = time.Now()
+
+// runtimeInfo is the response of the runtime info debug endpoint.
+type runtimeInfo struct {
+	Uptime       string            `json:"uptime"`
+	GoVersion    string            `json:"go_version"`
+	Version      string            `json:"version,omitempty"`
+	Build        map[string]string `json:"build,omitempty"`
+	Goroutines   int               `json:"goroutines"`
+	GOMAXPROCS   int               `json:"gomaxprocs"`
+	HeapAlloc    uint64            `json:"heap_alloc_bytes"`
+	HeapInuse    uint64            `json:"heap_inuse_bytes"`
+	HeapObjects  uint64            `json:"heap_objects"`
+	Sys          uint64            `json:"sys_bytes"`
+	NumGC        uint32            `json:"num_gc"`
+	LastGC       time.Time         `json:"last_gc,omitempty"`
+	PauseTotalNs uint64            `json:"gc_pause_total_ns"`
+}
+
+// registerDebug registers the pprof profiles at /debug/pprof/ and the runtime info at /debug/runtime.
+func registerDebug(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/runtime", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(newRuntimeInfo())
+	})
+}
+
+// newRuntimeInfo returns the current runtime info.
+func newRuntimeInfo() runtimeInfo {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	resp := runtimeInfo{
+		Uptime:       time.Since(startTime).Truncate(time.Second).String(),
+		GoVersion:    runtime.Version(),
+		Goroutines:   runtime.NumGoroutine(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		HeapAlloc:    mem.HeapAlloc,
+		HeapInuse:    mem.HeapInuse,
+		HeapObjects:  mem.HeapObjects,
+		Sys:          mem.Sys,
+		NumGC:        mem.NumGC,
+		PauseTotalNs: mem.PauseTotalNs,
+	}
+	if mem.LastGC > 0 {
+		resp.LastGC = time.Unix(0, int64(mem.LastGC)).UTC()
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		resp.Version = info.Main.Version
+		resp.Build = make(map[string]string)
+		for _, setting := range info.Settings {
+			resp.Build[setting.Key] = setting.Value
+		}
+	}
+
+	return resp
+}
+
+// serveDebug serves the debug endpoints on the provided address until the context is cancelled.
+func serveDebug(ctx context.Context, address string) error {
+	mux := http.NewServeMux()
+	registerDebug(mux)
+
+	// Profiles and traces take up to their requested duration, so no write timeout.
+	server := http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrap(err, "serve debug")
+	}
+
+	return nil
+}
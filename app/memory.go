@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/promauto"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/prometheus/client_golang/prometheus"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// cacheMemoryRatio is the ratio of the memory limit available to the in-process caches.
+	cacheMemoryRatio = 0.25
+	// avgCacheEntryBytes is the estimated average size of a cached definition or lock.
+	avgCacheEntryBytes = 64 << 10
+	// memorySampleInterval is the interval at which memory pressure metrics are sampled.
+	memorySampleInterval = 10 * time.Second
+)
+
+var (
+	memoryLimitGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "dvstore",
+		Subsystem: "app",
+		Name:      "memory_limit_bytes",
+		Help:      "The configured soft memory limit in bytes, zero if not limited",
+	})
+
+	memoryPressureGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "dvstore",
+		Subsystem: "app",
+		Name:      "memory_pressure_ratio",
+		Help:      "The ratio of memory obtained from the OS to the soft memory limit",
+	})
+)
+
+// parseMemoryLimit parses a GOMEMLIMIT style byte quantity, e.g. "512MiB", returning zero if empty.
+func parseMemoryLimit(limit string) (int64, error) {
+	if limit == "" {
+		return 0, nil
+	}
+
+	units := []struct {
+		Suffix string
+		Bytes  int64
+	}{
+		{"GiB", 1 << 30},
+		{"MiB", 1 << 20},
+		{"KiB", 1 << 10},
+		{"B", 1},
+	}
+
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(limit, unit.Suffix) {
+			limit, multiplier = strings.TrimSuffix(limit, unit.Suffix), unit.Bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(limit, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, errors.New("invalid memory limit, expected a positive quantity like 512MiB", z.Str("limit", limit))
+	}
+
+	return n * multiplier, nil
+}
+
+// applyMemoryLimit sets the runtime soft memory limit and returns the cache size capped
+// so the caches fit within their share of the limit.
+func applyMemoryLimit(ctx context.Context, limit int64, cacheSize int) int {
+	debug.SetMemoryLimit(limit)
+	memoryLimitGauge.Set(float64(limit))
+
+	// Definitions and locks are cached separately.
+	maxCacheSize := int(float64(limit) * cacheMemoryRatio / (2 * avgCacheEntryBytes))
+	if cacheSize > maxCacheSize {
+		log.Warn(ctx, "Cache size reduced to fit memory limit", nil,
+			z.Int("configured", cacheSize), z.Int("capped", maxCacheSize))
+
+		return maxCacheSize
+	}
+
+	return cacheSize
+}
+
+// sampleMemory returns a job updating the memory pressure metric.
+func sampleMemory(limit int64) func(context.Context) error {
+	return func(context.Context) error {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		memoryPressureGauge.Set(float64(stats.Sys-stats.HeapReleased) / float64(limit))
+
+		return nil
+	}
+}
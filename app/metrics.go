@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"github.com/corverroos/dvstore/service"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/promauto"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"net/http"
+	"time"
+)
+
+const (
+	// pushJob is the Pushgateway job name and OTLP service name that dvstore metrics are grouped under.
+	pushJob = "dvstore"
+
+	// PushPushgateway pushes metrics to a Prometheus Pushgateway, e.g. localhost:9091.
+	PushPushgateway = "pushgateway"
+	// PushOTLP pushes metrics to an OTLP/HTTP collector, e.g. http://localhost:4318.
+	PushOTLP = "otlp"
+)
+
+// metricsPusher pushes the current metrics.
+type metricsPusher interface {
+	PushContext(ctx context.Context) error
+}
+
+var definitionsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "dvstore",
+	Subsystem: "app",
+	Name:      "definitions",
+	Help:      "The number of stored definitions by status",
+}, []string{"status"})
+
+// newMetricsPusher returns a pusher of all metrics to the address using the protocol.
+func newMetricsPusher(protocol string, address string) (metricsPusher, error) {
+	registry, err := promauto.NewRegistry(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "create metrics registry")
+	}
+
+	switch protocol {
+	case PushPushgateway:
+		return push.New(address, pushJob).Gatherer(registry), nil
+	case PushOTLP:
+		return newOTLPPusher(address, registry)
+	default:
+		return nil, errors.New("unknown metrics push protocol", z.Str("protocol", protocol))
+	}
+}
+
+// pushMetrics pushes all metrics using the pusher every interval.
+// It blocks until the context is cancelled after which it does a final push.
+func pushMetrics(ctx context.Context, pusher metricsPusher, interval time.Duration) error {
+	ctx = log.WithTopic(ctx, "metrics")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Push a final time so that short-lived runs do not lose their metrics.
+			pushCtx, cancel := context.WithTimeout(context.Background(), interval) // Fresh push context.
+			defer cancel()
+
+			return pushOnce(pushCtx, pusher)
+		case <-ticker.C:
+			if err := pushOnce(ctx, pusher); err != nil {
+				log.Warn(ctx, "Failed pushing metrics", err)
+			}
+		}
+	}
+}
+
+// pushOnce pushes the current metrics, replacing all metrics of the job if pushed to the Pushgateway.
+func pushOnce(ctx context.Context, pusher metricsPusher) error {
+	if err := pusher.PushContext(ctx); err != nil {
+		return errors.Wrap(err, "push metrics")
+	}
+
+	return nil
+}
+
+// serveMetrics serves all metrics at /metrics on the provided address until the context is cancelled,
+// including the debug endpoints if enabled. OpenMetrics is enabled since exemplars are only exposed in that format.
+func serveMetrics(ctx context.Context, address string, withDebug bool) error {
+	registry, err := promauto.NewRegistry(nil)
+	if err != nil {
+		return errors.Wrap(err, "create metrics registry")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	if withDebug {
+		registerDebug(mux)
+	}
+
+	server := http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrap(err, "serve metrics")
+	}
+
+	return nil
+}
+
+// sampleDefinitions returns a job that samples the number of stored definitions by status.
+func sampleDefinitions(defs service.Definition) func(context.Context) error {
+	return func(ctx context.Context) error {
+		stats, err := defs.Stats(ctx, service.StatsFilter{})
+		if err != nil {
+			return err
+		}
+
+		// Reset so that statuses without definitions are removed rather than reporting stale counts.
+		definitionsGauge.Reset()
+		for status, count := range stats.ByStatus {
+			definitionsGauge.WithLabelValues(string(status)).Set(float64(count))
+		}
+
+		return nil
+	}
+}
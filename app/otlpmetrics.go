@@ -0,0 +1,212 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// otlpMetricsPath is the default OTLP/HTTP metrics path of collectors.
+const otlpMetricsPath = "/v1/metrics"
+
+// otlpPusher pushes the gathered prometheus metrics to an OTLP/HTTP collector as cumulative OTLP metrics.
+type otlpPusher struct {
+	endpoint string
+	gatherer prometheus.Gatherer
+	client   *http.Client
+	start    time.Time // Start of the cumulative metrics.
+}
+
+// newOTLPPusher returns a pusher of the gathered metrics to the OTLP/HTTP collector url, e.g. http://localhost:4318.
+func newOTLPPusher(address string, gatherer prometheus.Gatherer) (*otlpPusher, error) {
+	endpoint, err := url.Parse(address)
+	if err != nil || endpoint.Host == "" {
+		return nil, errors.New("invalid metrics push address, expected otlp collector url", z.Str("address", address))
+	}
+	if endpoint.Path == "" || endpoint.Path == "/" {
+		endpoint.Path = otlpMetricsPath
+	}
+
+	return &otlpPusher{
+		endpoint: endpoint.String(),
+		gatherer: gatherer,
+		client:   new(http.Client),
+		start:    time.Now(),
+	}, nil
+}
+
+// PushContext pushes the current metrics to the collector.
+func (p *otlpPusher) PushContext(ctx context.Context) error {
+	families, err := p.gatherer.Gather()
+	if err != nil {
+		return errors.Wrap(err, "gather metrics")
+	}
+
+	now := uint64(time.Now().UnixNano())
+	start := uint64(p.start.UnixNano())
+
+	var metrics []*metricspb.Metric
+	for _, family := range families {
+		if metric, ok := otlpMetric(family, start, now); ok {
+			metrics = append(metrics, metric)
+		}
+	}
+
+	body, err := proto.Marshal(&collectorpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{otlpAttribute("service.name", pushJob)}},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope:   &commonpb.InstrumentationScope{Name: pushJob},
+				Metrics: metrics,
+			}},
+		}},
+	})
+	if err != nil {
+		return errors.Wrap(err, "marshal otlp metrics")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "create otlp request")
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "post otlp metrics")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return errors.New("otlp collector rejected metrics", z.Int("status", resp.StatusCode))
+	}
+
+	return nil
+}
+
+// otlpMetric returns the cumulative OTLP metric of the prometheus metric family, or false if its type isn't supported.
+func otlpMetric(family *dto.MetricFamily, start, now uint64) (*metricspb.Metric, bool) {
+	metric := &metricspb.Metric{Name: family.GetName(), Description: family.GetHelp()}
+
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		var points []*metricspb.NumberDataPoint
+		for _, m := range family.GetMetric() {
+			points = append(points, otlpNumber(m, m.GetCounter().GetValue(), start, now))
+		}
+		metric.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			IsMonotonic:            true,
+			DataPoints:             points,
+		}}
+	case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+		var points []*metricspb.NumberDataPoint
+		for _, m := range family.GetMetric() {
+			value := m.GetGauge().GetValue()
+			if family.GetType() == dto.MetricType_UNTYPED {
+				value = m.GetUntyped().GetValue()
+			}
+			points = append(points, otlpNumber(m, value, start, now))
+		}
+		metric.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: points}}
+	case dto.MetricType_HISTOGRAM:
+		var points []*metricspb.HistogramDataPoint
+		for _, m := range family.GetMetric() {
+			points = append(points, otlpHistogram(m, start, now))
+		}
+		metric.Data = &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			DataPoints:             points,
+		}}
+	case dto.MetricType_SUMMARY:
+		var points []*metricspb.SummaryDataPoint
+		for _, m := range family.GetMetric() {
+			point := &metricspb.SummaryDataPoint{
+				Attributes:        otlpAttributes(m),
+				StartTimeUnixNano: start,
+				TimeUnixNano:      now,
+				Count:             m.GetSummary().GetSampleCount(),
+				Sum:               m.GetSummary().GetSampleSum(),
+			}
+			for _, q := range m.GetSummary().GetQuantile() {
+				point.QuantileValues = append(point.QuantileValues, &metricspb.SummaryDataPoint_ValueAtQuantile{
+					Quantile: q.GetQuantile(),
+					Value:    q.GetValue(),
+				})
+			}
+			points = append(points, point)
+		}
+		metric.Data = &metricspb.Metric_Summary{Summary: &metricspb.Summary{DataPoints: points}}
+	default:
+		return nil, false
+	}
+
+	return metric, true
+}
+
+func otlpNumber(m *dto.Metric, value float64, start, now uint64) *metricspb.NumberDataPoint {
+	return &metricspb.NumberDataPoint{
+		Attributes:        otlpAttributes(m),
+		StartTimeUnixNano: start,
+		TimeUnixNano:      now,
+		Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+	}
+}
+
+// otlpHistogram returns the OTLP histogram data point of the prometheus histogram. Prometheus buckets are cumulative
+// and include the +Inf bucket implicitly, while OTLP bucket counts are per bucket with an explicit overflow bucket.
+func otlpHistogram(m *dto.Metric, start, now uint64) *metricspb.HistogramDataPoint {
+	hist := m.GetHistogram()
+	sum := hist.GetSampleSum()
+
+	point := &metricspb.HistogramDataPoint{
+		Attributes:        otlpAttributes(m),
+		StartTimeUnixNano: start,
+		TimeUnixNano:      now,
+		Count:             hist.GetSampleCount(),
+		Sum:               &sum,
+	}
+
+	var cumulative uint64
+	for _, bucket := range hist.GetBucket() {
+		if math.IsInf(bucket.GetUpperBound(), 1) {
+			continue
+		}
+		point.ExplicitBounds = append(point.ExplicitBounds, bucket.GetUpperBound())
+		point.BucketCounts = append(point.BucketCounts, bucket.GetCumulativeCount()-cumulative)
+		cumulative = bucket.GetCumulativeCount()
+	}
+	point.BucketCounts = append(point.BucketCounts, hist.GetSampleCount()-cumulative)
+
+	return point
+}
+
+func otlpAttributes(m *dto.Metric) []*commonpb.KeyValue {
+	var resp []*commonpb.KeyValue
+	for _, label := range m.GetLabel() {
+		resp = append(resp, otlpAttribute(label.GetName(), label.GetValue()))
+	}
+
+	return resp
+}
+
+func otlpAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
@@ -0,0 +1,158 @@
+package app
+
+import (
+	"context"
+	"github.com/corverroos/dvstore/notify"
+	"github.com/corverroos/dvstore/router"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ReloadConfig are the settings applied without restarting the server when the config file changes.
+type ReloadConfig struct {
+	LogLevel    string
+	RateLimit   router.RateLimitConfig
+	CORSOrigins []string
+	// Notify replaces the notification integrations, retaining the retry settings and DKG publisher.
+	Notify notify.Config
+}
+
+// Reloadable returns the reloadable settings of the config.
+func (c Config) Reloadable() ReloadConfig {
+	return ReloadConfig{
+		LogLevel:    c.Log.Level,
+		RateLimit:   c.RateLimit,
+		CORSOrigins: c.CORS.AllowedOrigins,
+		Notify:      c.Notify,
+	}
+}
+
+// activeConfig is the active reloadable config returned by the admin config endpoint.
+// Webhook URLs are reduced to their scheme and host since they contain credentials.
+type activeConfig struct {
+	LogLevel       string     `json:"log_level"`
+	IPRate         float64    `json:"ip_rate"`
+	IPBurst        int        `json:"ip_burst"`
+	KeyRate        float64    `json:"key_rate"`
+	KeyBurst       int        `json:"key_burst"`
+	CORSOrigins    []string   `json:"cors_allowed_origins"`
+	SlackWebhook   string     `json:"slack_webhook,omitempty"`
+	DiscordWebhook string     `json:"discord_webhook,omitempty"`
+	DKGTriggerURL  string     `json:"dkg_trigger_url,omitempty"`
+	SMTPAddress    string     `json:"smtp_address,omitempty"`
+	SMTPTo         []string   `json:"smtp_to,omitempty"`
+	ReloadedAt     *time.Time `json:"reloaded_at,omitempty"`
+}
+
+// reloader applies reloaded settings to the running server and tracks the active settings.
+type reloader struct {
+	log      log.Config
+	notify   notify.Config
+	settings *router.Settings
+	notifier notify.Notifier
+
+	mu         sync.Mutex
+	active     ReloadConfig
+	reloadedAt *time.Time // Nil if not reloaded yet.
+}
+
+func newReloader(conf Config, settings *router.Settings, notifier notify.Notifier) *reloader {
+	return &reloader{
+		log:      conf.Log,
+		notify:   conf.Notify,
+		settings: settings,
+		notifier: notifier,
+		active:   conf.Reloadable(),
+	}
+}
+
+// Run applies the reloaded settings until the context is cancelled or the channel closed.
+func (r *reloader) Run(ctx context.Context, reloads <-chan ReloadConfig) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case conf, ok := <-reloads:
+			if !ok {
+				return
+			}
+
+			if err := r.apply(conf); err != nil {
+				log.Warn(ctx, "Failed reloading config, retaining previous settings", err)
+				continue
+			}
+
+			log.Info(ctx, "Reloaded config", z.Str("log_level", conf.LogLevel), z.Any("cors_origins", conf.CORSOrigins))
+		}
+	}
+}
+
+// apply applies the settings, validating the log level before changing anything.
+func (r *reloader) apply(conf ReloadConfig) error {
+	logConf := r.log
+	logConf.Level = conf.LogLevel
+	if _, err := logConf.ZapLevel(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if conf.LogLevel != r.active.LogLevel {
+		if err := log.InitLogger(logConf); err != nil {
+			return err
+		}
+	}
+
+	notifyConf := conf.Notify
+	notifyConf.MaxAttempts = r.notify.MaxAttempts
+	notifyConf.RetryBackoff = r.notify.RetryBackoff
+	notifyConf.MaxRetryBackoff = r.notify.MaxRetryBackoff
+	notifyConf.DKGPublisher = r.notify.DKGPublisher
+
+	r.settings.Update(conf.RateLimit, conf.CORSOrigins)
+	r.notifier.Reload(notifyConf)
+	r.active = conf
+	now := time.Now()
+	r.reloadedAt = &now
+
+	return nil
+}
+
+// Active returns the active reloadable config.
+func (r *reloader) Active() interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return activeConfig{
+		LogLevel:       r.active.LogLevel,
+		IPRate:         r.active.RateLimit.IPRate,
+		IPBurst:        r.active.RateLimit.IPBurst,
+		KeyRate:        r.active.RateLimit.KeyRate,
+		KeyBurst:       r.active.RateLimit.KeyBurst,
+		CORSOrigins:    r.active.CORSOrigins,
+		SlackWebhook:   redactURL(r.active.Notify.SlackWebhook),
+		DiscordWebhook: redactURL(r.active.Notify.DiscordWebhook),
+		DKGTriggerURL:  redactURL(r.active.Notify.DKGTriggerURL),
+		SMTPAddress:    r.active.Notify.SMTPAddress,
+		SMTPTo:         r.active.Notify.SMTPTo,
+		ReloadedAt:     r.reloadedAt,
+	}
+}
+
+// redactURL returns the scheme and host of the URL, omitting its path and query which may contain secrets.
+func redactURL(rawURL string) string {
+	if rawURL == "" {
+		return ""
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "redacted"
+	}
+
+	return u.Scheme + "://" + u.Host
+}
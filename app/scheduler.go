@@ -0,0 +1,250 @@
+package app
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/promauto"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// leaderLeaseID is the id of the scheduler leader lease document.
+const leaderLeaseID = "scheduler"
+
+var (
+	jobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dvstore",
+		Subsystem: "scheduler",
+		Name:      "job_run_total",
+		Help:      "The total number of background job runs by job and result, either success, error or skipped if not the leader",
+	}, []string{"job", "result"})
+
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "dvstore",
+		Subsystem: "scheduler",
+		Name:      "job_duration_seconds",
+		Help:      "The duration of background job runs in seconds by job",
+	}, []string{"job"})
+
+	jobLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dvstore",
+		Subsystem: "scheduler",
+		Name:      "job_last_success_timestamp_seconds",
+		Help:      "The unix timestamp of the last successful background job run by job",
+	}, []string{"job"})
+
+	leaderGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "dvstore",
+		Subsystem: "scheduler",
+		Name:      "leader",
+		Help:      "Whether this instance holds the scheduler leader lease, always 1 if leader election is disabled",
+	})
+)
+
+// SchedulerConfig defines the background job scheduler.
+type SchedulerConfig struct {
+	// Jitter is the maximum fraction of the interval randomly added to or subtracted from each job's interval,
+	// spreading the load of jobs across instances.
+	Jitter float64
+	// LeaderElection restricts leader-only jobs to the instance holding the leader lease.
+	// All instances run leader-only jobs if disabled.
+	LeaderElection bool
+	// LeaderLease is the duration of the leader lease, renewed every third of it.
+	LeaderLease time.Duration
+	// NotifyRetries enables retrying failed notification deliveries.
+	NotifyRetries bool
+	// JoinDeadlines enables cancelling draft definitions whose join deadline passed.
+	JoinDeadlines bool
+	// Cleanup enables periodically deleting expired drafts and checking consistency.
+	Cleanup bool
+	// CleanupInterval is the interval of the cleanup job.
+	CleanupInterval time.Duration
+	// Alarms enables evaluating the quota and retention alarms.
+	Alarms bool
+}
+
+// job is a periodic background task.
+type job struct {
+	Name     string
+	Interval time.Duration
+	// Enabled is false if the job is disabled by configuration.
+	Enabled bool
+	// LeaderOnly restricts the job to the leader instance, avoiding duplicate work across instances.
+	LeaderOnly bool
+	Run        func(context.Context) error
+}
+
+// scheduler runs registered background jobs at their jittered interval until the context is cancelled.
+type scheduler struct {
+	conf SchedulerConfig
+	jobs []job
+
+	// leases is the collection of the leader lease, nil if leader election is disabled.
+	leases   *mongo.Collection
+	holderID string
+
+	mu          sync.Mutex
+	leaderUntil time.Time
+}
+
+// newScheduler returns a new scheduler electing a leader via the leases collection if enabled.
+func newScheduler(conf SchedulerConfig, leases *mongo.Collection) *scheduler {
+	s := &scheduler{
+		conf:     conf,
+		holderID: primitive.NewObjectID().Hex(),
+	}
+	if conf.LeaderElection {
+		s.leases = leases
+	}
+
+	return s
+}
+
+// Register registers the job, ignoring it if disabled.
+func (s *scheduler) Register(j job) {
+	if !j.Enabled {
+		return
+	}
+
+	s.jobs = append(s.jobs, j)
+}
+
+// Run starts the leader election and all registered jobs, returning immediately.
+func (s *scheduler) Run(ctx context.Context) {
+	ctx = log.WithTopic(ctx, "scheduler")
+
+	if s.leases != nil {
+		go s.elect(ctx)
+	} else {
+		leaderGauge.Set(1)
+	}
+
+	for _, j := range s.jobs {
+		log.Info(ctx, "Scheduling background job",
+			z.Str("job", j.Name), z.Any("interval", j.Interval), z.Bool("leader_only", j.LeaderOnly))
+
+		go s.loop(ctx, j)
+	}
+}
+
+// loop runs the job at its jittered interval until the context is cancelled.
+func (s *scheduler) loop(ctx context.Context, j job) {
+	ctx = log.WithCtx(ctx, z.Str("job", j.Name))
+
+	for {
+		timer := time.NewTimer(s.jittered(j.Interval))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if j.LeaderOnly && !s.isLeader() {
+			jobRuns.WithLabelValues(j.Name, "skipped").Inc()
+			continue
+		}
+
+		t0 := time.Now()
+		err := j.Run(ctx)
+		jobDuration.WithLabelValues(j.Name).Observe(time.Since(t0).Seconds())
+
+		if err != nil {
+			jobRuns.WithLabelValues(j.Name, "error").Inc()
+			log.Warn(ctx, "Background job failed", err)
+
+			continue
+		}
+
+		jobRuns.WithLabelValues(j.Name, "success").Inc()
+		jobLastSuccess.WithLabelValues(j.Name).Set(float64(time.Now().Unix()))
+	}
+}
+
+// jittered returns the interval randomly adjusted by up to the configured jitter fraction.
+func (s *scheduler) jittered(interval time.Duration) time.Duration {
+	if s.conf.Jitter <= 0 {
+		return interval
+	}
+
+	return interval + time.Duration((rand.Float64()*2-1)*s.conf.Jitter*float64(interval))
+}
+
+// isLeader returns true if leader election is disabled or this instance holds an unexpired leader lease.
+func (s *scheduler) isLeader() bool {
+	if s.leases == nil {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return time.Now().Before(s.leaderUntil)
+}
+
+// elect acquires or renews the leader lease every third of its duration until the context is cancelled.
+func (s *scheduler) elect(ctx context.Context) {
+	ticker := time.NewTicker(s.conf.LeaderLease / 3)
+	defer ticker.Stop()
+
+	for {
+		wasLeader := s.isLeader()
+
+		leader, err := s.acquire(ctx)
+		if err != nil {
+			log.Warn(ctx, "Failed acquiring scheduler leader lease", err)
+		} else if leader != wasLeader {
+			log.Info(ctx, "Scheduler leadership changed", z.Bool("leader", leader))
+		}
+
+		if s.isLeader() {
+			leaderGauge.Set(1)
+		} else {
+			leaderGauge.Set(0)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// acquire acquires or renews the leader lease if it is expired or already held by this instance,
+// returning true if this instance is the leader.
+func (s *scheduler) acquire(ctx context.Context) (bool, error) {
+	now := time.Now()
+	until := now.Add(s.conf.LeaderLease)
+
+	_, err := s.leases.UpdateOne(ctx,
+		bson.D{
+			{"_id", leaderLeaseID},
+			{"$or", bson.A{
+				bson.D{{"holder", s.holderID}},
+				bson.D{{"expires_at", bson.D{{"$lt", now}}}},
+			}},
+		},
+		bson.D{{"$set", bson.D{{"holder", s.holderID}, {"expires_at", until}}}},
+		options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// The lease is held by another instance, so the upsert conflicts with the existing lease.
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrap(err, "update leader lease")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.leaderUntil = until
+
+	return true, nil
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"net/url"
+)
+
+const (
+	// ExporterOTLP exports spans to an OTLP/HTTP collector, e.g. http://localhost:4318.
+	ExporterOTLP = "otlp"
+	// ExporterJaeger exports spans to a Jaeger collector, e.g. http://localhost:14268/api/traces.
+	ExporterJaeger = "jaeger"
+)
+
+// TracingConfig defines the OpenTelemetry span exporter. Spans are not exported if the exporter is empty.
+type TracingConfig struct {
+	// Exporter is the span exporter, either ExporterOTLP or ExporterJaeger.
+	Exporter string
+	// Endpoint is the URL of the collector spans are exported to.
+	Endpoint string
+	// SampleRatio is the fraction of root spans sampled, child spans follow their parent's sampling decision.
+	SampleRatio float64
+	// ServiceName is the service name resource attribute of the exported spans.
+	ServiceName string
+}
+
+// initTracing installs the global tracer provider exporting spans as configured and the W3C trace context
+// propagator, so incoming requests continue the caller's trace. It returns a function flushing pending spans
+// and stopping the exporter.
+func initTracing(ctx context.Context, conf TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if conf.Exporter == "" {
+		return func(context.Context) error { return nil }, nil
+	} else if conf.SampleRatio < 0 || conf.SampleRatio > 1 {
+		return nil, errors.New("invalid tracing sample ratio, expected between 0 and 1")
+	}
+
+	endpoint, err := url.Parse(conf.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, errors.New("invalid tracing endpoint, expected collector url", z.Str("endpoint", conf.Endpoint))
+	}
+
+	var exporter sdktrace.SpanExporter
+	switch conf.Exporter {
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint.Host)}
+		if endpoint.Scheme == "http" {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if endpoint.Path != "" && endpoint.Path != "/" {
+			opts = append(opts, otlptracehttp.WithURLPath(endpoint.Path))
+		}
+
+		exporter, err = otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, errors.Wrap(err, "create otlp exporter")
+		}
+	case ExporterJaeger:
+		exporter, err = jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(conf.Endpoint)))
+		if err != nil {
+			return nil, errors.Wrap(err, "create jaeger exporter")
+		}
+	default:
+		return nil, errors.New("unknown tracing exporter", z.Str("exporter", conf.Exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(conf.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	log.Info(ctx, "Exporting traces", z.Str("exporter", conf.Exporter), z.Str("endpoint", endpoint.Host),
+		z.Any("sample_ratio", conf.SampleRatio))
+
+	return provider.Shutdown, nil
+}
+
+// chainMonitors returns a mongo command monitor calling each of the monitors in order.
+func chainMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				if m.Started != nil {
+					m.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				if m.Failed != nil {
+					m.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
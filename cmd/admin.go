@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/corverroos/dvstore/app"
+	"github.com/corverroos/dvstore/service"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// adminPrincipal is the principal of deletions made via the admin commands, recorded in the trash.
+const adminPrincipal = "admin-cli"
+
+func newAdminCmd() *cobra.Command {
+	var (
+		conf    app.Config
+		defConf service.DefinitionConfig
+	)
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Inspect and repair stored data directly in mongo",
+		Long: "Operates on the definitions stored in the mongo database directly, bypassing the API and its access " +
+			"control, so operators can inspect and repair data without crafting HTTP requests.",
+	}
+
+	cmd.PersistentFlags().StringVar(&conf.MongoURL, "mongo-url", "mongodb://localhost:27017", "Mongo connection string URL")
+	cmd.PersistentFlags().DurationVar(&defConf.TrashRetention, "trash-retention", 7*24*time.Hour, "Deleted definitions are retained in the trash for restoring if non-zero, matching the server's --trash-retention. Deleted permanently if zero")
+
+	// run returns a cobra run function calling fn with the definition service of the mongo storage.
+	run := func(fn func(ctx context.Context, out io.Writer, defs service.Definition, args []string) error) func(*cobra.Command, []string) error {
+		return func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			storage, err := app.NewMongoStorage(ctx, conf)
+			if err != nil {
+				return err
+			}
+			defer storage.Close(ctx)
+
+			return fn(ctx, cmd.OutOrStdout(), storage.Definition(defConf), args)
+		}
+	}
+
+	var (
+		limit int
+		out   string
+	)
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List the config hashes, statuses and names of published definitions",
+		Args:  cobra.NoArgs,
+		RunE: run(func(ctx context.Context, w io.Writer, defs service.Definition, _ []string) error {
+			return runAdminList(ctx, w, defs, limit)
+		}),
+	}
+	list.Flags().IntVar(&limit, "limit", 100, "Maximum number of definitions listed")
+
+	get := &cobra.Command{
+		Use:   "get <config_hash>",
+		Short: "Print the full lifecycle of a cluster as json",
+		Args:  cobra.ExactArgs(1),
+		RunE:  run(runAdminGet),
+	}
+
+	del := &cobra.Command{
+		Use:   "delete <config_hash>",
+		Short: "Delete a definition, retained in the trash unless --trash-retention is zero",
+		Args:  cobra.ExactArgs(1),
+		RunE:  run(runAdminDelete),
+	}
+
+	export := &cobra.Command{
+		Use:   "export <config_hash>",
+		Short: "Export a cluster as an unsigned portable bundle",
+		Args:  cobra.ExactArgs(1),
+		RunE: run(func(ctx context.Context, w io.Writer, defs service.Definition, args []string) error {
+			return runAdminExport(ctx, w, defs, args[0], out)
+		}),
+	}
+	export.Flags().StringVar(&out, "out", "", "File the bundle is written to. Written to stdout if empty")
+
+	imp := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import a cluster from an exported bundle file, or from stdin if -",
+		Args:  cobra.ExactArgs(1),
+		RunE:  run(runAdminImport),
+	}
+
+	cmd.AddCommand(list, get, del, export, imp)
+
+	return cmd
+}
+
+func runAdminList(ctx context.Context, w io.Writer, defs service.Definition, limit int) error {
+	var cursor string
+	for listed := 0; listed < limit; {
+		page, err := defs.List(ctx, service.ListFilter{}, cursor, limit-listed)
+		if err != nil {
+			return err
+		}
+
+		for _, def := range page.Definitions {
+			state, err := defs.State(ctx, def.ConfigHash)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(w, "%#x\t%s\t%s\n", def.ConfigHash, state.Status, def.Name)
+		}
+
+		listed += len(page.Definitions)
+		cursor = page.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+
+	return nil
+}
+
+func runAdminGet(ctx context.Context, w io.Writer, defs service.Definition, args []string) error {
+	hash, err := parseConfigHash(args[0])
+	if err != nil {
+		return err
+	}
+
+	resp, err := defs.Cluster(ctx, hash)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, resp)
+}
+
+func runAdminDelete(ctx context.Context, w io.Writer, defs service.Definition, args []string) error {
+	hash, err := parseConfigHash(args[0])
+	if err != nil {
+		return err
+	}
+
+	// Administrators delete without request signatures.
+	if _, err := defs.Delete(service.WithAdmin(ctx), hash, adminPrincipal, "", service.RequestAuth{}); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(w, "Deleted %#x\n", hash)
+
+	return nil
+}
+
+func runAdminExport(ctx context.Context, w io.Writer, defs service.Definition, configHash string, out string) error {
+	hash, err := parseConfigHash(configHash)
+	if err != nil {
+		return err
+	}
+
+	bundle, err := defs.Export(ctx, hash)
+	if err != nil {
+		return err
+	}
+
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return errors.Wrap(err, "create bundle file")
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return writeJSON(w, bundle)
+}
+
+func runAdminImport(ctx context.Context, w io.Writer, defs service.Definition, args []string) error {
+	var (
+		b   []byte
+		err error
+	)
+	if args[0] == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return errors.Wrap(err, "read bundle")
+	}
+
+	var bundle service.Bundle
+	if err := json.Unmarshal(b, &bundle); err != nil {
+		return errors.Wrap(err, "unmarshal bundle")
+	}
+
+	if err := defs.Import(ctx, bundle); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(w, "Imported bundle")
+
+	return nil
+}
+
+// parseConfigHash returns the config hash of the 0x-hex argument.
+func parseConfigHash(arg string) ([]byte, error) {
+	hash, err := hex.DecodeString(strings.TrimPrefix(arg, "0x"))
+	if err != nil || len(hash) != 32 {
+		return nil, errors.New("invalid config hash, expected 32 byte hex")
+	}
+
+	return hash, nil
+}
+
+// writeJSON writes the value as indented json.
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(v); err != nil {
+		return errors.Wrap(err, "write json")
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/corverroos/dvstore/app"
+	"github.com/corverroos/dvstore/service"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+func newExportCmd() *cobra.Command {
+	var (
+		conf   app.Config
+		output string
+		tenant string
+	)
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export all published clusters to a portable archive",
+		Long: "Writes all published definitions, locks, change logs and audit entries to a gzipped tar archive of " +
+			"json files, for backups and migrating instances. The archive is restored via the import command.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExport(service.WithTenant(cmd.Context(), tenant), cmd.OutOrStdout(), conf, output)
+		},
+	}
+
+	cmd.Flags().StringVar(&conf.MongoURL, "mongo-url", "mongodb://localhost:27017", "Mongo connection string URL")
+	cmd.Flags().StringVar(&output, "output", "", "File the archive is written to, e.g. clusters.tar.gz. Written to stdout if empty")
+	cmd.Flags().StringVar(&tenant, "tenant", "", "Tenant whose clusters are exported. The default tenant if empty")
+
+	return cmd
+}
+
+func newImportCmd() *cobra.Command {
+	var (
+		conf   app.Config
+		tenant string
+	)
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import the clusters of an exported archive file, or from stdin if -",
+		Long: "Restores the clusters and their audit entries of an archive written by the export command. " +
+			"Clusters that already exist are skipped.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(service.WithTenant(cmd.Context(), tenant), cmd.OutOrStdout(), conf, args[0])
+		},
+	}
+
+	cmd.Flags().StringVar(&conf.MongoURL, "mongo-url", "mongodb://localhost:27017", "Mongo connection string URL")
+	cmd.Flags().StringVar(&tenant, "tenant", "", "Tenant the clusters are imported into. The default tenant if empty")
+
+	return cmd
+}
+
+func runExport(ctx context.Context, out io.Writer, conf app.Config, output string) error {
+	storage, err := app.NewMongoStorage(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer storage.Close(ctx)
+
+	w := out
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return errors.Wrap(err, "create archive file")
+		}
+		defer f.Close()
+		w = f
+	}
+
+	count, err := service.WriteArchive(ctx, w, storage.Definition(service.DefinitionConfig{}), storage.Audit())
+	if err != nil {
+		return err
+	}
+
+	if output != "" {
+		_, _ = fmt.Fprintf(out, "Exported %d clusters to %s\n", count, output)
+	}
+
+	return nil
+}
+
+func runImport(ctx context.Context, out io.Writer, conf app.Config, input string) error {
+	storage, err := app.NewMongoStorage(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer storage.Close(ctx)
+
+	r := io.Reader(os.Stdin)
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return errors.Wrap(err, "open archive file")
+		}
+		defer f.Close()
+		r = f
+	}
+
+	report, err := service.ReadArchive(ctx, r, storage.Definition(service.DefinitionConfig{}), storage.Audit())
+	if err != nil {
+		return err
+	}
+
+	for _, failure := range report.Failed {
+		_, _ = fmt.Fprintf(out, "Failed %s: %s\n", failure.ConfigHash, failure.Error)
+	}
+	_, _ = fmt.Fprintf(out, "Imported %d clusters, skipped %d existing, %d failed\n", report.Imported, report.Skipped, len(report.Failed))
+
+	if len(report.Failed) > 0 {
+		return errors.New("failed importing clusters")
+	}
+
+	return nil
+}
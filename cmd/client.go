@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"github.com/corverroos/dvstore/service"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"github.com/spf13/cobra"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// clientConfig is the configuration of the client commands interacting with a remote dvstore.
+type clientConfig struct {
+	APIAddress string
+	Token      string
+	Timeout    time.Duration
+	// KeyFile is the file of the hex secp256k1 private key signing mutation requests.
+	KeyFile string
+}
+
+// url returns the versioned API url of the path.
+func (c clientConfig) url(path string) string {
+	return strings.TrimSuffix(c.APIAddress, "/") + "/v1" + path
+}
+
+func newClientCmd() *cobra.Command {
+	var conf clientConfig
+	cmd := &cobra.Command{
+		Use:   "client",
+		Short: "Interact with a remote dvstore via its API",
+		Long: "Drives the cluster ceremony against a remote dvstore via its API, reading and writing charon " +
+			"compatible cluster-definition.json files, so node operators can script it without crafting HTTP requests.",
+	}
+
+	cmd.PersistentFlags().StringVar(&conf.APIAddress, "api-address", "http://localhost:8080", "URL of the remote dvstore")
+	cmd.PersistentFlags().StringVar(&conf.Token, "token", "", "API key or bearer token, required if the remote enforces access control")
+	cmd.PersistentFlags().DurationVar(&conf.Timeout, "timeout", 30*time.Second, "Timeout of each request")
+	cmd.PersistentFlags().StringVar(&conf.KeyFile, "key-file", "", "File of the hex secp256k1 private key of the creator or operator signing requests, required by add-operator and delete")
+
+	// run returns a cobra run function calling fn with an http client of the configured timeout.
+	run := func(fn func(ctx context.Context, w io.Writer, client *http.Client, args []string) error) func(*cobra.Command, []string) error {
+		return func(cmd *cobra.Command, args []string) error {
+			return fn(cmd.Context(), cmd.OutOrStdout(), &http.Client{Timeout: conf.Timeout}, args)
+		}
+	}
+
+	var (
+		defFile     string
+		termsHash   string
+		out         string
+		address     string
+		inviteToken string
+	)
+
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Create a definition from a signed cluster-definition.json file",
+		Args:  cobra.NoArgs,
+		RunE: run(func(ctx context.Context, w io.Writer, client *http.Client, _ []string) error {
+			return runClientCreate(ctx, w, client, conf, defFile, termsHash)
+		}),
+	}
+	create.Flags().StringVar(&defFile, "definition-file", "cluster-definition.json", "Signed cluster definition file created by the creator")
+	create.Flags().StringVar(&termsHash, "terms-hash", "", "0x-hex terms and conditions hash accepted by the creator, required if the remote enforces it")
+
+	get := &cobra.Command{
+		Use:   "get <config_hash>",
+		Short: "Write the definition as a cluster-definition.json file",
+		Args:  cobra.ExactArgs(1),
+		RunE: run(func(ctx context.Context, w io.Writer, client *http.Client, args []string) error {
+			return runClientGet(ctx, w, client, conf, args[0], out)
+		}),
+	}
+	get.Flags().StringVar(&out, "out", "", "File the definition is written to. Written to stdout if empty")
+
+	addOperator := &cobra.Command{
+		Use:   "add-operator <config_hash>",
+		Short: "Join the definition with the operator's ENR and signatures from a cluster-definition.json file",
+		Args:  cobra.ExactArgs(1),
+		RunE: run(func(ctx context.Context, w io.Writer, client *http.Client, args []string) error {
+			return runClientAddOperator(ctx, w, client, conf, args[0], defFile, address, inviteToken)
+		}),
+	}
+	addOperator.Flags().StringVar(&defFile, "definition-file", "cluster-definition.json", "Cluster definition file containing the operator's ENR and signatures")
+	addOperator.Flags().StringVar(&address, "address", "", "Address of the joining operator. The address of the key file if empty")
+	addOperator.Flags().StringVar(&inviteToken, "invite-token", "", "Invite token of the operator, required if the definition was created with invite tokens")
+
+	status := &cobra.Command{
+		Use:   "status <config_hash>",
+		Short: "Print the DKG ceremony state of the definition as json",
+		Args:  cobra.ExactArgs(1),
+		RunE: run(func(ctx context.Context, w io.Writer, client *http.Client, args []string) error {
+			return runClientStatus(ctx, w, client, conf, args[0])
+		}),
+	}
+
+	del := &cobra.Command{
+		Use:   "delete <config_hash>",
+		Short: "Delete the definition, signed by the key file's address",
+		Args:  cobra.ExactArgs(1),
+		RunE: run(func(ctx context.Context, w io.Writer, client *http.Client, args []string) error {
+			return runClientDelete(ctx, w, client, conf, args[0])
+		}),
+	}
+
+	cmd.AddCommand(create, get, addOperator, status, del)
+
+	return cmd
+}
+
+func runClientCreate(ctx context.Context, w io.Writer, client *http.Client, conf clientConfig, defFile string, termsHash string) error {
+	def, err := readDefinitionFile(defFile)
+	if err != nil {
+		return err
+	}
+
+	// The definition is sent as is, adding the accepted terms hash if provided.
+	b, err := json.Marshal(def)
+	if err != nil {
+		return errors.Wrap(err, "marshal definition")
+	}
+
+	req := make(map[string]interface{})
+	if err := json.Unmarshal(b, &req); err != nil {
+		return errors.Wrap(err, "unmarshal definition")
+	}
+	if termsHash != "" {
+		req["terms_and_conditions_hash"] = termsHash
+	}
+
+	var created service.Created
+	if err := doJSON(ctx, client, http.MethodPost, conf.url("/dv"), conf.Token, req, &created); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(w, "Created %#x\n", def.ConfigHash)
+	for i, token := range created.InviteTokens {
+		_, _ = fmt.Fprintf(w, "Invite token of %s: %s\n", def.Operators[i].Address, token)
+	}
+
+	return nil
+}
+
+func runClientGet(ctx context.Context, w io.Writer, client *http.Client, conf clientConfig, configHash string, out string) error {
+	hash, err := parseConfigHash(configHash)
+	if err != nil {
+		return err
+	}
+
+	var def cluster.Definition
+	if err := doJSON(ctx, client, http.MethodGet, conf.url(fmt.Sprintf("/dv/%#x", hash)), conf.Token, nil, &def); err != nil {
+		return err
+	}
+
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return errors.Wrap(err, "create definition file")
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return writeJSON(w, def)
+}
+
+func runClientAddOperator(ctx context.Context, w io.Writer, client *http.Client, conf clientConfig, configHash string, defFile string, address string, inviteToken string) error {
+	hash, err := parseConfigHash(configHash)
+	if err != nil {
+		return err
+	}
+
+	key, err := readClientKey(conf.KeyFile)
+	if err != nil {
+		return err
+	} else if key == nil {
+		return errors.New("--key-file required to sign the request")
+	} else if address == "" {
+		address = crypto.PubkeyToAddress(key.PublicKey).Hex()
+	}
+
+	def, err := readDefinitionFile(defFile)
+	if err != nil {
+		return err
+	}
+
+	var operator *cluster.Operator
+	for i := range def.Operators {
+		if strings.EqualFold(def.Operators[i].Address, address) {
+			operator = &def.Operators[i]
+		}
+	}
+	if operator == nil {
+		return errors.New("operator not in definition file", z.Str("address", address))
+	} else if operator.ENR == "" {
+		return errors.New("operator hasn't signed the definition file", z.Str("address", address))
+	}
+
+	req := map[string]interface{}{
+		"address":          operator.Address,
+		"enr":              operator.ENR,
+		"config_signature": fmt.Sprintf("%#x", operator.ConfigSignature),
+		"enr_signature":    fmt.Sprintf("%#x", operator.ENRSignature),
+		"fork_version":     fmt.Sprintf("%#x", def.ForkVersion),
+		"version":          def.Version,
+		"invite_token":     inviteToken,
+	}
+
+	// The request signature binds the config hash of the stored definition.
+	var stored cluster.Definition
+	if err := doJSON(ctx, client, http.MethodGet, conf.url(fmt.Sprintf("/dv/%#x", hash)), conf.Token, nil, &stored); err != nil {
+		return err
+	}
+
+	auth, err := service.SignAddOperator(key, stored, *operator, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	req["request_timestamp"] = fmt.Sprint(auth.Timestamp)
+	req["request_signature"] = fmt.Sprintf("%#x", auth.Signature)
+
+	if err := doJSON(ctx, client, http.MethodPut, conf.url(fmt.Sprintf("/dv/%#x", hash)), conf.Token, req, nil); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(w, "Added operator %s to %#x\n", operator.Address, hash)
+
+	return nil
+}
+
+func runClientStatus(ctx context.Context, w io.Writer, client *http.Client, conf clientConfig, configHash string) error {
+	hash, err := parseConfigHash(configHash)
+	if err != nil {
+		return err
+	}
+
+	var state service.State
+	if err := doJSON(ctx, client, http.MethodGet, conf.url(fmt.Sprintf("/dv/%#x/state", hash)), conf.Token, nil, &state); err != nil {
+		return err
+	}
+
+	return writeJSON(w, state)
+}
+
+func runClientDelete(ctx context.Context, w io.Writer, client *http.Client, conf clientConfig, configHash string) error {
+	hash, err := parseConfigHash(configHash)
+	if err != nil {
+		return err
+	}
+
+	key, err := readClientKey(conf.KeyFile)
+	if err != nil {
+		return err
+	} else if key == nil {
+		return errors.New("--key-file required to sign the request")
+	}
+
+	// The request signature binds the definition's current revision.
+	var (
+		def   cluster.Definition
+		state service.State
+	)
+	if err := doJSON(ctx, client, http.MethodGet, conf.url(fmt.Sprintf("/dv/%#x", hash)), conf.Token, nil, &def); err != nil {
+		return err
+	} else if err := doJSON(ctx, client, http.MethodGet, conf.url(fmt.Sprintf("/dv/%#x/state", hash)), conf.Token, nil, &state); err != nil {
+		return err
+	}
+
+	auth, err := service.SignDelete(key, def, state.Revision, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	req := map[string]interface{}{
+		"address":           crypto.PubkeyToAddress(key.PublicKey).Hex(),
+		"request_timestamp": fmt.Sprint(auth.Timestamp),
+		"request_signature": fmt.Sprintf("%#x", auth.Signature),
+	}
+
+	var deletion service.Deletion
+	if err := doJSON(ctx, client, http.MethodDelete, conf.url(fmt.Sprintf("/dv/%#x", hash)), conf.Token, req, &deletion); err != nil {
+		return err
+	} else if deletion.Pending {
+		_, _ = fmt.Fprintf(w, "Deletion of %#x awaits approval\n", hash)
+		return nil
+	}
+	_, _ = fmt.Fprintf(w, "Deleted %#x\n", hash)
+
+	return nil
+}
+
+// readDefinitionFile returns the charon cluster definition of the json file.
+func readDefinitionFile(file string) (cluster.Definition, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return cluster.Definition{}, errors.Wrap(err, "read definition file")
+	}
+
+	var def cluster.Definition
+	if err := json.Unmarshal(b, &def); err != nil {
+		return cluster.Definition{}, errors.Wrap(err, "unmarshal definition file")
+	}
+
+	return def, nil
+}
+
+// readClientKey returns the secp256k1 private key of the hex key file or nil if the file is empty.
+func readClientKey(file string) (*ecdsa.PrivateKey, error) {
+	if file == "" {
+		return nil, nil
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "read key file")
+	}
+
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(string(b)), "0x"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid hex secp256k1 key file")
+	}
+
+	return key, nil
+}
@@ -4,6 +4,11 @@ import (
 	"context"
 	"fmt"
 	"github.com/corverroos/dvstore/app"
+	"github.com/corverroos/dvstore/ipfs"
+	"github.com/corverroos/dvstore/notify"
+	"github.com/corverroos/dvstore/objstore"
+	"github.com/corverroos/dvstore/router"
+	"github.com/corverroos/dvstore/service"
 	"github.com/obolnetwork/charon/app/errors"
 	"github.com/obolnetwork/charon/app/log"
 	"github.com/obolnetwork/charon/app/z"
@@ -12,6 +17,7 @@ import (
 	"github.com/spf13/viper"
 	"net/url"
 	"strings"
+	"time"
 )
 
 const (
@@ -29,12 +35,16 @@ func New() *cobra.Command {
 }
 
 func newRootCmd() *cobra.Command {
-	var conf app.Config
+	var (
+		conf app.Config
+		src  *configSource
+	)
 	root := &cobra.Command{
 		Use:   "dvstore",
 		Short: "DVStore - API for storing and retrieving DV cluster data",
-		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			return initializeConfig(cmd)
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) (err error) {
+			src, err = initializeConfig(cmd)
+			return err
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := log.InitLogger(conf.Log); err != nil {
@@ -43,20 +53,224 @@ func newRootCmd() *cobra.Command {
 
 			printFlags(cmd.Context(), cmd.Flags())
 
+			reloads := make(chan app.ReloadConfig)
+			conf.Reloads = reloads
+			go watchConfig(cmd.Context(), src, reloads)
+
 			return app.Run(cmd.Context(), conf)
 		},
 	}
 
-	bindRunFlags(root.Flags(), &conf)
-	bindLogFlags(root.Flags(), &conf.Log)
+	bindRootFlags(root.Flags(), &conf)
+
+	root.AddCommand(newReplayCmd(), newSmokeCmd(), newAdminCmd(), newMigrateCmd(), newExportCmd(), newImportCmd(), newClientCmd())
 
 	titledHelp(root)
 
 	return root
 }
 
+// bindRootFlags binds the flags of the root command to the config.
+func bindRootFlags(flags *pflag.FlagSet, conf *app.Config) {
+	bindRunFlags(flags, conf)
+	bindLogFlags(flags, &conf.Log)
+	bindNotifyFlags(flags, &conf.Notify)
+	bindIPFSFlags(flags, &conf.IPFS)
+	bindObjectStorageFlags(flags, &conf.ObjectStorage)
+	bindAbuseFlags(flags, &conf.Abuse)
+	bindCORSFlags(flags, &conf.CORS)
+	bindAuthFlags(flags, conf)
+	bindJSONLimitFlags(flags, &conf.JSONLimits)
+	bindRateLimitFlags(flags, &conf.RateLimit)
+	bindSchedulerFlags(flags, &conf.Scheduler)
+	bindAlarmFlags(flags, &conf.Alarms)
+	bindTracingFlags(flags, &conf.Tracing)
+}
+
 func bindRunFlags(flags *pflag.FlagSet, config *app.Config) {
 	flags.StringVar(&config.HTTPAddress, "http-address", "localhost:8080", "HTTP server address")
+	flags.DurationVar(&config.DrainTimeout, "drain-timeout", 30*time.Second, "Maximum duration in-flight requests are waited for on shutdown before being aborted. The database is closed after draining")
+	flags.StringVar(&config.MetricsPushAddress, "metrics-push-address", "", "Address to push metrics to, a Prometheus Pushgateway address or an OTLP/HTTP collector url depending on --metrics-push-protocol. Metrics are not pushed if empty")
+	flags.StringVar(&config.MetricsPushProtocol, "metrics-push-protocol", app.PushPushgateway, "Protocol metrics are pushed with: pushgateway or otlp")
+	flags.DurationVar(&config.MetricsPushInterval, "metrics-push-interval", 15*time.Second, "Interval at which metrics are pushed, must be positive")
+	flags.StringVar(&config.DebugAddress, "debug-address", "", "Address serving pprof profiles at /debug/pprof/ and runtime info at /debug/runtime, shared with the monitoring server if the same address. Not served if empty, do not expose publicly")
+	flags.StringVar(&config.MonitoringAddress, "monitoring-address", "", "Address serving prometheus metrics, including trace exemplars, at /metrics in OpenMetrics format. Metrics are not served if empty")
+	flags.StringVar(&config.TermsHash, "terms-hash", "", "Required 0x-hex hash of the terms and conditions that definition creators must accept. Not enforced if empty")
+	flags.DurationVar(&config.DraftExpiry, "draft-expiry", 0, "Age after which draft definitions expire based on their timestamp. Definitions do not expire if zero")
+	flags.DurationVar(&config.DefinitionTTL, "definition-ttl", 0, "Age since creation after which unpublished and draft definitions that never became ready are deleted by a leader-only background job, retained in the trash if enabled. Definitions are not deleted if zero")
+	flags.BoolVar(&config.BlockExpired, "block-expired", false, "Reject operators accepting expired draft definitions")
+	flags.BoolVar(&config.DeleteApproval, "delete-approval", false, "Require deletion of finalized definitions to be requested and then approved by another principal or confirmed after the cooling-off period")
+	flags.DurationVar(&config.DeleteCoolOff, "delete-cool-off", 24*time.Hour, "Period after which the requester may confirm its own deletion request of a finalized definition")
+	flags.DurationVar(&config.TrashRetention, "trash-retention", 7*24*time.Hour, "Period deleted definitions are retained for restoring before being purged. Deleted permanently if zero")
+	flags.DurationVar(&config.RegistrationExpiry, "registration-expiry", 0, "Age after which builder registrations expire based on their timestamp. Registrations do not expire if zero")
+	flags.DurationVar(&config.RequestWindow, "request-window", 0, "Maximum age of signed request timestamps, limiting how long captured requests remain valid. Requests are always signed, timestamps aren't bounded by age if zero")
+	flags.BoolVar(&config.RequireInvites, "require-invites", false, "Reject operators joining definitions without invite tokens, minted on creation or via POST /dv/{config_hash}/invites")
+	flags.DurationVar(&config.InviteTTL, "invite-ttl", 7*24*time.Hour, "Duration after which invite tokens minted via POST /dv/{config_hash}/invites expire. Tokens never expire if zero")
+	flags.BoolVar(&config.RejectIncompatible, "reject-incompatible-version", false, "Reject operators joining with an incompatible definition version instead of logging a warning")
+	flags.BoolVar(&config.AutoFinalize, "auto-finalize", false, "Finalize draft definitions when the last operator joins instead of requiring an explicit finalize request")
+	flags.BoolVar(&config.RequireCreator, "require-creator", false, "Reject publishing definitions without a creator address and config signature")
+	flags.BoolVar(&config.BFTThreshold, "bft-threshold", false, "Reject definitions whose threshold isn't the byzantine fault tolerant threshold of the operator count")
+	flags.StringSliceVar(&config.Networks, "networks", nil, "Comma separated networks whose definitions are accepted, e.g. mainnet or goerli,sepolia, rejecting definitions and operators of other networks. All known networks are accepted if empty")
+	flags.Float64Var(&config.MinThresholdRatio, "min-threshold-ratio", 0, "Reject definitions whose threshold is below this ratio of the operator count. Not enforced if zero")
+	flags.DurationVar(&config.SlowRequest, "slow-request-threshold", time.Second, "Duration after which requests are logged as slow. Slow requests are not logged if zero")
+	flags.DurationVar(&config.RequestTimeout, "request-timeout", 0, "Maximum duration of handling requests after which their database operations are cancelled and 503 is returned. Requests are not timed out if zero")
+	flags.StringSliceVar(&config.EndpointTimeouts, "endpoint-timeouts", nil, "Comma separated request timeout overrides by endpoint as endpoint:duration, e.g. get_definitions:1m,export_definition:0s. Zero disables the timeout of the endpoint")
+	flags.BoolVar(&config.Lenient, "lenient-decoding", false, "Accept legacy camelCase json field names emitted by older tools and launchpad exports, normalizing them to snake_case")
+	flags.BoolVar(&config.ReadOnly, "read-only", false, "Disable all write endpoints and prefer reading from mongo secondaries, for horizontally scaled read replicas")
+	flags.BoolVar(&config.UI, "ui", false, "Serve the embedded web UI at /ui for browsing stored clusters, reading them via the API with the access control of the API key entered by the user")
+	flags.BoolVar(&config.SwaggerUI, "swagger-ui", false, "Serve the Swagger UI rendering the OpenAPI document at /api/docs. The page loads its assets from the jsDelivr CDN")
+	flags.BoolVar(&config.RequireIfMatch, "require-if-match", false, "Require the If-Match header with the definition's ETag when adding operators or deleting definitions, rejecting writes based on stale reads")
+	flags.BoolVar(&config.Debug.Enabled, "debug", false, "Include the wrapped error chain and stack trace in all error responses. Exposes internals, do not enable on public instances")
+	flags.BoolVar(&config.Debug.Header, "debug-header", false, "Include the wrapped error chain and stack trace in error responses of requests with the X-Debug: true header. Exposes internals to any caller, do not enable on public instances")
+	flags.StringVar(&config.LegacySunset, "legacy-sunset", "", "Date (YYYY-MM-DD) after which legacy unversioned routes will be removed, advertised via the Sunset header. Not advertised if empty")
+	flags.BoolVar(&config.ValidateSchemas, "validate-schemas", false, "Validate definition and operator request bodies against the published json schemas, returning path-level validation errors")
+	flags.StringVar(&config.StorageDriver, "storage-driver", string(service.StorageMongo), "Storage backend: mongo, postgres or memory. The postgres and memory drivers support all definition endpoints except search, and neither the admin nor the audit endpoints. The memory driver loses all data on restart")
+	flags.StringVar(&config.MongoURL, "mongo-url", "mongodb://localhost:27017", "Mongo connection string URL")
+	flags.StringVar(&config.PostgresURL, "postgres-url", "postgres://localhost:5432/dvstore?sslmode=disable", "Postgres connection string URL of the postgres storage driver")
+	flags.BoolVar(&config.MigrateOnStart, "migrate-on-start", true, "Apply pending database migrations, creating indexes or the postgres tables, on start. Migrations must be applied via the migrate command if disabled")
+	flags.StringSliceVar(&config.MongoHosts, "mongo-hosts", nil, "Comma separated seed list of mongo replica set members or mongos routers (host:port), overriding the hosts of the mongo url")
+	flags.StringVar(&config.MongoReplicaSet, "mongo-replica-set", "", "Name of the mongo replica set to connect to, overriding the mongo url")
+	flags.BoolVar(&config.MongoRetryWrites, "mongo-retry-writes", true, "Retry mongo writes once on transient errors like primary failover")
+	flags.BoolVar(&config.MongoRetryReads, "mongo-retry-reads", true, "Retry mongo reads once on transient errors like primary failover")
+	flags.Uint64Var(&config.MongoMaxPoolSize, "mongo-max-pool-size", 100, "Maximum number of connections in the mongo connection pool per server")
+	flags.Uint64Var(&config.MongoMinPoolSize, "mongo-min-pool-size", 0, "Minimum number of idle connections kept in the mongo connection pool per server")
+	flags.DurationVar(&config.MongoSelectTimeout, "mongo-server-selection-timeout", 10*time.Second, "Duration mongo operations wait for a suitable server, e.g. a new primary during replica set elections")
+	flags.StringVar(&config.MongoReadConcern, "mongo-read-concern", "", "Mongo read concern level: local, available, majority, linearizable or snapshot. The server default if empty")
+	flags.StringVar(&config.MongoWriteConcern, "mongo-write-concern", "", "Mongo write concern: majority or the number of acknowledging members. The server default if empty")
+	flags.IntVar(&config.MongoRetry.Attempts, "mongo-retry-attempts", 3, "Maximum attempts of mongo operations failing with transient errors like no primary during elections. Not retried if one or less")
+	flags.DurationVar(&config.MongoRetry.Backoff, "mongo-retry-backoff", 250*time.Millisecond, "Delay before the first retry of a transient mongo error, doubling for each subsequent retry")
+	flags.BoolVar(&config.MongoTransactions, "mongo-transactions", false, "Apply definition mutations atomically with their change log events and audit entries in mongo transactions. Requires a replica set or sharded cluster")
+	flags.BoolVar(&config.MongoCompat, "mongo-compat", false, "Enable compatibility with Mongo API databases like Amazon DocumentDB and Azure Cosmos DB by disabling retryable writes")
+	flags.BoolVar(&config.StableAPI, "mongo-stable-api", true, "Pin the mongo Stable API version 1. Disable for servers older than MongoDB 5.0 or Mongo API databases without Stable API support")
+	flags.BoolVar(&config.StableAPIStrict, "mongo-stable-api-strict", false, "Reject mongo commands not included in the Stable API version 1")
+	flags.IntVar(&config.CacheSize, "cache-size", 1000, "Number of final definitions and locks cached in memory. Caching is disabled if zero")
+	flags.BoolVar(&config.CacheSync, "cache-sync", false, "Evict cached definitions and locks mutated by other replicas via a mongo change stream, for horizontally scaled deployments. Requires a replica set")
+	flags.Int64Var(&config.MaxBodyBytes, "max-body-size", 32<<20, "Maximum size in bytes of decoded request bodies, rejecting larger requests with 413. Not enforced if zero")
+	flags.IntVar(&config.CompressValidators, "compress-validators", 0, "Number of validators from which definitions and locks are stored as gzip-compressed json, keeping large clusters well under the BSON document limit. Not compressed if zero")
+	flags.IntVar(&config.ShardValidators, "shard-validators", 0, "Number of validators from which lock validators are stored in chunks in a separate collection, keeping huge clusters under the BSON document limit. Not sharded if zero")
+	flags.StringVar(&config.ExportSigningKey, "export-signing-key", "", "Hex encoded 32 byte ed25519 seed signing exported cluster bundles. Bundles are not signed if empty")
+	flags.StringVar(&config.ResponseSigningKey, "response-signing-key", "", "Hex encoded 32 byte ed25519 seed signing definition and lock response bodies via the X-Dvstore-Response-Signature header, its public key served at /.well-known/dvstore-signing-key. Responses are not signed if empty")
+	flags.StringSliceVar(&config.ImportTrustedKeys, "import-trusted-keys", nil, "Comma separated hex ed25519 public keys of servers whose signed bundles are imported. Any bundle is imported if empty")
+	flags.StringVar(&config.MemoryLimit, "memory-limit", "", "Soft memory limit like 512MiB, setting GOMEMLIMIT and capping the cache size to a quarter of it. Not limited if empty")
+	flags.DurationVar(&config.DBTimeouts.Find, "db-find-timeout", 5*time.Second, "Deadline of individual mongo find operations, distinct from the request deadline. Disabled if zero")
+	flags.DurationVar(&config.DBTimeouts.Insert, "db-insert-timeout", 5*time.Second, "Deadline of individual mongo insert operations. Disabled if zero")
+	flags.BoolVar(&config.CaptureFailures, "capture-failures", false, "Record anonymized failed (4xx and 5xx) requests and their responses for debugging, fetched via the admin captures endpoints")
+	flags.Int64Var(&config.CaptureSize, "capture-size", 16<<20, "Maximum size in bytes of the capped collection of captured requests, evicting the oldest. Not changed if the collection exists")
+	flags.DurationVar(&config.UploadTTL, "upload-ttl", 24*time.Hour, "Period after which abandoned chunked uploads of large definitions and locks are deleted")
+	flags.DurationVar(&config.DBTimeouts.Update, "db-update-timeout", 5*time.Second, "Deadline of individual mongo update and delete operations. Disabled if zero")
+}
+
+func bindNotifyFlags(flags *pflag.FlagSet, config *notify.Config) {
+	flags.StringVar(&config.SlackWebhook, "notify-slack-webhook", "", "Slack incoming webhook URL notified when all operators joined a cluster")
+	flags.StringVar(&config.DiscordWebhook, "notify-discord-webhook", "", "Discord webhook URL notified when all operators joined a cluster")
+	flags.StringVar(&config.SMTPAddress, "notify-smtp-address", "", "SMTP server host:port used to email when all operators joined a cluster. Emails are not sent if empty")
+	flags.StringVar(&config.SMTPUsername, "notify-smtp-username", "", "SMTP server username")
+	flags.StringVar(&config.SMTPPassword, "notify-smtp-password", "", "SMTP server password")
+	flags.StringVar(&config.SMTPFrom, "notify-smtp-from", "", "Sender email address")
+	flags.StringSliceVar(&config.SMTPTo, "notify-smtp-to", nil, "Comma separated recipient email addresses")
+	flags.StringVar(&config.DKGTriggerURL, "notify-dkg-trigger-url", "", "URL of a charon-compatible endpoint the cluster definition is posted to when all operators joined and signed a cluster, triggering its DKG ceremony")
+	flags.IntVar(&config.MaxAttempts, "notify-max-attempts", 5, "Maximum delivery attempts of notifications before they are dead-lettered. Failed deliveries are not retried if less than two")
+	flags.DurationVar(&config.RetryBackoff, "notify-retry-backoff", 30*time.Second, "Delay before the first notification retry, doubling for each subsequent retry")
+	flags.DurationVar(&config.MaxRetryBackoff, "notify-max-retry-backoff", time.Hour, "Maximum delay between notification retries")
+}
+
+func bindIPFSFlags(flags *pflag.FlagSet, config *ipfs.Config) {
+	flags.StringVar(&config.NodeURL, "ipfs-node-url", "", "URL of the IPFS node RPC API, e.g. http://localhost:5001, that completed definitions and stored locks are published and pinned to. Their CIDs are returned in the definition state. Not published if empty")
+	flags.StringVar(&config.PinningServiceURL, "ipfs-pinning-service-url", "", "Endpoint of an IPFS Pinning Service API additionally requested to pin published CIDs. Not used if empty")
+	flags.StringVar(&config.PinningServiceToken, "ipfs-pinning-service-token", "", "Bearer access token of the IPFS pinning service")
+}
+
+func bindObjectStorageFlags(flags *pflag.FlagSet, config *objstore.Config) {
+	flags.StringVar(&config.Bucket, "archive-bucket", "", "S3-compatible object storage bucket that immutable snapshots of completed definitions and stored locks are archived to. Not archived if empty")
+	flags.StringVar(&config.Endpoint, "archive-endpoint", "https://s3.amazonaws.com", "URL of the S3-compatible object storage API, e.g. https://storage.googleapis.com for GCS")
+	flags.StringVar(&config.Region, "archive-region", "us-east-1", "Signing region of the archive bucket, auto for GCS")
+	flags.StringVar(&config.Prefix, "archive-prefix", "", "Key prefix of archived snapshots, e.g. dvstore/")
+	flags.StringVar(&config.AccessKeyID, "archive-access-key-id", "", "Access key ID of the archive bucket, an HMAC key for GCS")
+	flags.StringVar(&config.SecretAccessKey, "archive-secret-access-key", "", "Secret access key of the archive bucket")
+}
+
+func bindAbuseFlags(flags *pflag.FlagSet, config *router.AbuseConfig) {
+	flags.IntVar(&config.MaxErrors, "abuse-max-errors", 0, "Number of client error responses within the abuse window after which a client IP is temporarily banned. Clients are not banned if zero")
+	flags.DurationVar(&config.Window, "abuse-window", time.Minute, "Period over which client error responses are counted")
+	flags.DurationVar(&config.BanDuration, "abuse-ban-duration", 15*time.Minute, "Cooldown period during which banned clients are rejected")
+}
+
+func bindCORSFlags(flags *pflag.FlagSet, config *router.CORSConfig) {
+	flags.StringSliceVar(&config.AllowedOrigins, "cors-allowed-origins", nil, "Comma separated origins allowed to call the API from browsers, e.g. https://launchpad.obol.tech, or * for any origin. CORS is disabled if empty")
+	flags.StringSliceVar(&config.AllowedMethods, "cors-allowed-methods", []string{"GET", "POST", "PUT", "PATCH", "DELETE"}, "Comma separated methods allowed in cross-origin requests")
+	flags.StringSliceVar(&config.AllowedHeaders, "cors-allowed-headers", []string{"Authorization", "Content-Type", "Content-Encoding", "If-Match", "If-None-Match", "If-Modified-Since"}, "Comma separated request headers allowed in cross-origin requests")
+	flags.DurationVar(&config.MaxAge, "cors-max-age", 10*time.Minute, "Duration browsers may cache preflight responses")
+}
+
+func bindAuthFlags(flags *pflag.FlagSet, config *app.Config) {
+	flags.StringVar(&config.Auth.AdminToken, "admin-token", "", "Bearer API key granted the admin role, required by the admin endpoints")
+	flags.StringSliceVar(&config.APIKeys, "api-keys", nil, "Comma separated bearer API keys with their roles as role:key, roles are admin, creator, operator or readonly. Access control is disabled if no API keys or admin token are configured")
+	flags.StringSliceVar(&config.TenantAPIKeys, "tenant-api-keys", nil, "Comma separated bearer API keys bound to tenants as tenant:role:key, scoping their requests to the tenant's definitions. Roles are creator, operator or readonly. Keys without a tenant access the default tenant and any tenant via the /tenants/{tenant}/v1 path prefix. Tenants are not isolated by the memory storage driver")
+	flags.StringSliceVar(&config.ScopedAPIKeys, "scoped-api-keys", nil, "Comma separated bearer API keys with their roles restricted to endpoints and definitions as role:endpoints:config_hashes:key, where endpoints and 0x-hex config hashes are separated by |, e.g. creator:delete_definition|cancel_deletion::key. Empty endpoints or config hashes don't restrict, keys restricted to config hashes may only access endpoints of those definitions")
+	flags.IntVar(&config.CreatorQuota, "creator-quota", 0, "Maximum number of active, i.e. unpublished, draft or ready, definitions per creator address. Creating more is rejected with 429 Too Many Requests until definitions are locked, cancelled or deleted. Not enforced if zero")
+	flags.IntVar(&config.TenantQuota, "tenant-quota", 0, "Maximum number of definitions per tenant, excluding the default tenant. Not enforced if zero")
+	flags.StringVar(&config.Auth.OIDC.IssuerURL, "oidc-issuer-url", "", "OIDC issuer URL used to discover the signing keys of ID tokens authenticating users. OIDC is disabled if empty")
+	flags.StringVar(&config.Auth.OIDC.ClientID, "oidc-client-id", "", "OIDC client ID, the expected audience of ID tokens")
+	flags.StringVar(&config.Auth.OIDC.GroupsClaim, "oidc-groups-claim", "groups", "ID token claim containing the user's groups")
+	flags.StringSliceVar(&config.OIDCGroupRoles, "oidc-group-roles", nil, "Comma separated roles granted to OIDC groups as group:role, users are granted the most privileged role of their groups")
+	flags.StringSliceVar(&config.HMACKeys, "hmac-keys", nil, "Comma separated shared secrets of machine clients signing requests as id:role:secret")
+	flags.DurationVar(&config.Auth.HMACWindow, "hmac-window", 5*time.Minute, "Maximum age of HMAC signed request timestamps")
+	flags.StringVar(&config.Auth.SIWE.Domain, "siwe-domain", "", "Expected domain of Sign-In with Ethereum (EIP-4361) messages authenticating wallets. SIWE is disabled if empty")
+	flags.StringSliceVar(&config.SIWERoles, "siwe-roles", nil, "Comma separated roles granted to SIWE authenticated wallets as address:role")
+	flags.StringVar((*string)(&config.Auth.SIWE.DefaultRole), "siwe-default-role", "", "Role granted to SIWE authenticated wallets not included in --siwe-roles. Other wallets are rejected if empty")
+	flags.DurationVar(&config.Auth.SIWE.MaxAge, "siwe-max-age", 24*time.Hour, "Maximum age of SIWE messages, which are reused for subsequent requests until they expire")
+	flags.StringSliceVar(&config.MTLSRoles, "mtls-roles", nil, "Comma separated roles granted to verified TLS client certificates by subject common name as common_name:role, requires --tls-client-ca-file")
+	flags.StringVar(&config.TLSCertFile, "tls-cert-file", "", "TLS certificate file of the HTTP server. The server doesn't use TLS if empty")
+	flags.StringVar(&config.TLSKeyFile, "tls-key-file", "", "TLS private key file of the HTTP server")
+	flags.StringVar(&config.TLSClientCAFile, "tls-client-ca-file", "", "PEM CA certificates verifying optional TLS client certificates authenticated via --mtls-roles")
+	flags.BoolVar(&config.TLSRequireClientCert, "tls-require-client-cert", false, "Reject TLS clients without a certificate verified by --tls-client-ca-file")
+	flags.StringVar((*string)(&config.Auth.Reads), "auth-reads", string(router.AuthAnonymous), "Whether anonymous GET requests are allowed: anonymous, unfinalized (requiring authentication to read definitions operators are still joining) or required")
+	flags.StringVar((*string)(&config.Auth.Writes), "auth-writes", string(router.AuthAnonymous), "Whether anonymous write requests are allowed: anonymous or required")
+	flags.StringSliceVar(&config.Redactions, "redact", nil, "Comma separated json fields stripped from responses by caller as caller:field, callers are roles or anonymous for unauthenticated requests, e.g. anonymous:enr,anonymous:address,readonly:enr")
+	flags.StringVar(&config.Auth.Share.Secret, "share-secret", "", "HMAC secret signing time-limited share links granting unauthenticated read access to a single definition. Share links are disabled if empty")
+	flags.StringVar(&config.Auth.Share.BaseURL, "share-base-url", "", "Public base URL prefixed to share links, e.g. https://dvstore.example.com. Share links are relative if empty")
+	flags.DurationVar(&config.Auth.Share.TTL, "share-ttl", 72*time.Hour, "Default validity of share links")
+	flags.DurationVar(&config.Auth.Share.MaxTTL, "share-max-ttl", 30*24*time.Hour, "Maximum validity of share links requested by creators. Not enforced if zero")
+	flags.StringVar((*string)(&config.Auth.AnonymousRole), "anonymous-role", string(router.RoleReadOnly), "Role of requests without an API key when access control is enabled. Anonymous requests are rejected if empty")
+}
+
+func bindJSONLimitFlags(flags *pflag.FlagSet, config *router.JSONLimits) {
+	flags.IntVar(&config.MaxDepth, "json-max-depth", 32, "Maximum nesting depth of json request bodies. Not enforced if zero")
+	flags.IntVar(&config.MaxArrayLen, "json-max-array-len", 10000, "Maximum number of elements of any array in json request bodies. Not enforced if zero")
+	flags.IntVar(&config.MaxTokens, "json-max-tokens", 1000000, "Maximum number of tokens of json request bodies. Not enforced if zero")
+}
+
+func bindSchedulerFlags(flags *pflag.FlagSet, config *app.SchedulerConfig) {
+	flags.Float64Var(&config.Jitter, "jobs-jitter", 0.1, "Maximum fraction of each background job's interval randomly added or subtracted, spreading load across instances")
+	flags.BoolVar(&config.LeaderElection, "leader-election", false, "Restrict leader-only background jobs to the instance holding the leader lease. All instances run them if disabled")
+	flags.DurationVar(&config.LeaderLease, "leader-lease", 30*time.Second, "Duration of the scheduler leader lease, renewed every third of it")
+	flags.BoolVar(&config.NotifyRetries, "job-notify-retries", true, "Enable the background job retrying failed notification deliveries")
+	flags.BoolVar(&config.JoinDeadlines, "job-join-deadlines", true, "Enable the leader-only background job cancelling draft definitions whose join deadline passed")
+	flags.BoolVar(&config.Cleanup, "job-cleanup", false, "Enable the leader-only background job deleting expired drafts and checking consistency")
+	flags.DurationVar(&config.CleanupInterval, "job-cleanup-interval", time.Hour, "Interval of the cleanup background job")
+	flags.BoolVar(&config.Alarms, "job-alarms", true, "Enable the leader-only background job evaluating the quota and retention alarms")
+}
+
+func bindAlarmFlags(flags *pflag.FlagSet, config *service.AlarmConfig) {
+	flags.IntVar(&config.OwnerQuota, "owner-quota", 0, "Maximum number of definitions per creator address. Not enforced if zero")
+	flags.Float64Var(&config.QuotaWarnRatio, "alarm-quota-warn-ratio", 0.8, "Ratio of the owner quota above which the owner quota alarm fires")
+	flags.Int64Var(&config.StorageGrowth, "alarm-storage-growth", 0, "Definitions storage growth in bytes per hour above which the storage growth alarm fires. Disabled if zero")
+	flags.DurationVar(&config.ExpiryWarning, "alarm-expiry-warning", 24*time.Hour, "Period before draft expiry in which drafts are considered about to expire")
+	flags.IntVar(&config.ExpiringDrafts, "alarm-expiring-drafts", 0, "Number of drafts about to expire above which the expiring drafts alarm fires. Disabled if zero")
+}
+
+func bindTracingFlags(flags *pflag.FlagSet, config *app.TracingConfig) {
+	flags.StringVar(&config.Exporter, "tracing-exporter", "", "OpenTelemetry span exporter of request and mongo command spans, either otlp or jaeger. Spans are not exported if empty")
+	flags.StringVar(&config.Endpoint, "tracing-endpoint", "", "URL of the collector spans are exported to, e.g. http://localhost:4318 for otlp or http://localhost:14268/api/traces for jaeger")
+	flags.Float64Var(&config.SampleRatio, "tracing-sample-ratio", 1, "Fraction of requests sampled unless the caller's trace context decides, between 0 and 1")
+	flags.StringVar(&config.ServiceName, "tracing-service-name", "dvstore", "Service name of exported spans, distinguishing deployments sharing a collector")
+}
+
+func bindRateLimitFlags(flags *pflag.FlagSet, config *router.RateLimitConfig) {
+	flags.Float64Var(&config.IPRate, "rate-limit-ip", 0, "Default requests per second of each unauthenticated client IP. Not limited if zero")
+	flags.IntVar(&config.IPBurst, "rate-limit-ip-burst", 20, "Default maximum requests at once of each unauthenticated client IP")
+	flags.Float64Var(&config.KeyRate, "rate-limit-key", 0, "Default requests per second of each API key, HMAC key or OIDC user. Not limited if zero")
+	flags.IntVar(&config.KeyBurst, "rate-limit-key-burst", 50, "Default maximum requests at once of each API key, HMAC key or OIDC user")
 }
 
 func bindLogFlags(flags *pflag.FlagSet, config *log.Config) {
@@ -65,7 +279,8 @@ func bindLogFlags(flags *pflag.FlagSet, config *log.Config) {
 }
 
 // initializeConfig sets up the general viper config and binds the cobra flags to the viper flags.
-func initializeConfig(cmd *cobra.Command) error {
+// It returns the config source for reloading the config.
+func initializeConfig(cmd *cobra.Command) (*configSource, error) {
 	v := viper.New()
 
 	v.SetConfigName(defaultConfigFilename)
@@ -74,6 +289,25 @@ func initializeConfig(cmd *cobra.Command) error {
 	// Attempt to read the config file, gracefully ignoring errors
 	// caused by a config file not being found. Return an error
 	// if we cannot parse the config file.
+	if err := readConfig(v); err != nil {
+		return nil, err
+	}
+
+	v.SetEnvPrefix(envPrefix)
+	v.AutomaticEnv()
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+
+	src := &configSource{v: v, flags: cmd.Flags(), cli: make(map[string]bool)}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		src.cli[f.Name] = true
+	})
+
+	// Bind the current command's flags to viper
+	return src, bindFlags(cmd.Flags(), v)
+}
+
+// readConfig reads the config file, ignoring a missing config file.
+func readConfig(v *viper.Viper) error {
 	if err := v.ReadInConfig(); err != nil {
 		// It's okay if there isn't a config file
 		var cfgError viper.ConfigFileNotFoundError
@@ -82,19 +316,14 @@ func initializeConfig(cmd *cobra.Command) error {
 		}
 	}
 
-	v.SetEnvPrefix(envPrefix)
-	v.AutomaticEnv()
-	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
-
-	// Bind the current command's flags to viper
-	return bindFlags(cmd, v)
+	return nil
 }
 
 // bindFlags binds each cobra flag to its associated viper configuration (config file and environment variable).
-func bindFlags(cmd *cobra.Command, v *viper.Viper) error {
+func bindFlags(flags *pflag.FlagSet, v *viper.Viper) error {
 	var lastErr error
 
-	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+	flags.VisitAll(func(f *pflag.Flag) {
 		// Cobra provided flags take priority
 		if f.Changed {
 			return
@@ -112,7 +341,7 @@ func bindFlags(cmd *cobra.Command, v *viper.Viper) error {
 			}
 
 			val := v.Get(name)
-			err := cmd.Flags().Set(f.Name, fmt.Sprintf("%v", val))
+			err := flags.Set(f.Name, fmt.Sprintf("%v", val))
 			if err != nil {
 				lastErr = err
 			}
@@ -163,9 +392,14 @@ func flagsToLogFields(flags *pflag.FlagSet) []z.Field {
 }
 
 // redact returns a redacted version of the given flag value.
-// It currently only supports redacting passwords in valid URLs provided in ".*address.*" flags.
+// It fully redacts non-empty ".*password.*", ".*secret.*", ".*token.*", ".*key.*" and ".*webhook.*" flags since webhook URLs embed secrets,
+// and redacts passwords in valid URLs provided in ".*address.*" and ".*url.*" flags.
 func redact(flag, val string) string {
-	if !strings.Contains(flag, "address") {
+	if val != "" && (strings.Contains(flag, "password") || strings.Contains(flag, "secret") || strings.Contains(flag, "token") || strings.Contains(flag, "key") || strings.Contains(flag, "webhook")) {
+		return "xxxxx"
+	}
+
+	if !strings.Contains(flag, "address") && !strings.Contains(flag, "url") {
 		return val
 	}
 
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"io"
+	"net/http"
+)
+
+// doJSON sends the request with the json encoded body, if not nil, authenticated with the token, if not empty,
+// and decodes the json response into v, if not nil. It returns an error if the response status isn't 2xx.
+func doJSON(ctx context.Context, client *http.Client, method string, endpoint string, token string, body interface{}, v interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "marshal request")
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return errors.Wrap(err, "new request")
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "send request", z.Str("method", method), z.Str("endpoint", endpoint))
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "read response")
+	} else if resp.StatusCode/100 != 2 {
+		return errors.New("request failed", z.Str("method", method), z.Str("endpoint", endpoint),
+			z.Int("status", resp.StatusCode), z.Str("body", string(respBody)))
+	}
+
+	if v == nil || len(respBody) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(respBody, v); err != nil {
+		return errors.Wrap(err, "decode response")
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/corverroos/dvstore/app"
+	"github.com/corverroos/dvstore/service"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/spf13/cobra"
+	"io"
+)
+
+func newMigrateCmd() *cobra.Command {
+	var (
+		conf   app.Config
+		dryRun bool
+	)
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply pending database migrations",
+		Long: "Applies the pending versioned schema migrations, e.g. index creation, to the mongo database in order, " +
+			"recording each once applied, or creates the tables of the postgres storage driver. " +
+			"Servers apply them on start unless --migrate-on-start is disabled.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMigrate(cmd.Context(), cmd.OutOrStdout(), conf, dryRun)
+		},
+	}
+
+	cmd.Flags().StringVar(&conf.StorageDriver, "storage-driver", string(service.StorageMongo), "Storage backend to migrate: mongo or postgres")
+	cmd.Flags().StringVar(&conf.PostgresURL, "postgres-url", "postgres://localhost:5432/dvstore?sslmode=disable", "Postgres connection string URL")
+	cmd.Flags().StringVar(&conf.MongoURL, "mongo-url", "mongodb://localhost:27017", "Mongo connection string URL")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the pending migrations without applying them")
+
+	return cmd
+}
+
+func runMigrate(ctx context.Context, out io.Writer, conf app.Config, dryRun bool) error {
+	if service.StorageDriver(conf.StorageDriver) == service.StoragePostgres {
+		// The postgres tables aren't versioned, they are created if they don't exist.
+		if dryRun {
+			return errors.New("dry run not supported by the postgres storage driver")
+		} else if err := app.MigratePostgres(ctx, conf); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(out, "Created postgres tables")
+
+		return nil
+	}
+
+	migrations, err := app.Migrate(ctx, conf, dryRun)
+	for _, m := range migrations {
+		if dryRun {
+			_, _ = fmt.Fprintf(out, "Pending %d %s\n", m.Version, m.Name)
+		} else {
+			_, _ = fmt.Fprintf(out, "Applied %d %s\n", m.Version, m.Name)
+		}
+	}
+	if err != nil {
+		return err
+	} else if len(migrations) == 0 {
+		_, _ = fmt.Fprintln(out, "No pending migrations")
+	}
+
+	return nil
+}
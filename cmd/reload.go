@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"github.com/corverroos/dvstore/app"
+	"github.com/fsnotify/fsnotify"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// reloadDebounce is the delay after the last config file change before it is reloaded, since editors and
+// config management often write files in several steps.
+const reloadDebounce = 500 * time.Millisecond
+
+// configSource is the source of the root command's config, re-read to reload settings without restarting.
+type configSource struct {
+	mu    sync.Mutex
+	v     *viper.Viper
+	flags *pflag.FlagSet
+	// cli are the names of the flags set on the command line, which take priority over the config file.
+	cli map[string]bool
+}
+
+// load returns the config of the command line flags overlaid on the current config file and environment variables.
+func (s *configSource) load() (app.Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var conf app.Config
+	flags := pflag.NewFlagSet("reload", pflag.ContinueOnError)
+	bindRootFlags(flags, &conf)
+
+	for name := range s.cli {
+		if err := copyFlag(flags, s.flags.Lookup(name)); err != nil {
+			return app.Config{}, err
+		}
+	}
+
+	if err := bindFlags(flags, s.v); err != nil {
+		return app.Config{}, errors.Wrap(err, "bind config")
+	}
+
+	return conf, nil
+}
+
+// read re-reads the config file.
+func (s *configSource) read() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return readConfig(s.v)
+}
+
+// copyFlag sets the flag of the flag set to the value of the flag.
+func copyFlag(flags *pflag.FlagSet, f *pflag.Flag) error {
+	dst := flags.Lookup(f.Name)
+	if dst == nil {
+		return nil // Not a root command flag.
+	}
+
+	// Slice values are formatted as [a,b], so they are copied as slices.
+	if src, ok := f.Value.(pflag.SliceValue); ok {
+		if err := dst.Value.(pflag.SliceValue).Replace(src.GetSlice()); err != nil {
+			return errors.Wrap(err, "copy flag")
+		}
+		dst.Changed = true
+
+		return nil
+	}
+
+	if err := flags.Set(f.Name, f.Value.String()); err != nil {
+		return errors.Wrap(err, "copy flag")
+	}
+
+	return nil
+}
+
+// watchConfig sends the reloadable settings when the config file changes or on SIGHUP,
+// until the context is cancelled. Command line flags retain priority over the config file.
+func watchConfig(ctx context.Context, src *configSource, reloads chan<- app.ReloadConfig) {
+	changed := make(chan struct{}, 1)
+	if src.v.ConfigFileUsed() != "" {
+		src.v.OnConfigChange(func(fsnotify.Event) {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+		src.v.WatchConfig()
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+			debounce = time.After(reloadDebounce)
+			continue
+		case <-hup:
+		case <-debounce:
+			debounce = nil
+		}
+
+		if err := src.read(); err != nil {
+			log.Warn(ctx, "Failed reading config file", err)
+			continue
+		}
+
+		conf, err := src.load()
+		if err != nil {
+			log.Warn(ctx, "Failed loading changed config", err)
+			continue
+		}
+
+		select {
+		case reloads <- conf.Reloadable():
+		case <-ctx.Done():
+			return
+		}
+	}
+}
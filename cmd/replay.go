@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/corverroos/dvstore/service"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type replayConfig struct {
+	SourceURL   string
+	SourceToken string
+	TargetURL   string
+	TargetToken string
+	Capture     string
+	Limit       int
+	Timeout     time.Duration
+}
+
+func newReplayCmd() *cobra.Command {
+	var conf replayConfig
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Replay captured failed requests against a dev instance",
+		Long: "Fetches the anonymized failed requests captured by a dvstore instance running with --capture-failures " +
+			"and replays them against a dev instance, printing the captured and replayed status codes.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReplay(cmd.Context(), cmd.OutOrStdout(), conf)
+		},
+	}
+
+	bindReplayFlags(cmd.Flags(), &conf)
+
+	return cmd
+}
+
+func bindReplayFlags(flags *pflag.FlagSet, config *replayConfig) {
+	flags.StringVar(&config.SourceURL, "source-url", "http://localhost:8080", "URL of the dvstore instance to fetch captured requests from")
+	flags.StringVar(&config.SourceToken, "source-token", "", "Admin API key of the source instance")
+	flags.StringVar(&config.TargetURL, "target-url", "", "URL of the dev dvstore instance to replay captured requests against")
+	flags.StringVar(&config.TargetToken, "target-token", "", "API key added to replayed requests, since captures don't contain credentials. Requests are replayed unauthenticated if empty")
+	flags.StringVar(&config.Capture, "capture", "", "ID of a single capture to replay. The most recent captures are replayed if empty")
+	flags.IntVar(&config.Limit, "limit", 50, "Number of most recent captures to replay")
+	flags.DurationVar(&config.Timeout, "timeout", 10*time.Second, "Timeout of each request")
+}
+
+func runReplay(ctx context.Context, out io.Writer, conf replayConfig) error {
+	conf.SourceURL = strings.TrimSuffix(conf.SourceURL, "/")
+	conf.TargetURL = strings.TrimSuffix(conf.TargetURL, "/")
+
+	if conf.TargetURL == "" {
+		return errors.New("missing target url")
+	} else if conf.TargetURL == conf.SourceURL {
+		return errors.New("target url must differ from source url, replay against a dev instance")
+	}
+
+	client := &http.Client{Timeout: conf.Timeout}
+
+	var captures []service.Capture
+	if conf.Capture != "" {
+		var capture service.Capture
+		endpoint := conf.SourceURL + "/admin/captures/" + conf.Capture
+		if err := doJSON(ctx, client, http.MethodGet, endpoint, conf.SourceToken, nil, &capture); err != nil {
+			return err
+		}
+		captures = append(captures, capture)
+	} else {
+		endpoint := fmt.Sprintf("%s/admin/captures?limit=%d", conf.SourceURL, conf.Limit)
+		if err := doJSON(ctx, client, http.MethodGet, endpoint, conf.SourceToken, nil, &captures); err != nil {
+			return err
+		}
+	}
+
+	// Replay oldest first, the order the requests were originally made.
+	for i := len(captures) - 1; i >= 0; i-- {
+		capture := captures[i]
+
+		statusCode, err := replayCapture(ctx, client, conf, capture)
+		if err != nil {
+			_, _ = fmt.Fprintf(out, "%s %s %s: captured=%d error=%v\n", capture.ID, capture.Method, capture.Path, capture.StatusCode, err)
+			continue
+		}
+
+		result := "reproduced"
+		if statusCode != capture.StatusCode {
+			result = "differs"
+		}
+		_, _ = fmt.Fprintf(out, "%s %s %s: captured=%d replayed=%d %s\n", capture.ID, capture.Method, capture.Path, capture.StatusCode, statusCode, result)
+	}
+
+	return nil
+}
+
+// replayCapture sends the captured request to the target instance and returns the response status code.
+func replayCapture(ctx context.Context, client *http.Client, conf replayConfig, capture service.Capture) (int, error) {
+	if capture.Truncated {
+		return 0, errors.New("captured body truncated")
+	}
+
+	endpoint := conf.TargetURL + capture.Path
+	if capture.Query != "" {
+		endpoint += "?" + capture.Query
+	}
+
+	req, err := http.NewRequestWithContext(ctx, capture.Method, endpoint, bytes.NewReader(capture.Body))
+	if err != nil {
+		return 0, errors.Wrap(err, "new request")
+	}
+
+	for key, values := range capture.Header {
+		req.Header[key] = values
+	}
+	if conf.TargetToken != "" {
+		req.Header.Set("Authorization", "Bearer "+conf.TargetToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "send request")
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	crand "crypto/rand"
+	"encoding/json"
+	"fmt"
+	"github.com/corverroos/dvstore/service"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"github.com/obolnetwork/charon/eth2util"
+	"github.com/obolnetwork/charon/eth2util/enr"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type smokeConfig struct {
+	ServerURL string
+	Token     string
+	Network   string
+	Operators int
+	TermsHash string
+	Timeout   time.Duration
+}
+
+func newSmokeCmd() *cobra.Command {
+	var conf smokeConfig
+	cmd := &cobra.Command{
+		Use:   "smoke",
+		Short: "Run an end-to-end smoke test against a live deployment",
+		Long: "Exercises the definition happy path against a live dvstore deployment on a throwaway definition: " +
+			"create, get, add operators, state and finalize, then deletes it. Exits non-zero on any failure or mismatch.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSmoke(cmd.Context(), cmd.OutOrStdout(), conf)
+		},
+	}
+
+	bindSmokeFlags(cmd.Flags(), &conf)
+
+	return cmd
+}
+
+func bindSmokeFlags(flags *pflag.FlagSet, config *smokeConfig) {
+	flags.StringVar(&config.ServerURL, "server-url", "http://localhost:8080", "URL of the dvstore deployment to test")
+	flags.StringVar(&config.Token, "token", "", "API key of a creator role, required if the deployment enforces access control")
+	flags.StringVar(&config.Network, "network", "goerli", "Network of the throwaway definition")
+	flags.IntVar(&config.Operators, "operators", 4, "Number of operators of the throwaway definition")
+	flags.StringVar(&config.TermsHash, "terms-hash", "", "0x-hex terms and conditions hash accepted by the throwaway definition, required if the deployment enforces it")
+	flags.DurationVar(&config.Timeout, "timeout", 10*time.Second, "Timeout of each request")
+}
+
+// smokeStep is a step of the smoke test.
+type smokeStep struct {
+	Name string
+	Run  func(context.Context) error
+}
+
+func runSmoke(ctx context.Context, out io.Writer, conf smokeConfig) (err error) {
+	conf.ServerURL = strings.TrimSuffix(conf.ServerURL, "/") + "/v1"
+	client := &http.Client{Timeout: conf.Timeout}
+
+	def, keys, err := newSmokeDefinition(conf.Network, conf.Operators)
+	if err != nil {
+		return err
+	}
+
+	hash := fmt.Sprintf("%#x", def.ConfigHash)
+	_, _ = fmt.Fprintf(out, "Smoke testing %s with definition %s\n", conf.ServerURL, hash)
+
+	// Signed request timestamps must increase per address, the creator also joins and deletes within a second.
+	var lastTimestamp int64
+	requestTimestamp := func() int64 {
+		lastTimestamp++
+		if now := time.Now().Unix(); now > lastTimestamp {
+			lastTimestamp = now
+		}
+
+		return lastTimestamp
+	}
+
+	steps := []smokeStep{
+		{Name: "create", Run: func(ctx context.Context) error {
+			body, err := json.Marshal(def)
+			if err != nil {
+				return errors.Wrap(err, "marshal definition")
+			}
+
+			req := make(map[string]interface{})
+			if err := json.Unmarshal(body, &req); err != nil {
+				return errors.Wrap(err, "unmarshal definition")
+			}
+			if conf.TermsHash != "" {
+				req["terms_and_conditions_hash"] = conf.TermsHash
+			}
+
+			return doJSON(ctx, client, http.MethodPost, conf.ServerURL+"/dv", conf.Token, req, nil)
+		}},
+		{Name: "get", Run: func(ctx context.Context) error {
+			var got cluster.Definition
+			if err := doJSON(ctx, client, http.MethodGet, conf.ServerURL+"/dv/"+hash, conf.Token, nil, &got); err != nil {
+				return err
+			} else if fmt.Sprintf("%#x", got.DefinitionHash) != fmt.Sprintf("%#x", def.DefinitionHash) {
+				return errors.New("definition hash mismatch",
+					z.Str("expected", fmt.Sprintf("%#x", def.DefinitionHash)), z.Str("actual", fmt.Sprintf("%#x", got.DefinitionHash)))
+			}
+
+			return nil
+		}},
+		{Name: "add operators", Run: func(ctx context.Context) error {
+			for i, key := range keys {
+				if err := addSmokeOperator(ctx, client, conf, def, key, i, requestTimestamp()); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}},
+		{Name: "draft state", Run: func(ctx context.Context) error {
+			// Deployments may finalize definitions when the last operator joins.
+			return verifySmokeState(ctx, client, conf, hash, service.StatusDraft, service.StatusReady)
+		}},
+		{Name: "finalize", Run: func(ctx context.Context) error {
+			var state service.State
+			if err := doJSON(ctx, client, http.MethodGet, conf.ServerURL+"/dv/"+hash+"/state", conf.Token, nil, &state); err != nil {
+				return err
+			} else if state.Status == service.StatusReady {
+				return nil // Already finalized automatically.
+			}
+
+			return doJSON(ctx, client, http.MethodPost, conf.ServerURL+"/dv/"+hash+"/finalize", conf.Token, nil, nil)
+		}},
+		{Name: "ready state", Run: func(ctx context.Context) error {
+			return verifySmokeState(ctx, client, conf, hash, service.StatusReady)
+		}},
+	}
+
+	defer func() {
+		// Always delete the throwaway definition, even if a step failed.
+		var deletion service.Deletion
+		delErr := deleteSmokeDefinition(ctx, client, conf, def, keys[0], requestTimestamp(), &deletion)
+		if delErr != nil {
+			_, _ = fmt.Fprintf(out, "FAIL cleanup: %v\n", delErr)
+			if err == nil {
+				err = errors.Wrap(delErr, "cleanup")
+			}
+
+			return
+		} else if deletion.Pending {
+			_, _ = fmt.Fprintf(out, "WARN cleanup: deletion of %s awaits approval\n", hash)
+			return
+		}
+		_, _ = fmt.Fprintln(out, "OK   cleanup")
+	}()
+
+	for _, step := range steps {
+		if err := step.Run(ctx); err != nil {
+			_, _ = fmt.Fprintf(out, "FAIL %s: %v\n", step.Name, err)
+			return errors.Wrap(err, "smoke test failed", z.Str("step", step.Name))
+		}
+		_, _ = fmt.Fprintf(out, "OK   %s\n", step.Name)
+	}
+
+	return nil
+}
+
+// newSmokeDefinition returns a new signed single validator definition of the network created by the first of the
+// returned operator keys.
+func newSmokeDefinition(network string, numOperators int) (cluster.Definition, []*ecdsa.PrivateKey, error) {
+	if numOperators < 1 {
+		return cluster.Definition{}, nil, errors.New("invalid number of operators")
+	}
+
+	forkVersion, err := eth2util.NetworkToForkVersion(network)
+	if err != nil {
+		return cluster.Definition{}, nil, err
+	}
+
+	var (
+		keys      []*ecdsa.PrivateKey
+		operators []cluster.Operator
+	)
+	for i := 0; i < numOperators; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return cluster.Definition{}, nil, errors.Wrap(err, "generate operator key")
+		}
+
+		keys = append(keys, key)
+		operators = append(operators, cluster.Operator{Address: crypto.PubkeyToAddress(key.PublicKey).Hex()})
+	}
+
+	creator := cluster.Creator{Address: operators[0].Address}
+	threshold := (2*numOperators + 2) / 3 // Byzantine fault tolerant threshold, ceil(2n/3).
+
+	def, err := cluster.NewDefinition("dvstore smoke test", 1, threshold, creator.Address, creator.Address,
+		forkVersion, creator, operators, crand.Reader)
+	if err != nil {
+		return cluster.Definition{}, nil, errors.Wrap(err, "new definition")
+	}
+
+	def, err = service.SignCreator(keys[0], def)
+	if err != nil {
+		return cluster.Definition{}, nil, err
+	}
+
+	def, err = def.SetDefinitionHashes()
+	if err != nil {
+		return cluster.Definition{}, nil, errors.Wrap(err, "set definition hashes")
+	}
+
+	return def, keys, nil
+}
+
+// addSmokeOperator joins the definition as the operator at the index with a new ENR of its key,
+// signing the request at the timestamp.
+func addSmokeOperator(ctx context.Context, client *http.Client, conf smokeConfig, def cluster.Definition, key *ecdsa.PrivateKey, idx int, timestamp int64) error {
+	record, err := enr.New(key)
+	if err != nil {
+		return errors.Wrap(err, "new enr")
+	}
+
+	operator := def.Operators[idx]
+	operator.ENR = record.String()
+
+	operator, err = service.SignOperator(key, def, operator)
+	if err != nil {
+		return err
+	}
+
+	auth, err := service.SignAddOperator(key, def, operator, timestamp)
+	if err != nil {
+		return err
+	}
+
+	req := map[string]interface{}{
+		"address":           operator.Address,
+		"enr":               operator.ENR,
+		"config_signature":  fmt.Sprintf("%#x", operator.ConfigSignature),
+		"enr_signature":     fmt.Sprintf("%#x", operator.ENRSignature),
+		"fork_version":      fmt.Sprintf("%#x", def.ForkVersion),
+		"version":           def.Version,
+		"request_timestamp": fmt.Sprint(auth.Timestamp),
+		"request_signature": fmt.Sprintf("%#x", auth.Signature),
+	}
+
+	err = doJSON(ctx, client, http.MethodPut, fmt.Sprintf("%s/dv/%#x", conf.ServerURL, def.ConfigHash), conf.Token, req, nil)
+	if err != nil {
+		return errors.Wrap(err, "add operator", z.Str("address", operator.Address))
+	}
+
+	return nil
+}
+
+// deleteSmokeDefinition deletes the definition as its creator key, signing the request at the timestamp,
+// decoding the response into the deletion.
+func deleteSmokeDefinition(ctx context.Context, client *http.Client, conf smokeConfig, def cluster.Definition, key *ecdsa.PrivateKey, timestamp int64, deletion *service.Deletion) error {
+	// The request signs the definition's current revision.
+	var state service.State
+	if err := doJSON(ctx, client, http.MethodGet, fmt.Sprintf("%s/dv/%#x/state", conf.ServerURL, def.ConfigHash), conf.Token, nil, &state); err != nil {
+		return err
+	}
+
+	auth, err := service.SignDelete(key, def, state.Revision, timestamp)
+	if err != nil {
+		return err
+	}
+
+	req := map[string]interface{}{
+		"address":           def.Creator.Address,
+		"request_timestamp": fmt.Sprint(auth.Timestamp),
+		"request_signature": fmt.Sprintf("%#x", auth.Signature),
+	}
+
+	return doJSON(ctx, client, http.MethodDelete, fmt.Sprintf("%s/dv/%#x", conf.ServerURL, def.ConfigHash), conf.Token, req, deletion)
+}
+
+// verifySmokeState returns an error if the definition's state doesn't have any of the expected statuses.
+func verifySmokeState(ctx context.Context, client *http.Client, conf smokeConfig, hash string, expected ...service.Status) error {
+	var state service.State
+	if err := doJSON(ctx, client, http.MethodGet, conf.ServerURL+"/dv/"+hash+"/state", conf.Token, nil, &state); err != nil {
+		return err
+	}
+
+	for _, status := range expected {
+		if state.Status == status {
+			return nil
+		}
+	}
+
+	return errors.New("status mismatch", z.Any("expected", expected), z.Str("actual", string(state.Status)))
+}
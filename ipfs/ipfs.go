@@ -0,0 +1,155 @@
+// Package ipfs publishes cluster definitions and locks to IPFS, giving clusters a censorship-resistant,
+// content-addressed copy of their configuration.
+package ipfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Config defines the IPFS node and optional remote pinning service. Publishing is disabled if not configured.
+type Config struct {
+	// NodeURL is the URL of the IPFS node's RPC API content is added and pinned to, e.g. http://localhost:5001.
+	NodeURL string
+	// PinningServiceURL is the endpoint of an IPFS Pinning Service API requested to pin the published content,
+	// e.g. https://api.pinata.cloud/psa. Content is only pinned by the node if empty.
+	PinningServiceURL string
+	// PinningServiceToken is the bearer access token of the pinning service.
+	PinningServiceToken string
+}
+
+// Publisher publishes content to IPFS.
+type Publisher interface {
+	// Publish adds and pins the named content and returns its CID.
+	Publish(ctx context.Context, name string, data []byte) (string, error)
+}
+
+// New returns a publisher adding content to the configured IPFS node or nil if no node is configured.
+func New(conf Config) (Publisher, error) {
+	if conf.NodeURL == "" {
+		if conf.PinningServiceURL != "" {
+			return nil, errors.New("ipfs pinning service requires an ipfs node")
+		}
+
+		return nil, nil
+	}
+
+	if _, err := url.ParseRequestURI(conf.NodeURL); err != nil {
+		return nil, errors.Wrap(err, "invalid ipfs node url")
+	}
+
+	if conf.PinningServiceURL != "" {
+		if _, err := url.ParseRequestURI(conf.PinningServiceURL); err != nil {
+			return nil, errors.Wrap(err, "invalid ipfs pinning service url")
+		}
+	}
+
+	return publisher{conf: conf}, nil
+}
+
+type publisher struct {
+	conf Config
+}
+
+func (p publisher) Publish(ctx context.Context, name string, data []byte) (string, error) {
+	cid, err := p.add(ctx, name, data)
+	if err != nil {
+		return "", err
+	}
+
+	if p.conf.PinningServiceURL != "" {
+		if err := p.pinRemote(ctx, name, cid); err != nil {
+			return "", err
+		}
+	}
+
+	return cid, nil
+}
+
+// add adds and pins the content to the node via its RPC API and returns its CIDv1.
+func (p publisher) add(ctx context.Context, name string, data []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return "", errors.Wrap(err, "create ipfs form file")
+	} else if _, err := part.Write(data); err != nil {
+		return "", errors.Wrap(err, "write ipfs form file")
+	} else if err := form.Close(); err != nil {
+		return "", errors.Wrap(err, "close ipfs form")
+	}
+
+	endpoint := strings.TrimSuffix(p.conf.NodeURL, "/") + "/api/v0/add?pin=true&cid-version=1"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		return "", errors.Wrap(err, "create ipfs add request")
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	var resp struct {
+		Hash string `json:"Hash"`
+	}
+	if err := do(req, &resp); err != nil {
+		return "", errors.Wrap(err, "ipfs add")
+	} else if resp.Hash == "" {
+		return "", errors.New("ipfs add response without cid")
+	}
+
+	return resp.Hash, nil
+}
+
+// pinRemote requests the pinning service to pin the CID.
+func (p publisher) pinRemote(ctx context.Context, name string, cid string) error {
+	b, err := json.Marshal(map[string]string{"cid": cid, "name": name})
+	if err != nil {
+		return errors.Wrap(err, "marshal pin request")
+	}
+
+	endpoint := strings.TrimSuffix(p.conf.PinningServiceURL, "/") + "/pins"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "create pin request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.conf.PinningServiceToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.conf.PinningServiceToken)
+	}
+
+	if err := do(req, nil); err != nil {
+		return errors.Wrap(err, "ipfs pin", z.Str("cid", cid))
+	}
+
+	return nil
+}
+
+// do sends the request and decodes the json response body into resp if not nil.
+func do(req *http.Request, resp interface{}) error {
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "send request")
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return errors.Wrap(err, "read response")
+	} else if res.StatusCode/100 != 2 {
+		return errors.New("request failed", z.Int("status", res.StatusCode), z.Str("body", string(body)))
+	}
+
+	if resp == nil {
+		return nil
+	} else if err := json.Unmarshal(body, resp); err != nil {
+		return errors.Wrap(err, "unmarshal response")
+	}
+
+	return nil
+}
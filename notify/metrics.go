@@ -0,0 +1,41 @@
+package notify
+
+import (
+	"github.com/obolnetwork/charon/app/promauto"
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+var (
+	deliveryLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "dvstore",
+		Subsystem: "notify",
+		Name:      "delivery_latency_seconds",
+		Help:      "The notification delivery latencies in seconds by sender",
+	}, []string{"sender"})
+
+	deliveryTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dvstore",
+		Subsystem: "notify",
+		Name:      "delivery_total",
+		Help:      "The total number of notification delivery attempts by sender and result",
+	}, []string{"sender", "result"})
+
+	queueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dvstore",
+		Subsystem: "notify",
+		Name:      "queue_depth",
+		Help:      "The number of failed notification deliveries by queue, either pending retry or dead",
+	}, []string{"queue"})
+)
+
+// observeDelivery records the latency and result of a delivery attempt.
+func observeDelivery(sender string, t0 time.Time, err error) {
+	deliveryLatency.WithLabelValues(sender).Observe(time.Since(t0).Seconds())
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	deliveryTotal.WithLabelValues(sender, result).Inc()
+}
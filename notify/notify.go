@@ -0,0 +1,332 @@
+// Package notify sends notifications to cluster creators via Slack, Discord or email
+// and triggers DKG ceremonies of completed clusters via a webhook or external queue.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/tracer"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config defines the notification integrations. Integrations are disabled if not configured.
+type Config struct {
+	// SlackWebhook is the Slack incoming webhook URL.
+	SlackWebhook string
+	// DiscordWebhook is the Discord webhook URL.
+	DiscordWebhook string
+	// SMTPAddress is the host:port of the SMTP server used to send emails.
+	SMTPAddress  string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	// SMTPTo are the email recipients.
+	SMTPTo []string
+	// MaxAttempts is the maximum number of delivery attempts before failed deliveries are dead-lettered.
+	// Failed deliveries are not retried if less than two.
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry, doubling for each subsequent retry.
+	RetryBackoff time.Duration
+	// MaxRetryBackoff is the maximum delay between retries.
+	MaxRetryBackoff time.Duration
+	// DKGTriggerURL is the URL of the charon-compatible endpoint the DKG trigger payload of completed clusters
+	// is posted to.
+	DKGTriggerURL string
+	// DKGPublisher publishes the DKG trigger payload of completed clusters to an external queue, e.g. NATS or Kafka.
+	DKGPublisher Publisher
+}
+
+// Publisher publishes messages to an external queue.
+type Publisher interface {
+	// Publish publishes the message, returning an error if it wasn't acknowledged.
+	Publish(ctx context.Context, msg []byte) error
+}
+
+// TriggerPayload is the json payload announcing that all operators joined and signed the cluster definition,
+// so the DKG ceremony can start. The definition is the charon cluster definition file content.
+type TriggerPayload struct {
+	Event         string             `json:"event"`
+	ConfigHash    string             `json:"config_hash"`
+	NumOperators  int                `json:"num_operators"`
+	Threshold     int                `json:"threshold"`
+	NumValidators int                `json:"num_validators"`
+	Definition    cluster.Definition `json:"definition"`
+}
+
+// triggerEvent is the event of the DKG trigger payload.
+const triggerEvent = "cluster_ready"
+
+// Notifier sends notifications.
+type Notifier interface {
+	// Complete notifies that all operators joined the cluster and the DKG ceremony can start.
+	Complete(ctx context.Context, def cluster.Definition) error
+	// Alarm notifies operators of the deployment that an alarm fired.
+	Alarm(ctx context.Context, name string, msg string) error
+	// Reload replaces the integrations with those of the config, retaining the retry settings.
+	Reload(conf Config)
+}
+
+// New returns a notifier sending to all configured integrations, if any.
+// Failed deliveries are persisted to the queue for retrying, which shares the notifier's integrations.
+func New(conf Config, queue *Queue) Notifier {
+	if queue != nil {
+		return notifier{targets: queue.targets, queue: queue}
+	}
+
+	return notifier{targets: newTargets(conf)}
+}
+
+// targets are the reloadable senders and triggers of the configured integrations.
+type targets struct {
+	mu       sync.RWMutex
+	senders  map[string]sender
+	triggers map[string]sender
+}
+
+func newTargets(conf Config) *targets {
+	t := new(targets)
+	t.reload(conf)
+
+	return t
+}
+
+// reload replaces the integrations with those of the config.
+func (t *targets) reload(conf Config) {
+	senders, triggers := newSenders(conf), newTriggers(conf)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.senders, t.triggers = senders, triggers
+}
+
+// get returns the current senders and triggers by name.
+func (t *targets) get() (map[string]sender, map[string]sender) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.senders, t.triggers
+}
+
+// lookup returns the sender or trigger by name.
+func (t *targets) lookup(name string) (sender, bool) {
+	senders, triggers := t.get()
+	if send, ok := senders[name]; ok {
+		return send, true
+	}
+
+	send, ok := triggers[name]
+
+	return send, ok
+}
+
+// newSenders returns the senders of the configured integrations by name.
+func newSenders(conf Config) map[string]sender {
+	senders := make(map[string]sender)
+	if conf.SlackWebhook != "" {
+		senders["slack"] = webhookSender(conf.SlackWebhook, "text")
+	}
+	if conf.DiscordWebhook != "" {
+		senders["discord"] = webhookSender(conf.DiscordWebhook, "content")
+	}
+	if conf.SMTPAddress != "" {
+		senders["email"] = emailSender(conf)
+	}
+
+	return senders
+}
+
+// newTriggers returns the senders of the configured DKG triggers by name, sending the json encoded
+// TriggerPayload as message.
+func newTriggers(conf Config) map[string]sender {
+	triggers := make(map[string]sender)
+	if conf.DKGTriggerURL != "" {
+		triggers["dkg_webhook"] = triggerSender(conf.DKGTriggerURL)
+	}
+	if conf.DKGPublisher != nil {
+		triggers["dkg_publisher"] = func(ctx context.Context, _ string, msg string) error {
+			return conf.DKGPublisher.Publish(ctx, []byte(msg))
+		}
+	}
+
+	return triggers
+}
+
+// sender sends a notification message.
+type sender func(ctx context.Context, subject string, msg string) error
+
+type notifier struct {
+	targets *targets
+	queue   *Queue
+}
+
+func (n notifier) Complete(ctx context.Context, def cluster.Definition) error {
+	subject := fmt.Sprintf("Cluster %s is ready for DKG", def.Name)
+	msg := fmt.Sprintf("All %d operators joined cluster %s (config hash %#x), the DKG ceremony can start.",
+		len(def.Operators), def.Name, def.ConfigHash)
+
+	payload, err := json.Marshal(TriggerPayload{
+		Event:         triggerEvent,
+		ConfigHash:    fmt.Sprintf("%#x", def.ConfigHash),
+		NumOperators:  len(def.Operators),
+		Threshold:     def.Threshold,
+		NumValidators: def.NumValidators,
+		Definition:    def,
+	})
+	if err != nil {
+		return errors.Wrap(err, "marshal dkg trigger payload")
+	}
+
+	senders, triggers := n.targets.get()
+	err = n.send(ctx, senders, subject, msg)
+	if triggerErr := n.send(ctx, triggers, subject, string(payload)); triggerErr != nil {
+		if err != nil {
+			return errors.New("send notifications and dkg triggers", z.Str("errors", err.Error()+"; "+triggerErr.Error()))
+		}
+
+		return triggerErr
+	}
+
+	return err
+}
+
+func (n notifier) Alarm(ctx context.Context, name string, msg string) error {
+	senders, _ := n.targets.get()
+	return n.send(ctx, senders, fmt.Sprintf("dvstore alarm: %s", name), msg)
+}
+
+func (n notifier) Reload(conf Config) {
+	n.targets.reload(conf)
+}
+
+// send delivers the message to all the integrations, enqueuing failed deliveries for retrying.
+func (n notifier) send(ctx context.Context, senders map[string]sender, subject string, msg string) error {
+	var errs []string
+	for name, send := range senders {
+		err := deliver(ctx, name, send, subject, msg, trace.Link{})
+		if err == nil {
+			continue
+		}
+
+		errs = append(errs, err.Error())
+		if err := n.queue.enqueue(ctx, name, subject, msg, err, trace.SpanContextFromContext(ctx)); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New("send notifications", z.Str("errors", strings.Join(errs, "; ")))
+	}
+
+	return nil
+}
+
+// deliver sends the message via the named sender, instrumenting the attempt with metrics and a span.
+// The span is a child of the context's span, optionally linked to the span of a previous attempt.
+func deliver(ctx context.Context, name string, send sender, subject string, msg string, link trace.Link) error {
+	var opts []trace.SpanStartOption
+	if link.SpanContext.IsValid() {
+		opts = append(opts, trace.WithLinks(link))
+	}
+
+	ctx, span := tracer.Start(ctx, "notify/deliver", opts...)
+	defer span.End()
+	span.SetAttributes(attribute.String("sender", name))
+
+	t0 := time.Now()
+	err := send(ctx, subject, msg)
+	observeDelivery(name, t0, err)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "delivery failed")
+	}
+
+	return err
+}
+
+// webhookSender returns a sender posting the message as the json field to the webhook URL.
+func webhookSender(url string, field string) sender {
+	return func(ctx context.Context, _ string, msg string) error {
+		b, err := json.Marshal(map[string]string{field: msg})
+		if err != nil {
+			return errors.Wrap(err, "marshal webhook body")
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+		if err != nil {
+			return errors.Wrap(err, "create webhook request")
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return errors.Wrap(err, "post webhook")
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode/100 != 2 {
+			body, _ := io.ReadAll(resp.Body)
+			return errors.New("webhook failed", z.Int("status", resp.StatusCode), z.Str("body", string(body)))
+		}
+
+		return nil
+	}
+}
+
+// triggerSender returns a sender posting the json message to the DKG trigger URL.
+func triggerSender(url string) sender {
+	return func(ctx context.Context, _ string, msg string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(msg))
+		if err != nil {
+			return errors.Wrap(err, "create dkg trigger request")
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return errors.Wrap(err, "post dkg trigger")
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode/100 != 2 {
+			body, _ := io.ReadAll(resp.Body)
+			return errors.New("dkg trigger failed", z.Int("status", resp.StatusCode), z.Str("body", string(body)))
+		}
+
+		return nil
+	}
+}
+
+// emailSender returns a sender emailing the message to the configured recipients.
+func emailSender(conf Config) sender {
+	return func(_ context.Context, subject string, msg string) error {
+		var auth smtp.Auth
+		if conf.SMTPUsername != "" {
+			host := strings.Split(conf.SMTPAddress, ":")[0]
+			auth = smtp.PlainAuth("", conf.SMTPUsername, conf.SMTPPassword, host)
+		}
+
+		body := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n",
+			conf.SMTPFrom, strings.Join(conf.SMTPTo, ", "), subject, msg)
+
+		if err := smtp.SendMail(conf.SMTPAddress, auth, conf.SMTPFrom, conf.SMTPTo, []byte(body)); err != nil {
+			return errors.Wrap(err, "send email")
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,280 @@
+package notify
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+const (
+	// RetryInterval is the interval at which due deliveries are retried.
+	RetryInterval = 10 * time.Second
+	// claimLease is the duration a claimed delivery is hidden from other instances while being retried.
+	claimLease = time.Minute
+)
+
+// ErrNotFound is returned when a dead-lettered delivery doesn't exist.
+var ErrNotFound = errors.New("delivery not found")
+
+// Delivery is a failed notification delivery to a single integration.
+type Delivery struct {
+	ID          string    `json:"id" bson:"_id"`
+	Sender      string    `json:"sender" bson:"sender"`
+	Subject     string    `json:"subject" bson:"subject"`
+	Message     string    `json:"message" bson:"message"`
+	Attempts    int       `json:"attempts" bson:"attempts"`
+	LastError   string    `json:"last_error" bson:"last_error"`
+	NextAttempt time.Time `json:"next_attempt" bson:"next_attempt"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	// TraceID and SpanID identify the span of the originating delivery attempt, linked by retries.
+	TraceID string `json:"trace_id,omitempty" bson:"trace_id"`
+	SpanID  string `json:"span_id,omitempty" bson:"span_id"`
+}
+
+// link returns a trace link to the span of the originating delivery attempt or an empty link if unknown.
+func (d Delivery) link() trace.Link {
+	traceID, err := trace.TraceIDFromHex(d.TraceID)
+	if err != nil {
+		return trace.Link{}
+	}
+
+	spanID, err := trace.SpanIDFromHex(d.SpanID)
+	if err != nil {
+		return trace.Link{}
+	}
+
+	return trace.Link{SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+		Remote:  true,
+	})}
+}
+
+// Queue persists failed deliveries and retries them with exponential backoff,
+// moving them to the dead-letter collection once the maximum attempts are exhausted.
+// A nil queue drops failed deliveries.
+type Queue struct {
+	targets *targets
+	pending *mongo.Collection
+	dead    *mongo.Collection
+	conf    Config
+}
+
+// NewQueue returns a new retry queue of the configured integrations or nil if retries are disabled.
+func NewQueue(conf Config, pending, dead *mongo.Collection) *Queue {
+	if conf.MaxAttempts <= 1 {
+		return nil
+	}
+
+	return &Queue{
+		targets: newTargets(conf),
+		pending: pending,
+		dead:    dead,
+		conf:    conf,
+	}
+}
+
+// enqueue persists the failed delivery for retrying.
+func (q *Queue) enqueue(ctx context.Context, name, subject, msg string, sendErr error, spanCtx trace.SpanContext) error {
+	if q == nil {
+		return nil
+	}
+
+	var traceID, spanID string
+	if spanCtx.IsValid() {
+		traceID, spanID = spanCtx.TraceID().String(), spanCtx.SpanID().String()
+	}
+
+	now := time.Now()
+	_, err := q.pending.InsertOne(ctx, Delivery{
+		ID:          primitive.NewObjectID().Hex(),
+		Sender:      name,
+		Subject:     subject,
+		Message:     msg,
+		Attempts:    1,
+		LastError:   sendErr.Error(),
+		NextAttempt: now.Add(q.backoff(1)),
+		CreatedAt:   now,
+		TraceID:     traceID,
+		SpanID:      spanID,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to enqueue delivery")
+	}
+
+	return nil
+}
+
+// backoff returns the delay before the next attempt after the number of failed attempts.
+func (q *Queue) backoff(attempts int) time.Duration {
+	delay := q.conf.RetryBackoff
+	for i := 1; i < attempts && delay < q.conf.MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > q.conf.MaxRetryBackoff {
+		return q.conf.MaxRetryBackoff
+	}
+
+	return delay
+}
+
+// RetryDue updates the queue depth metrics and retries all due deliveries.
+// It is run periodically by the scheduler every RetryInterval.
+func (q *Queue) RetryDue(ctx context.Context) error {
+	if q == nil {
+		return nil
+	}
+
+	q.updateDepth(ctx)
+
+	for {
+		ok, err := q.retryNext(ctx)
+		if err != nil {
+			return err
+		} else if !ok {
+			return nil
+		}
+	}
+}
+
+// updateDepth updates the queue depth gauges.
+func (q *Queue) updateDepth(ctx context.Context) {
+	for name, coll := range map[string]*mongo.Collection{"pending": q.pending, "dead": q.dead} {
+		count, err := coll.EstimatedDocumentCount(ctx)
+		if err != nil {
+			log.Warn(ctx, "Failed counting notification queue", err, z.Str("queue", name))
+			continue
+		}
+
+		queueDepth.WithLabelValues(name).Set(float64(count))
+	}
+}
+
+// retryNext claims and retries the next due delivery, returning false if no delivery is due.
+func (q *Queue) retryNext(ctx context.Context) (bool, error) {
+	now := time.Now()
+
+	res := q.pending.FindOneAndUpdate(ctx,
+		bson.D{{"next_attempt", bson.D{{"$lte", now}}}},
+		bson.D{{"$set", bson.D{{"next_attempt", now.Add(claimLease)}}}},
+		options.FindOneAndUpdate().SetSort(bson.D{{"next_attempt", 1}}))
+	if errors.Is(res.Err(), mongo.ErrNoDocuments) {
+		return false, nil
+	} else if res.Err() != nil {
+		return false, errors.Wrap(res.Err(), "failed to claim delivery")
+	}
+
+	var delivery Delivery
+	if err := res.Decode(&delivery); err != nil {
+		return false, errors.Wrap(err, "failed to decode delivery")
+	}
+
+	send, ok := q.targets.lookup(delivery.Sender)
+	if !ok {
+		// Integration no longer configured.
+		return true, q.deadLetter(ctx, delivery)
+	}
+
+	sendErr := deliver(ctx, delivery.Sender, send, delivery.Subject, delivery.Message, delivery.link())
+	if sendErr == nil {
+		if _, err := q.pending.DeleteOne(ctx, bson.D{{"_id", delivery.ID}}); err != nil {
+			return false, errors.Wrap(err, "failed to delete delivery")
+		}
+
+		return true, nil
+	}
+
+	delivery.Attempts++
+	delivery.LastError = sendErr.Error()
+
+	if delivery.Attempts >= q.conf.MaxAttempts {
+		log.Warn(ctx, "Notification delivery dead-lettered", sendErr,
+			z.Str("id", delivery.ID), z.Str("sender", delivery.Sender), z.Int("attempts", delivery.Attempts))
+
+		return true, q.deadLetter(ctx, delivery)
+	}
+
+	_, err := q.pending.UpdateOne(ctx, bson.D{{"_id", delivery.ID}}, bson.D{{"$set", bson.D{
+		{"attempts", delivery.Attempts},
+		{"last_error", delivery.LastError},
+		{"next_attempt", time.Now().Add(q.backoff(delivery.Attempts))},
+	}}})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update delivery")
+	}
+
+	return true, nil
+}
+
+// deadLetter moves the delivery from the pending to the dead-letter collection.
+func (q *Queue) deadLetter(ctx context.Context, delivery Delivery) error {
+	if _, err := q.dead.InsertOne(ctx, delivery); err != nil {
+		return errors.Wrap(err, "failed to insert dead letter")
+	}
+
+	if _, err := q.pending.DeleteOne(ctx, bson.D{{"_id", delivery.ID}}); err != nil {
+		return errors.Wrap(err, "failed to delete delivery")
+	}
+
+	return nil
+}
+
+// DeadLetters returns the dead-lettered deliveries.
+func (q *Queue) DeadLetters(ctx context.Context) ([]Delivery, error) {
+	if q == nil {
+		return []Delivery{}, nil
+	}
+
+	cursor, err := q.dead.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{"created_at", 1}}))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find dead letters")
+	}
+
+	resp := []Delivery{}
+	if err := cursor.All(ctx, &resp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode dead letters")
+	}
+
+	return resp, nil
+}
+
+// Replay moves the dead-lettered delivery back to the pending collection for immediate retrying
+// with reset attempts.
+func (q *Queue) Replay(ctx context.Context, id string) error {
+	if q == nil {
+		return errors.Wrap(ErrNotFound, "notification retries disabled")
+	}
+
+	res := q.dead.FindOne(ctx, bson.D{{"_id", id}})
+	if errors.Is(res.Err(), mongo.ErrNoDocuments) {
+		return errors.Wrap(ErrNotFound, "dead letter not found")
+	} else if res.Err() != nil {
+		return errors.Wrap(res.Err(), "failed to get dead letter")
+	}
+
+	var delivery Delivery
+	if err := res.Decode(&delivery); err != nil {
+		return errors.Wrap(err, "failed to decode dead letter")
+	}
+
+	delivery.Attempts = 0
+	delivery.NextAttempt = time.Now()
+
+	if _, err := q.pending.InsertOne(ctx, delivery); err != nil {
+		return errors.Wrap(err, "failed to insert delivery")
+	}
+
+	if _, err := q.dead.DeleteOne(ctx, bson.D{{"_id", id}}); err != nil {
+		return errors.Wrap(err, "failed to delete dead letter")
+	}
+
+	return nil
+}
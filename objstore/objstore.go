@@ -0,0 +1,141 @@
+// Package objstore archives immutable snapshots of cluster definitions and locks to S3-compatible object storage,
+// including Google Cloud Storage via its XML API interoperability, for compliance and disaster recovery.
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Config defines the object storage bucket. Archiving is disabled if no bucket is configured.
+type Config struct {
+	// Endpoint is the URL of the S3-compatible API, e.g. https://s3.eu-west-1.amazonaws.com or
+	// https://storage.googleapis.com for GCS.
+	Endpoint string
+	// Region is the signing region of the bucket, "auto" for GCS.
+	Region string
+	// Bucket is the name of the bucket snapshots are written to.
+	Bucket string
+	// Prefix is prepended to the keys of all snapshots, e.g. "dvstore/".
+	Prefix string
+	// AccessKeyID and SecretAccessKey are the credentials requests are signed with, HMAC keys for GCS.
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// Archiver writes immutable objects to object storage.
+type Archiver interface {
+	// Put writes the json object to the key, if it doesn't exist yet, and returns its location.
+	Put(ctx context.Context, key string, data []byte) (string, error)
+}
+
+// New returns an archiver writing to the configured bucket or nil if no bucket is configured.
+func New(conf Config) (Archiver, error) {
+	if conf.Bucket == "" {
+		return nil, nil
+	}
+
+	if _, err := url.ParseRequestURI(conf.Endpoint); err != nil {
+		return nil, errors.Wrap(err, "invalid object storage endpoint")
+	} else if conf.AccessKeyID == "" || conf.SecretAccessKey == "" {
+		return nil, errors.New("object storage requires access key credentials")
+	} else if conf.Region == "" {
+		return nil, errors.New("object storage requires a region")
+	}
+
+	return archiver{conf: conf}, nil
+}
+
+type archiver struct {
+	conf Config
+}
+
+func (a archiver) Put(ctx context.Context, key string, data []byte) (string, error) {
+	location := fmt.Sprintf("%s/%s/%s%s", strings.TrimSuffix(a.conf.Endpoint, "/"), a.conf.Bucket, a.conf.Prefix, key)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, location, bytes.NewReader(data))
+	if err != nil {
+		return "", errors.Wrap(err, "create put object request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Snapshots are immutable, so existing objects are never overwritten.
+	req.Header.Set("If-None-Match", "*")
+	a.sign(req, data, time.Now().UTC())
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "put object")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusPreconditionFailed {
+		// The object was written by a previous attempt or another instance.
+		return location, nil
+	} else if res.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(res.Body)
+		return "", errors.New("put object failed", z.Int("status", res.StatusCode), z.Str("body", string(body)))
+	}
+
+	return location, nil
+}
+
+// sign signs the request with AWS Signature Version 4, see
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html.
+func (a archiver) sign(req *http.Request, payload []byte, now time.Time) {
+	const service = "s3"
+
+	payloadHash := sha256Hex(payload)
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "content-type;host;if-none-match;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := fmt.Sprintf("content-type:%s\nhost:%s\nif-none-match:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n",
+		req.Header.Get("Content-Type"), req.URL.Host, req.Header.Get("If-None-Match"), payloadHash, amzDate)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, a.conf.Region, service)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+a.conf.SecretAccessKey), date)
+	key = hmacSHA256(key, a.conf.Region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		a.conf.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,233 @@
+package router
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AbuseConfig defines the automatic banning of abusive clients.
+type AbuseConfig struct {
+	// MaxErrors is the number of client error responses within the window after which a client is banned.
+	// Clients are never banned if zero.
+	MaxErrors int
+	// Window is the period over which client errors are counted.
+	Window time.Duration
+	// BanDuration is the cooldown period during which banned clients are rejected.
+	BanDuration time.Duration
+}
+
+// Ban is a temporarily banned client.
+type Ban struct {
+	Client string    `json:"client"`
+	Errors int       `json:"errors"`
+	Until  time.Time `json:"until"`
+}
+
+// clientErrors tracks client error responses within the current window.
+type clientErrors struct {
+	Start time.Time
+	Count int
+}
+
+// banlist tracks client errors by client IP and bans clients exceeding the configured limit.
+// State is in-memory so bans are per instance and are cleared on restart.
+type banlist struct {
+	conf AbuseConfig
+	now  func() time.Time
+
+	mu     sync.Mutex
+	errors map[string]clientErrors
+	bans   map[string]Ban
+}
+
+// newBanlist returns a new banlist or nil if banning is disabled.
+func newBanlist(conf AbuseConfig) *banlist {
+	if conf.MaxErrors <= 0 {
+		return nil
+	}
+
+	return &banlist{
+		conf:   conf,
+		now:    time.Now,
+		errors: make(map[string]clientErrors),
+		bans:   make(map[string]Ban),
+	}
+}
+
+// Banned returns true if the client is currently banned.
+func (b *banlist) Banned(client string) bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ban, ok := b.bans[client]
+	if !ok {
+		return false
+	} else if b.now().After(ban.Until) {
+		delete(b.bans, client)
+		return false
+	}
+
+	return true
+}
+
+// Record records a response status code of the client and returns true if the client was banned as a result.
+func (b *banlist) Record(client string, statusCode int) bool {
+	if b == nil || statusCode/100 != 4 {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+
+	errs := b.errors[client]
+	if now.Sub(errs.Start) > b.conf.Window {
+		errs = clientErrors{Start: now}
+	}
+	errs.Count++
+
+	if errs.Count < b.conf.MaxErrors {
+		b.errors[client] = errs
+		return false
+	}
+
+	delete(b.errors, client)
+	b.bans[client] = Ban{
+		Client: client,
+		Errors: errs.Count,
+		Until:  now.Add(b.conf.BanDuration),
+	}
+
+	return true
+}
+
+// List returns the active bans ordered by client.
+func (b *banlist) List() []Ban {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+
+	resp := make([]Ban, 0, len(b.bans))
+	for client, ban := range b.bans {
+		if now.After(ban.Until) {
+			delete(b.bans, client)
+			continue
+		}
+		resp = append(resp, ban)
+	}
+
+	sort.Slice(resp, func(i, j int) bool {
+		return resp[i].Client < resp[j].Client
+	})
+
+	return resp
+}
+
+// Unban removes the client ban and error history and returns true if the client was banned.
+func (b *banlist) Unban(client string) bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, ok := b.bans[client]
+	delete(b.bans, client)
+	delete(b.errors, client)
+
+	return ok
+}
+
+// Middleware returns a handler rejecting banned clients and recording the response status codes of others.
+func (b *banlist) Middleware(endpoint string, next http.Handler) http.Handler {
+	if b == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := clientIP(r)
+		if b.Banned(client) {
+			writeError(r.Context(), w, endpoint, apiError{
+				StatusCode: http.StatusForbidden,
+				Message:    "client temporarily banned due to excessive errors",
+			})
+
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		if b.Record(client, sw.statusCode) {
+			log.Warn(r.Context(), "Banned abusive client", nil,
+				z.Str("client", client),
+				z.Str("endpoint", endpoint),
+				z.Any("duration", b.conf.BanDuration))
+		}
+	})
+}
+
+// clientIP returns the IP of the request's remote address.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// statusWriter is a http.ResponseWriter that records the response status code.
+type statusWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Flush flushes buffered data to the client if supported by the underlying writer.
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func listBans(bans *banlist) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		return bans.List(), nil
+	}
+}
+
+func unban(bans *banlist) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if !bans.Unban(params["client"]) {
+			return nil, apiError{
+				StatusCode: http.StatusNotFound,
+				Message:    "client not banned",
+			}
+		}
+
+		return nil, nil
+	}
+}
@@ -0,0 +1,39 @@
+package router
+
+import (
+	"fmt"
+	"github.com/corverroos/dvstore/service"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
+	"net/http"
+	"time"
+)
+
+// exportArchive returns a handler writing the archive of all published clusters of the request's tenant as
+// a gzipped tar file, see service.ArchiveManifest for the format. The archive is streamed, so errors after
+// the first bytes were written can only be logged; clients detect the truncated archive.
+func exportArchive(defSvc service.Definition, audit service.Audit) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithTopic(r.Context(), "router")
+		ctx = log.WithCtx(ctx, z.Str("endpoint", "export_archive"))
+
+		ctx, err := withTenant(ctx, r)
+		if err != nil {
+			writeError(r.Context(), w, "export_archive", err)
+			return
+		}
+
+		filename := fmt.Sprintf("dvstore-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+		count, err := service.WriteArchive(ctx, w, defSvc, audit)
+		if err != nil {
+			log.Error(ctx, "Failed writing archive", err)
+			return
+		}
+
+		log.Info(ctx, "Exported archive", z.Int("clusters", count))
+	}
+}
@@ -0,0 +1,46 @@
+package router
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/corverroos/dvstore/service"
+	"net/http"
+	"net/url"
+)
+
+// withActor returns a copy of the context with the request's actor, recorded in the audit entries of its mutations.
+func withActor(ctx context.Context, r *http.Request, body []byte) context.Context {
+	actor := service.Actor{
+		Principal: principalFromCtx(ctx),
+		RemoteIP:  clientIP(r),
+		RequestID: requestIDFromCtx(ctx),
+	}
+	if len(body) > 0 {
+		hash := sha256.Sum256(body)
+		actor.BodyHash = hex.EncodeToString(hash[:])
+	}
+
+	return service.WithActor(ctx, actor)
+}
+
+func getAudit(audit service.Audit) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if audit == nil {
+			return nil, errAuditDisabled
+		}
+
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return audit.List(ctx, hash)
+	}
+}
+
+// errAuditDisabled is returned by the audit endpoint if the storage doesn't support auditing.
+var errAuditDisabled = apiError{
+	StatusCode: http.StatusNotFound,
+	Message:    "audit log disabled",
+}
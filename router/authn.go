@@ -0,0 +1,372 @@
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Headers of Sign-In with Ethereum authenticated requests.
+const (
+	siweMessageHeader   = "X-Dvstore-Siwe-Message"
+	siweSignatureHeader = "X-Dvstore-Siwe-Signature"
+)
+
+// ErrNoCredentials is returned by authenticators if the request doesn't contain credentials they verify,
+// passing the request to the next authenticator.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Principal is the authenticated client of a request.
+type Principal struct {
+	// Subject identifies the client for rate limiting, usage tracking and ownership, e.g. "key:1a2b3c4d5e6f7a8b".
+	Subject string
+	// Role is the access control role granted to the client.
+	Role Role
+	// Tenant is the tenant the client is bound to, empty if the client may access the default tenant
+	// and select any tenant via the tenant path prefix.
+	Tenant string
+	// Scope restricts the endpoints and definitions the client may access, nil if only restricted by its role.
+	Scope *Scope
+}
+
+// Authenticator authenticates requests. Embedders may supply their own via AuthConfig.Authenticators
+// to compose dvstore into services with custom authentication.
+type Authenticator interface {
+	// Authenticate returns the principal of the request, ErrNoCredentials if the request doesn't contain credentials
+	// verified by the authenticator, or any other error if the credentials are invalid, responding unauthorized.
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+// AuthenticatorFunc adapts a function to an Authenticator.
+type AuthenticatorFunc func(r *http.Request) (Principal, error)
+
+// Authenticate calls f(r).
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (Principal, error) {
+	return f(r)
+}
+
+// NewAPIKeyAuthenticator returns an authenticator of bearer API keys granted the roles by API key.
+func NewAPIKeyAuthenticator(keys map[string]Role) (Authenticator, error) {
+	hashes := make(apiKeyAuthenticator)
+	for key, role := range keys {
+		if !role.Valid() {
+			return nil, errors.New("invalid api key role", z.Str("role", string(role)))
+		}
+		hashes[sha256.Sum256([]byte(key))] = role
+	}
+
+	return hashes, nil
+}
+
+// apiKeyAuthenticator authenticates bearer API keys by the roles by sha256 hash of the API key,
+// avoiding timing attacks on map lookups.
+type apiKeyAuthenticator map[[32]byte]Role
+
+func (a apiKeyAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	keyHash := sha256.Sum256([]byte(strings.TrimPrefix(header, "Bearer ")))
+	role, ok := a[keyHash]
+	if !ok {
+		return Principal{}, ErrNoCredentials // Possibly a token of another authenticator.
+	}
+
+	return Principal{Subject: keySubject(keyHash), Role: role}, nil
+}
+
+// NewJWTAuthenticator returns an authenticator of bearer OIDC ID tokens signed by the configured issuer.
+func NewJWTAuthenticator(conf OIDCConfig) (Authenticator, error) {
+	if conf.IssuerURL == "" {
+		return nil, errors.New("oidc issuer url required")
+	}
+
+	verifier, err := newOIDCVerifier(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return jwtAuthenticator{verifier: verifier}, nil
+}
+
+type jwtAuthenticator struct {
+	verifier *oidcVerifier
+}
+
+func (a jwtAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if strings.Count(token, ".") != 2 {
+		return Principal{}, ErrNoCredentials
+	}
+
+	role, subject, err := a.verifier.Identify(r.Context(), token)
+	if err != nil {
+		return Principal{}, apiError{
+			StatusCode: http.StatusUnauthorized,
+			Message:    "invalid oidc token",
+			Err:        err,
+		}
+	}
+
+	return Principal{Subject: "oidc:" + subject, Role: role}, nil
+}
+
+// NewHMACAuthenticator returns an authenticator of requests signed with the shared secrets by key ID
+// and timestamped within the window.
+func NewHMACAuthenticator(keys map[string]HMACKey, window time.Duration) (Authenticator, error) {
+	for id, key := range keys {
+		if !key.Role.Valid() {
+			return nil, errors.New("invalid hmac key role", z.Str("id", id), z.Str("role", string(key.Role)))
+		}
+	}
+
+	return hmacAuthenticator{keys: keys, window: window}, nil
+}
+
+type hmacAuthenticator struct {
+	keys   map[string]HMACKey
+	window time.Duration
+}
+
+func (a hmacAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	if r.Header.Get(hmacSignatureHeader) == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	role, err := verifyHMAC(r, a.keys, a.window)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	return Principal{Subject: "hmac:" + r.Header.Get(hmacKeyIDHeader), Role: role}, nil
+}
+
+// NewMTLSAuthenticator returns an authenticator of verified TLS client certificates granted the roles by
+// certificate subject common name. The server must be configured to verify client certificates.
+func NewMTLSAuthenticator(roles map[string]Role) (Authenticator, error) {
+	for name, role := range roles {
+		if !role.Valid() {
+			return nil, errors.New("invalid mtls role", z.Str("common_name", name), z.Str("role", string(role)))
+		}
+	}
+
+	return mtlsAuthenticator(roles), nil
+}
+
+type mtlsAuthenticator map[string]Role
+
+func (a mtlsAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Principal{}, ErrNoCredentials
+	}
+
+	name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	role, ok := a[name]
+	if !ok {
+		return Principal{}, apiError{
+			StatusCode: http.StatusUnauthorized,
+			Message:    "unknown client certificate",
+			Err:        errors.New("unknown common name", z.Str("common_name", name)),
+		}
+	}
+
+	return Principal{Subject: "mtls:" + name, Role: role}, nil
+}
+
+// SIWEConfig defines Sign-In with Ethereum (EIP-4361) authentication of users via their wallets.
+type SIWEConfig struct {
+	// Domain is the expected domain of signed messages. SIWE authentication is disabled if empty.
+	Domain string
+	// Roles are the roles by 0x-hex address.
+	Roles map[string]Role
+	// DefaultRole is the role of addresses not included in Roles. Other addresses are rejected if empty.
+	DefaultRole Role
+	// MaxAge is the maximum age of a signed message's issued at time, since signed messages are reused for
+	// subsequent requests until they expire.
+	MaxAge time.Duration
+}
+
+// NewSIWEAuthenticator returns an authenticator of EIP-4361 messages signed by wallets. Requests provide the
+// base64 encoded message and its 0x-hex personal_sign signature via the X-Dvstore-Siwe-Message and
+// X-Dvstore-Siwe-Signature headers.
+func NewSIWEAuthenticator(conf SIWEConfig) (Authenticator, error) {
+	if conf.Domain == "" {
+		return nil, errors.New("siwe domain required")
+	} else if conf.MaxAge <= 0 {
+		return nil, errors.New("siwe max age required")
+	} else if conf.DefaultRole != "" && !conf.DefaultRole.Valid() {
+		return nil, errors.New("invalid siwe default role", z.Str("role", string(conf.DefaultRole)))
+	}
+
+	roles := make(map[string]Role)
+	for address, role := range conf.Roles {
+		if !role.Valid() {
+			return nil, errors.New("invalid siwe role", z.Str("address", address), z.Str("role", string(role)))
+		}
+		roles[strings.ToLower(address)] = role
+	}
+	conf.Roles = roles
+
+	return siweAuthenticator{conf: conf}, nil
+}
+
+type siweAuthenticator struct {
+	conf SIWEConfig
+}
+
+func (a siweAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	if r.Header.Get(siweSignatureHeader) == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	unauthorized := func(msg string, err error) (Principal, error) {
+		return Principal{}, apiError{
+			StatusCode: http.StatusUnauthorized,
+			Message:    msg,
+			Err:        err,
+		}
+	}
+
+	message, err := base64.StdEncoding.DecodeString(r.Header.Get(siweMessageHeader))
+	if err != nil {
+		return unauthorized("invalid siwe message base64", err)
+	}
+
+	address, fields, err := parseSIWEMessage(string(message), a.conf.Domain)
+	if err != nil {
+		return unauthorized("invalid siwe message", err)
+	}
+
+	if err := verifySIWETimes(fields, a.conf.MaxAge, time.Now()); err != nil {
+		return unauthorized("expired siwe message", err)
+	}
+
+	sig, err := hex.DecodeString(strings.TrimPrefix(r.Header.Get(siweSignatureHeader), "0x"))
+	if err != nil {
+		return unauthorized("invalid siwe signature hex", err)
+	}
+
+	signer, err := recoverPersonalSign(message, sig)
+	if err != nil {
+		return unauthorized("invalid siwe signature", err)
+	} else if signer != address {
+		return unauthorized("invalid siwe signature", errors.New("signer mismatch", z.Str("signer", signer)))
+	}
+
+	role, ok := a.conf.Roles[address]
+	if !ok {
+		role = a.conf.DefaultRole
+	}
+	if role == "" {
+		return Principal{}, apiError{
+			StatusCode: http.StatusForbidden,
+			Message:    "address not authorized",
+		}
+	}
+
+	return Principal{Subject: "siwe:" + address, Role: role}, nil
+}
+
+// parseSIWEMessage returns the lower case address and the "Key: value" fields of the EIP-4361 message
+// after verifying its domain.
+func parseSIWEMessage(message string, domain string) (string, map[string]string, error) {
+	lines := strings.Split(message, "\n")
+	if len(lines) < 2 {
+		return "", nil, errors.New("message too short")
+	}
+
+	const suffix = " wants you to sign in with your Ethereum account:"
+	if !strings.HasSuffix(lines[0], suffix) {
+		return "", nil, errors.New("invalid message header")
+	} else if actual := strings.TrimSuffix(lines[0], suffix); actual != domain {
+		return "", nil, errors.New("domain mismatch", z.Str("domain", actual))
+	}
+
+	address := strings.ToLower(strings.TrimSpace(lines[1]))
+	if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+		return "", nil, errors.New("invalid address")
+	}
+
+	fields := make(map[string]string)
+	for _, line := range lines[2:] {
+		if key, value, ok := strings.Cut(line, ": "); ok {
+			fields[key] = value
+		}
+	}
+
+	return address, fields, nil
+}
+
+// verifySIWETimes returns an error if the message isn't valid at the time according to its issued at,
+// expiration and not before fields.
+func verifySIWETimes(fields map[string]string, maxAge time.Duration, now time.Time) error {
+	issuedAt, err := time.Parse(time.RFC3339, fields["Issued At"])
+	if err != nil {
+		return errors.Wrap(err, "invalid issued at")
+	} else if now.Sub(issuedAt) > maxAge {
+		return errors.New("issued at exceeds max age")
+	}
+
+	if value, ok := fields["Expiration Time"]; ok {
+		expiration, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return errors.Wrap(err, "invalid expiration time")
+		} else if now.After(expiration) {
+			return errors.New("message expired")
+		}
+	}
+
+	if value, ok := fields["Not Before"]; ok {
+		notBefore, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return errors.Wrap(err, "invalid not before")
+		} else if now.Before(notBefore) {
+			return errors.New("message not yet valid")
+		}
+	}
+
+	return nil
+}
+
+// recoverPersonalSign returns the lower case 0x-hex address that signed the message via personal_sign.
+func recoverPersonalSign(message []byte, sig []byte) (string, error) {
+	if len(sig) != 65 {
+		return "", errors.New("invalid signature length", z.Int("siglen", len(sig)))
+	}
+
+	// Copy the signature since the recovery id may be normalised.
+	sig = append([]byte(nil), sig...)
+
+	// Wallet signatures end with 27 or 28 while go-ethereum signatures end with 0 or 1 and both are correct.
+	switch sig[64] {
+	case 0, 1:
+	case 27, 28:
+		sig[64] -= 27
+	default:
+		return "", errors.New("invalid recovery id", z.Any("id", sig[64]))
+	}
+
+	digest := crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)))
+
+	pubkey, err := crypto.SigToPub(digest, sig)
+	if err != nil {
+		return "", errors.Wrap(err, "pubkey from signature")
+	}
+
+	return strings.ToLower(crypto.PubkeyToAddress(*pubkey).Hex()), nil
+}
+
+// hasCredentials returns true if the request provides credentials of any built-in authenticator.
+func hasCredentials(r *http.Request) bool {
+	return r.Header.Get("Authorization") != "" || r.Header.Get(hmacSignatureHeader) != "" ||
+		r.Header.Get(siweSignatureHeader) != ""
+}
@@ -0,0 +1,171 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/corverroos/dvstore/service"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// maxCaptureBody is the maximum number of request and response body bytes captured.
+	maxCaptureBody = 64 << 10
+	// defaultCaptureLimit is the default number of captures listed.
+	defaultCaptureLimit = 50
+	// maxCaptureLimit is the maximum number of captures listed.
+	maxCaptureLimit = 500
+)
+
+// capturedHeaders are the request headers retained in captures, all others, including credentials, are dropped.
+var capturedHeaders = []string{"Accept", "Accept-Encoding", "Content-Encoding", "Content-Type", "User-Agent"}
+
+// captureMiddleware returns a handler recording anonymized failed (4xx and 5xx) requests and their responses.
+// Requests are not captured if captures is nil.
+func captureMiddleware(endpoint string, captures service.Captures, next http.Handler) http.Handler {
+	if captures == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqBody []byte
+		var truncated bool
+		if r.Body != nil {
+			// Read at most the captured size, then restore the complete body for the handler.
+			var err error
+			reqBody, err = io.ReadAll(io.LimitReader(r.Body, maxCaptureBody+1))
+			if err != nil {
+				writeError(r.Context(), w, endpoint, apiError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid request body",
+					Err:        err,
+				})
+
+				return
+			}
+			r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(reqBody), r.Body), Closer: r.Body}
+
+			if len(reqBody) > maxCaptureBody {
+				reqBody = reqBody[:maxCaptureBody]
+				truncated = true
+			}
+		}
+
+		cw := &captureWriter{statusWriter: &statusWriter{ResponseWriter: w, statusCode: http.StatusOK}}
+		next.ServeHTTP(cw, r)
+
+		if cw.statusCode < http.StatusBadRequest {
+			return
+		}
+
+		capture := service.Capture{
+			Time:       time.Now(),
+			Endpoint:   endpoint,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Query:      anonymizeQuery(r.URL.Query()).Encode(),
+			Header:     anonymizeHeader(r.Header),
+			Body:       reqBody,
+			Truncated:  truncated || cw.truncated,
+			StatusCode: cw.statusCode,
+		}
+		if cw.Header().Get("Content-Encoding") == "" {
+			// Compressed responses are not captured since truncation would corrupt them.
+			capture.Response = cw.body.Bytes()
+		}
+
+		// Record with a fresh context since the request context may be cancelled.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		if err := captures.Record(ctx, capture); err != nil {
+			log.Warn(r.Context(), "Failed capturing request", err, z.Str("endpoint", endpoint))
+		}
+	})
+}
+
+// anonymizeHeader returns the retained request headers.
+func anonymizeHeader(header http.Header) http.Header {
+	resp := make(http.Header)
+	for _, key := range capturedHeaders {
+		if values := header.Values(key); len(values) > 0 {
+			resp[key] = values
+		}
+	}
+
+	return resp
+}
+
+// anonymizeQuery returns the query without share link signatures.
+func anonymizeQuery(query url.Values) url.Values {
+	query.Del(shareSignatureParam)
+	query.Del(shareExpiresParam)
+
+	return query
+}
+
+// readCloser combines a reader with a separate closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// captureWriter is a http.ResponseWriter that records the response status code and up to the maximum captured
+// size of the response body.
+type captureWriter struct {
+	*statusWriter
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if remaining := maxCaptureBody - w.body.Len(); remaining < len(b) {
+		w.body.Write(b[:remaining])
+		w.truncated = true
+	} else {
+		w.body.Write(b)
+	}
+
+	return w.statusWriter.Write(b)
+}
+
+func listCaptures(captures service.Captures) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if captures == nil {
+			return nil, errCaptureDisabled
+		}
+
+		limit, err := intQuery(query, "limit", defaultCaptureLimit)
+		if err != nil {
+			return nil, err
+		} else if limit < 1 || limit > maxCaptureLimit {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("invalid limit, expected 1 to %d", maxCaptureLimit),
+			}
+		}
+
+		return captures.List(ctx, limit)
+	}
+}
+
+func getCapture(captures service.Captures) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if captures == nil {
+			return nil, errCaptureDisabled
+		}
+
+		return captures.Get(ctx, params["id"])
+	}
+}
+
+// errCaptureDisabled is returned by the capture endpoints if request capture is disabled.
+var errCaptureDisabled = apiError{
+	StatusCode: http.StatusNotFound,
+	Message:    "request capture disabled",
+}
@@ -0,0 +1,87 @@
+package router
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsExposedHeaders are the response headers readable by cross-origin browser clients in addition to the
+// CORS-safelisted ones, e.g., the ETag required for conditional writes.
+var corsExposedHeaders = []string{"ETag", "Link", "Deprecation", "Sunset", "Retry-After", requestIDHeader, signatureHeader, statusHeader, revisionHeader}
+
+// CORSConfig defines the cross-origin resource sharing policy allowing browser frontends to call the API.
+// CORS is disabled if no origins are allowed.
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed to call the API, e.g., "https://launchpad.obol.tech". "*" allows any origin.
+	AllowedOrigins []string
+	// AllowedMethods are the methods allowed in cross-origin requests.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers allowed in cross-origin requests.
+	AllowedHeaders []string
+	// MaxAge is the duration browsers may cache preflight responses. Browsers use their default if zero.
+	MaxAge time.Duration
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value of the request origin or false if it isn't allowed.
+func (c CORSConfig) allowOrigin(origin string) (string, bool) {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return "*", true
+		} else if strings.EqualFold(allowed, origin) {
+			return origin, true
+		}
+	}
+
+	return "", false
+}
+
+// registerCORS adds the CORS headers to responses of allowed origins and answers preflight requests of all routes.
+// The allowed origins are read from the settings per request. It is a no-op if CORS is disabled and not reloadable.
+func registerCORS(r *mux.Router, conf CORSConfig, settings *Settings) {
+	if len(settings.AllowedOrigins()) == 0 && settings.fixed {
+		return
+	}
+
+	// Preflight requests don't match the routes of other methods, so a catch-all route answers them.
+	r.Methods(http.MethodOptions).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(settings.AllowedOrigins()) == 0 {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Origin")
+
+			conf := conf
+			conf.AllowedOrigins = settings.AllowedOrigins()
+
+			origin := r.Header.Get("Origin")
+			allowed, ok := conf.allowOrigin(origin)
+			if origin == "" || !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", strings.Join(conf.AllowedMethods, ", "))
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(conf.AllowedHeaders, ", "))
+				if conf.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(conf.MaxAge.Seconds())))
+				}
+			} else {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	})
+}
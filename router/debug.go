@@ -0,0 +1,72 @@
+package router
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+)
+
+// debugHeader is the request header enabling debug error responses if allowed by DebugConfig.Header.
+const debugHeader = "X-Debug"
+
+// DebugConfig configures including internal error details in error responses.
+// Debug responses expose internals like error messages of dependencies and source paths, so should not be
+// enabled on public instances.
+type DebugConfig struct {
+	// Enabled includes the debug details in all error responses.
+	Enabled bool
+	// Header includes the debug details in error responses of requests with the "X-Debug: true" header.
+	Header bool
+}
+
+// debugErrorResponse are the internal details of an error, only included in debug mode.
+type debugErrorResponse struct {
+	// Chain is the wrapped error chain, outermost first.
+	Chain []string `json:"chain,omitempty"`
+	// Stacktrace is the stack trace of where the error was created, if known.
+	Stacktrace []string `json:"stacktrace,omitempty"`
+}
+
+type debugKey struct{}
+
+// withDebug returns a copy of the context marked to include debug details in error responses
+// if enabled by the config for the request.
+func withDebug(ctx context.Context, r *http.Request, conf DebugConfig) context.Context {
+	if !conf.Enabled && !(conf.Header && strings.EqualFold(r.Header.Get(debugHeader), "true")) {
+		return ctx
+	}
+
+	return context.WithValue(ctx, debugKey{}, true)
+}
+
+// isDebug returns true if the context is marked to include debug details in error responses.
+func isDebug(ctx context.Context) bool {
+	debug, _ := ctx.Value(debugKey{}).(bool)
+	return debug
+}
+
+// newDebugErrorResponse returns the debug details of the error or nil if it is nil.
+func newDebugErrorResponse(err error) *debugErrorResponse {
+	if err == nil {
+		return nil
+	}
+
+	resp := new(debugErrorResponse)
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		// Structured errors wrap a fmt error with the same message, so skip repeated messages.
+		if n := len(resp.Chain); n > 0 && resp.Chain[n-1] == e.Error() {
+			continue
+		}
+		resp.Chain = append(resp.Chain, e.Error())
+	}
+
+	// Structured errors contain the stack trace of where they were created as a zap field.
+	var stacked interface{ Stack() zap.Field }
+	if errors.As(err, &stacked) && stacked.Stack().String != "" {
+		resp.Stacktrace = strings.Split(stacked.Stack().String, "\n")
+	}
+
+	return resp
+}
@@ -0,0 +1,182 @@
+package router
+
+import (
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Supported content encodings in order of server preference.
+const (
+	encodingBrotli = "br"
+	encodingZstd   = "zstd"
+	encodingGzip   = "gzip"
+)
+
+var supportedEncodings = []string{encodingBrotli, encodingZstd, encodingGzip}
+
+// encoder is a compressing writer that supports flushing buffered data.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// newEncoder returns a compressing writer of the encoding.
+func newEncoder(encoding string, w io.Writer) (encoder, error) {
+	switch encoding {
+	case encodingBrotli:
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression), nil
+	case encodingZstd:
+		enc, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, errors.Wrap(err, "new zstd writer")
+		}
+
+		return enc, nil
+	case encodingGzip:
+		return gzip.NewWriter(w), nil
+	default:
+		return nil, errors.New("unsupported encoding", z.Str("encoding", encoding))
+	}
+}
+
+// decodeBody returns the request body decoded according to the content encoding header.
+func decodeBody(r *http.Request) (io.ReadCloser, error) {
+	switch encoding := strings.TrimSpace(strings.ToLower(r.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return r.Body, nil
+	case encodingBrotli:
+		return io.NopCloser(brotli.NewReader(r.Body)), nil
+	case encodingZstd:
+		dec, err := zstd.NewReader(r.Body)
+		if err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid zstd body",
+				Err:        err,
+			}
+		}
+
+		return dec.IOReadCloser(), nil
+	case encodingGzip:
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid gzip body",
+				Err:        err,
+			}
+		}
+
+		return reader, nil
+	default:
+		return nil, apiError{
+			StatusCode: http.StatusUnsupportedMediaType,
+			Message:    "unsupported content encoding " + encoding,
+		}
+	}
+}
+
+// negotiateEncoding returns the preferred supported encoding of the accept encoding header
+// or an empty string if the response should not be encoded.
+func negotiateEncoding(acceptEncoding string) string {
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, q := parseQValue(part)
+
+		for _, encoding := range supportedEncodings {
+			if name != encoding && name != "*" {
+				continue
+			}
+
+			// Ties are resolved by server preference since supported encodings are ordered.
+			if q > bestQ || (q == bestQ && best != "" && preference(encoding) < preference(best)) {
+				best, bestQ = encoding, q
+			}
+		}
+	}
+
+	return best
+}
+
+// parseQValue returns the lowercase name and quality value of an accept encoding header element.
+func parseQValue(element string) (string, float64) {
+	params := strings.Split(element, ";")
+	name := strings.TrimSpace(strings.ToLower(params[0]))
+
+	q := 1.0
+	for _, param := range params[1:] {
+		param = strings.TrimSpace(param)
+		if !strings.HasPrefix(param, "q=") {
+			continue
+		}
+
+		parsed, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+		if err == nil {
+			q = parsed
+		}
+	}
+
+	return name, q
+}
+
+// preference returns the server preference index of the encoding, lower is preferred.
+func preference(encoding string) int {
+	for i, e := range supportedEncodings {
+		if e == encoding {
+			return i
+		}
+	}
+
+	return len(supportedEncodings)
+}
+
+// encodingWriter is a http.ResponseWriter that compresses the response body.
+type encodingWriter struct {
+	http.ResponseWriter
+	encoder encoder
+}
+
+func (w encodingWriter) Write(b []byte) (int, error) {
+	return w.encoder.Write(b)
+}
+
+// Flush flushes buffered compressed data to the client.
+func (w encodingWriter) Flush() {
+	_ = w.encoder.Flush()
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// encodeResponse returns a response writer compressing the body with the encoding negotiated from the request
+// and a function that must be called to complete the response. It returns the original writer if no encoding
+// was negotiated.
+func encodeResponse(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func() error) {
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		return w, func() error { return nil }
+	}
+
+	enc, err := newEncoder(encoding, w)
+	if err != nil {
+		return w, func() error { return nil }
+	}
+
+	w.Header().Set("Content-Encoding", encoding)
+	w.Header().Del("Content-Length")
+
+	return encodingWriter{ResponseWriter: w, encoder: enc}, enc.Close
+}
@@ -0,0 +1,167 @@
+package router
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/corverroos/dvstore/service"
+	"github.com/obolnetwork/charon/cluster"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ifMatchEndpoints are the endpoints requiring the If-Match header if Config.RequireIfMatch is enabled.
+var ifMatchEndpoints = map[string]bool{
+	"add_operator":      true,
+	"delete_definition": true,
+}
+
+// networkHeader is the response header of definition requests containing the network name of the definition's
+// fork version, absent if unknown.
+const networkHeader = "X-Dvstore-Network"
+
+// tagged wraps a response body with the entity tag and modification time of the definition it represents.
+type tagged struct {
+	Body interface{}
+	ETag string
+	// LastModified is when the definition was last modified, zero if unknown.
+	LastModified time.Time
+	// Related is true if the body includes related documents not covered by the entity tag,
+	// so only the modification time validates it.
+	Related bool
+	// State is the definition's ceremony state summarised in the headers of HEAD requests, nil otherwise.
+	State *service.State
+	// Network is the network name of the definition's fork version, empty if unknown.
+	Network string
+}
+
+// definitionETag returns the strong entity tag of the definition at the revision, formatted as
+// "<revision>-<0x-hex definition hash>". The revision changes with every change of the stored definition,
+// including pins, metadata and pending deletions, while the definition hash distinguishes recreated definitions.
+func definitionETag(revision int, def cluster.Definition) string {
+	return fmt.Sprintf(`"%d-%#x"`, revision, def.DefinitionHash)
+}
+
+// parseIfMatch returns the revision and definition hash of the If-Match header entity tag or false if the header
+// is empty or matches any entity tag. Only a single strong entity tag is supported.
+func parseIfMatch(header string) (int, []byte, bool, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil, false, nil
+	}
+
+	invalid := apiError{
+		StatusCode: http.StatusPreconditionFailed,
+		Message:    "Invalid If-Match header, expected a single definition entity tag",
+	}
+	if len(header) < 2 || !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) {
+		return 0, nil, false, invalid
+	}
+
+	rev, hexHash, ok := strings.Cut(header[1:len(header)-1], "-")
+	if !ok {
+		return 0, nil, false, invalid
+	}
+
+	revision, err := strconv.Atoi(rev)
+	if err != nil || revision < 0 {
+		invalid.Err = err
+		return 0, nil, false, invalid
+	}
+
+	hash, err := hex.DecodeString(strings.TrimPrefix(hexHash, "0x"))
+	if err != nil {
+		invalid.Err = err
+		return 0, nil, false, invalid
+	}
+
+	return revision, hash, true, nil
+}
+
+// writeETag sets the entity tag header of tagged responses and returns the response body.
+func writeETag(w http.ResponseWriter, response interface{}) interface{} {
+	t, ok := response.(tagged)
+	if !ok {
+		return response
+	}
+
+	w.Header().Set("ETag", t.ETag)
+	if !t.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", t.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if t.Network != "" {
+		w.Header().Set(networkHeader, t.Network)
+	}
+	if t.State != nil {
+		w.Header().Set(statusHeader, string(t.State.Status))
+		w.Header().Set(revisionHeader, strconv.Itoa(t.State.Revision))
+	}
+
+	return t.Body
+}
+
+// writeNotModified writes 304 Not Modified and returns true if the tagged response is unchanged according to
+// the request's If-None-Match or, if absent, If-Modified-Since header, see RFC 9110 section 13.2.2.
+// This saves polling clients from transferring the full definition.
+func writeNotModified(w http.ResponseWriter, r *http.Request, response interface{}) bool {
+	t, ok := response.(tagged)
+	if !ok || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		if t.Related || !etagMatch(header, t.ETag) {
+			return false
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err != nil || t.LastModified.IsZero() {
+		return false
+	} else if t.LastModified.Truncate(time.Second).After(since) {
+		return false
+	}
+
+	body := writeETag(w, response)
+
+	cacheControl := "no-cache"
+	if _, ok := body.(immutable); ok {
+		cacheControl = "public, max-age=31536000, immutable"
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	w.WriteHeader(http.StatusNotModified)
+
+	return true
+}
+
+// etagMatch returns true if the If-None-Match header matches the entity tag using weak comparison.
+func etagMatch(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withIfMatch returns a copy of the context with the request's If-Match header revision and definition hash
+// precondition.
+// It returns an error if the header is invalid, or missing while required for the endpoint.
+func withIfMatch(ctx context.Context, r *http.Request, endpoint string, required bool) (context.Context, error) {
+	header := r.Header.Get("If-Match")
+
+	revision, hash, ok, err := parseIfMatch(header)
+	if err != nil {
+		return nil, err
+	} else if ok {
+		return service.WithIfMatch(ctx, revision, hash), nil
+	} else if required && ifMatchEndpoints[endpoint] && header == "" {
+		return nil, apiError{
+			StatusCode: http.StatusPreconditionRequired,
+			Message:    "If-Match header required, set it to the ETag of the definition",
+		}
+	}
+
+	return ctx, nil
+}
@@ -0,0 +1,185 @@
+package router
+
+import (
+	"bytes"
+	"github.com/obolnetwork/charon/cluster"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	def := cluster.Definition{DefinitionHash: []byte{0xab, 0xcd}}
+
+	tests := []struct {
+		name         string
+		header       string
+		wantRevision int
+		wantHash     []byte
+		wantOK       bool
+		wantErr      bool
+	}{
+		{name: "empty"},
+		{name: "any", header: "*"},
+		{name: "entity tag", header: definitionETag(7, def), wantRevision: 7, wantHash: def.DefinitionHash, wantOK: true},
+		{name: "padded", header: " " + definitionETag(0, def) + " ", wantHash: def.DefinitionHash, wantOK: true},
+		{name: "unquoted", header: "7-0xabcd", wantErr: true},
+		{name: "quote", header: `"`, wantErr: true},
+		{name: "hash only", header: `"0xabcd"`, wantErr: true},
+		{name: "invalid revision", header: `"x-0xabcd"`, wantErr: true},
+		{name: "negative revision", header: `"-1-0xabcd"`, wantErr: true},
+		{name: "invalid hash", header: `"7-0xzz"`, wantErr: true},
+		{name: "weak", header: `W/"7-0xabcd"`, wantErr: true},
+		{name: "multiple", header: `"7-0xabcd", "8-0xabcd"`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			revision, hash, ok, err := parseIfMatch(test.header)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if ok != test.wantOK || revision != test.wantRevision || !bytes.Equal(hash, test.wantHash) {
+				t.Fatalf("unexpected precondition: got %v %d %#x, want %v %d %#x",
+					ok, revision, hash, test.wantOK, test.wantRevision, test.wantHash)
+			}
+		})
+	}
+}
+
+func TestWriteNotModified(t *testing.T) {
+	def := cluster.Definition{DefinitionHash: []byte{0xab, 0xcd}}
+	etag := definitionETag(3, def)
+	modified := time.Date(2023, 3, 1, 12, 0, 0, 500e6, time.UTC)
+
+	resp := tagged{Body: def, ETag: etag, LastModified: modified}
+	related := tagged{Body: def, ETag: etag, LastModified: modified, Related: true}
+	final := tagged{Body: immutable{Body: def}, ETag: etag, LastModified: modified}
+
+	tests := []struct {
+		name             string
+		method           string
+		headers          map[string]string
+		response         interface{}
+		wantNotModified  bool
+		wantCacheControl string
+	}{
+		{
+			name:             "matching entity tag",
+			headers:          map[string]string{"If-None-Match": etag},
+			response:         resp,
+			wantNotModified:  true,
+			wantCacheControl: "no-cache",
+		},
+		{
+			name:             "weak entity tag",
+			headers:          map[string]string{"If-None-Match": "W/" + etag},
+			response:         resp,
+			wantNotModified:  true,
+			wantCacheControl: "no-cache",
+		},
+		{
+			name:             "any of entity tags",
+			headers:          map[string]string{"If-None-Match": definitionETag(2, def) + ", " + etag},
+			response:         resp,
+			wantNotModified:  true,
+			wantCacheControl: "no-cache",
+		},
+		{
+			name:             "immutable",
+			headers:          map[string]string{"If-None-Match": "*"},
+			response:         final,
+			wantNotModified:  true,
+			wantCacheControl: "public, max-age=31536000, immutable",
+		},
+		{
+			name:     "previous revision",
+			headers:  map[string]string{"If-None-Match": definitionETag(2, def)},
+			response: resp,
+		},
+		{
+			name:     "related documents",
+			headers:  map[string]string{"If-None-Match": etag},
+			response: related,
+		},
+		{
+			name:     "entity tag precedes modification time",
+			headers:  map[string]string{"If-None-Match": definitionETag(2, def), "If-Modified-Since": modified.Format(http.TimeFormat)},
+			response: resp,
+		},
+		{
+			name:             "not modified since",
+			headers:          map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)},
+			response:         resp,
+			wantNotModified:  true,
+			wantCacheControl: "no-cache",
+		},
+		{
+			name:             "not modified since related",
+			headers:          map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)},
+			response:         related,
+			wantNotModified:  true,
+			wantCacheControl: "no-cache",
+		},
+		{
+			name:     "modified since",
+			headers:  map[string]string{"If-Modified-Since": modified.Add(-time.Second).Format(http.TimeFormat)},
+			response: resp,
+		},
+		{
+			name:     "unknown modification time",
+			headers:  map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)},
+			response: tagged{Body: def, ETag: etag},
+		},
+		{
+			name:     "no conditional headers",
+			response: resp,
+		},
+		{
+			name:     "write",
+			method:   http.MethodPost,
+			headers:  map[string]string{"If-None-Match": etag},
+			response: resp,
+		},
+		{
+			name:     "untagged",
+			headers:  map[string]string{"If-None-Match": "*"},
+			response: def,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method := test.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			r := httptest.NewRequest(method, "/dv/0x01", nil)
+			for key, value := range test.headers {
+				r.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+
+			if got := writeNotModified(w, r, test.response); got != test.wantNotModified {
+				t.Fatalf("unexpected not modified: got %v, want %v", got, test.wantNotModified)
+			} else if !got {
+				return
+			}
+
+			if w.Code != http.StatusNotModified {
+				t.Fatalf("unexpected status: got %d, want %d", w.Code, http.StatusNotModified)
+			} else if got := w.Header().Get("ETag"); got != etag {
+				t.Fatalf("unexpected etag: got %s, want %s", got, etag)
+			} else if got := w.Header().Get("Cache-Control"); got != test.wantCacheControl {
+				t.Fatalf("unexpected cache control: got %s, want %s", got, test.wantCacheControl)
+			}
+		})
+	}
+}
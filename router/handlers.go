@@ -1,52 +1,1197 @@
 package router
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	eth2v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/corverroos/dvstore/notify"
 	"github.com/corverroos/dvstore/service"
+	"github.com/obolnetwork/charon/app/errors"
 	"github.com/obolnetwork/charon/cluster"
+	"github.com/obolnetwork/charon/eth2util"
+	"github.com/obolnetwork/charon/eth2util/enr"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func getDefinition(svc service.Definition) handlerFunc {
 	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
-		hash, ok, err := hexQuery(query, "config_hash")
+		hash, err := configHash(params)
 		if err != nil {
 			return nil, err
-		} else if !ok {
+		}
+
+		includes, err := parseIncludes(query)
+		if err != nil {
+			return nil, err
+		}
+
+		switch query.Get("status") {
+		case "", "published":
+		case string(service.StatusUnpublished):
+			if len(includes) > 0 {
+				return nil, apiError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Related documents can't be included with unpublished definitions",
+				}
+			}
+
+			def, version, err := svc.GetUnpublished(ctx, hash)
+			if err != nil {
+				return nil, err
+			}
+
+			// The entity tag is of the stored definition, since conditional writes apply to it.
+			etag := definitionETag(version.Revision, def)
+			def, err = convertVersion(ctx, query, def)
+			if err != nil {
+				return nil, err
+			}
+
+			return tagged{Body: def, ETag: etag, Network: networkName(def.ForkVersion)}, nil
+		default:
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid status filter, expected published or unpublished",
+			}
+		}
+
+		def, final, version, err := svc.Get(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+
+		// The entity tag allows clients to make subsequent writes conditional via If-Match.
+		// It is of the stored definition, since conditional writes apply to it.
+		etag := definitionETag(version.Revision, def)
+		def, err = convertVersion(ctx, query, def)
+		if err != nil {
+			return nil, err
+		}
+
+		// HEAD requests summarise the ceremony state in headers, so clients can check whether the definition exists
+		// and changed without downloading it.
+		var state *service.State
+		if isHead(ctx) {
+			s, err := svc.State(ctx, hash)
+			if err != nil {
+				return nil, err
+			}
+			state = &s
+		}
+
+		var resp interface{} = def
+		if len(includes) > 0 {
+			resp, err = includeRelated(ctx, svc, hash, def, includes)
+			if err != nil {
+				return nil, err
+			}
+		} else if final {
+			resp = immutable{Body: def}
+		}
+
+		return tagged{
+			Body:         resp,
+			ETag:         etag,
+			LastModified: version.UpdatedAt,
+			Related:      len(includes) > 0,
+			State:        state,
+			Network:      networkName(def.ForkVersion),
+		}, nil
+	}
+}
+
+// includable are the related documents that can be embedded in definition responses via the include query parameter.
+var includable = map[string]bool{
+	"lock":         true,
+	"deposit_data": true,
+	"status":       true,
+}
+
+// compositeDefinition is a definition response embedding related documents.
+// Related documents that don't exist yet, e.g., the lock of a draft definition, are omitted.
+type compositeDefinition struct {
+	Definition  cluster.Definition `json:"definition"`
+	Network     string             `json:"network,omitempty"` // Empty if the fork version's network is unknown.
+	Lock        *cluster.Lock      `json:"lock,omitempty"`
+	DepositData json.RawMessage    `json:"deposit_data,omitempty"`
+	Status      *service.State     `json:"status,omitempty"`
+}
+
+// parseIncludes returns the related documents of the comma separated include query parameter.
+func parseIncludes(query url.Values) (map[string]bool, error) {
+	resp := make(map[string]bool)
+	for _, value := range query["include"] {
+		for _, include := range strings.Split(value, ",") {
+			include = strings.TrimSpace(include)
+			if include == "" {
+				continue
+			} else if !includable[include] {
+				return nil, apiError{
+					StatusCode: http.StatusBadRequest,
+					Message:    fmt.Sprintf("Invalid include [%s], expected lock, deposit_data or status", include),
+				}
+			}
+			resp[include] = true
+		}
+	}
+
+	return resp, nil
+}
+
+// includeRelated returns the definition embedding the included related documents.
+func includeRelated(ctx context.Context, svc service.Definition, hash []byte, def cluster.Definition, includes map[string]bool) (compositeDefinition, error) {
+	resp := compositeDefinition{Definition: def, Network: networkName(def.ForkVersion)}
+
+	// absent returns true if the related document doesn't exist yet.
+	absent := func(err error) bool {
+		return errors.Is(err, service.ErrNotFound) || errors.Is(err, service.ErrInvalidState)
+	}
+
+	if includes["lock"] {
+		lock, err := svc.GetLock(ctx, hash)
+		if err == nil {
+			resp.Lock = &lock
+		} else if !absent(err) {
+			return compositeDefinition{}, err
+		}
+	}
+
+	if includes["deposit_data"] {
+		depositData, err := svc.DepositData(ctx, hash)
+		if err == nil {
+			resp.DepositData = depositData
+		} else if !absent(err) {
+			return compositeDefinition{}, err
+		}
+	}
+
+	if includes["status"] {
+		state, err := svc.State(ctx, hash)
+		if err != nil {
+			return compositeDefinition{}, err
+		}
+		resp.Status = &state
+	}
+
+	return resp, nil
+}
+
+func getDefinitions(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if query.Has("q") {
+			return searchDefinitions(ctx, svc, query)
+		} else if !query.Has("config_hash") && !query.Has("config_hashes") {
+			return listDefinitions(ctx, svc, query)
+		}
+
+		// Config hashes are either repeated config_hash or comma separated config_hashes query parameters.
+		values := query["config_hash"]
+		for _, value := range query["config_hashes"] {
+			for _, hash := range strings.Split(value, ",") {
+				if hash = strings.TrimSpace(hash); hash != "" {
+					values = append(values, hash)
+				}
+			}
+		}
+		if len(values) > maxQueryHashes {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("at most %d config hashes allowed", maxQueryHashes),
+			}
+		}
+
+		var (
+			hashes [][]byte
+			dedup  = make(map[string]bool)
+		)
+		for _, value := range values {
+			hash, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+			if err != nil {
+				return nil, apiError{
+					StatusCode: http.StatusBadRequest,
+					Message:    fmt.Sprintf("invalid 0x-hex query parameter config_hash [%s]", value),
+					Err:        err,
+				}
+			} else if dedup[string(hash)] {
+				continue
+			}
+
+			dedup[string(hash)] = true
+			hashes = append(hashes, hash)
+		}
+
+		defs, err := svc.GetMany(ctx, hashes)
+		if err != nil {
+			return nil, err
+		}
+
+		resp := struct {
+			Definitions []cluster.Definition `json:"definitions"`
+			Misses      []string             `json:"misses"`
+		}{
+			Definitions: defs,
+			Misses:      []string{},
+		}
+
+		if resp.Definitions == nil {
+			resp.Definitions = []cluster.Definition{}
+		}
+
+		for _, def := range defs {
+			delete(dedup, string(def.ConfigHash))
+		}
+		for _, hash := range hashes {
+			if dedup[string(hash)] {
+				resp.Misses = append(resp.Misses, fmt.Sprintf("%#x", hash))
+			}
+		}
+
+		return resp, nil
+	}
+}
+
+// searchDefinitions returns a page of definitions whose name matches the q query parameter,
+// paginated by the optional offset and limit query parameters.
+func searchDefinitions(ctx context.Context, svc service.Definition, query url.Values) (interface{}, error) {
+	offset, err := intQuery(query, "offset", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := intQuery(query, "limit", defaultSearchLimit)
+	if err != nil {
+		return nil, err
+	} else if limit < 1 || limit > maxSearchLimit {
+		return nil, apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("limit must be between 1 and %d", maxSearchLimit),
+		}
+	}
+
+	return svc.Search(ctx, query.Get("q"), offset, limit)
+}
+
+// listDefinitions returns a page of definitions filtered by the optional filter expression, operator and
+// fork_version or network query parameters.
+func listDefinitions(ctx context.Context, svc service.Definition, query url.Values) (interface{}, error) {
+	limit, err := intQuery(query, "limit", defaultListLimit)
+	if err != nil {
+		return nil, err
+	} else if limit < 1 || limit > maxListLimit {
+		return nil, apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("limit must be between 1 and %d", maxListLimit),
+		}
+	}
+
+	filter, err := service.ParseListFilter(query.Get("filter"))
+	if err != nil {
+		return nil, err
+	}
+	filter.Operator = query.Get("operator")
+
+	if value := query.Get("fork_version"); value != "" {
+		filter.ForkVersion, err = hex.DecodeString(strings.TrimPrefix(value, "0x"))
+		if err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("invalid 0x-hex query parameter fork_version [%s]", value),
+				Err:        err,
+			}
+		}
+	} else if network := query.Get("network"); network != "" {
+		filter.ForkVersion, err = eth2util.NetworkToForkVersionBytes(network)
+		if err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("Unknown network %s", network),
+				Err:        err,
+			}
+		}
+	}
+
+	page, err := svc.List(ctx, filter, query.Get("cursor"), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := definitionPage{DefinitionPage: page, Networks: make([]string, 0, len(page.Definitions))}
+	for _, def := range page.Definitions {
+		resp.Networks = append(resp.Networks, networkName(def.ForkVersion))
+	}
+
+	return resp, nil
+}
+
+// definitionPage is a page of definitions with the network names of their fork versions.
+type definitionPage struct {
+	service.DefinitionPage
+	// Networks are the network names of the definitions by index, empty if unknown.
+	Networks []string `json:"networks"`
+}
+
+// networkName returns the network name of the fork version, or empty if unknown.
+func networkName(forkVersion []byte) string {
+	network, _ := eth2util.ForkVersionToNetwork(forkVersion) // Empty if unknown.
+	return network
+}
+
+// intQuery returns the integer query parameter or the default if absent.
+func intQuery(query url.Values, name string, def int) (int, error) {
+	if !query.Has(name) {
+		return def, nil
+	}
+
+	resp, err := strconv.Atoi(query.Get(name))
+	if err != nil || resp < 0 {
+		return 0, apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("invalid non-negative integer query parameter %s [%s]", name, query.Get(name)),
+			Err:        err,
+		}
+	}
+
+	return resp, nil
+}
+
+func getDefinitionsByPrefix(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		prefix := strings.TrimPrefix(params["prefix"], "0x")
+		if len(prefix) < minHashPrefixLen {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("config hash prefix must be at least %d hex characters", minHashPrefixLen),
+			}
+		}
+
+		return svc.GetByPrefix(ctx, prefix, maxPrefixResults)
+	}
+}
+
+func deleteDefinition(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		// The body contains the signed request of the creator or operator, an empty body fails as missing the signature.
+		var req struct {
+			Address string `json:"address"`
+			requestAuthJSON
+		}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &req); err != nil {
+				return nil, apiError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid body",
+					Err:        err,
+				}
+			}
+		}
+
+		deletion, err := svc.Delete(ctx, hash, principalFromCtx(ctx), req.Address, req.toAuth())
+		if err != nil {
+			return nil, err
+		} else if deletion.Pending {
+			return deletion, nil
+		}
+
+		return nil, nil
+	}
+}
+
+func cancelDeletion(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		// The optional body contains the signed request of the creator, the requester needs no signature.
+		var req struct {
+			Address string `json:"address"`
+			requestAuthJSON
+		}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &req); err != nil {
+				return nil, apiError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid body",
+					Err:        err,
+				}
+			}
+		}
+
+		return nil, svc.CancelDeletion(ctx, hash, principalFromCtx(ctx), req.Address, req.toAuth())
+	}
+}
+
+func restoreDefinition(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, svc.Restore(ctx, hash)
+	}
+}
+
+func pinDefinition(svc service.Definition, pinned bool) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		// The body contains the signed request of the creator, admins need no signature.
+		var req struct {
+			Address string `json:"address"`
+			requestAuthJSON
+		}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &req); err != nil {
+				return nil, apiError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid body",
+					Err:        err,
+				}
+			}
+		}
+
+		return nil, svc.Pin(ctx, hash, pinned, req.Address, req.toAuth())
+	}
+}
+
+func createDefinition(svc service.Definition, termsHash []byte) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		create, err := parseCreate(body, termsHash)
+		if err != nil {
+			return nil, err
+		}
+
+		return svc.Create(ctx, create.Definition, create.Options)
+	}
+}
+
+func createDefinitions(svc service.Definition, termsHash []byte) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		var bodies []json.RawMessage
+		if err := json.Unmarshal(body, &bodies); err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid body, expected array of definitions",
+				Err:        err,
+			}
+		} else if len(bodies) == 0 || len(bodies) > maxBatchSize {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("Invalid batch size, expected 1 to %d definitions", maxBatchSize),
+			}
+		}
+
+		type result struct {
+			ConfigHash   string   `json:"config_hash,omitempty"`
+			InviteTokens []string `json:"invite_tokens,omitempty"`
+			Code         int      `json:"code"`
+			Message      string   `json:"message,omitempty"`
+		}
+
+		// Invalid definitions fail individually, valid ones are created in a single bulk write.
+		var (
+			results = make([]result, len(bodies))
+			batch   []service.BatchCreate
+			indexes []int // Result indexes of the batch.
+		)
+		for i, b := range bodies {
+			create, err := parseCreate(b, termsHash)
+			if err != nil {
+				aerr := toAPIError(err)
+				results[i] = result{Code: aerr.StatusCode, Message: aerr.Message}
+
+				continue
+			}
+
+			batch = append(batch, create)
+			indexes = append(indexes, i)
+		}
+
+		if len(batch) > 0 {
+			created, err := svc.CreateBatch(ctx, batch)
+			if err != nil {
+				return nil, err
+			}
+
+			for i, res := range created {
+				r := result{ConfigHash: fmt.Sprintf("%#x", res.ConfigHash), Code: http.StatusOK}
+				if res.Err != nil {
+					aerr := toAPIError(res.Err)
+					r.Code, r.Message = aerr.StatusCode, aerr.Message
+				} else {
+					r.InviteTokens = res.Created.InviteTokens
+				}
+				results[indexes[i]] = r
+			}
+		}
+
+		return struct {
+			Results []result `json:"results"`
+		}{
+			Results: results,
+		}, nil
+	}
+}
+
+// parseCreate returns the definition and create options of the create definition request body,
+// verifying the hashes and signatures of definitions that are published.
+func parseCreate(body []byte, termsHash []byte) (service.BatchCreate, error) {
+	var def cluster.Definition
+	if err := json.Unmarshal(body, &def); err != nil {
+		return service.BatchCreate{}, apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    "Invalid body",
+			Err:        err,
+		}
+	}
+
+	var err error
+	def.ForkVersion, err = resolveNetwork(body, def.ForkVersion)
+	if err != nil {
+		return service.BatchCreate{}, err
+	}
+
+	if err := verifyTermsHash(body, termsHash); err != nil {
+		return service.BatchCreate{}, err
+	}
+
+	var req struct {
+		Parent       hexBytes  `json:"parent_config_hash"`
+		InviteTokens bool      `json:"invite_tokens"`
+		Unpublished  bool      `json:"unpublished"`
+		JoinBy       time.Time `json:"join_by"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return service.BatchCreate{}, apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    "Invalid create options",
+			Err:        err,
+		}
+	}
+
+	// Unpublished definitions are verified when published.
+	if !req.Unpublished {
+		if err := def.VerifyHashes(); err != nil {
+			return service.BatchCreate{}, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid definition hash",
+				Err:        err,
+			}
+		}
+
+		if err := def.VerifySignatures(); err != nil {
+			return service.BatchCreate{}, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid definition signature",
+				Err:        err,
+			}
+		}
+	}
+
+	return service.BatchCreate{
+		Definition: def,
+		Options: service.CreateOptions{
+			Parent:       req.Parent,
+			InviteTokens: req.InviteTokens,
+			Unpublished:  req.Unpublished,
+			JoinBy:       req.JoinBy,
+		},
+	}, nil
+}
+
+func reviseDefinition(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		// The body is the revised definition with the signed request of the creator.
+		var (
+			def cluster.Definition
+			req requestAuthJSON
+		)
+		if err := json.Unmarshal(body, &def); err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid body",
+				Err:        err,
+			}
+		} else if err := json.Unmarshal(body, &req); err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid body",
+				Err:        err,
+			}
+		}
+
+		def.ForkVersion, err = resolveNetwork(body, def.ForkVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		revised, err := svc.Revise(ctx, hash, def, req.toAuth())
+		if err != nil {
+			return nil, err
+		}
+
+		return struct {
+			ConfigHash string `json:"config_hash"`
+		}{
+			ConfigHash: fmt.Sprintf("%#x", revised),
+		}, nil
+	}
+}
+
+// editableFields are the json fields of definition patches, all other fields are part of the config hash
+// apart from the request authentication fields.
+var editableFields = map[string]bool{"dashboard_url": true, "description": true}
+
+// requestAuthFields are the json fields of the request authentication, see requestAuthJSON.
+var requestAuthFields = map[string]bool{"request_timestamp": true, "request_signature": true, "invite_token": true}
+
+func patchDefinition(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid body",
+				Err:        err,
+			}
+		}
+
+		for field := range fields {
+			if !editableFields[field] && !requestAuthFields[field] {
+				return nil, apiError{
+					StatusCode: http.StatusBadRequest,
+					Message:    fmt.Sprintf("field %s not editable, revise unpublished definitions to change hashed fields", field),
+				}
+			}
+		}
+
+		// The body contains the signed request of the creator.
+		var req struct {
+			service.MetadataPatch
+			requestAuthJSON
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid body",
+				Err:        err,
+			}
+		}
+
+		return svc.UpdateMetadata(ctx, hash, req.MetadataPatch, req.toAuth())
+	}
+}
+
+func publishDefinition(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, svc.Publish(ctx, hash)
+	}
+}
+
+func addOperator(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		req := struct {
+			operatorJSON
+			requestAuthJSON
+			ForkVersion      string
+			ForkVersionSnake string `json:"fork_version"` // Normalized field name of lenient decoding.
+			Version          string `json:"version"`
+		}{}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid body",
+				Err:        err,
+			}
+		}
+
+		if req.ForkVersion == "" {
+			req.ForkVersion = req.ForkVersionSnake
+		}
+
+		forkVersion, err := hex.DecodeString(strings.TrimPrefix(req.ForkVersion, "0x"))
+		if err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid fork version hex",
+				Err:        err,
+			}
+		}
+
+		forkVersion, err = resolveNetwork(body, forkVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := verifyENR(req.ENR); err != nil {
+			return nil, err
+		}
+
+		return nil, svc.AddOperator(ctx, hash, forkVersion, req.Version, req.toOperator(), req.toAuth())
+	}
+}
+
+// verifyENR returns a bad request error explaining why the ENR is malformed or its node record signature invalid.
+// Invalid ENRs would otherwise only fail charon peer discovery after the cluster is locked.
+func verifyENR(record string) error {
+	if record == "" {
+		return apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    "Missing operator enr",
+		}
+	}
+
+	if _, err := enr.Parse(record); err != nil {
+		return apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("Invalid operator enr: %v", err),
+			Err:        err,
+		}
+	}
+
+	return nil
+}
+
+func declineOperator(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		var req struct {
+			operatorJSON
+			requestAuthJSON
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid body",
+				Err:        err,
+			}
+		}
+
+		return nil, svc.Decline(ctx, hash, req.toOperator(), req.toAuth())
+	}
+}
+
+func removeOperator(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		// The body contains the signed request of the creator, an empty body fails as missing the signature.
+		var req requestAuthJSON
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &req); err != nil {
+				return nil, apiError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid body",
+					Err:        err,
+				}
+			}
+		}
+
+		removed, err := svc.RemoveOperator(ctx, hash, params["address"], req.toAuth())
+		if err != nil {
+			return nil, err
+		}
+
+		return struct {
+			ConfigHash string `json:"config_hash"`
+		}{
+			ConfigHash: fmt.Sprintf("%#x", removed),
+		}, nil
+	}
+}
+
+func createInvite(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		// The body contains the invited operator address and the signed request of the creator.
+		var req struct {
+			Address string `json:"address"`
+			requestAuthJSON
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid body",
+				Err:        err,
+			}
+		}
+
+		return svc.Invite(ctx, hash, req.Address, req.toAuth())
+	}
+}
+
+func finalizeDefinition(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, svc.Finalize(ctx, hash)
+	}
+}
+
+func lockDefinition(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		var lock cluster.Lock
+		if err := json.Unmarshal(body, &lock); err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid body",
+				Err:        err,
+			}
+		}
+
+		return nil, svc.Lock(ctx, hash, lock)
+	}
+}
+
+func getLock(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		lock, err := svc.GetLock(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+
+		return immutable{Body: lock}, nil
+	}
+}
+
+func getValidators(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		offset, err := intQuery(query, "offset", 0)
+		if err != nil {
+			return nil, err
+		}
+
+		limit, err := intQuery(query, "limit", defaultValidatorLimit)
+		if err != nil {
+			return nil, err
+		} else if limit < 1 || limit > maxValidatorLimit {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("limit must be between 1 and %d", maxValidatorLimit),
+			}
+		}
+
+		return svc.Validators(ctx, hash, offset, limit)
+	}
+}
+
+func getState(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return svc.State(ctx, hash)
+	}
+}
+
+// resolveNetwork returns the fork version of the optional network name in the body, e.g. "goerli",
+// or the provided fork version if the body doesn't contain a network name.
+func resolveNetwork(body []byte, forkVersion []byte) ([]byte, error) {
+	var req struct {
+		Network string `json:"network"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    "Invalid network",
+			Err:        err,
+		}
+	} else if req.Network == "" {
+		return forkVersion, nil
+	}
+
+	resolved, err := eth2util.NetworkToForkVersionBytes(req.Network)
+	if err != nil {
+		return nil, apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("Unknown network %s", req.Network),
+			Err:        err,
+		}
+	}
+
+	if len(forkVersion) > 0 && !bytes.Equal(forkVersion, resolved) {
+		return nil, apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("Fork version %#x does not match network %s", forkVersion, req.Network),
+		}
+	}
+
+	return resolved, nil
+}
+
+// verifyTermsHash returns an error if the definition body doesn't contain the expected terms and conditions hash.
+// It is a noop if the expected terms hash is empty.
+func verifyTermsHash(body []byte, expected []byte) error {
+	if len(expected) == 0 {
+		return nil
+	}
+
+	var terms struct {
+		Hash hexBytes `json:"terms_and_conditions_hash"`
+	}
+	if err := json.Unmarshal(body, &terms); err != nil {
+		return apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    "Invalid terms_and_conditions_hash",
+			Err:        err,
+		}
+	}
+
+	if len(terms.Hash) == 0 {
+		return apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    "Missing terms_and_conditions_hash, terms and conditions must be accepted",
+		}
+	} else if !bytes.Equal(terms.Hash, expected) {
+		return apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("Invalid terms_and_conditions_hash, expected %#x", expected),
+		}
+	}
+
+	return nil
+}
+
+// exitJSON is the json request body of an operator proposing or confirming a validator exit, signed by the operator.
+type exitJSON struct {
+	PubKey  string `json:"pubkey"`
+	Epoch   uint64 `json:"epoch,string"`
+	Address string `json:"address"`
+	requestAuthJSON
+}
+
+// requestAuthJSON is the json request authentication of an operator mutation request.
+type requestAuthJSON struct {
+	RequestTimestamp int64    `json:"request_timestamp,string"`
+	RequestSignature hexBytes `json:"request_signature"`
+	InviteToken      string   `json:"invite_token"`
+}
+
+func (r requestAuthJSON) toAuth() service.RequestAuth {
+	return service.RequestAuth{
+		Timestamp:   r.RequestTimestamp,
+		Signature:   r.RequestSignature,
+		InviteToken: r.InviteToken,
+	}
+}
+
+// operatorJSON is the json request body of an operator with 0x-hex encoded signatures as produced by charon.
+type operatorJSON struct {
+	Address         string   `json:"address"`
+	ENR             string   `json:"enr"`
+	ConfigSignature hexBytes `json:"config_signature"`
+	ENRSignature    hexBytes `json:"enr_signature"`
+}
+
+func (o operatorJSON) toOperator() cluster.Operator {
+	return cluster.Operator{
+		Address:         o.Address,
+		ENR:             o.ENR,
+		ConfigSignature: o.ConfigSignature,
+		ENRSignature:    o.ENRSignature,
+	}
+}
+
+// hexBytes is a byte slice that is json encoded as a 0x-prefixed hex string.
+type hexBytes []byte
+
+func (h *hexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.Wrap(err, "unmarshal hex string")
+	}
+
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return errors.Wrap(err, "decode hex")
+	}
+
+	*h = b
+
+	return nil
+}
+
+func (h hexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%#x", []byte(h)))
+}
+
+func addDepositSignatures(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		var req struct {
+			ShareIdx          int                 `json:"share_idx"`
+			PartialSignatures map[string]hexBytes `json:"partial_signatures"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid body",
+				Err:        err,
+			}
+		}
+
+		partialSigs := make(map[string][]byte)
+		for pubkey, sig := range req.PartialSignatures {
+			partialSigs[pubkey] = sig
+		}
+
+		return nil, svc.AddDepositSignatures(ctx, hash, req.ShareIdx, partialSigs)
+	}
+}
+
+func getDepositData(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		depositData, err := svc.DepositData(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+
+		return json.RawMessage(depositData), nil
+	}
+}
+
+func getValidator(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		pubkey, err := hexParam(params, "pubkey")
+		if err != nil {
+			return nil, err
+		}
+
+		return svc.GetValidator(ctx, pubkey)
+	}
+}
+
+func proposeExit(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		var req exitJSON
+		if err := json.Unmarshal(body, &req); err != nil {
 			return nil, apiError{
 				StatusCode: http.StatusBadRequest,
-				Message:    "Missing config_hash",
+				Message:    "Invalid body",
+				Err:        err,
 			}
 		}
 
-		return svc.Get(ctx, hash)
+		return nil, svc.ProposeExit(ctx, hash, req.PubKey, req.Epoch, req.Address, req.toAuth())
 	}
 }
 
-func deleteDefinition(svc service.Definition) handlerFunc {
+func confirmExit(svc service.Definition) handlerFunc {
 	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
-		hash, ok, err := hexQuery(query, "config_hash")
+		hash, err := configHash(params)
 		if err != nil {
 			return nil, err
-		} else if !ok {
+		}
+
+		var req exitJSON
+		if err := json.Unmarshal(body, &req); err != nil {
 			return nil, apiError{
 				StatusCode: http.StatusBadRequest,
-				Message:    "Missing config_hash",
+				Message:    "Invalid body",
+				Err:        err,
 			}
 		}
 
-		return nil, svc.Delete(ctx, hash)
+		return nil, svc.ConfirmExit(ctx, hash, params["pubkey"], req.Epoch, req.Address, req.toAuth())
 	}
 }
 
-func createDefinition(svc service.Definition) handlerFunc {
+func addExitSignature(svc service.Definition) handlerFunc {
 	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
-		var def cluster.Definition
-		if err := json.Unmarshal(body, &def); err != nil {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		var req struct {
+			ShareIdx       int      `json:"share_idx"`
+			ValidatorIndex uint64   `json:"validator_index,string"`
+			ForkVersion    hexBytes `json:"fork_version"`
+			Signature      hexBytes `json:"signature"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
 			return nil, apiError{
 				StatusCode: http.StatusBadRequest,
 				Message:    "Invalid body",
@@ -54,42 +1199,247 @@ func createDefinition(svc service.Definition) handlerFunc {
 			}
 		}
 
-		if err := def.VerifyHashes(); err != nil {
+		return nil, svc.AddExitSignature(ctx, hash, service.PartialExit{
+			PubKey:         params["pubkey"],
+			ShareIdx:       req.ShareIdx,
+			ValidatorIndex: req.ValidatorIndex,
+			ForkVersion:    req.ForkVersion,
+			Signature:      req.Signature,
+		})
+	}
+}
+
+func getSignedExit(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return svc.SignedExit(ctx, hash, params["pubkey"])
+	}
+}
+
+func getExits(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return svc.Exits(ctx, hash)
+	}
+}
+
+func addRegistrations(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		var regs []*eth2v1.SignedValidatorRegistration
+		if err := json.Unmarshal(body, &regs); err != nil {
 			return nil, apiError{
 				StatusCode: http.StatusBadRequest,
-				Message:    "Invalid definition hash",
+				Message:    "Invalid body",
 				Err:        err,
 			}
 		}
 
-		if err := def.VerifySignatures(); err != nil {
+		return nil, svc.AddRegistrations(ctx, hash, regs)
+	}
+}
+
+func getRegistrations(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return svc.Registrations(ctx, hash)
+	}
+}
+
+func getRegistration(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		pubkey, err := hexParam(params, "pubkey")
+		if err != nil {
+			return nil, err
+		}
+
+		return svc.GetRegistration(ctx, pubkey)
+	}
+}
+
+func getCluster(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return svc.Cluster(ctx, hash)
+	}
+}
+
+func getSummary(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return svc.Summary(ctx, hash)
+	}
+}
+
+func getCompletion(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return svc.Completion(ctx, hash)
+	}
+}
+
+func getLineage(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return svc.Lineage(ctx, hash)
+	}
+}
+
+func getHistory(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return svc.History(ctx, hash)
+	}
+}
+
+func getRevision(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		revision, err := strconv.Atoi(params["revision"])
+		if err != nil || revision < 0 {
 			return nil, apiError{
 				StatusCode: http.StatusBadRequest,
-				Message:    "Invalid definition signature",
+				Message:    "Invalid revision",
 				Err:        err,
 			}
 		}
 
-		return nil, svc.Create(ctx, def)
+		return svc.AtRevision(ctx, hash, revision)
 	}
 }
 
-func addOperator(svc service.Definition) handlerFunc {
+func getArchiveLocation(svc service.Definition) handlerFunc {
 	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
-		hash, ok, err := hexQuery(query, "config_hash")
+		hash, err := configHash(params)
 		if err != nil {
 			return nil, err
-		} else if !ok {
+		}
+
+		return svc.ArchiveLocation(ctx, hash)
+	}
+}
+
+func getDiff(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		from, err := strconv.Atoi(query.Get("from"))
+		if err != nil || from < 0 {
 			return nil, apiError{
 				StatusCode: http.StatusBadRequest,
-				Message:    "Missing config_hash",
+				Message:    "Invalid from revision",
+				Err:        err,
 			}
 		}
 
-		req := struct {
-			cluster.Operator
-			ForkVersion string
-		}{}
+		to, err := strconv.Atoi(query.Get("to"))
+		if err != nil || to < 0 {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid to revision",
+				Err:        err,
+			}
+		}
+
+		return svc.Diff(ctx, hash, from, to)
+	}
+}
+
+func exportDefinition(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return svc.Export(ctx, hash)
+	}
+}
+
+func importDefinition(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		var bundle service.Bundle
+		if err := unmarshal(body, &bundle); err != nil {
+			return nil, err
+		}
+
+		return nil, svc.Import(ctx, bundle)
+	}
+}
+
+func getStats(svc service.Definition) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		filter := service.StatsFilter{
+			Type: service.ClusterType(query.Get("type")),
+		}
+
+		if network := query.Get("network"); network != "" {
+			filter.ForkVersion, err = eth2util.NetworkToForkVersionBytes(network)
+			if err != nil {
+				return nil, apiError{
+					StatusCode: http.StatusBadRequest,
+					Message:    fmt.Sprintf("Unknown network %s", network),
+					Err:        err,
+				}
+			}
+		}
+
+		return svc.Stats(ctx, filter)
+	}
+}
+
+func createFromTemplate(defSvc service.Definition, tmplSvc service.Template, termsHash []byte) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		var req struct {
+			Name                string   `json:"name"`
+			NumValidators       int      `json:"num_validators"`
+			Operators           []string `json:"operators"`
+			FeeRecipientAddress string   `json:"fee_recipient_address"`
+			WithdrawalAddress   string   `json:"withdrawal_address"`
+		}
 		if err := json.Unmarshal(body, &req); err != nil {
 			return nil, apiError{
 				StatusCode: http.StatusBadRequest,
@@ -98,15 +1448,144 @@ func addOperator(svc service.Definition) handlerFunc {
 			}
 		}
 
-		forkVersion, err := hex.DecodeString(strings.TrimPrefix(req.ForkVersion, "0x"))
+		if err := verifyTermsHash(body, termsHash); err != nil {
+			return nil, err
+		}
+
+		def, err := tmplSvc.Stamp(ctx, params["id"], service.StampFields{
+			Name:                req.Name,
+			NumValidators:       req.NumValidators,
+			Operators:           req.Operators,
+			FeeRecipientAddress: req.FeeRecipientAddress,
+			WithdrawalAddress:   req.WithdrawalAddress,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := defSvc.Create(ctx, def, service.CreateOptions{}); err != nil {
+			return nil, err
+		}
+
+		return def, nil
+	}
+}
+
+func listTemplates(svc service.Template) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		iter, err := svc.List(ctx)
 		if err != nil {
+			return nil, err
+		}
+
+		return stream{Iter: iter}, nil
+	}
+}
+
+func createTemplate(svc service.Template) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		var tmpl service.TemplateDoc
+		if err := json.Unmarshal(body, &tmpl); err != nil {
 			return nil, apiError{
 				StatusCode: http.StatusBadRequest,
-				Message:    "Invalid fork version hex",
+				Message:    "Invalid body",
+				Err:        err,
+			}
+		}
+
+		return svc.Create(ctx, tmpl)
+	}
+}
+
+func getTemplate(svc service.Template) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		return svc.Get(ctx, params["id"])
+	}
+}
+
+func updateTemplate(svc service.Template) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		var tmpl service.TemplateDoc
+		if err := json.Unmarshal(body, &tmpl); err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid body",
 				Err:        err,
 			}
 		}
+		tmpl.ID = params["id"]
+
+		return nil, svc.Update(ctx, tmpl)
+	}
+}
+
+func deleteTemplate(svc service.Template) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		return nil, svc.Delete(ctx, params["id"])
+	}
+}
+
+func listDeadLetters(queue *notify.Queue) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		return queue.DeadLetters(ctx)
+	}
+}
+
+func replayDeadLetter(queue *notify.Queue) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		return nil, queue.Replay(ctx, params["id"])
+	}
+}
+
+// errStorageUnsupported is returned by endpoints of services not supported by the storage driver.
+var errStorageUnsupported = apiError{
+	StatusCode: http.StatusNotImplemented,
+	Message:    "not supported by storage driver",
+}
+
+func reindex(admin service.Admin) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if admin == nil {
+			return nil, errStorageUnsupported
+		}
+
+		return admin.Reindex(ctx)
+	}
+}
+
+func getReindexProgress(admin service.Admin) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if admin == nil {
+			return nil, errStorageUnsupported
+		}
+
+		return admin.ReindexProgress(), nil
+	}
+}
+
+func cleanup(admin service.Admin) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if admin == nil {
+			return nil, errStorageUnsupported
+		}
+
+		var opts service.CleanupOptions
+		if len(body) > 0 {
+			if err := unmarshal(body, &opts); err != nil {
+				return nil, err
+			}
+		}
+
+		return admin.Cleanup(ctx, opts)
+	}
+}
+
+func listAlarms(admin service.Admin) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if admin == nil {
+			return nil, errStorageUnsupported
+		}
 
-		return nil, svc.AddOperator(ctx, hash, forkVersion, req.Operator)
+		return admin.Alarms(), nil
 	}
 }
@@ -0,0 +1,72 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// statusHeader is the response header of HEAD definition requests containing the definition's status.
+	statusHeader = "X-Dvstore-Status"
+	// revisionHeader is the response header of HEAD definition requests containing the definition's revision,
+	// incremented by every mutation.
+	revisionHeader = "X-Dvstore-Revision"
+)
+
+type headKey struct{}
+
+// withHead returns a copy of the context marking HEAD requests, so handlers can skip work only needed for the body
+// or add headers summarising it.
+func withHead(ctx context.Context, r *http.Request) context.Context {
+	if r.Method != http.MethodHead {
+		return ctx
+	}
+
+	return context.WithValue(ctx, headKey{}, true)
+}
+
+// isHead returns true if the context is of a HEAD request.
+func isHead(ctx context.Context) bool {
+	ok, _ := ctx.Value(headKey{}).(bool)
+	return ok
+}
+
+// endpointMethods returns the methods an endpoint is routed for, GET endpoints also serve HEAD via the same handler.
+func endpointMethods(method string) []string {
+	if method == http.MethodGet {
+		return []string{http.MethodGet, http.MethodHead}
+	}
+
+	return []string{method}
+}
+
+// headWriter is a http.ResponseWriter of HEAD requests that discards the response body written by the GET handler,
+// deferring the status code until the body is complete so the Content-Length header is that of the GET response.
+type headWriter struct {
+	http.ResponseWriter
+	status int
+	length int
+}
+
+func (w *headWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *headWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	w.length += len(b)
+
+	return len(b), nil
+}
+
+// finish writes the deferred status code and the Content-Length header of the discarded body.
+func (w *headWriter) finish() {
+	w.WriteHeader(http.StatusOK)
+	if w.status != http.StatusNotModified && w.status != http.StatusNoContent {
+		w.Header().Set("Content-Length", strconv.Itoa(w.length))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
@@ -0,0 +1,89 @@
+package router
+
+import (
+	"context"
+	"github.com/corverroos/dvstore/service"
+	"github.com/obolnetwork/charon/app/errors"
+	"net/http"
+	"net/url"
+)
+
+// readiness is the response of the readiness endpoint.
+type readiness struct {
+	// Status is "ok", or "degraded" if the replica set has no reachable primary.
+	Status string `json:"status"`
+	// Host is the address of the mongo host serving primary traffic, empty if not a replica set.
+	Host            string              `json:"host,omitempty"`
+	ReplicaSet      *service.ReplicaSet `json:"replica_set,omitempty"`
+	ReplicaSetError string              `json:"replica_set_error,omitempty"`
+}
+
+// liveness is the response of the liveness endpoint.
+type liveness struct {
+	Status string `json:"status"`
+	// Inflight is the number of requests currently being handled, including this one.
+	Inflight int64 `json:"inflight"`
+}
+
+// live returns the liveness of the instance. It doesn't depend on mongo, so orchestrators don't restart
+// instances that are only unready because the database is unreachable.
+func live() handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		return liveness{Status: "ok", Inflight: Inflight()}, nil
+	}
+}
+
+// readyz returns the readiness of the instance, failing if mongo isn't reachable.
+// The replica set health is optionally included via the replica_set query parameter
+// so on-call can distinguish mongo being down from being degraded.
+func readyz(health service.Health) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		return checkReadiness(ctx, health, query.Get("replica_set") == "true")
+	}
+}
+
+// ready returns the readiness of the instance including the replica set health, failing if mongo isn't reachable.
+// Storage without a replica set omits its health.
+func ready(health service.Health) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		return checkReadiness(ctx, health, true)
+	}
+}
+
+// checkReadiness pings mongo and returns the readiness, including the replica set health if requested.
+func checkReadiness(ctx context.Context, health service.Health, replicaSet bool) (readiness, error) {
+	resp := readiness{Status: "ok"}
+	if health == nil {
+		return resp, nil
+	}
+
+	if err := health.Ready(ctx); err != nil {
+		return readiness{}, apiError{
+			StatusCode: http.StatusServiceUnavailable,
+			Message:    "mongo not reachable",
+			Err:        err,
+		}
+	}
+
+	// Standalone servers don't report their address.
+	resp.Host, _ = health.Host(ctx)
+
+	if !replicaSet {
+		return resp, nil
+	}
+
+	rs, err := health.ReplicaSet(ctx)
+	if errors.Is(err, service.ErrUnsupported) {
+		return resp, nil
+	} else if err != nil {
+		resp.ReplicaSetError = err.Error()
+		return resp, nil
+	}
+
+	resp.ReplicaSet = &rs
+	if !rs.PrimaryReachable {
+		resp.Status = "degraded"
+	}
+
+	return resp, nil
+}
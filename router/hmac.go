@@ -0,0 +1,84 @@
+package router
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers of HMAC signed requests.
+const (
+	hmacKeyIDHeader     = "X-Dvstore-Key-Id"
+	hmacTimestampHeader = "X-Dvstore-Timestamp"
+	hmacSignatureHeader = "X-Dvstore-Signature"
+)
+
+// HMACKey is a shared secret used by machine clients to sign requests.
+type HMACKey struct {
+	Secret string
+	Role   Role
+}
+
+// hmacSigningString returns the string signed by HMAC signed requests:
+// the method, path, unix timestamp and hex sha256 hash of the raw request body separated by newlines.
+func hmacSigningString(method, path string, timestamp int64, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return fmt.Sprintf("%s\n%s\n%d\n%x", method, path, timestamp, bodyHash)
+}
+
+// verifyHMAC returns the role of the HMAC signed request's key after verifying its timestamp and signature.
+// The request body is read and replaced so it can be read again by the handler.
+func verifyHMAC(r *http.Request, keys map[string]HMACKey, window time.Duration) (Role, error) {
+	unauthorized := func(msg string, err error) (Role, error) {
+		return "", apiError{
+			StatusCode: http.StatusUnauthorized,
+			Message:    msg,
+			Err:        err,
+		}
+	}
+
+	key, ok := keys[r.Header.Get(hmacKeyIDHeader)]
+	if !ok {
+		return unauthorized("unknown hmac key id", nil)
+	}
+
+	timestamp, err := strconv.ParseInt(r.Header.Get(hmacTimestampHeader), 10, 64)
+	if err != nil {
+		return unauthorized("invalid hmac timestamp", err)
+	}
+
+	if age := time.Since(time.Unix(timestamp, 0)); age > window || age < -window {
+		return unauthorized("hmac timestamp outside window", nil)
+	}
+
+	sig, err := hex.DecodeString(r.Header.Get(hmacSignatureHeader))
+	if err != nil {
+		return unauthorized("invalid hmac signature hex", err)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    "Invalid request body",
+			Err:        err,
+		}
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	mac := hmac.New(sha256.New, []byte(key.Secret))
+	_, _ = mac.Write([]byte(hmacSigningString(r.Method, r.URL.Path, timestamp, body)))
+
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return unauthorized("invalid hmac signature", nil)
+	}
+
+	return key.Role, nil
+}
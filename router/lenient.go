@@ -0,0 +1,71 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// camelCase matches legacy camelCase json field names, e.g. "feeRecipientAddress".
+var camelCase = regexp.MustCompile(`^[a-z]+(?:[A-Z][a-z0-9]*)+$`)
+
+// normalizeFieldNames returns the json body with all legacy camelCase object keys converted to snake_case,
+// e.g. "numValidators" to "num_validators". Other keys, including hex map keys, are not modified.
+func normalizeFieldNames(body []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return body, nil
+	}
+
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber() // Retain number precision.
+
+	var val interface{}
+	if err := d.Decode(&val); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(normalizeValue(val))
+}
+
+// normalizeValue recursively converts camelCase object keys to snake_case.
+func normalizeValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		resp := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			if camelCase.MatchString(key) {
+				key = toSnakeCase(key)
+			}
+
+			if _, ok := resp[key]; ok {
+				continue // Prefer the snake_case variant if both are present.
+			}
+			resp[key] = normalizeValue(elem)
+		}
+
+		return resp
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = normalizeValue(elem)
+		}
+
+		return v
+	default:
+		return val
+	}
+}
+
+// toSnakeCase converts a camelCase name to snake_case.
+func toSnakeCase(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			sb.WriteByte('_')
+			r += 'a' - 'A'
+		}
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
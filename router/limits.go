@@ -0,0 +1,108 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// JSONLimits defines the complexity limits of json request bodies, protecting decoding from pathological payloads.
+// Zero fields are not enforced.
+type JSONLimits struct {
+	// MaxDepth is the maximum nesting depth of objects and arrays.
+	MaxDepth int
+	// MaxArrayLen is the maximum number of elements of any array.
+	MaxArrayLen int
+	// MaxTokens is the maximum total number of json tokens.
+	MaxTokens int
+}
+
+// checkLimits returns an error if the json body exceeds the limits. It tokenizes the body without
+// decoding it into values, so it aborts early without allocating the full structure.
+func checkLimits(body []byte, limits JSONLimits) error {
+	if limits == (JSONLimits{}) || len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+
+	tooComplex := func(format string, args ...interface{}) error {
+		return apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    "request body too complex: " + fmt.Sprintf(format, args...),
+		}
+	}
+
+	var (
+		d      = json.NewDecoder(bytes.NewReader(body))
+		tokens int
+		// arrays contains the element count of each nested container, or -1 for objects.
+		arrays []int
+	)
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid json body",
+				Err:        err,
+			}
+		}
+
+		tokens++
+		if limits.MaxTokens > 0 && tokens > limits.MaxTokens {
+			return tooComplex("more than %d tokens", limits.MaxTokens)
+		}
+
+		// Count array elements, object keys are not elements and closing delimiters are not counted.
+		isClose := token == json.Delim(']') || token == json.Delim('}')
+		if n := len(arrays); n > 0 && arrays[n-1] >= 0 && !isClose {
+			arrays[n-1]++
+			if limits.MaxArrayLen > 0 && arrays[n-1] > limits.MaxArrayLen {
+				return tooComplex("array longer than %d elements", limits.MaxArrayLen)
+			}
+		}
+
+		switch token {
+		case json.Delim('['), json.Delim('{'):
+			count := 0
+			if token == json.Delim('{') {
+				count = -1
+			}
+
+			arrays = append(arrays, count)
+			if limits.MaxDepth > 0 && len(arrays) > limits.MaxDepth {
+				return tooComplex("nesting deeper than %d", limits.MaxDepth)
+			}
+		case json.Delim(']'), json.Delim('}'):
+			arrays = arrays[:len(arrays)-1]
+		}
+	}
+}
+
+// readBody reads the decoded request body, returning 413 Request Entity Too Large if it exceeds the maximum size
+// in bytes. Limiting the decoded rather than the transferred body also bounds compressed bodies expanding in memory.
+// The size is not limited if zero.
+func readBody(reader io.Reader, maxBytes int64) ([]byte, error) {
+	if maxBytes > 0 {
+		reader = io.LimitReader(reader, maxBytes+1)
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    "Invalid request body",
+			Err:        err,
+		}
+	} else if maxBytes > 0 && int64(len(body)) > maxBytes {
+		return nil, apiError{
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Message:    fmt.Sprintf("request body larger than %d bytes", maxBytes),
+		}
+	}
+
+	return body, nil
+}
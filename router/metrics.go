@@ -16,10 +16,13 @@
 package router
 
 import (
+	"context"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/obolnetwork/charon/app/promauto"
 )
@@ -32,22 +35,108 @@ var (
 		Help:      "The request latencies in seconds by endpoint",
 	}, []string{"endpoint"})
 
+	apiInflight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "obolapi",
+		Subsystem: "router",
+		Name:      "requests_in_flight",
+		Help:      "The number of requests currently being handled by endpoint",
+	}, []string{"endpoint"})
+
 	apiErrors = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "obolapi",
 		Subsystem: "router",
 		Name:      "request_error_total",
 		Help:      "The total number of request errors",
 	}, []string{"endpoint", "status_code"})
+
+	deprecatedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "obolapi",
+		Subsystem: "router",
+		Name:      "deprecated_request_total",
+		Help:      "The total number of requests to deprecated legacy routes by endpoint",
+	}, []string{"endpoint"})
+
+	usageRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "obolapi",
+		Subsystem: "router",
+		Name:      "usage_request_total",
+		Help:      "The total number of requests by client subject",
+	}, []string{"subject"})
+
+	usageErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "obolapi",
+		Subsystem: "router",
+		Name:      "usage_error_total",
+		Help:      "The total number of client and server error responses by client subject",
+	}, []string{"subject"})
+
+	tenantRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "obolapi",
+		Subsystem: "router",
+		Name:      "tenant_request_total",
+		Help:      "The total number of requests by tenant",
+	}, []string{"tenant"})
+
+	usageBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "obolapi",
+		Subsystem: "router",
+		Name:      "usage_bytes_total",
+		Help:      "The total number of request and response body bytes by client subject and direction",
+	}, []string{"subject", "direction"})
 )
 
 func incAPIErrors(endpoint string, statusCode int) {
 	apiErrors.WithLabelValues(endpoint, strconv.Itoa(statusCode)).Inc()
 }
 
-func observeAPILatency(endpoint string) func() {
+func incDeprecated(endpoint string) {
+	deprecatedRequests.WithLabelValues(endpoint).Inc()
+}
+
+func observeUsage(subject string, isErr bool, bytesIn, bytesOut int64) {
+	usageRequests.WithLabelValues(subject).Inc()
+	if isErr {
+		usageErrors.WithLabelValues(subject).Inc()
+	}
+	usageBytes.WithLabelValues(subject, "in").Add(float64(bytesIn))
+	usageBytes.WithLabelValues(subject, "out").Add(float64(bytesOut))
+}
+
+// inflight is the total number of requests currently being handled, waited for when draining.
+var inflight atomic.Int64
+
+// Inflight returns the total number of requests currently being handled.
+func Inflight() int64 {
+	return inflight.Load()
+}
+
+// trackInflight increments the endpoint's in-flight requests and returns a function that decrements it when called.
+func trackInflight(endpoint string) func() {
+	gauge := apiInflight.WithLabelValues(endpoint)
+	gauge.Inc()
+	inflight.Add(1)
+
+	return func() {
+		gauge.Dec()
+		inflight.Add(-1)
+	}
+}
+
+// observeAPILatency returns a function that observes the request latency when called.
+// The trace ID of sampled requests is attached as an exemplar, linking latency spikes to traces.
+func observeAPILatency(ctx context.Context, endpoint string) func() {
 	t0 := time.Now()
 
 	return func() {
-		apiLatency.WithLabelValues(endpoint).Observe(time.Since(t0).Seconds())
+		latency := time.Since(t0).Seconds()
+		observer := apiLatency.WithLabelValues(endpoint)
+
+		spanCtx := trace.SpanContextFromContext(ctx)
+		if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && spanCtx.IsSampled() {
+			exemplarObserver.ObserveWithExemplar(latency, prometheus.Labels{"trace_id": spanCtx.TraceID().String()})
+			return
+		}
+
+		observer.Observe(latency)
 	}
 }
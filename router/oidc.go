@@ -0,0 +1,319 @@
+package router
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksRefreshInterval is the minimum interval between fetches of the issuer's signing keys.
+	jwksRefreshInterval = time.Minute
+	// oidcTimeout is the timeout of requests to the issuer.
+	oidcTimeout = 10 * time.Second
+)
+
+// OIDCConfig defines OpenID Connect authentication of human users via the organisation's identity provider.
+type OIDCConfig struct {
+	// IssuerURL is the OIDC issuer URL used for discovery. OIDC authentication is disabled if empty.
+	IssuerURL string
+	// ClientID is the expected audience of ID tokens.
+	ClientID string
+	// GroupsClaim is the ID token claim containing the user's groups.
+	GroupsClaim string
+	// GroupRoles are the roles by group. Users are granted the most privileged role of their groups.
+	GroupRoles map[string]Role
+}
+
+// rolePriority orders roles from most to least privileged.
+var rolePriority = []Role{RoleAdmin, RoleCreator, RoleOperator, RoleReadOnly}
+
+// oidcVerifier verifies OIDC ID tokens signed by the issuer and maps their groups to roles.
+// The issuer's signing keys are discovered lazily and refreshed when an unknown key is encountered.
+type oidcVerifier struct {
+	conf OIDCConfig
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey // By key ID.
+	fetchedAt time.Time
+}
+
+// newOIDCVerifier returns a new verifier or nil if OIDC is disabled.
+func newOIDCVerifier(conf OIDCConfig) (*oidcVerifier, error) {
+	if conf.IssuerURL == "" {
+		return nil, nil
+	} else if conf.ClientID == "" {
+		return nil, errors.New("oidc client id required")
+	}
+
+	for group, role := range conf.GroupRoles {
+		if !role.Valid() {
+			return nil, errors.New("invalid oidc group role", z.Str("group", group), z.Str("role", string(role)))
+		}
+	}
+
+	return &oidcVerifier{conf: conf}, nil
+}
+
+// Identify returns the role and subject of the verified ID token.
+func (v *oidcVerifier) Identify(ctx context.Context, token string) (Role, string, error) {
+	claims, err := v.verify(ctx, token)
+	if err != nil {
+		return "", "", err
+	}
+
+	subject, _ := claims["sub"].(string)
+
+	groups := make(map[string]bool)
+	if values, ok := claims[v.conf.GroupsClaim].([]interface{}); ok {
+		for _, value := range values {
+			if group, ok := value.(string); ok {
+				groups[group] = true
+			}
+		}
+	}
+
+	for _, role := range rolePriority {
+		for group, groupRole := range v.conf.GroupRoles {
+			if groupRole == role && groups[group] {
+				return role, subject, nil
+			}
+		}
+	}
+
+	return "", "", errors.New("no role granted to oidc groups")
+}
+
+// verify returns the claims of the ID token after verifying its signature, issuer, audience and expiry.
+func (v *oidcVerifier) verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed jwt")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(err, "decode jwt signature")
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := verifyJWTSignature(header.Alg, key, digest[:], sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	if iss, _ := claims["iss"].(string); iss != v.conf.IssuerURL {
+		return nil, errors.New("unexpected jwt issuer", z.Str("iss", iss))
+	} else if !hasAudience(claims["aud"], v.conf.ClientID) {
+		return nil, errors.New("unexpected jwt audience")
+	}
+
+	now := float64(time.Now().Unix())
+	if exp, ok := claims["exp"].(float64); !ok || now >= exp {
+		return nil, errors.New("jwt expired")
+	} else if nbf, ok := claims["nbf"].(float64); ok && now < nbf {
+		return nil, errors.New("jwt not yet valid")
+	}
+
+	return claims, nil
+}
+
+// key returns the issuer's public key by ID, fetching the issuer's keys if unknown.
+func (v *oidcVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	} else if time.Since(v.fetchedAt) < jwksRefreshInterval {
+		return nil, errors.New("unknown jwt key id", z.Str("kid", kid))
+	}
+
+	keys, err := fetchJWKS(ctx, v.conf.IssuerURL)
+	if err != nil {
+		return nil, err
+	}
+	v.keys, v.fetchedAt = keys, time.Now()
+
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown jwt key id", z.Str("kid", kid))
+	}
+
+	return key, nil
+}
+
+// fetchJWKS returns the issuer's public keys by ID via OIDC discovery.
+func fetchJWKS(ctx context.Context, issuerURL string) (map[string]crypto.PublicKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, oidcTimeout)
+	defer cancel()
+
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := getJSON(ctx, strings.TrimSuffix(issuerURL, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, errors.Wrap(err, "oidc discovery")
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := getJSON(ctx, discovery.JWKSURI, &jwks); err != nil {
+		return nil, errors.Wrap(err, "fetch jwks")
+	}
+
+	resp := make(map[string]crypto.PublicKey)
+	for _, jwk := range jwks.Keys {
+		switch {
+		case jwk.Kty == "RSA":
+			n, err1 := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, err2 := base64.RawURLEncoding.DecodeString(jwk.E)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+
+			resp[jwk.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case jwk.Kty == "EC" && jwk.Crv == "P-256":
+			x, err1 := base64.RawURLEncoding.DecodeString(jwk.X)
+			y, err2 := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+
+			resp[jwk.Kid] = &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		}
+	}
+
+	return resp, nil
+}
+
+// verifyJWTSignature verifies the RS256 or ES256 signature of the sha256 digest.
+func verifyJWTSignature(alg string, key crypto.PublicKey, digest []byte, sig []byte) error {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			break
+		}
+
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, sig); err != nil {
+			return errors.Wrap(err, "invalid jwt signature")
+		}
+
+		return nil
+	case *ecdsa.PublicKey:
+		if alg != "ES256" {
+			break
+		}
+
+		if len(sig) != 64 {
+			return errors.New("invalid jwt signature length")
+		}
+
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid jwt signature")
+		}
+
+		return nil
+	}
+
+	return errors.New("unsupported jwt algorithm", z.Str("alg", alg))
+}
+
+// hasAudience returns true if the aud claim, either a string or array of strings, contains the client ID.
+func hasAudience(aud interface{}, clientID string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == clientID
+	case []interface{}:
+		for _, elem := range a {
+			if elem == clientID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// decodeSegment decodes the base64url encoded json jwt segment.
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.Wrap(err, "decode jwt segment")
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return errors.Wrap(err, "unmarshal jwt segment")
+	}
+
+	return nil
+}
+
+// getJSON gets the url and unmarshals the json response into v.
+func getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "get request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return errors.New("unexpected status", z.Int("status", resp.StatusCode))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errors.Wrap(err, "decode response")
+	}
+
+	return nil
+}
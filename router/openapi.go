@@ -0,0 +1,200 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Paths of the OpenAPI document and the optional Swagger UI.
+const (
+	openAPIPath   = "/api/docs/openapi.json"
+	swaggerUIPath = "/api/docs"
+)
+
+// pathParamRegex matches the path parameters of mux route templates, e.g. "{config_hash}".
+var pathParamRegex = regexp.MustCompile(`{(\w+)}`)
+
+// endpoint is an API endpoint of the router.
+type endpoint struct {
+	Name    string
+	Path    string
+	Method  string
+	Handler handlerFunc
+	Schema  string // Optional request body json schema.
+}
+
+// openAPIDoc is the OpenAPI 3.1 document describing the API, generated from the router's endpoints.
+type openAPIDoc struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       openAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components openAPIComponents                      `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]json.RawMessage `json:"schemas"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Deprecated  bool                       `json:"deprecated,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string          `json:"name"`
+	In       string          `json:"in"`
+	Required bool            `json:"required"`
+	Schema   json.RawMessage `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema json.RawMessage `json:"schema"`
+}
+
+// errorSchema is the json schema of errorResponse, the error model of all endpoints.
+const errorSchema = `{"type":"object","required":["code","message"],"properties":{` +
+	`"code":{"type":"integer"},"message":{"type":"string"},"errors":{"type":"array","items":{"type":"string"}},` +
+	`"request_id":{"type":"string"},` +
+	`"debug":{"type":"object","properties":{"chain":{"type":"array","items":{"type":"string"}},` +
+	`"stacktrace":{"type":"array","items":{"type":"string"}}}}}}`
+
+// problemSchema is the json schema of problemResponse, the error model of requests accepting problem details.
+const problemSchema = `{"type":"object","required":["type","title","status"],"properties":{` +
+	`"type":{"type":"string"},"title":{"type":"string"},"status":{"type":"integer"},` +
+	`"detail":{"type":"string"},"instance":{"type":"string"},"errors":{"type":"array","items":{"type":"string"}},` +
+	`"request_id":{"type":"string"},` +
+	`"debug":{"type":"object","properties":{"chain":{"type":"array","items":{"type":"string"}},` +
+	`"stacktrace":{"type":"array","items":{"type":"string"}}}}}}`
+
+// newOpenAPIDoc returns a new OpenAPI document containing the embedded request body schemas and the error models.
+func newOpenAPIDoc() *openAPIDoc {
+	doc := &openAPIDoc{
+		OpenAPI: "3.1.0",
+		Info:    openAPIInfo{Title: "dvstore", Version: strings.TrimPrefix(apiVersionPrefix, "/")},
+		Paths:   make(map[string]map[string]openAPIOperation),
+		Components: openAPIComponents{
+			Schemas: map[string]json.RawMessage{
+				"error":   json.RawMessage(errorSchema),
+				"problem": json.RawMessage(problemSchema),
+			},
+		},
+	}
+
+	for name, s := range schemas {
+		doc.Components.Schemas[schemaRef(name)] = s.Raw
+	}
+
+	return doc
+}
+
+// schemaRef returns the component name of the embedded schema file, e.g. "definition" of "definition.json".
+func schemaRef(name string) string {
+	return strings.TrimSuffix(name, ".json")
+}
+
+// add adds the endpoints at their paths with the prefix, marked deprecated if so.
+func (d *openAPIDoc) add(prefix string, endpoints []endpoint, deprecated bool) {
+	for _, e := range endpoints {
+		op := openAPIOperation{
+			OperationID: e.Name,
+			Deprecated:  deprecated,
+			Responses: map[string]openAPIResponse{
+				"200": {Description: "Success"},
+				"default": {
+					Description: "Error",
+					Content: map[string]openAPIMediaType{
+						"application/json": {Schema: json.RawMessage(`{"$ref":"#/components/schemas/error"}`)},
+						problemContentType: {Schema: json.RawMessage(`{"$ref":"#/components/schemas/problem"}`)},
+					},
+				},
+			},
+		}
+		if deprecated {
+			op.OperationID += "_legacy"
+		}
+
+		for _, match := range pathParamRegex.FindAllStringSubmatch(e.Path, -1) {
+			op.Parameters = append(op.Parameters, openAPIParameter{
+				Name:     match[1],
+				In:       "path",
+				Required: true,
+				Schema:   json.RawMessage(`{"type":"string"}`),
+			})
+		}
+
+		if e.Schema != "" {
+			op.RequestBody = &openAPIRequestBody{
+				Required: true,
+				Content: map[string]openAPIMediaType{
+					"application/json": {Schema: json.RawMessage(`{"$ref":"#/components/schemas/` + schemaRef(e.Schema) + `"}`)},
+				},
+			}
+		}
+
+		path := prefix + e.Path
+		if d.Paths[path] == nil {
+			d.Paths[path] = make(map[string]openAPIOperation)
+		}
+		d.Paths[path][strings.ToLower(e.Method)] = op
+
+		if e.Method == http.MethodGet {
+			// GET endpoints also serve HEAD, returning the headers of the GET response without its body.
+			head := op
+			head.OperationID = strings.Replace(op.OperationID, e.Name, e.Name+"_head", 1)
+			d.Paths[path]["head"] = head
+		}
+	}
+}
+
+func getOpenAPI(doc *openAPIDoc) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		return doc, nil
+	}
+}
+
+// swaggerUI is the Swagger UI page rendering the OpenAPI document, loading its assets from the jsDelivr CDN.
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>dvstore API</title>
+  <link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({url: "` + openAPIPath + `", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+// swaggerUIHandler returns a handler serving the Swagger UI page.
+func swaggerUIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		_, _ = w.Write([]byte(swaggerUI))
+	})
+}
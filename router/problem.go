@@ -0,0 +1,59 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// problemContentType is the RFC 7807 problem details media type of error responses.
+const problemContentType = "application/problem+json"
+
+type problemKey struct{}
+
+// problemMiddleware records whether the request accepts problem details error responses, storing the request
+// path as the problem instance. Other requests receive the default errorResponse.
+func problemMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.Header.Get("Accept"), problemContentType) {
+			r = r.WithContext(context.WithValue(r.Context(), problemKey{}, r.URL.Path))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// problemInstance returns the problem instance of the request and true if it accepts problem details.
+func problemInstance(ctx context.Context) (string, bool) {
+	instance, ok := ctx.Value(problemKey{}).(string)
+	return instance, ok
+}
+
+// problemResponse is the RFC 7807 problem details error response, extended with the errorResponse fields
+// that have no problem details equivalent.
+type problemResponse struct {
+	Type      string   `json:"type"`
+	Title     string   `json:"title"`
+	Status    int      `json:"status"`
+	Detail    string   `json:"detail,omitempty"`
+	Instance  string   `json:"instance,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+	RequestID string   `json:"request_id,omitempty"`
+	// Debug are the internal error details, only included in debug mode.
+	Debug *debugErrorResponse `json:"debug,omitempty"`
+}
+
+// newProblemResponse returns the problem details of the error response. Problems aren't typed beyond
+// their status code, so the type is "about:blank" and the title the status text, as per RFC 7807.
+func newProblemResponse(res errorResponse, instance string) problemResponse {
+	return problemResponse{
+		Type:      "about:blank",
+		Title:     http.StatusText(res.Code),
+		Status:    res.Code,
+		Detail:    res.Message,
+		Instance:  instance,
+		Errors:    res.Errors,
+		RequestID: res.RequestID,
+		Debug:     res.Debug,
+	}
+}
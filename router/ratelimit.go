@@ -0,0 +1,257 @@
+package router
+
+import (
+	"context"
+	"github.com/corverroos/dvstore/service"
+	"github.com/obolnetwork/charon/app/log"
+	"golang.org/x/time/rate"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// overrideRefresh is the interval at which rate limit overrides are reloaded from the database.
+	overrideRefresh = 30 * time.Second
+	// limiterIdle is the duration after which limiters of inactive subjects are pruned.
+	limiterIdle = 10 * time.Minute
+)
+
+// RateLimitConfig defines the default request rate limits by client subject.
+// Defaults are overridden per subject via the admin API.
+type RateLimitConfig struct {
+	// IPRate is the requests per second of each unauthenticated client IP. It is not limited if zero.
+	IPRate float64
+	// IPBurst is the maximum number of requests at once of each unauthenticated client IP.
+	IPBurst int
+	// KeyRate is the requests per second of each API key, HMAC key or OIDC user. It is not limited if zero.
+	KeyRate float64
+	// KeyBurst is the maximum number of requests at once of each API key, HMAC key or OIDC user.
+	KeyBurst int
+}
+
+type subjectKey struct{}
+
+// withSubject returns a copy of the context containing the rate limit subject of the authenticated client.
+func withSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+// subjectFromCtx returns the rate limit subject of the request, defaulting to its client IP if unauthenticated.
+func subjectFromCtx(r *http.Request) string {
+	if subject, ok := r.Context().Value(subjectKey{}).(string); ok {
+		return subject
+	}
+
+	return "ip:" + clientIP(r)
+}
+
+// principalFromCtx returns the subject of the authenticated client or empty if unauthenticated.
+func principalFromCtx(ctx context.Context) string {
+	subject, _ := ctx.Value(subjectKey{}).(string)
+	return subject
+}
+
+// limiterEntry is the token bucket of a subject.
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter limits the request rate by subject using in-memory token buckets,
+// so limits are per instance. Overrides are periodically reloaded from the database.
+type rateLimiter struct {
+	settings *Settings
+	store    service.RateLimits
+
+	mu        sync.Mutex
+	limiters  map[string]*limiterEntry
+	overrides map[string]service.RateLimit
+	refreshed time.Time
+}
+
+// newRateLimiter returns a new rate limiter or nil if rate limiting is disabled and not reloadable.
+// Existing token buckets are updated when the settings are.
+func newRateLimiter(settings *Settings, store service.RateLimits) *rateLimiter {
+	conf := settings.RateLimit()
+	if conf.IPRate == 0 && conf.KeyRate == 0 && store == nil && settings.fixed {
+		return nil
+	}
+
+	l := &rateLimiter{
+		settings:  settings,
+		store:     store,
+		limiters:  make(map[string]*limiterEntry),
+		overrides: make(map[string]service.RateLimit),
+	}
+	settings.subscribe(l.invalidate)
+
+	return l
+}
+
+// limit returns the rate limit of the subject.
+func (l *rateLimiter) limit(subject string) (rate.Limit, int) {
+	conf := l.settings.RateLimit()
+	r, burst := conf.KeyRate, conf.KeyBurst
+	if strings.HasPrefix(subject, "ip:") {
+		r, burst = conf.IPRate, conf.IPBurst
+	}
+
+	if override, ok := l.overrides[subject]; ok {
+		r, burst = override.Rate, override.Burst
+	}
+
+	if r == 0 {
+		return rate.Inf, 0
+	}
+
+	return rate.Limit(r), burst
+}
+
+// Allow returns true if the subject's request is within its rate limit.
+func (l *rateLimiter) Allow(subject string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.limiters[subject]
+	if !ok {
+		entry = &limiterEntry{limiter: rate.NewLimiter(l.limit(subject))}
+		l.limiters[subject] = entry
+	}
+	entry.lastSeen = time.Now()
+
+	return entry.limiter.Allow()
+}
+
+// refresh reloads the overrides from the database if stale, prunes idle limiters and applies the current limits.
+func (l *rateLimiter) refresh(ctx context.Context) {
+	l.mu.Lock()
+	stale := time.Since(l.refreshed) > overrideRefresh
+	if stale {
+		l.refreshed = time.Now()
+	}
+	l.mu.Unlock()
+
+	if !stale {
+		return
+	}
+
+	var overrides []service.RateLimit
+	if l.store != nil {
+		var err error
+		overrides, err = l.store.List(ctx)
+		if err != nil {
+			log.Warn(ctx, "Failed loading rate limit overrides", err)
+			return
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.overrides = make(map[string]service.RateLimit)
+	for _, override := range overrides {
+		l.overrides[override.Subject] = override
+	}
+
+	for subject, entry := range l.limiters {
+		if time.Since(entry.lastSeen) > limiterIdle {
+			delete(l.limiters, subject)
+			continue
+		}
+
+		limit, burst := l.limit(subject)
+		entry.limiter.SetLimit(limit)
+		entry.limiter.SetBurst(burst)
+	}
+}
+
+// invalidate results in the overrides being reloaded and the limits reapplied on the next request.
+func (l *rateLimiter) invalidate() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refreshed = time.Time{}
+}
+
+// Middleware returns a handler that rejects requests exceeding the rate limit of their subject.
+// It must wrap the handler after authentication so authenticated subjects are known.
+func (l *rateLimiter) Middleware(endpoint string, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.refresh(r.Context())
+
+		if !l.Allow(subjectFromCtx(r)) {
+			w.Header().Set("Retry-After", "1")
+			writeError(r.Context(), w, endpoint, apiError{
+				StatusCode: http.StatusTooManyRequests,
+				Message:    "rate limit exceeded",
+			})
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func listRateLimits(store service.RateLimits) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if store == nil {
+			return nil, errStorageUnsupported
+		}
+
+		return store.List(ctx)
+	}
+}
+
+func setRateLimit(store service.RateLimits, limiter *rateLimiter) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if store == nil {
+			return nil, errStorageUnsupported
+		}
+
+		var req struct {
+			Rate  float64 `json:"rate"`
+			Burst int     `json:"burst"`
+		}
+		if err := unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+
+		if err := store.Set(ctx, service.RateLimit{
+			Subject: params["subject"],
+			Rate:    req.Rate,
+			Burst:   req.Burst,
+		}); err != nil {
+			return nil, err
+		}
+		limiter.invalidate()
+
+		return nil, nil
+	}
+}
+
+func deleteRateLimit(store service.RateLimits, limiter *rateLimiter) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if store == nil {
+			return nil, errStorageUnsupported
+		}
+
+		if err := store.Delete(ctx, params["subject"]); err != nil {
+			return nil, err
+		}
+		limiter.invalidate()
+
+		return nil, nil
+	}
+}
@@ -0,0 +1,447 @@
+package router
+
+import (
+	"fmt"
+	"github.com/corverroos/dvstore/service"
+	"github.com/gorilla/mux"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"net/http"
+	"time"
+)
+
+// Role is an access control role granted to API clients.
+type Role string
+
+const (
+	// RoleAdmin may access all endpoints, including the admin endpoints.
+	RoleAdmin Role = "admin"
+	// RoleCreator may create and manage definitions and templates.
+	RoleCreator Role = "creator"
+	// RoleOperator may join definitions and submit DKG, deposit, exit and registration data.
+	RoleOperator Role = "operator"
+	// RoleReadOnly may only read.
+	RoleReadOnly Role = "readonly"
+)
+
+// Valid returns true if the role is known.
+func (r Role) Valid() bool {
+	return r == RoleAdmin || r == RoleCreator || r == RoleOperator || r == RoleReadOnly
+}
+
+// AuthMode defines whether anonymous requests of a method class are allowed.
+type AuthMode string
+
+const (
+	// AuthAnonymous allows anonymous requests, granted the anonymous role.
+	AuthAnonymous AuthMode = "anonymous"
+	// AuthUnfinalized allows anonymous reads of finalized definitions only, requiring authentication to read
+	// definitions operators are still joining. It is only valid for reads.
+	AuthUnfinalized AuthMode = "unfinalized"
+	// AuthRequired rejects anonymous requests.
+	AuthRequired AuthMode = "required"
+)
+
+// AuthConfig defines the authenticators of API clients and their roles.
+type AuthConfig struct {
+	// AdminToken is an API key granted the admin role.
+	AdminToken string
+	// APIKeys are the roles by API key. Access control is not enforced if no authenticators are configured.
+	APIKeys map[string]Role
+	// AnonymousRole is the role of requests without credentials. Anonymous requests are rejected if empty.
+	AnonymousRole Role
+	// OIDC configures authenticating users via OIDC ID tokens as an alternative to API keys.
+	OIDC OIDCConfig
+	// TenantAPIKeys are the tenants and roles by API key, scoping the key's requests to its tenant.
+	TenantAPIKeys map[string]TenantKey
+	// ScopedAPIKeys are the roles and scopes by API key, restricting the endpoints and definitions the key may access.
+	ScopedAPIKeys map[string]ScopedKey
+	// HMACKeys are the shared secrets by key ID of machine clients signing requests as an alternative to API keys.
+	HMACKeys map[string]HMACKey
+	// HMACWindow is the maximum age of HMAC signed request timestamps.
+	HMACWindow time.Duration
+	// Reads defines whether anonymous GET requests are allowed, defaults to AuthAnonymous.
+	Reads AuthMode
+	// Writes defines whether anonymous write requests are allowed, defaults to AuthAnonymous.
+	Writes AuthMode
+	// Share configures time-limited signed links granting unauthenticated read access to a single definition.
+	Share ShareConfig
+	// SIWE configures authenticating users via Sign-In with Ethereum messages signed by their wallets.
+	SIWE SIWEConfig
+	// MTLSRoles are the roles by subject common name of verified TLS client certificates.
+	MTLSRoles map[string]Role
+	// Authenticators are custom authenticators tried before the built-in ones, allowing embedders to supply their own.
+	Authenticators []Authenticator
+}
+
+// readers are the roles allowed to access read-only endpoints.
+var readers = []Role{RoleReadOnly, RoleOperator, RoleCreator}
+
+// uploaders are the roles allowed to upload in chunks, the upload's kind is authorized when it is initialized.
+var uploaders = []Role{RoleOperator, RoleCreator}
+
+// policy defines the roles, in addition to admin, allowed to access each endpoint.
+// Endpoints not included are restricted to admins.
+var policy = map[string][]Role{
+	"get_definition":            readers,
+	"get_definitions":           readers,
+	"get_definitions_by_prefix": readers,
+	"get_lock":                  readers,
+	"get_validators":            readers,
+	"get_state":                 readers,
+	"get_deposit_data":          readers,
+	"get_validator":             readers,
+	"get_exits":                 readers,
+	"get_signed_exit":           readers,
+	"get_registrations":         readers,
+	"get_registration":          readers,
+	"get_cluster":               readers,
+	"get_summary":               readers,
+	"get_completion":            readers,
+	"get_lineage":               readers,
+	"get_history":               readers,
+	"get_revision":              readers,
+	"get_revisions":             readers,
+	"get_archive_location":      readers,
+	"get_diff":                  readers,
+	"export_definition":         readers,
+	"get_stats":                 readers,
+	"list_templates":            readers,
+	"get_template":              readers,
+	"get_schema":                readers,
+	"share_definition":          {RoleCreator},
+	"create_definition":         {RoleCreator},
+	"create_definitions":        {RoleCreator},
+	"patch_definition":          {RoleCreator},
+	"delete_definition":         {RoleCreator},
+	"cancel_deletion":           {RoleCreator},
+	"restore_definition":        {RoleCreator},
+	"pin_definition":            {RoleCreator},
+	"unpin_definition":          {RoleCreator},
+	"finalize_definition":       {RoleCreator},
+	"remove_operator":           {RoleCreator},
+	"create_invite":             {RoleCreator},
+	"revise_definition":         {RoleCreator},
+	"publish_definition":        {RoleCreator},
+	"create_from_template":      {RoleCreator},
+	"create_template":           {RoleCreator},
+	"update_template":           {RoleCreator},
+	"delete_template":           {RoleCreator},
+	"add_operator":              {RoleOperator},
+	"decline_operator":          {RoleOperator},
+	"lock_definition":           {RoleOperator},
+	"add_deposit_signatures":    {RoleOperator},
+	"propose_exit":              {RoleOperator},
+	"confirm_exit":              {RoleOperator},
+	"add_exit_signature":        {RoleOperator},
+	"add_registrations":         {RoleOperator},
+	"init_definition_upload":    {RoleCreator},
+	"init_lock_upload":          {RoleOperator},
+	"get_upload":                uploaders,
+	"append_upload_part":        uploaders,
+	"commit_upload":             uploaders,
+	"abort_upload":              uploaders,
+}
+
+// unfinalizedListings are the read endpoints listing definitions by other means than the config hash path parameter.
+// They require authentication if reads of non-finalized definitions do.
+var unfinalizedListings = map[string]bool{
+	"get_definitions":           true,
+	"get_definitions_by_prefix": true,
+}
+
+// allowed returns true if the role may access the endpoint.
+func allowed(endpoint string, role Role) bool {
+	if role == RoleAdmin {
+		return true
+	}
+
+	for _, r := range policy[endpoint] {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authorizer authenticates requests and enforces the endpoint policy.
+type authorizer struct {
+	authenticators []Authenticator
+	anonymous      Role
+	share          ShareConfig
+	reads          AuthMode
+	writes         AuthMode
+	defs           service.Definition
+}
+
+// newAuthorizer returns a new authorizer or nil if access control is disabled.
+// The definition service is used to check whether definitions are finalized.
+func newAuthorizer(conf AuthConfig, defs service.Definition) (*authorizer, error) {
+	if conf.Reads == "" {
+		conf.Reads = AuthAnonymous
+	}
+	if conf.Writes == "" {
+		conf.Writes = AuthAnonymous
+	}
+
+	if conf.Reads != AuthAnonymous && conf.Reads != AuthUnfinalized && conf.Reads != AuthRequired {
+		return nil, errors.New("invalid read auth mode", z.Str("mode", string(conf.Reads)))
+	} else if conf.Writes != AuthAnonymous && conf.Writes != AuthRequired {
+		return nil, errors.New("invalid write auth mode", z.Str("mode", string(conf.Writes)))
+	}
+
+	authenticators, err := newAuthenticators(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(authenticators) == 0 {
+		if conf.Reads != AuthAnonymous || conf.Writes != AuthAnonymous {
+			return nil, errors.New("authenticated reads or writes require api keys, oidc, hmac keys, siwe, mtls or custom authenticators")
+		}
+
+		return nil, nil
+	} else if conf.AnonymousRole != "" && !conf.AnonymousRole.Valid() {
+		return nil, errors.New("invalid anonymous role", z.Str("role", string(conf.AnonymousRole)))
+	}
+
+	return &authorizer{
+		authenticators: authenticators,
+		anonymous:      conf.AnonymousRole,
+		share:          conf.Share,
+		reads:          conf.Reads,
+		writes:         conf.Writes,
+		defs:           defs,
+	}, nil
+}
+
+// newAuthenticators returns the custom authenticators followed by the configured built-in authenticators.
+func newAuthenticators(conf AuthConfig) ([]Authenticator, error) {
+	authenticators := append([]Authenticator(nil), conf.Authenticators...)
+
+	if len(conf.HMACKeys) > 0 {
+		hmacAuth, err := NewHMACAuthenticator(conf.HMACKeys, conf.HMACWindow)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, hmacAuth)
+	}
+
+	keys := make(map[string]Role)
+	for key, role := range conf.APIKeys {
+		keys[key] = role
+	}
+	if conf.AdminToken != "" {
+		keys[conf.AdminToken] = RoleAdmin
+	}
+	if len(keys) > 0 {
+		keyAuth, err := NewAPIKeyAuthenticator(keys)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, keyAuth)
+	}
+
+	if len(conf.TenantAPIKeys) > 0 {
+		tenantAuth, err := NewTenantAPIKeyAuthenticator(conf.TenantAPIKeys)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, tenantAuth)
+	}
+
+	if len(conf.ScopedAPIKeys) > 0 {
+		scopedAuth, err := NewScopedAPIKeyAuthenticator(conf.ScopedAPIKeys)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, scopedAuth)
+	}
+
+	if conf.OIDC.IssuerURL != "" {
+		jwtAuth, err := NewJWTAuthenticator(conf.OIDC)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, jwtAuth)
+	}
+
+	if conf.SIWE.Domain != "" {
+		siweAuth, err := NewSIWEAuthenticator(conf.SIWE)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, siweAuth)
+	}
+
+	if len(conf.MTLSRoles) > 0 {
+		mtlsAuth, err := NewMTLSAuthenticator(conf.MTLSRoles)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, mtlsAuth)
+	}
+
+	return authenticators, nil
+}
+
+// authenticate returns the principal of the first authenticator verifying the request's credentials.
+// It returns ErrNoCredentials if the request is anonymous.
+func (a *authorizer) authenticate(r *http.Request) (Principal, error) {
+	for _, authenticator := range a.authenticators {
+		principal, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		} else if err != nil {
+			if !errors.As(err, new(apiError)) {
+				err = apiError{
+					StatusCode: http.StatusUnauthorized,
+					Message:    "invalid credentials",
+					Err:        err,
+				}
+			}
+
+			return Principal{}, err
+		} else if !principal.Role.Valid() {
+			return Principal{}, errors.New("invalid authenticated role", z.Str("role", string(principal.Role)))
+		}
+
+		return principal, nil
+	}
+
+	if hasCredentials(r) {
+		return Principal{}, apiError{
+			StatusCode: http.StatusUnauthorized,
+			Message:    "invalid credentials",
+		}
+	}
+
+	return Principal{}, ErrNoCredentials
+}
+
+// keySubject returns the rate limit subject of the API key identified by its sha256 hash,
+// so keys can be referenced without disclosing them.
+func keySubject(keyHash [32]byte) string {
+	return fmt.Sprintf("key:%x", keyHash[:8])
+}
+
+// Middleware returns a handler that rejects requests whose role may not access the endpoint.
+func (a *authorizer) Middleware(endpoint string, next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Share links grant unauthenticated read access to a single definition.
+		if endpoint == "get_definition" && isShared(r) {
+			if err := verifyShareLink(r, a.share.Secret); err != nil {
+				writeError(r.Context(), w, endpoint, err)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		principal, err := a.authenticate(r)
+		anonymous := errors.Is(err, ErrNoCredentials)
+		if anonymous {
+			if err := a.verifyAnonymous(r, endpoint); err != nil {
+				writeError(r.Context(), w, endpoint, err)
+				return
+			} else if a.anonymous == "" {
+				writeError(r.Context(), w, endpoint, apiError{
+					StatusCode: http.StatusUnauthorized,
+					Message:    "missing credentials",
+				})
+
+				return
+			}
+
+			principal = Principal{Role: a.anonymous}
+		} else if err != nil {
+			writeError(r.Context(), w, endpoint, err)
+			return
+		}
+
+		if !allowed(endpoint, principal.Role) {
+			writeError(r.Context(), w, endpoint, apiError{
+				StatusCode: http.StatusForbidden,
+				Message:    fmt.Sprintf("role %s may not access %s", principal.Role, endpoint),
+			})
+
+			return
+		} else if !principal.Scope.allows(endpoint, mux.Vars(r)) {
+			writeError(r.Context(), w, endpoint, apiError{
+				StatusCode: http.StatusForbidden,
+				Message:    fmt.Sprintf("credentials scope may not access %s", endpoint),
+			})
+
+			return
+		}
+
+		caller := string(principal.Role)
+		if anonymous {
+			caller = anonymousCaller
+		}
+
+		ctx := withCaller(r.Context(), caller)
+		if principal.Subject != "" {
+			ctx = withSubject(ctx, principal.Subject)
+		}
+		if principal.Tenant != "" {
+			ctx = withBoundTenant(ctx, principal.Tenant)
+		}
+		if principal.Role == RoleAdmin && !anonymous {
+			ctx = service.WithAdmin(ctx)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// verifyAnonymous returns an error if the anonymous request requires authentication
+// according to the auth mode of its method class.
+func (a *authorizer) verifyAnonymous(r *http.Request, endpoint string) error {
+	required := apiError{
+		StatusCode: http.StatusUnauthorized,
+		Message:    "authentication required",
+	}
+
+	mode := a.writes
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		mode = a.reads
+	}
+
+	switch mode {
+	case AuthRequired:
+		return required
+	case AuthUnfinalized:
+		if unfinalizedListings[endpoint] || r.URL.Query().Get("status") == string(service.StatusUnpublished) {
+			return required
+		}
+
+		if _, ok := mux.Vars(r)["config_hash"]; !ok {
+			return nil // Endpoint doesn't read a definition.
+		}
+
+		hash, err := configHash(mux.Vars(r))
+		if err != nil {
+			return err
+		}
+
+		_, final, _, err := a.defs.Get(r.Context(), hash)
+		if errors.Is(err, service.ErrNotFound) {
+			return nil // Handler responds not found.
+		} else if err != nil {
+			return err
+		} else if !final {
+			required.Message = "authentication required to read non-finalized definitions"
+			return required
+		}
+	}
+
+	return nil
+}
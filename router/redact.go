@@ -0,0 +1,121 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/obolnetwork/charon/app/errors"
+)
+
+// anonymousCaller is the redaction policy caller of unauthenticated requests, including all requests when
+// access control is disabled.
+const anonymousCaller = "anonymous"
+
+// RedactionPolicy defines the json fields stripped from responses by caller, either a role or "anonymous".
+// Fields are stripped at any depth, e.g. "enr" strips the ENRs of all operators.
+type RedactionPolicy map[string][]string
+
+// Validate returns an error if the policy contains unknown callers.
+func (p RedactionPolicy) Validate() error {
+	for caller := range p {
+		if caller != anonymousCaller && !Role(caller).Valid() {
+			return errors.New("invalid redaction caller, expected role or anonymous")
+		}
+	}
+
+	return nil
+}
+
+type callerKey struct{}
+
+// withCaller returns a copy of the context containing the redaction policy caller of the request.
+func withCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// callerFromCtx returns the redaction policy caller of the request, defaulting to anonymous.
+func callerFromCtx(ctx context.Context) string {
+	if caller, ok := ctx.Value(callerKey{}).(string); ok {
+		return caller
+	}
+
+	return anonymousCaller
+}
+
+// redact returns the json encoded response with the fields stripped at any depth.
+// Object keys of redacted responses are sorted.
+func redact(response interface{}, fields []string) (json.RawMessage, error) {
+	b, err := json.Marshal(response)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal response")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber() // Retain the precision of large integers.
+
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, errors.Wrap(err, "unmarshal response")
+	}
+
+	strip := make(map[string]bool)
+	for _, field := range fields {
+		strip[field] = true
+	}
+
+	b, err = json.Marshal(stripFields(value, strip))
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal redacted response")
+	}
+
+	return b, nil
+}
+
+// redactResponse returns the handler response with the fields stripped, retaining whether it is immutable or tagged.
+func redactResponse(response interface{}, fields []string) (interface{}, error) {
+	if response == nil {
+		return nil, nil
+	}
+
+	if t, ok := response.(tagged); ok {
+		b, err := redactResponse(t.Body, fields)
+		if err != nil {
+			return nil, err
+		}
+
+		t.Body = b
+
+		return t, nil
+	}
+
+	if imm, ok := response.(immutable); ok {
+		b, err := redact(imm.Body, fields)
+		if err != nil {
+			return nil, err
+		}
+
+		return immutable{Body: b}, nil
+	}
+
+	return redact(response, fields)
+}
+
+// stripFields recursively deletes the fields from the decoded json objects of the value.
+func stripFields(value interface{}, strip map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, elem := range v {
+			if strip[key] {
+				delete(v, key)
+				continue
+			}
+			v[key] = stripFields(elem, strip)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = stripFields(elem, strip)
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,52 @@
+package router
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
+	"net/http"
+	"regexp"
+)
+
+// requestIDHeader is the request and response header containing the request ID.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDRegex matches the client supplied request IDs that are accepted, others are replaced.
+var requestIDRegex = regexp.MustCompile(`^[\w.:-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// requestIDMiddleware assigns each request an ID, either the client supplied X-Request-ID or a new random ID.
+// The ID is returned in the X-Request-ID response header and error bodies, logged and recorded in audit entries,
+// correlating client reported failures with server logs.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDRegex.MatchString(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = log.WithCtx(ctx, z.Str("request_id", id))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestIDFromCtx returns the request ID of the context, empty if not assigned.
+func requestIDFromCtx(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID returns a new random request ID.
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+
+	return hex.EncodeToString(b[:])
+}
@@ -1,64 +1,596 @@
 package router
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"github.com/corverroos/dvstore/notify"
 	"github.com/corverroos/dvstore/service"
 	"github.com/gorilla/mux"
 	"github.com/obolnetwork/charon/app/errors"
 	"github.com/obolnetwork/charon/app/log"
 	"github.com/obolnetwork/charon/app/z"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 )
 
-func NewRouter(defSvc service.Definition) (*mux.Router, error) {
-	endpoints := []struct {
-		Name    string
-		Path    string
-		Method  string
-		Handler handlerFunc
-	}{
+const (
+	// ndjsonContentType is the newline delimited json content type of streamed responses.
+	ndjsonContentType = "application/x-ndjson"
+	// streamFlushInterval is the minimum interval between flushes of streamed responses.
+	streamFlushInterval = 500 * time.Millisecond
+	// maxQueryHashes is the maximum number of config hashes queried in a single request.
+	maxQueryHashes = 20
+	// maxBatchSize is the maximum number of definitions created in a single batch request.
+	maxBatchSize = 20
+	// minHashPrefixLen is the minimum number of hex characters of config hash prefix lookups.
+	minHashPrefixLen = 6
+	// maxPrefixResults is the maximum number of definitions returned by config hash prefix lookups.
+	maxPrefixResults = 10
+	// defaultSearchLimit is the default number of definitions returned per page of search results.
+	defaultSearchLimit = 20
+	// maxSearchLimit is the maximum number of definitions returned per page of search results.
+	maxSearchLimit = 100
+	// defaultListLimit is the default number of definitions returned per page of listed definitions.
+	defaultListLimit = 20
+	// maxListLimit is the maximum number of definitions returned per page of listed definitions.
+	maxListLimit = 100
+	// defaultValidatorLimit is the default number of validators returned per page of lock validators.
+	defaultValidatorLimit = 100
+	// maxValidatorLimit is the maximum number of validators returned per page of lock validators.
+	maxValidatorLimit = 1000
+	// apiVersionPrefix is the path prefix of the current API version, unprefixed paths are deprecated.
+	apiVersionPrefix = "/v1"
+)
+
+// Config defines the router configuration.
+type Config struct {
+	// TermsHash is the 0x-hex hash of the terms and conditions that created definitions must have accepted.
+	// It is not enforced if empty.
+	TermsHash string
+	// SlowRequest is the duration after which requests are logged as slow. Slow requests are not logged if zero.
+	SlowRequest time.Duration
+	// RequestTimeout is the maximum duration of handling requests, including their database operations.
+	// Requests are not timed out if zero.
+	RequestTimeout time.Duration
+	// EndpointTimeouts override the request timeout by endpoint name, disabling it if zero.
+	EndpointTimeouts map[string]time.Duration
+	// Lenient enables accepting legacy camelCase json field names in request bodies by normalizing them to snake_case.
+	Lenient bool
+	// ReadOnly disables all write endpoints, returning 405 Method Not Allowed.
+	ReadOnly bool
+	// Abuse configures automatic banning of abusive clients.
+	Abuse AbuseConfig
+	// Auth configures role-based access control and the admin endpoints.
+	Auth AuthConfig
+	// LegacySunset is the date after which legacy unversioned routes will be removed.
+	// The Sunset header is not set if zero.
+	LegacySunset time.Time
+	// ValidateSchemas enables validating request bodies against the published json schemas before decoding.
+	ValidateSchemas bool
+	// MaxBodyBytes is the maximum size of decoded request bodies. It is not enforced if zero.
+	MaxBodyBytes int64
+	// JSONLimits are the complexity limits of json request bodies.
+	JSONLimits JSONLimits
+	// RateLimit configures the default request rate limits by client.
+	RateLimit RateLimitConfig
+	// Settings are the rate limits and allowed CORS origins reloaded without restarting the server, overriding
+	// RateLimit and CORS.AllowedOrigins. They are not reloadable if nil.
+	Settings *Settings
+	// ActiveConfig returns the active reloadable config served by the admin config endpoint.
+	ActiveConfig func() interface{}
+	// Debug configures including internal error details in error responses.
+	Debug DebugConfig
+	// Redaction defines the fields stripped from responses by caller. Responses are not redacted if empty.
+	Redaction RedactionPolicy
+	// CORS configures cross-origin requests of browser frontends.
+	CORS CORSConfig
+	// UI enables the embedded web UI at /ui for browsing stored clusters.
+	UI bool
+	// SwaggerUI enables the Swagger UI rendering the OpenAPI document at /api/docs.
+	SwaggerUI bool
+	// RequireIfMatch requires the If-Match header when adding operators to or deleting definitions,
+	// rejecting writes based on a stale read. The header is always honored if present.
+	RequireIfMatch bool
+	// SigningKey signs the response bodies of definitions and locks, its public key is served at a well-known
+	// path. Responses are not signed if nil.
+	SigningKey ed25519.PrivateKey
+}
+
+func NewRouter(defSvc service.Definition, tmplSvc service.Template, health service.Health, admin service.Admin, limits service.RateLimits, captures service.Captures, audit service.Audit, uploads service.Uploads, queue *notify.Queue, conf Config) (*mux.Router, error) {
+	termsHash, err := hex.DecodeString(strings.TrimPrefix(conf.TermsHash, "0x"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid terms hash")
+	}
+
+	endpoints := []endpoint{
 		{
 			Name:    "get_definition",
 			Method:  http.MethodGet,
 			Path:    "/dv/{config_hash}",
 			Handler: getDefinition(defSvc),
 		},
+		{
+			Name:    "get_definitions",
+			Method:  http.MethodGet,
+			Path:    "/dv",
+			Handler: getDefinitions(defSvc),
+		},
+		{
+			Name:    "get_definitions_by_prefix",
+			Method:  http.MethodGet,
+			Path:    "/dv/prefix/{prefix}",
+			Handler: getDefinitionsByPrefix(defSvc),
+		},
+		{
+			Name:    "patch_definition",
+			Method:  http.MethodPatch,
+			Path:    "/dv/{config_hash}",
+			Handler: patchDefinition(defSvc),
+		},
 		{
 			Name:    "delete_definition",
 			Method:  http.MethodDelete,
 			Path:    "/dv/{config_hash}",
 			Handler: deleteDefinition(defSvc),
 		},
+		{
+			Name:    "cancel_deletion",
+			Method:  http.MethodDelete,
+			Path:    "/dv/{config_hash}/deletion",
+			Handler: cancelDeletion(defSvc),
+		},
+		{
+			Name:    "restore_definition",
+			Method:  http.MethodPost,
+			Path:    "/dv/{config_hash}/restore",
+			Handler: restoreDefinition(defSvc),
+		},
+		{
+			Name:    "pin_definition",
+			Method:  http.MethodPut,
+			Path:    "/dv/{config_hash}/pin",
+			Handler: pinDefinition(defSvc, true),
+		},
+		{
+			Name:    "unpin_definition",
+			Method:  http.MethodDelete,
+			Path:    "/dv/{config_hash}/pin",
+			Handler: pinDefinition(defSvc, false),
+		},
 		{
 			Name:    "create_definition",
 			Method:  http.MethodPost,
 			Path:    "/dv",
-			Handler: createDefinition(defSvc),
+			Handler: createDefinition(defSvc, termsHash),
+			Schema:  schemaDefinition,
+		},
+		{
+			Name:    "create_definitions",
+			Method:  http.MethodPost,
+			Path:    "/dv/batch",
+			Handler: createDefinitions(defSvc, termsHash),
+		},
+		{
+			Name:    "revise_definition",
+			Method:  http.MethodPut,
+			Path:    "/dv/{config_hash}/unpublished",
+			Handler: reviseDefinition(defSvc),
+		},
+		{
+			Name:    "publish_definition",
+			Method:  http.MethodPost,
+			Path:    "/dv/{config_hash}/publish",
+			Handler: publishDefinition(defSvc),
 		},
 		{
 			Name:    "add_operator",
 			Method:  http.MethodPut,
 			Path:    "/dv/{config_hash}",
 			Handler: addOperator(defSvc),
+			Schema:  schemaOperator,
+		},
+		{
+			Name:    "create_invite",
+			Method:  http.MethodPost,
+			Path:    "/dv/{config_hash}/invites",
+			Handler: createInvite(defSvc),
+		},
+		{
+			Name:    "decline_operator",
+			Method:  http.MethodPost,
+			Path:    "/dv/{config_hash}/decline",
+			Handler: declineOperator(defSvc),
+		},
+		{
+			Name:    "remove_operator",
+			Method:  http.MethodDelete,
+			Path:    "/dv/{config_hash}/operator/{address}",
+			Handler: removeOperator(defSvc),
+		},
+		{
+			Name:    "finalize_definition",
+			Method:  http.MethodPost,
+			Path:    "/dv/{config_hash}/finalize",
+			Handler: finalizeDefinition(defSvc),
+		},
+		{
+			Name:    "lock_definition",
+			Method:  http.MethodPost,
+			Path:    "/dv/{config_hash}/lock",
+			Handler: lockDefinition(defSvc),
+		},
+		{
+			Name:    "init_lock_upload",
+			Method:  http.MethodPost,
+			Path:    "/dv/{config_hash}/lock/upload",
+			Handler: initUpload(uploads, service.UploadLock),
+		},
+		{
+			Name:    "get_lock",
+			Method:  http.MethodGet,
+			Path:    "/dv/{config_hash}/lock",
+			Handler: getLock(defSvc),
+		},
+		{
+			Name:    "get_validators",
+			Method:  http.MethodGet,
+			Path:    "/dv/{config_hash}/validators",
+			Handler: getValidators(defSvc),
+		},
+		{
+			Name:    "get_state",
+			Method:  http.MethodGet,
+			Path:    "/dv/{config_hash}/state",
+			Handler: getState(defSvc),
+		},
+		{
+			Name:    "add_deposit_signatures",
+			Method:  http.MethodPost,
+			Path:    "/dv/{config_hash}/deposit",
+			Handler: addDepositSignatures(defSvc),
+		},
+		{
+			Name:    "get_deposit_data",
+			Method:  http.MethodGet,
+			Path:    "/dv/{config_hash}/deposit",
+			Handler: getDepositData(defSvc),
+		},
+		{
+			Name:    "get_validator",
+			Method:  http.MethodGet,
+			Path:    "/validator/{pubkey}",
+			Handler: getValidator(defSvc),
+		},
+		{
+			Name:    "propose_exit",
+			Method:  http.MethodPost,
+			Path:    "/dv/{config_hash}/exits",
+			Handler: proposeExit(defSvc),
+		},
+		{
+			Name:    "confirm_exit",
+			Method:  http.MethodPost,
+			Path:    "/dv/{config_hash}/exits/{pubkey}/confirm",
+			Handler: confirmExit(defSvc),
+		},
+		{
+			Name:    "add_exit_signature",
+			Method:  http.MethodPost,
+			Path:    "/dv/{config_hash}/exits/{pubkey}/signatures",
+			Handler: addExitSignature(defSvc),
+		},
+		{
+			Name:    "get_signed_exit",
+			Method:  http.MethodGet,
+			Path:    "/dv/{config_hash}/exits/{pubkey}/signed",
+			Handler: getSignedExit(defSvc),
+		},
+		{
+			Name:    "get_exits",
+			Method:  http.MethodGet,
+			Path:    "/dv/{config_hash}/exits",
+			Handler: getExits(defSvc),
+		},
+		{
+			Name:    "add_registrations",
+			Method:  http.MethodPost,
+			Path:    "/dv/{config_hash}/registrations",
+			Handler: addRegistrations(defSvc),
+		},
+		{
+			Name:    "get_registrations",
+			Method:  http.MethodGet,
+			Path:    "/dv/{config_hash}/registrations",
+			Handler: getRegistrations(defSvc),
+		},
+		{
+			Name:    "get_registration",
+			Method:  http.MethodGet,
+			Path:    "/validator/{pubkey}/registration",
+			Handler: getRegistration(defSvc),
+		},
+		{
+			Name:    "get_cluster",
+			Method:  http.MethodGet,
+			Path:    "/cluster/{config_hash}",
+			Handler: getCluster(defSvc),
+		},
+		{
+			Name:    "get_summary",
+			Method:  http.MethodGet,
+			Path:    "/dv/{config_hash}/summary",
+			Handler: getSummary(defSvc),
+		},
+		{
+			Name:    "get_completion",
+			Method:  http.MethodGet,
+			Path:    "/dv/{config_hash}/status",
+			Handler: getCompletion(defSvc),
+		},
+		{
+			Name:    "share_definition",
+			Path:    "/dv/{config_hash}/share",
+			Method:  http.MethodPost,
+			Handler: shareDefinition(defSvc, conf.Auth.Share),
+		},
+		{
+			Name:    "get_lineage",
+			Method:  http.MethodGet,
+			Path:    "/dv/{config_hash}/lineage",
+			Handler: getLineage(defSvc),
+		},
+		{
+			Name:    "get_history",
+			Method:  http.MethodGet,
+			Path:    "/dv/{config_hash}/history",
+			Handler: getHistory(defSvc),
+		},
+		{
+			Name:    "get_revision",
+			Method:  http.MethodGet,
+			Path:    "/dv/{config_hash}/history/{revision}",
+			Handler: getRevision(defSvc),
+		},
+		{
+			Name:    "get_archive_location",
+			Method:  http.MethodGet,
+			Path:    "/dv/{config_hash}/archive",
+			Handler: getArchiveLocation(defSvc),
+		},
+		{
+			Name:    "get_revisions",
+			Method:  http.MethodGet,
+			Path:    "/dv/{config_hash}/revisions",
+			Handler: getHistory(defSvc),
+		},
+		{
+			Name:    "get_diff",
+			Method:  http.MethodGet,
+			Path:    "/dv/{config_hash}/diff",
+			Handler: getDiff(defSvc),
+		},
+		{
+			Name:    "export_definition",
+			Method:  http.MethodGet,
+			Path:    "/dv/{config_hash}/export",
+			Handler: exportDefinition(defSvc),
+		},
+		{
+			Name:    "import_definition",
+			Method:  http.MethodPost,
+			Path:    "/dv/import",
+			Handler: importDefinition(defSvc),
+		},
+		{
+			Name:    "init_definition_upload",
+			Method:  http.MethodPost,
+			Path:    "/uploads/definition",
+			Handler: initUpload(uploads, service.UploadDefinition),
+		},
+		{
+			Name:    "get_upload",
+			Method:  http.MethodGet,
+			Path:    "/uploads/{id}",
+			Handler: getUpload(uploads),
 		},
+		{
+			Name:    "append_upload_part",
+			Method:  http.MethodPut,
+			Path:    "/uploads/{id}/parts/{index}",
+			Handler: appendUploadPart(uploads),
+		},
+		{
+			Name:    "commit_upload",
+			Method:  http.MethodPost,
+			Path:    "/uploads/{id}/commit",
+			Handler: commitUpload(uploads, createDefinition(defSvc, termsHash), lockDefinition(defSvc), conf.JSONLimits),
+		},
+		{
+			Name:    "abort_upload",
+			Method:  http.MethodDelete,
+			Path:    "/uploads/{id}",
+			Handler: abortUpload(uploads),
+		},
+		{
+			Name:    "get_stats",
+			Method:  http.MethodGet,
+			Path:    "/stats",
+			Handler: getStats(defSvc),
+		},
+		{
+			Name:    "create_from_template",
+			Method:  http.MethodPost,
+			Path:    "/dv/from-template/{id}",
+			Handler: createFromTemplate(defSvc, tmplSvc, termsHash),
+		},
+		{
+			Name:    "list_templates",
+			Method:  http.MethodGet,
+			Path:    "/templates",
+			Handler: listTemplates(tmplSvc),
+		},
+		{
+			Name:    "create_template",
+			Method:  http.MethodPost,
+			Path:    "/templates",
+			Handler: createTemplate(tmplSvc),
+		},
+		{
+			Name:    "get_template",
+			Method:  http.MethodGet,
+			Path:    "/templates/{id}",
+			Handler: getTemplate(tmplSvc),
+		},
+		{
+			Name:    "update_template",
+			Method:  http.MethodPut,
+			Path:    "/templates/{id}",
+			Handler: updateTemplate(tmplSvc),
+		},
+		{
+			Name:    "get_schema",
+			Method:  http.MethodGet,
+			Path:    "/schemas/{name}",
+			Handler: getSchema,
+		},
+		{
+			Name:    "delete_template",
+			Method:  http.MethodDelete,
+			Path:    "/templates/{id}",
+			Handler: deleteTemplate(tmplSvc),
+		},
+	}
+
+	bans := newBanlist(conf.Abuse)
+	settings := conf.Settings
+	if settings == nil {
+		settings = NewSettings(conf.RateLimit, conf.CORS.AllowedOrigins)
+		settings.fixed = true
 	}
+	limiter := newRateLimiter(settings, limits)
+
+	auth, err := newAuthorizer(conf.Auth, defSvc)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conf.Redaction.Validate(); err != nil {
+		return nil, err
+	}
+
+	usage := newUsageTracker(auth)
 
 	r := mux.NewRouter()
+	r.Use(requestIDMiddleware)
+	r.Use(problemMiddleware)
 	for _, e := range endpoints {
-		r.Handle(e.Path, wrap(e.Name, e.Handler)).Methods(e.Method)
+		if conf.ValidateSchemas && e.Schema != "" {
+			e.Handler = validated(e.Schema, e.Handler)
+		}
+		if conf.ReadOnly && e.Method != http.MethodGet {
+			e.Handler = readOnly
+		}
+		handler := bans.Middleware(e.Name, captureMiddleware(e.Name, captures, auth.Middleware(e.Name, usage.Middleware(limiter.Middleware(e.Name, wrap(e.Name, e.Handler, conf))))))
+		r.Handle(apiVersionPrefix+e.Path, handler).Methods(endpointMethods(e.Method)...)
+		r.Handle(tenantPathPrefix+apiVersionPrefix+e.Path, handler).Methods(endpointMethods(e.Method)...)
+		r.Handle(e.Path, deprecated(e.Name, conf.LegacySunset, handler)).Methods(endpointMethods(e.Method)...)
 	}
 
+	docs := newOpenAPIDoc()
+	docs.add(apiVersionPrefix, endpoints, false)
+	docs.add("", endpoints, true)
+
+	// The health endpoints are unversioned and don't require authentication for orchestration probes.
+	r.Handle("/readyz", wrap("readyz", readyz(health), conf)).Methods(http.MethodGet)
+	r.Handle("/health/live", wrap("health_live", live(), conf)).Methods(http.MethodGet)
+	r.Handle("/health/ready", wrap("health_ready", ready(health), conf)).Methods(http.MethodGet)
+
+	if conf.UI {
+		r.Handle(strings.TrimSuffix(uiPath, "/"), http.RedirectHandler(uiPath, http.StatusMovedPermanently)).Methods(http.MethodGet)
+		r.PathPrefix(uiPath).Handler(uiHandler()).Methods(http.MethodGet, http.MethodHead)
+	}
+
+	// Admin endpoints are only available when access control is enabled.
+	if auth != nil {
+		adminEndpoints := []endpoint{
+			{Name: "list_bans", Path: "/admin/bans", Method: http.MethodGet, Handler: listBans(bans)},
+			{Name: "unban", Path: "/admin/bans/{client}", Method: http.MethodDelete, Handler: unban(bans)},
+			{Name: "list_dead_letters", Path: "/admin/dead-letters", Method: http.MethodGet, Handler: listDeadLetters(queue)},
+			{Name: "replay_dead_letter", Path: "/admin/dead-letters/{id}/replay", Method: http.MethodPost, Handler: replayDeadLetter(queue)},
+			{Name: "reindex", Path: "/admin/reindex", Method: http.MethodPost, Handler: reindex(admin)},
+			{Name: "get_reindex_progress", Path: "/admin/reindex", Method: http.MethodGet, Handler: getReindexProgress(admin)},
+			{Name: "cleanup", Path: "/admin/cleanup", Method: http.MethodPost, Handler: cleanup(admin)},
+			{Name: "list_alarms", Path: "/admin/alarms", Method: http.MethodGet, Handler: listAlarms(admin)},
+			{Name: "list_captures", Path: "/admin/captures", Method: http.MethodGet, Handler: listCaptures(captures)},
+			{Name: "get_capture", Path: "/admin/captures/{id}", Method: http.MethodGet, Handler: getCapture(captures)},
+			{Name: "get_audit", Path: "/dv/{config_hash}/audit", Method: http.MethodGet, Handler: getAudit(audit)},
+			{Name: "list_usage", Path: "/admin/usage", Method: http.MethodGet, Handler: listUsage(usage)},
+			{Name: "list_rate_limits", Path: "/admin/rate-limits", Method: http.MethodGet, Handler: listRateLimits(limits)},
+			{Name: "set_rate_limit", Path: "/admin/rate-limits/{subject}", Method: http.MethodPut, Handler: setRateLimit(limits, limiter)},
+			{Name: "delete_rate_limit", Path: "/admin/rate-limits/{subject}", Method: http.MethodDelete, Handler: deleteRateLimit(limits, limiter)},
+			{Name: "get_config", Path: "/admin/config", Method: http.MethodGet, Handler: getConfig(conf.ActiveConfig)},
+		}
+		for _, e := range adminEndpoints {
+			r.Handle(e.Path, auth.Middleware(e.Name, wrap(e.Name, e.Handler, conf))).Methods(endpointMethods(e.Method)...)
+		}
+
+		// The archive is a streamed gzipped tar file rather than a json response.
+		exportHandler := auth.Middleware("export_archive", wrapTrace("export_archive", exportArchive(defSvc, audit)))
+		r.Handle("/admin/export", exportHandler).Methods(http.MethodGet)
+		r.Handle(tenantPathPrefix+"/admin/export", exportHandler).Methods(http.MethodGet)
+		docs.add("", adminEndpoints, false)
+
+		scoped := append(append([]endpoint{{Name: "export_archive"}}, endpoints...), adminEndpoints...)
+		if err := verifyScopes(conf.Auth.ScopedAPIKeys, scoped); err != nil {
+			return nil, err
+		}
+	}
+
+	// The API documentation is public, like the schemas it references.
+	r.Handle(openAPIPath, wrap("get_openapi", getOpenAPI(docs), conf)).Methods(http.MethodGet)
+	if conf.SwaggerUI {
+		r.Handle(swaggerUIPath, swaggerUIHandler()).Methods(http.MethodGet)
+	}
+	if conf.SigningKey != nil {
+		r.Handle(signingKeyPath, wrap("get_signing_key", getSigningKey(conf.SigningKey), conf)).Methods(http.MethodGet)
+	}
+
+	registerCORS(r, conf.CORS, settings)
+
 	return r, nil
 }
 
+// deprecated returns a handler that marks responses of legacy routes as deprecated
+// via the Deprecation and Sunset headers and counts their usage.
+func deprecated(endpoint string, sunset time.Time, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		incDeprecated(endpoint)
+
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", apiVersionPrefix, r.URL.Path))
+		if !sunset.IsZero() {
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readOnly is the handler of write endpoints in read-only mode.
+func readOnly(context.Context, map[string]string, url.Values, []byte) (interface{}, error) {
+	return nil, apiError{
+		StatusCode: http.StatusMethodNotAllowed,
+		Message:    "writes are disabled on this read-only instance",
+	}
+}
+
 // apiErr defines a validator api error that is converted to an eth2 errorResponse.
 type apiError struct {
 	// StatusCode is the http status code to return, defaults to 500.
@@ -67,6 +599,8 @@ type apiError struct {
 	Message string
 	// Err is the original error, returned in debug mode.
 	Err error
+	// Errors are optional detailed safe human-readable errors, e.g., schema validation errors by json path.
+	Errors []string
 }
 
 func (a apiError) Error() string {
@@ -78,31 +612,124 @@ func (a apiError) Error() string {
 type handlerFunc func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error)
 
 // wrap adapts the handler function returning a standard http handler.
-// It does tracing, metrics and response and error writing.
-func wrap(endpoint string, handler handlerFunc) http.Handler {
+// It does tracing, metrics, slow request logging, json complexity limits, lenient field name decoding
+// and response and error writing.
+func wrap(endpoint string, handler handlerFunc, conf Config) http.Handler {
 	wrap := func(w http.ResponseWriter, r *http.Request) {
-		defer observeAPILatency(endpoint)()
+		defer observeAPILatency(r.Context(), endpoint)()
+		defer trackInflight(endpoint)()
 
 		ctx := r.Context()
 		ctx = log.WithTopic(ctx, "router")
 		ctx = log.WithCtx(ctx, z.Str("endpoint", endpoint))
 		ctx = withCtxDuration(ctx)
+		ctx = service.WithDBTimer(ctx)
+		ctx = withDebug(ctx, r, conf.Debug)
+		ctx = withAcceptVersion(ctx, r)
+		ctx = withSigner(ctx, endpoint, conf.SigningKey)
+		ctx = withHead(ctx, r)
+
+		tenantCtx, err := withTenant(ctx, r)
+		if err != nil {
+			writeError(ctx, w, endpoint, err)
+			return
+		}
+		ctx = tenantCtx
+
+		if conf.SlowRequest > 0 {
+			defer logSlowRequest(ctx, r, conf.SlowRequest)
+		}
+
+		if timeout := conf.requestTimeout(endpoint); timeout > 0 {
+			// Cancels the underlying mongo operations once exceeded.
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		if r.Method == http.MethodHead {
+			// HEAD requests are served by the GET handler, discarding the body after the encoder completed it.
+			hw := &headWriter{ResponseWriter: w}
+			defer hw.finish()
+			w = hw
+		}
+
+		w, closeEncoder := encodeResponse(w, r)
+		defer func() {
+			if err := closeEncoder(); err != nil {
+				log.Warn(ctx, "Failed closing response encoder", err)
+			}
+		}()
 
 		contentType := r.Header.Get("Content-Type")
-		if contentType != "" && !strings.Contains(contentType, "application/json") {
+		sszBody := strings.Contains(contentType, sszContentType)
+		yamlBody := isYAML(contentType)
+		if contentType != "" && !sszBody && !yamlBody && !strings.Contains(contentType, "application/json") {
 			writeError(ctx, w, endpoint, apiError{
 				StatusCode: http.StatusUnsupportedMediaType,
-				Message:    fmt.Sprintf("unsupported media type %s (only application/json supported)", contentType),
+				Message:    fmt.Sprintf("unsupported media type %s (only application/json, %s and %s supported)", contentType, yamlContentType, sszContentType),
 			})
 
 			return
 		}
 
-		body, err := io.ReadAll(r.Body)
+		ifMatchCtx, err := withIfMatch(ctx, r, endpoint, conf.RequireIfMatch)
 		if err != nil {
 			writeError(ctx, w, endpoint, err)
 			return
 		}
+		ctx = ifMatchCtx
+
+		reader, err := decodeBody(r)
+		if err != nil {
+			writeError(ctx, w, endpoint, err)
+			return
+		}
+
+		body, err := readBody(reader, conf.MaxBodyBytes)
+		_ = reader.Close()
+		if err != nil {
+			writeError(ctx, w, endpoint, err)
+			return
+		}
+
+		// Mutations record the request as submitted in their audit entries.
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			ctx = withActor(ctx, r, body)
+		}
+
+		// SSZ and YAML encoded bodies are converted to json, so handlers are agnostic of the request encoding.
+		if sszBody && len(body) > 0 {
+			body, err = sszToJSON(body)
+			if err != nil {
+				writeError(ctx, w, endpoint, err)
+				return
+			}
+		} else if yamlBody && len(body) > 0 {
+			body, err = yamlToJSON(body)
+			if err != nil {
+				writeError(ctx, w, endpoint, err)
+				return
+			}
+		}
+
+		if err := checkLimits(body, conf.JSONLimits); err != nil {
+			writeError(ctx, w, endpoint, err)
+			return
+		}
+
+		if conf.Lenient {
+			body, err = normalizeFieldNames(body)
+			if err != nil {
+				writeError(ctx, w, endpoint, apiError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid json body",
+					Err:        err,
+				})
+
+				return
+			}
+		}
 
 		res, err := handler(ctx, mux.Vars(r), r.URL.Query(), body)
 		if err != nil {
@@ -110,22 +737,96 @@ func wrap(endpoint string, handler handlerFunc) http.Handler {
 			return
 		}
 
-		writeResponse(ctx, w, endpoint, res)
+		// Responses are encoded as json, yaml or ssz depending on the accept header, definitions are converted
+		// to the version of the accept version header.
+		w.Header().Add("Vary", "Accept")
+		w.Header().Add("Vary", acceptVersionHeader)
+		if len(conf.Redaction) > 0 {
+			// Responses differ by caller, so shared caches must not serve them to other callers.
+			w.Header().Add("Vary", "Authorization")
+		}
+		redacted := conf.Redaction[callerFromCtx(ctx)]
+
+		if s, ok := res.(stream); ok {
+			writeStream(ctx, w, endpoint, s, r.Header.Get("Accept"), redacted)
+			return
+		}
+
+		if writeNotModified(w, r, res) {
+			return
+		}
+
+		if len(redacted) > 0 {
+			res, err = redactResponse(res, redacted)
+			if err != nil {
+				writeError(ctx, w, endpoint, err)
+				return
+			}
+		}
+
+		if sszAccepted(r) {
+			writeSSZResponse(ctx, w, endpoint, res)
+			return
+		} else if yamlAccepted(r) {
+			writeYAMLResponse(ctx, w, endpoint, res)
+			return
+		}
+
+		pretty := (r.Method == http.MethodGet || r.Method == http.MethodHead) && r.URL.Query().Get("pretty") == "true"
+		writeResponse(ctx, w, endpoint, res, pretty)
 	}
 
 	return wrapTrace(endpoint, wrap)
 }
 
+// logSlowRequest logs a warning if the request duration exceeds the threshold
+// including the breakdown of time spent in mongo and in the handler.
+func logSlowRequest(ctx context.Context, r *http.Request, threshold time.Duration) {
+	t0, ok := ctx.Value(durationKey{}).(time.Time)
+	if !ok {
+		return
+	}
+
+	total := time.Since(t0)
+	if total < threshold {
+		return
+	}
+
+	dbTime := service.DBTime(ctx)
+
+	log.Warn(ctx, "Slow request", nil,
+		z.Str("config_hash", mux.Vars(r)["config_hash"]),
+		z.Str("duration", total.String()),
+		z.Str("mongo_duration", dbTime.String()),
+		z.Str("handler_duration", (total-dbTime).String()),
+	)
+}
+
 // wrapTrace wraps the passed handler in a OpenTelemetry tracing span.
 func wrapTrace(endpoint string, handler http.HandlerFunc) http.Handler {
 	return otelhttp.NewHandler(handler, "core/validatorapi."+endpoint)
 }
 
-// writeResponse writes the 200 OK response and json response body.
-func writeResponse(ctx context.Context, w http.ResponseWriter, endpoint string, response interface{}) {
-	w.WriteHeader(http.StatusOK)
+// immutable wraps a response body that never changes, allowing clients and CDNs to cache it indefinitely.
+type immutable struct {
+	Body interface{}
+}
+
+// writeResponse writes the 200 OK response and json response body, indented if pretty.
+// Immutable responses are cacheable while all other responses must be revalidated.
+func writeResponse(ctx context.Context, w http.ResponseWriter, endpoint string, response interface{}, pretty bool) {
+	response = writeETag(w, response)
+
+	cacheControl := "no-cache"
+	if imm, ok := response.(immutable); ok {
+		cacheControl = "public, max-age=31536000, immutable"
+		response = imm.Body
+	}
 
 	if response == nil {
+		w.Header().Set("Cache-Control", cacheControl)
+		w.WriteHeader(http.StatusOK)
+
 		return
 	}
 
@@ -135,7 +836,21 @@ func writeResponse(ctx context.Context, w http.ResponseWriter, endpoint string,
 		return
 	}
 
+	if pretty {
+		// Indenting the marshalled json retains its deterministic key order; struct fields in declaration order and map keys sorted.
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, b, "", "  "); err != nil {
+			writeError(ctx, w, endpoint, errors.Wrap(err, "indent response body"))
+			return
+		}
+		b = append(buf.Bytes(), '\n')
+	}
+
+	// Headers must be set before writing the status code.
+	w.Header().Set("Cache-Control", cacheControl)
 	w.Header().Set("Content-Type", "application/json")
+	signBody(ctx, w, b)
+	w.WriteHeader(http.StatusOK)
 
 	if _, err = w.Write(b); err != nil {
 		// Too late to also try to writeError at this point, so just log.
@@ -143,9 +858,121 @@ func writeResponse(ctx context.Context, w http.ResponseWriter, endpoint string,
 	}
 }
 
+// stream wraps an iterator response written as a json array or as newline delimited json if requested.
+type stream struct {
+	Iter *service.Iterator
+}
+
+// writeStream writes the iterator values directly to the response as the iterator advances, either as
+// newline delimited json if the accept header requests it or as a json array. Written values are flushed
+// every streamFlushInterval. Streaming stops if the request context is cancelled. The redacted fields are
+// stripped from each value.
+func writeStream(ctx context.Context, w http.ResponseWriter, endpoint string, s stream, accept string, redacted []string) {
+	defer func() {
+		if err := s.Iter.Close(ctx); err != nil {
+			log.Warn(ctx, "Failed closing iterator", err)
+		}
+	}()
+
+	ndjson := strings.Contains(accept, ndjsonContentType)
+
+	// Read the first value before writing the status code so that query errors are returned as error responses.
+	first := s.Iter.Next(ctx)
+	if !first {
+		if err := s.Iter.Err(); err != nil {
+			writeError(ctx, w, endpoint, err)
+			return
+		}
+	}
+
+	contentType := "application/json"
+	if ndjson {
+		contentType = ndjsonContentType
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	lastFlush := time.Now()
+
+	// Errors after writing the status code can only be logged, the client detects a truncated stream.
+	write := func(b []byte) bool {
+		if _, err := w.Write(b); err != nil {
+			log.Error(ctx, "Failed writing streamed response", err)
+			return false
+		}
+
+		if flusher != nil && time.Since(lastFlush) >= streamFlushInterval {
+			flusher.Flush()
+			lastFlush = time.Now()
+		}
+
+		return true
+	}
+
+	if !ndjson && !write([]byte("[")) {
+		return
+	}
+
+	for ok, i := first, 0; ok; ok, i = s.Iter.Next(ctx), i+1 {
+		value, err := s.Iter.Value()
+		if err != nil {
+			log.Error(ctx, "Failed decoding streamed value", err)
+			return
+		}
+
+		var b []byte
+		if len(redacted) > 0 {
+			b, err = redact(value, redacted)
+		} else {
+			b, err = json.Marshal(value)
+		}
+		if err != nil {
+			log.Error(ctx, "Failed marshalling streamed value", err)
+			return
+		}
+
+		if ndjson {
+			b = append(b, '\n')
+		} else if i > 0 {
+			b = append([]byte(","), b...)
+		}
+
+		if !write(b) {
+			return
+		}
+	}
+
+	if err := s.Iter.Err(); err != nil {
+		log.Error(ctx, "Failed streaming response", err)
+		return
+	}
+
+	if !ndjson {
+		write([]byte("]"))
+	}
+}
+
+// requestTimeout returns the request timeout of the endpoint, zero if disabled.
+func (c Config) requestTimeout(endpoint string) time.Duration {
+	if timeout, ok := c.EndpointTimeouts[endpoint]; ok {
+		return timeout
+	}
+
+	return c.RequestTimeout
+}
+
 // writeError writes a http json error response object.
 func writeError(ctx context.Context, w http.ResponseWriter, endpoint string, err error) {
-	if ctx.Err() != nil {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		// Request exceeded its timeout.
+		err = apiError{
+			StatusCode: http.StatusServiceUnavailable,
+			Message:    "request timed out",
+			Err:        ctx.Err(),
+		}
+	} else if ctx.Err() != nil {
 		// Client cancelled the request
 		err = apiError{
 			StatusCode: http.StatusRequestTimeout,
@@ -154,14 +981,7 @@ func writeError(ctx context.Context, w http.ResponseWriter, endpoint string, err
 		}
 	}
 
-	var aerr apiError
-	if !errors.As(err, &aerr) {
-		aerr = apiError{
-			StatusCode: http.StatusInternalServerError,
-			Message:    "Internal server error",
-			Err:        err,
-		}
-	}
+	aerr := toAPIError(err)
 
 	if aerr.StatusCode/100 == 4 {
 		// 4xx status codes are client errors (not server), so log as debug only.
@@ -181,25 +1001,89 @@ func writeError(ctx context.Context, w http.ResponseWriter, endpoint string, err
 	incAPIErrors(endpoint, aerr.StatusCode)
 
 	res := errorResponse{
-		Code:    aerr.StatusCode,
-		Message: aerr.Message,
-		// TODO(corver): Add support for debug mode error and stacktraces.
+		Code:      aerr.StatusCode,
+		Message:   aerr.Message,
+		Errors:    aerr.Errors,
+		RequestID: requestIDFromCtx(ctx),
+	}
+	if isDebug(ctx) {
+		res.Debug = newDebugErrorResponse(aerr.Err)
+	}
+
+	var (
+		body        interface{} = res
+		contentType             = "application/json"
+	)
+	if instance, ok := problemInstance(ctx); ok {
+		body = newProblemResponse(res, instance)
+		contentType = problemContentType
 	}
 
-	b, err2 := json.Marshal(res)
+	b, err2 := json.Marshal(body)
 	if err2 != nil {
 		// Log and continue to write nil b.
 		log.Error(ctx, "Failed marshalling error response", err2)
 	}
 
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(aerr.StatusCode)
-	w.Header().Set("Content-Type", "application/json")
 
 	if _, err2 = w.Write(b); err2 != nil {
 		log.Error(ctx, "Failed writing api error", err2)
 	}
 }
 
+// serviceErrors maps service errors to http status codes and the fixed messages responded, since the wrapped
+// errors may contain internal details, e.g., database driver errors. Service errors are structured errors
+// which aren't hashable, so they can't be map keys.
+var serviceErrors = []struct {
+	err        error
+	statusCode int
+	message    string
+}{
+	{service.ErrNotFound, http.StatusNotFound, "Not found"},
+	{service.ErrInvalidRequest, http.StatusBadRequest, "Invalid request"},
+	{service.ErrInvalidState, http.StatusConflict, "Invalid definition state"},
+	{service.ErrConflict, http.StatusConflict, "Conflict"},
+	{service.ErrTimeout, http.StatusGatewayTimeout, "Database timeout"},
+	{service.ErrUnsupported, http.StatusNotImplemented, "Unsupported by storage driver"},
+	{service.ErrPreconditionFailed, http.StatusPreconditionFailed, "Precondition failed"},
+	{service.ErrQuotaExceeded, http.StatusTooManyRequests, "Quota exceeded"},
+	{notify.ErrNotFound, http.StatusNotFound, "Delivery not found"},
+}
+
+// toAPIError returns the api error of the error, mapping service errors to their status codes and fixed messages
+// and other errors to a redacted internal server error. The wrapped error is only logged.
+func toAPIError(err error) apiError {
+	var aerr apiError
+	if statusCode, message, ok := serviceError(err); ok {
+		return apiError{
+			StatusCode: statusCode,
+			Message:    message,
+			Err:        err,
+		}
+	} else if !errors.As(err, &aerr) {
+		return apiError{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "Internal server error",
+			Err:        err,
+		}
+	}
+
+	return aerr
+}
+
+// serviceError returns the http status code and message of the service error or false if not a service error.
+func serviceError(err error) (int, string, bool) {
+	for _, serviceErr := range serviceErrors {
+		if errors.Is(err, serviceErr.err) {
+			return serviceErr.statusCode, serviceErr.message, true
+		}
+	}
+
+	return 0, "", false
+}
+
 // unmarshal parses the JSON-encoded request body and stores the result
 // in the value pointed to by v.
 func unmarshal(body []byte, v interface{}) error {
@@ -266,6 +1150,33 @@ func hexQueryFixed(query url.Values, name string, target []byte) error {
 	return nil
 }
 
+// configHash returns the 0x-hex config_hash path parameter.
+func configHash(params map[string]string) ([]byte, error) {
+	return hexParam(params, "config_hash")
+}
+
+// hexParam returns a 0x-prefixed hex path parameter with name.
+func hexParam(params map[string]string, name string) ([]byte, error) {
+	value, ok := params[name]
+	if !ok {
+		return nil, apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("Missing %s", name),
+		}
+	}
+
+	resp, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	if err != nil {
+		return nil, apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("invalid 0x-hex %s [%s]", name, value),
+			Err:        err,
+		}
+	}
+
+	return resp, nil
+}
+
 // hexQuery returns a 0x-prefixed hex query parameter with name or false if not present.
 func hexQuery(query url.Values, name string) ([]byte, bool, error) {
 	valueA, ok := query[name]
@@ -289,7 +1200,10 @@ func hexQuery(query url.Values, name string) ([]byte, bool, error) {
 // errorResponse an error response from the beacon-node api.
 // See https://ethereum.github.io/beacon-APIs.
 type errorResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	// TODO(corver): Maybe add stacktraces field for debugging.
+	Code      int      `json:"code"`
+	Message   string   `json:"message"`
+	Errors    []string `json:"errors,omitempty"`
+	RequestID string   `json:"request_id,omitempty"`
+	// Debug are the internal error details, only included in debug mode.
+	Debug *debugErrorResponse `json:"debug,omitempty"`
 }
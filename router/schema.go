@@ -0,0 +1,241 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Request body json schemas by name.
+const (
+	schemaDefinition = "definition.json"
+	schemaOperator   = "operator.json"
+)
+
+//go:embed schemas/*.json
+var schemaFS embed.FS
+
+// schemas are the parsed embedded request body json schemas by name.
+var schemas = mustLoadSchemas()
+
+// schema is the subset of JSON Schema (draft-07) keywords used by the request body schemas.
+type schema struct {
+	Raw        json.RawMessage    `json:"-"`
+	Type       string             `json:"type"`
+	Required   []string           `json:"required"`
+	Properties map[string]*schema `json:"properties"`
+	Items      *schema            `json:"items"`
+	Pattern    string             `json:"pattern"`
+	Minimum    *float64           `json:"minimum"`
+	MinLength  *int               `json:"minLength"`
+	MaxLength  *int               `json:"maxLength"`
+	MinItems   *int               `json:"minItems"`
+	MaxItems   *int               `json:"maxItems"`
+
+	regex *regexp.Regexp
+}
+
+// compile compiles the patterns of the schema and its subschemas.
+func (s *schema) compile() error {
+	if s.Pattern != "" {
+		regex, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return errors.Wrap(err, "compile pattern", z.Str("pattern", s.Pattern))
+		}
+		s.regex = regex
+	}
+
+	for _, prop := range s.Properties {
+		if err := prop.compile(); err != nil {
+			return err
+		}
+	}
+
+	if s.Items != nil {
+		return s.Items.compile()
+	}
+
+	return nil
+}
+
+// validate appends a validation error per violation of the value at the json pointer path.
+func (s *schema) validate(ptr string, val interface{}, errs *[]string) {
+	addErr := func(format string, args ...interface{}) {
+		if ptr == "" {
+			ptr = "/"
+		}
+		*errs = append(*errs, ptr+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch v := val.(type) {
+	case map[string]interface{}:
+		if s.Type != "" && s.Type != "object" {
+			addErr("expected %s, got object", s.Type)
+			return
+		}
+
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				addErr("missing required property %q", name)
+			}
+		}
+
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if prop, ok := s.Properties[name]; ok {
+				prop.validate(ptr+"/"+escapePointer(name), v[name], errs)
+			}
+		}
+	case []interface{}:
+		if s.Type != "" && s.Type != "array" {
+			addErr("expected %s, got array", s.Type)
+			return
+		}
+
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			addErr("expected at least %d items, got %d", *s.MinItems, len(v))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			addErr("expected at most %d items, got %d", *s.MaxItems, len(v))
+		}
+
+		if s.Items != nil {
+			for i, elem := range v {
+				s.Items.validate(fmt.Sprintf("%s/%d", ptr, i), elem, errs)
+			}
+		}
+	case string:
+		if s.Type != "" && s.Type != "string" {
+			addErr("expected %s, got string", s.Type)
+			return
+		}
+
+		if s.MinLength != nil && len(v) < *s.MinLength {
+			addErr("expected at least %d characters, got %d", *s.MinLength, len(v))
+		}
+		if s.MaxLength != nil && len(v) > *s.MaxLength {
+			addErr("expected at most %d characters, got %d", *s.MaxLength, len(v))
+		}
+		if s.regex != nil && !s.regex.MatchString(v) {
+			addErr("does not match pattern %s", s.Pattern)
+		}
+	case json.Number:
+		if s.Type != "" && s.Type != "number" && s.Type != "integer" {
+			addErr("expected %s, got number", s.Type)
+			return
+		}
+
+		if _, err := v.Int64(); err != nil && s.Type == "integer" {
+			addErr("expected integer, got %s", v)
+			return
+		}
+
+		f, err := v.Float64()
+		if err == nil && s.Minimum != nil && f < *s.Minimum {
+			addErr("expected minimum %v, got %s", *s.Minimum, v)
+		}
+	case bool:
+		if s.Type != "" && s.Type != "boolean" {
+			addErr("expected %s, got boolean", s.Type)
+		}
+	case nil:
+		if s.Type != "" && s.Type != "null" {
+			addErr("expected %s, got null", s.Type)
+		}
+	}
+}
+
+// escapePointer escapes a json pointer reference token as per RFC 6901.
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// mustLoadSchemas returns the parsed embedded schemas by name, it panics if a schema is invalid.
+func mustLoadSchemas() map[string]*schema {
+	entries, err := schemaFS.ReadDir("schemas")
+	if err != nil {
+		panic(err)
+	}
+
+	resp := make(map[string]*schema)
+	for _, entry := range entries {
+		raw, err := schemaFS.ReadFile(path.Join("schemas", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		s := new(schema)
+		if err := json.Unmarshal(raw, s); err != nil {
+			panic(fmt.Sprintf("invalid schema %s: %v", entry.Name(), err))
+		} else if err := s.compile(); err != nil {
+			panic(fmt.Sprintf("invalid schema %s: %v", entry.Name(), err))
+		}
+		s.Raw = raw
+
+		resp[entry.Name()] = s
+	}
+
+	return resp
+}
+
+// validated returns a handler that validates the request body against the named schema before calling the handler.
+func validated(name string, handler handlerFunc) handlerFunc {
+	s, ok := schemas[name]
+	if !ok {
+		panic("unknown schema " + name)
+	}
+
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		d := json.NewDecoder(bytes.NewReader(body))
+		d.UseNumber()
+
+		var val interface{}
+		if err := d.Decode(&val); err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Invalid json body",
+				Err:        err,
+			}
+		}
+
+		var errs []string
+		s.validate("", val, &errs)
+		if len(errs) > 0 {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Request body does not match schema " + name,
+				Err:        errors.New("schema validation failed", z.Str("schema", name), z.Int("errors", len(errs))),
+				Errors:     errs,
+			}
+		}
+
+		return handler(ctx, params, query, body)
+	}
+}
+
+func getSchema(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+	s, ok := schemas[params["name"]]
+	if !ok {
+		return nil, apiError{
+			StatusCode: http.StatusNotFound,
+			Message:    "schema not found",
+		}
+	}
+
+	return s.Raw, nil
+}
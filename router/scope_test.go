@@ -0,0 +1,70 @@
+package router
+
+import "testing"
+
+func TestScopeAllows(t *testing.T) {
+	hash := "0x" + "01020304050607080910111213141516171819202122232425262728293031ff"
+	other := "0x" + "ff020304050607080910111213141516171819202122232425262728293031ff"
+
+	hashBytes, err := configHash(map[string]string{"config_hash": hash})
+	if err != nil {
+		t.Fatalf("config hash: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		scope    *Scope
+		endpoint string
+		params   map[string]string
+		want     bool
+	}{
+		{name: "unscoped", endpoint: "delete_definition", want: true},
+		{name: "empty scope", scope: &Scope{}, endpoint: "create_definition", want: true},
+		{
+			name:     "endpoint",
+			scope:    &Scope{Endpoints: []string{"delete_definition", "cancel_deletion"}},
+			endpoint: "cancel_deletion",
+			params:   map[string]string{"config_hash": hash},
+			want:     true,
+		},
+		{
+			name:     "other endpoint",
+			scope:    &Scope{Endpoints: []string{"delete_definition"}},
+			endpoint: "pin_definition",
+			params:   map[string]string{"config_hash": hash},
+		},
+		{
+			name:     "definition",
+			scope:    &Scope{ConfigHashes: [][]byte{hashBytes}},
+			endpoint: "get_definition",
+			params:   map[string]string{"config_hash": hash},
+			want:     true,
+		},
+		{
+			name:     "other definition",
+			scope:    &Scope{ConfigHashes: [][]byte{hashBytes}},
+			endpoint: "get_definition",
+			params:   map[string]string{"config_hash": other},
+		},
+		{
+			name:     "endpoint without definition",
+			scope:    &Scope{ConfigHashes: [][]byte{hashBytes}},
+			endpoint: "get_definitions",
+		},
+		{
+			name:     "endpoint and definition",
+			scope:    &Scope{Endpoints: []string{"delete_definition"}, ConfigHashes: [][]byte{hashBytes}},
+			endpoint: "delete_definition",
+			params:   map[string]string{"config_hash": hash},
+			want:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.scope.allows(test.endpoint, test.params); got != test.want {
+				t.Fatalf("unexpected allows: got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// Settings are the router settings reloaded without restarting the server. It is safe for concurrent use.
+type Settings struct {
+	mu             sync.RWMutex
+	rateLimit      RateLimitConfig
+	allowedOrigins []string
+	onUpdate       []func()
+	// fixed is true for settings that are never updated, allowing disabled features to be skipped.
+	fixed bool
+}
+
+// NewSettings returns new reloadable settings with the initial rate limits and allowed CORS origins.
+func NewSettings(rateLimit RateLimitConfig, allowedOrigins []string) *Settings {
+	return &Settings{rateLimit: rateLimit, allowedOrigins: allowedOrigins}
+}
+
+// Update replaces the rate limits and allowed CORS origins, applying them to subsequent requests.
+func (s *Settings) Update(rateLimit RateLimitConfig, allowedOrigins []string) {
+	s.mu.Lock()
+	s.rateLimit, s.allowedOrigins = rateLimit, allowedOrigins
+	onUpdate := s.onUpdate
+	s.mu.Unlock()
+
+	for _, fn := range onUpdate {
+		fn()
+	}
+}
+
+// RateLimit returns the default request rate limits by client.
+func (s *Settings) RateLimit() RateLimitConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.rateLimit
+}
+
+// AllowedOrigins returns the origins allowed to call the API.
+func (s *Settings) AllowedOrigins() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.allowedOrigins
+}
+
+// subscribe registers the function called after each update.
+func (s *Settings) subscribe(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onUpdate = append(s.onUpdate, fn)
+}
+
+func getConfig(activeConfig func() interface{}) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if activeConfig == nil {
+			return nil, apiError{
+				StatusCode: http.StatusNotImplemented,
+				Message:    "config reloading is disabled",
+			}
+		}
+
+		return activeConfig(), nil
+	}
+}
@@ -0,0 +1,162 @@
+package router
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/corverroos/dvstore/service"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query parameters of share links.
+const (
+	shareExpiresParam   = "expires"
+	shareSignatureParam = "signature"
+)
+
+// ShareConfig defines time-limited signed links granting unauthenticated read access to a single definition.
+type ShareConfig struct {
+	// Secret is the HMAC secret signing share links. Share links are disabled if empty.
+	Secret string
+	// BaseURL is the public base URL prefixed to share links, e.g. "https://dvstore.example.com".
+	// Share links are relative if empty.
+	BaseURL string
+	// TTL is the default validity of share links.
+	TTL time.Duration
+	// MaxTTL is the maximum validity of share links requested by creators.
+	MaxTTL time.Duration
+}
+
+// shareLink is the response of the share endpoint.
+type shareLink struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// shareSignature returns the HMAC signature of a share link of the definition expiring at the unix timestamp.
+func shareSignature(secret string, configHash []byte, expires int64) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(fmt.Sprintf("share\n%x\n%d", configHash, expires)))
+
+	return mac.Sum(nil)
+}
+
+// isShared returns true if the request contains a share link signature.
+func isShared(r *http.Request) bool {
+	return r.URL.Query().Get(shareSignatureParam) != ""
+}
+
+// verifyShareLink verifies the share link signature and expiry of the get definition request.
+// Share links only grant access to the published definition.
+func verifyShareLink(r *http.Request, secret string) error {
+	unauthorized := func(msg string, err error) error {
+		return apiError{
+			StatusCode: http.StatusUnauthorized,
+			Message:    msg,
+			Err:        err,
+		}
+	}
+
+	if secret == "" {
+		return unauthorized("share links disabled", nil)
+	}
+
+	hash, err := configHash(mux.Vars(r))
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	if status := query.Get("status"); status != "" && status != "published" {
+		return unauthorized("share link only grants access to the published definition", nil)
+	}
+
+	expires, err := strconv.ParseInt(query.Get(shareExpiresParam), 10, 64)
+	if err != nil {
+		return unauthorized("invalid share link expiry", err)
+	} else if time.Now().After(time.Unix(expires, 0)) {
+		return unauthorized("share link expired", nil)
+	}
+
+	sig, err := hex.DecodeString(query.Get(shareSignatureParam))
+	if err != nil {
+		return unauthorized("invalid share link signature hex", err)
+	}
+
+	if !hmac.Equal(sig, shareSignature(secret, hash, expires)) {
+		return unauthorized("invalid share link signature", nil)
+	}
+
+	return nil
+}
+
+func shareDefinition(svc service.Definition, conf ShareConfig) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if conf.Secret == "" {
+			return nil, apiError{
+				StatusCode: http.StatusNotFound,
+				Message:    "Share links disabled",
+			}
+		}
+
+		hash, err := configHash(params)
+		if err != nil {
+			return nil, err
+		}
+
+		var req struct {
+			TTL string `json:"ttl"`
+		}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &req); err != nil {
+				return nil, apiError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid body",
+					Err:        err,
+				}
+			}
+		}
+
+		ttl := conf.TTL
+		if req.TTL != "" {
+			ttl, err = time.ParseDuration(req.TTL)
+			if err != nil || ttl <= 0 {
+				return nil, apiError{
+					StatusCode: http.StatusBadRequest,
+					Message:    "Invalid ttl, expected positive duration, e.g. 72h",
+					Err:        err,
+				}
+			}
+		}
+
+		if conf.MaxTTL > 0 && ttl > conf.MaxTTL {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("ttl exceeds maximum of %s", conf.MaxTTL),
+			}
+		}
+
+		if _, _, _, err := svc.Get(ctx, hash); err != nil {
+			return nil, err
+		}
+
+		expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+
+		link := url.Values{}
+		link.Set(shareExpiresParam, strconv.FormatInt(expiresAt.Unix(), 10))
+		link.Set(shareSignatureParam, hex.EncodeToString(shareSignature(conf.Secret, hash, expiresAt.Unix())))
+
+		return shareLink{
+			URL:       fmt.Sprintf("%s%s/dv/%#x?%s", strings.TrimSuffix(conf.BaseURL, "/"), apiVersionPrefix, hash, link.Encode()),
+			ExpiresAt: expiresAt,
+		}, nil
+	}
+}
@@ -0,0 +1,64 @@
+package router
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	// signatureHeader is the response header containing the server's ed25519 signature of the response body,
+	// before content encoding.
+	signatureHeader = "X-Dvstore-Response-Signature"
+	// signingKeyPath is the well-known path of the public key verifying response signatures.
+	signingKeyPath = "/.well-known/dvstore-signing-key"
+)
+
+// signedEndpoints are the endpoints whose response bodies are signed, allowing clients fetching clusters via
+// untrusted intermediaries to verify the served bytes.
+var signedEndpoints = map[string]bool{
+	"get_definition": true,
+	"get_lock":       true,
+}
+
+type signerKey struct{}
+
+// withSigner returns a copy of the context with the key signing the response body if the endpoint's responses
+// are signed.
+func withSigner(ctx context.Context, endpoint string, key ed25519.PrivateKey) context.Context {
+	if key == nil || !signedEndpoints[endpoint] {
+		return ctx
+	}
+
+	return context.WithValue(ctx, signerKey{}, key)
+}
+
+// signBody sets the signature header of the response body if the response is signed.
+// It must be called before writing the status code.
+func signBody(ctx context.Context, w http.ResponseWriter, body []byte) {
+	key, ok := ctx.Value(signerKey{}).(ed25519.PrivateKey)
+	if !ok {
+		return
+	}
+
+	w.Header().Set(signatureHeader, fmt.Sprintf("%#x", ed25519.Sign(key, body)))
+}
+
+// signingKeyResponse is the public key verifying the signatures of response bodies.
+type signingKeyResponse struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"`
+	Header    string `json:"header"`
+}
+
+func getSigningKey(key ed25519.PrivateKey) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		return signingKeyResponse{
+			Algorithm: "ed25519",
+			PublicKey: fmt.Sprintf("%#x", []byte(key.Public().(ed25519.PublicKey))),
+			Header:    signatureHeader,
+		}, nil
+	}
+}
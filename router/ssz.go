@@ -0,0 +1,474 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"net/http"
+	"strings"
+)
+
+// sszContentType is the media type of SSZ encoded request and response bodies.
+const sszContentType = "application/octet-stream"
+
+// SSZ definition container limits and sizes, matching the charon definition hash containers.
+const (
+	sszMaxUUID         = 64
+	sszMaxName         = 256
+	sszMaxVersion      = 16
+	sszMaxTimestamp    = 32
+	sszMaxDKGAlgorithm = 32
+	sszMaxENR          = 1024
+	sszMaxOperators    = 256
+
+	sszOffsetLen    = 4
+	sszAddressLen   = 20
+	sszSignatureLen = 65
+
+	// sszOperatorFixedLen is the fixed part of an operator: address, enr offset and both signatures.
+	sszOperatorFixedLen = sszAddressLen + sszOffsetLen + 2*sszSignatureLen
+	// sszDefinitionFixedLen is the fixed part of a v1.3 definition: uuid, name, version and timestamp offsets,
+	// num validators, threshold, both addresses, dkg algorithm offset, fork version and operators offset.
+	sszDefinitionFixedLen = 4*sszOffsetLen + 8 + 8 + 2*sszAddressLen + sszOffsetLen + 4 + sszOffsetLen
+	// sszCreatorLen is the size of the creator, address and signature, added in v1.4.
+	sszCreatorLen = sszAddressLen + sszSignatureLen
+	// sszConfigHashLen is the size of the trailing config hash.
+	sszConfigHashLen = 32
+)
+
+// sszAccepted returns true if the request accepts SSZ encoded responses.
+func sszAccepted(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), sszContentType)
+}
+
+// sszToJSON returns the json encoding of the SSZ encoded definition request body,
+// so handlers only have to decode json.
+func sszToJSON(body []byte) ([]byte, error) {
+	def, err := unmarshalDefinitionSSZ(body)
+	if err != nil {
+		return nil, apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    "Invalid ssz body",
+			Err:        err,
+		}
+	}
+
+	b, err := json.Marshal(def)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal definition")
+	}
+
+	return b, nil
+}
+
+// writeSSZResponse writes the 200 OK response and SSZ encoded response body.
+// Only definitions are SSZ encodable, other responses are not acceptable.
+func writeSSZResponse(ctx context.Context, w http.ResponseWriter, endpoint string, response interface{}) {
+	response = writeETag(w, response)
+
+	cacheControl := "no-cache"
+	if imm, ok := response.(immutable); ok {
+		cacheControl = "public, max-age=31536000, immutable"
+		response = imm.Body
+	}
+
+	if response == nil {
+		w.Header().Set("Cache-Control", cacheControl)
+		w.WriteHeader(http.StatusOK)
+
+		return
+	}
+
+	def, ok := response.(cluster.Definition)
+	if !ok {
+		writeError(ctx, w, endpoint, apiError{
+			StatusCode: http.StatusNotAcceptable,
+			Message:    fmt.Sprintf("response not available as %s", sszContentType),
+		})
+
+		return
+	}
+
+	b, err := marshalDefinitionSSZ(def)
+	if err != nil {
+		writeError(ctx, w, endpoint, apiError{
+			StatusCode: http.StatusNotAcceptable,
+			Message:    fmt.Sprintf("definition not encodable as %s", sszContentType),
+			Err:        err,
+		})
+
+		return
+	}
+
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("Content-Type", sszContentType)
+	signBody(ctx, w, b)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err = w.Write(b); err != nil {
+		log.Error(ctx, "Failed writing api response", err)
+	}
+}
+
+// marshalDefinitionSSZ returns the SSZ encoding of the v1.3 or later definition. The container fields match the
+// charon definition hash container. Empty addresses and signatures are encoded as zero bytes.
+func marshalDefinitionSSZ(def cluster.Definition) ([]byte, error) {
+	withCreator, err := sszWithCreator(def.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	fixedLen := sszDefinitionFixedLen + sszConfigHashLen
+	if withCreator {
+		fixedLen += sszCreatorLen
+	}
+
+	var operators [][]byte
+	for _, op := range def.Operators {
+		enc := newSSZEncoder(sszOperatorFixedLen)
+		enc.putFixed(op.Address, sszAddressLen)
+		enc.putVariable([]byte(op.ENR), sszMaxENR, "enr")
+		enc.putFixedBytes(op.ConfigSignature, sszSignatureLen, "config_signature")
+		enc.putFixedBytes(op.ENRSignature, sszSignatureLen, "enr_signature")
+
+		b, err := enc.bytes()
+		if err != nil {
+			return nil, errors.Wrap(err, "encode operator", z.Str("address", op.Address))
+		}
+		operators = append(operators, b)
+	}
+
+	if len(operators) > sszMaxOperators {
+		return nil, errors.New("too many operators", z.Int("operators", len(operators)))
+	}
+
+	// A list of variable size containers is encoded as the item offsets followed by the items.
+	var list []byte
+	offset := len(operators) * sszOffsetLen
+	for _, op := range operators {
+		list = binary.LittleEndian.AppendUint32(list, uint32(offset))
+		offset += len(op)
+	}
+	for _, op := range operators {
+		list = append(list, op...)
+	}
+
+	enc := newSSZEncoder(fixedLen)
+	enc.putVariable([]byte(def.UUID), sszMaxUUID, "uuid")
+	enc.putVariable([]byte(def.Name), sszMaxName, "name")
+	enc.putVariable([]byte(def.Version), sszMaxVersion, "version")
+	enc.putVariable([]byte(def.Timestamp), sszMaxTimestamp, "timestamp")
+	enc.putUint64(uint64(def.NumValidators))
+	enc.putUint64(uint64(def.Threshold))
+	enc.putFixed(def.FeeRecipientAddress, sszAddressLen)
+	enc.putFixed(def.WithdrawalAddress, sszAddressLen)
+	enc.putVariable([]byte(def.DKGAlgorithm), sszMaxDKGAlgorithm, "dkg_algorithm")
+	enc.putFixedBytes(def.ForkVersion, 4, "fork_version")
+	enc.putVariable(list, len(list), "operators")
+	if withCreator {
+		enc.putFixed(def.Creator.Address, sszAddressLen)
+		enc.putFixedBytes(def.Creator.ConfigSignature, sszSignatureLen, "creator_config_signature")
+	}
+	enc.putFixedBytes(def.ConfigHash, sszConfigHashLen, "config_hash")
+
+	return enc.bytes()
+}
+
+// unmarshalDefinitionSSZ returns the v1.3 or later definition decoded from SSZ with its definition hash set.
+// It returns an error if the encoded config hash doesn't match the definition.
+func unmarshalDefinitionSSZ(b []byte) (cluster.Definition, error) {
+	// The version is a variable field, so infer the fixed part length, and whether the creator is included,
+	// from the first offset.
+	if len(b) < sszOffsetLen {
+		return cluster.Definition{}, errors.New("ssz too short")
+	}
+
+	fixedLen := int(binary.LittleEndian.Uint32(b))
+	withCreator := fixedLen == sszDefinitionFixedLen+sszCreatorLen+sszConfigHashLen
+	if !withCreator && fixedLen != sszDefinitionFixedLen+sszConfigHashLen {
+		return cluster.Definition{}, errors.New("invalid ssz fixed length", z.Int("length", fixedLen))
+	}
+
+	var def cluster.Definition
+
+	dec := newSSZDecoder(b, fixedLen)
+	dec.offset()
+	dec.offset()
+	dec.offset()
+	dec.offset()
+	def.NumValidators = int(dec.uint64())
+	def.Threshold = int(dec.uint64())
+	def.FeeRecipientAddress = dec.address()
+	def.WithdrawalAddress = dec.address()
+	dec.offset()
+	def.ForkVersion = dec.fixed(4)
+	dec.offset()
+	if withCreator {
+		def.Creator.Address = dec.address()
+		def.Creator.ConfigSignature = dec.signature()
+	}
+	def.ConfigHash = dec.fixed(sszConfigHashLen)
+
+	fields, err := dec.variables(sszMaxUUID, sszMaxName, sszMaxVersion, sszMaxTimestamp, sszMaxDKGAlgorithm, len(b))
+	if err != nil {
+		return cluster.Definition{}, err
+	}
+
+	def.UUID = string(fields[0])
+	def.Name = string(fields[1])
+	def.Version = string(fields[2])
+	def.Timestamp = string(fields[3])
+	def.DKGAlgorithm = string(fields[4])
+
+	if expect, err := sszWithCreator(def.Version); err != nil {
+		return cluster.Definition{}, err
+	} else if expect != withCreator {
+		return cluster.Definition{}, errors.New("invalid ssz fixed length for version", z.Str("version", def.Version))
+	}
+
+	def.Operators, err = unmarshalOperatorsSSZ(fields[5])
+	if err != nil {
+		return cluster.Definition{}, err
+	}
+
+	configHash := def.ConfigHash
+	def, err = def.SetDefinitionHashes()
+	if err != nil {
+		return cluster.Definition{}, errors.Wrap(err, "set definition hashes")
+	} else if !bytes.Equal(def.ConfigHash, configHash) {
+		return cluster.Definition{}, errors.New("config hash mismatch",
+			z.Str("expected", fmt.Sprintf("%#x", def.ConfigHash)), z.Str("actual", fmt.Sprintf("%#x", configHash)))
+	}
+
+	return def, nil
+}
+
+// unmarshalOperatorsSSZ returns the operators decoded from the SSZ list of variable size operator containers.
+func unmarshalOperatorsSSZ(b []byte) ([]cluster.Operator, error) {
+	if len(b) == 0 {
+		return nil, nil
+	} else if len(b) < sszOffsetLen {
+		return nil, errors.New("invalid ssz operators")
+	}
+
+	first := int(binary.LittleEndian.Uint32(b))
+	if first%sszOffsetLen != 0 || first > len(b) || first/sszOffsetLen > sszMaxOperators {
+		return nil, errors.New("invalid ssz operators offset", z.Int("offset", first))
+	}
+
+	num := first / sszOffsetLen
+	offsets := make([]int, 0, num+1)
+	for i := 0; i < num; i++ {
+		offsets = append(offsets, int(binary.LittleEndian.Uint32(b[i*sszOffsetLen:])))
+	}
+	offsets = append(offsets, len(b))
+
+	var operators []cluster.Operator
+	for i := 0; i < num; i++ {
+		if offsets[i] > offsets[i+1] {
+			return nil, errors.New("invalid ssz operator offset", z.Int("index", i))
+		}
+
+		dec := newSSZDecoder(b[offsets[i]:offsets[i+1]], sszOperatorFixedLen)
+
+		var op cluster.Operator
+		op.Address = dec.address()
+		dec.offset()
+		op.ConfigSignature = dec.signature()
+		op.ENRSignature = dec.signature()
+
+		fields, err := dec.variables(sszMaxENR)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode operator", z.Int("index", i))
+		}
+		op.ENR = string(fields[0])
+
+		operators = append(operators, op)
+	}
+
+	return operators, nil
+}
+
+// sszWithCreator returns true if the definition version includes the creator or an error if SSZ isn't supported.
+func sszWithCreator(version string) (bool, error) {
+	switch version {
+	case "v1.3.0":
+		return false, nil
+	case "v1.4.0":
+		return true, nil
+	default:
+		return false, errors.New("ssz not supported for definition version", z.Str("version", version))
+	}
+}
+
+// sszEncoder encodes an SSZ container, appending fixed size fields and the offsets of variable size fields
+// to the fixed part, and variable size fields to the variable part. The first error is returned by bytes.
+type sszEncoder struct {
+	fixedLen int
+	fixed    []byte
+	variable []byte
+	err      error
+}
+
+func newSSZEncoder(fixedLen int) *sszEncoder {
+	return &sszEncoder{fixedLen: fixedLen}
+}
+
+func (e *sszEncoder) putUint64(v uint64) {
+	e.fixed = binary.LittleEndian.AppendUint64(e.fixed, v)
+}
+
+// putFixed appends the 0x-hex encoded fixed size value, or zero bytes if empty.
+func (e *sszEncoder) putFixed(hexStr string, size int) {
+	if hexStr == "" {
+		e.putFixedBytes(nil, size, "")
+		return
+	}
+
+	b, err := hex.DecodeString(strings.TrimPrefix(hexStr, "0x"))
+	if err != nil && e.err == nil {
+		e.err = errors.Wrap(err, "decode hex", z.Str("value", hexStr))
+	}
+
+	e.putFixedBytes(b, size, hexStr)
+}
+
+// putFixedBytes appends the fixed size value, or zero bytes if empty.
+func (e *sszEncoder) putFixedBytes(b []byte, size int, field string) {
+	if len(b) == 0 {
+		b = make([]byte, size)
+	} else if len(b) != size && e.err == nil {
+		e.err = errors.New("invalid ssz field length", z.Str("field", field), z.Int("expect", size), z.Int("actual", len(b)))
+	}
+
+	e.fixed = append(e.fixed, b...)
+}
+
+// putVariable appends the offset of the variable size value to the fixed part and the value to the variable part.
+func (e *sszEncoder) putVariable(b []byte, maxLen int, field string) {
+	if len(b) > maxLen && e.err == nil {
+		e.err = errors.New("ssz field too long", z.Str("field", field), z.Int("max", maxLen), z.Int("actual", len(b)))
+	}
+
+	e.fixed = binary.LittleEndian.AppendUint32(e.fixed, uint32(e.fixedLen+len(e.variable)))
+	e.variable = append(e.variable, b...)
+}
+
+func (e *sszEncoder) bytes() ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	} else if len(e.fixed) != e.fixedLen {
+		return nil, errors.New("ssz fixed length mismatch", z.Int("expect", e.fixedLen), z.Int("actual", len(e.fixed)))
+	}
+
+	return append(e.fixed, e.variable...), nil
+}
+
+// sszDecoder decodes an SSZ container, reading the fixed part in order and recording the offsets of
+// variable size fields, which are returned by variables. The first error is returned by variables.
+type sszDecoder struct {
+	b        []byte
+	fixedLen int
+	pos      int
+	offsets  []int
+	err      error
+}
+
+func newSSZDecoder(b []byte, fixedLen int) *sszDecoder {
+	d := &sszDecoder{b: b, fixedLen: fixedLen}
+	if len(b) < fixedLen {
+		d.err = errors.New("ssz too short", z.Int("expect", fixedLen), z.Int("actual", len(b)))
+	}
+
+	return d
+}
+
+// fixed returns the next fixed size field.
+func (d *sszDecoder) fixed(size int) []byte {
+	if d.err != nil || d.pos+size > d.fixedLen {
+		if d.err == nil {
+			d.err = errors.New("ssz fixed part overflow")
+		}
+
+		return make([]byte, size)
+	}
+
+	b := d.b[d.pos : d.pos+size]
+	d.pos += size
+
+	return b
+}
+
+func (d *sszDecoder) uint64() uint64 {
+	return binary.LittleEndian.Uint64(d.fixed(8))
+}
+
+// address returns the next 0x-hex address or empty if zero.
+func (d *sszDecoder) address() string {
+	b := d.fixed(sszAddressLen)
+	if isZero(b) {
+		return ""
+	}
+
+	return fmt.Sprintf("%#x", b)
+}
+
+// signature returns the next signature or nil if zero.
+func (d *sszDecoder) signature() []byte {
+	b := d.fixed(sszSignatureLen)
+	if isZero(b) {
+		return nil
+	}
+
+	return b
+}
+
+func (d *sszDecoder) offset() {
+	d.offsets = append(d.offsets, int(binary.LittleEndian.Uint32(d.fixed(sszOffsetLen))))
+}
+
+// variables returns the variable size fields in offset order, verifying their maximum lengths.
+func (d *sszDecoder) variables(maxLens ...int) ([][]byte, error) {
+	if d.err != nil {
+		return nil, d.err
+	} else if d.pos != d.fixedLen || len(d.offsets) != len(maxLens) {
+		return nil, errors.New("ssz fixed part mismatch")
+	} else if len(d.offsets) > 0 && d.offsets[0] != d.fixedLen {
+		return nil, errors.New("invalid ssz first offset", z.Int("offset", d.offsets[0]))
+	}
+
+	var resp [][]byte
+	for i, start := range d.offsets {
+		end := len(d.b)
+		if i+1 < len(d.offsets) {
+			end = d.offsets[i+1]
+		}
+
+		if start > end || end > len(d.b) {
+			return nil, errors.New("invalid ssz offset", z.Int("offset", start))
+		} else if end-start > maxLens[i] {
+			return nil, errors.New("ssz field too long", z.Int("max", maxLens[i]), z.Int("actual", end-start))
+		}
+
+		resp = append(resp, d.b[start:end])
+	}
+
+	return resp, nil
+}
+
+func isZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,141 @@
+package router
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/obolnetwork/charon/cluster"
+	"testing"
+)
+
+func TestDefinitionSSZ(t *testing.T) {
+	v1x4, _, _ := cluster.NewForT(t, 1, 3, 4, 1)
+	v1x3, _, _ := cluster.NewForT(t, 1, 3, 4, 2, cluster.WithVersion("v1.3.0"))
+
+	draft := v1x4.Definition
+	draft.Operators = nil
+	for _, op := range v1x4.Definition.Operators {
+		draft.Operators = append(draft.Operators, cluster.Operator{Address: op.Address})
+	}
+	draft, err := draft.SetDefinitionHashes()
+	if err != nil {
+		t.Fatalf("set definition hashes: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		def  cluster.Definition
+	}{
+		{name: "v1.4", def: v1x4.Definition},
+		{name: "v1.3", def: v1x3.Definition},
+		{name: "draft", def: draft},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := marshalDefinitionSSZ(test.def)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+
+			def, err := unmarshalDefinitionSSZ(b)
+			if err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			} else if !bytes.Equal(def.ConfigHash, test.def.ConfigHash) {
+				t.Fatalf("config hash mismatch: got %#x, want %#x", def.ConfigHash, test.def.ConfigHash)
+			} else if !bytes.Equal(def.DefinitionHash, test.def.DefinitionHash) {
+				t.Fatalf("definition hash mismatch: got %#x, want %#x", def.DefinitionHash, test.def.DefinitionHash)
+			} else if len(def.Operators) != len(test.def.Operators) {
+				t.Fatalf("operators mismatch: got %d, want %d", len(def.Operators), len(test.def.Operators))
+			}
+
+			// Encoding is deterministic.
+			again, err := marshalDefinitionSSZ(def)
+			if err != nil {
+				t.Fatalf("marshal again: %v", err)
+			} else if !bytes.Equal(again, b) {
+				t.Fatalf("encoding mismatch")
+			}
+		})
+	}
+}
+
+func TestDefinitionSSZErrors(t *testing.T) {
+	lock, _, _ := cluster.NewForT(t, 1, 3, 4, 1)
+
+	valid, err := marshalDefinitionSSZ(lock.Definition)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	t.Run("marshal", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			mutate func(*cluster.Definition)
+		}{
+			{name: "unsupported version", mutate: func(def *cluster.Definition) { def.Version = "v1.2.0" }},
+			{name: "invalid address", mutate: func(def *cluster.Definition) { def.FeeRecipientAddress = "0x1234" }},
+			{name: "invalid hex address", mutate: func(def *cluster.Definition) { def.WithdrawalAddress = "0xzz" }},
+			{name: "invalid fork version", mutate: func(def *cluster.Definition) { def.ForkVersion = []byte{0x00} }},
+			{name: "name too long", mutate: func(def *cluster.Definition) { def.Name = string(make([]byte, sszMaxName+1)) }},
+			{name: "invalid signature", mutate: func(def *cluster.Definition) { def.Creator.ConfigSignature = []byte{0x01} }},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				def := lock.Definition
+				test.mutate(&def)
+
+				if _, err := marshalDefinitionSSZ(def); err == nil {
+					t.Fatalf("expected error")
+				}
+			})
+		}
+	})
+
+	t.Run("unmarshal", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			mutate func([]byte) []byte
+		}{
+			{name: "empty", mutate: func([]byte) []byte { return nil }},
+			{name: "truncated fixed part", mutate: func(b []byte) []byte { return b[:sszDefinitionFixedLen] }},
+			{
+				name: "truncated variable part",
+				mutate: func(b []byte) []byte {
+					return b[:sszDefinitionFixedLen+sszCreatorLen+sszConfigHashLen+1]
+				},
+			},
+			{
+				name: "invalid fixed length",
+				mutate: func(b []byte) []byte {
+					binary.LittleEndian.PutUint32(b, uint32(sszDefinitionFixedLen))
+					return b
+				},
+			},
+			{
+				name: "config hash mismatch",
+				mutate: func(b []byte) []byte {
+					b[sszDefinitionFixedLen+sszCreatorLen] ^= 0xff
+					return b
+				},
+			},
+			{
+				name: "name offset before uuid",
+				mutate: func(b []byte) []byte {
+					binary.LittleEndian.PutUint32(b[sszOffsetLen:], binary.LittleEndian.Uint32(b)-1)
+					return b
+				},
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				b := test.mutate(append([]byte(nil), valid...))
+
+				if _, err := unmarshalDefinitionSSZ(b); err == nil {
+					t.Fatalf("expected error")
+				}
+			})
+		}
+	})
+}
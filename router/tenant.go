@@ -0,0 +1,99 @@
+package router
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"github.com/corverroos/dvstore/service"
+	"github.com/gorilla/mux"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// tenantPathPrefix is the path prefix of the versioned routes scoped to a tenant, e.g. /tenants/acme/v1/dv.
+const tenantPathPrefix = "/tenants/{tenant}"
+
+// tenantRegex matches valid tenant names.
+var tenantRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// TenantKey is an API key bound to a tenant. Requests authenticated with the key are scoped to the tenant,
+// only accessing the tenant's definitions.
+type TenantKey struct {
+	Tenant string
+	Role   Role
+}
+
+// NewTenantAPIKeyAuthenticator returns an authenticator of bearer API keys bound to the tenants by API key.
+// Tenant keys may not be granted the admin role since admin endpoints operate across tenants.
+func NewTenantAPIKeyAuthenticator(keys map[string]TenantKey) (Authenticator, error) {
+	hashes := make(tenantKeyAuthenticator)
+	for key, tenantKey := range keys {
+		if !tenantKey.Role.Valid() || tenantKey.Role == RoleAdmin {
+			return nil, errors.New("invalid tenant api key role", z.Str("role", string(tenantKey.Role)))
+		} else if !tenantRegex.MatchString(tenantKey.Tenant) {
+			return nil, errors.New("invalid tenant name", z.Str("tenant", tenantKey.Tenant))
+		}
+		hashes[sha256.Sum256([]byte(key))] = tenantKey
+	}
+
+	return hashes, nil
+}
+
+// tenantKeyAuthenticator authenticates bearer API keys by the tenant keys by sha256 hash of the API key.
+type tenantKeyAuthenticator map[[32]byte]TenantKey
+
+func (a tenantKeyAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	keyHash := sha256.Sum256([]byte(strings.TrimPrefix(header, "Bearer ")))
+	tenantKey, ok := a[keyHash]
+	if !ok {
+		return Principal{}, ErrNoCredentials // Possibly a token of another authenticator.
+	}
+
+	return Principal{Subject: keySubject(keyHash), Role: tenantKey.Role, Tenant: tenantKey.Tenant}, nil
+}
+
+type boundTenantKey struct{}
+
+// withBoundTenant returns a copy of the context with the tenant the authenticated principal is bound to.
+func withBoundTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, boundTenantKey{}, tenant)
+}
+
+// withTenant returns a copy of the service context scoped to the request's tenant: the tenant the principal
+// is bound to, else the tenant of the path prefix, else the default tenant. It returns 403 Forbidden if a bound
+// principal requests another tenant's path.
+func withTenant(ctx context.Context, r *http.Request) (context.Context, error) {
+	tenant, hasPrefix := mux.Vars(r)["tenant"]
+	if hasPrefix && !tenantRegex.MatchString(tenant) {
+		return nil, apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    "invalid tenant name",
+		}
+	}
+
+	if bound, ok := ctx.Value(boundTenantKey{}).(string); ok {
+		if hasPrefix && tenant != bound {
+			return nil, apiError{
+				StatusCode: http.StatusForbidden,
+				Message:    fmt.Sprintf("credentials may not access tenant %s", tenant),
+			}
+		}
+		tenant = bound
+	}
+
+	label := tenant
+	if label == "" {
+		label = "default"
+	}
+	tenantRequests.WithLabelValues(label).Inc()
+
+	return service.WithTenant(ctx, tenant), nil
+}
@@ -0,0 +1,30 @@
+package router
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// uiPath is the path prefix of the embedded web UI.
+const uiPath = "/ui/"
+
+//go:embed ui
+var uiFS embed.FS
+
+// uiHandler returns a handler serving the embedded single-page web UI. The UI only contains static assets,
+// it reads clusters via the API using the API key entered by the user, so access control is enforced by the API.
+func uiHandler() http.Handler {
+	sub, err := fs.Sub(uiFS, "ui")
+	if err != nil {
+		panic(err) // Embedded directory always exists.
+	}
+
+	files := http.StripPrefix(uiPath, http.FileServer(http.FS(sub)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		files.ServeHTTP(w, r)
+	})
+}
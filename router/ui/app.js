@@ -0,0 +1,264 @@
+// dvstore web UI: a read-only single page app browsing stored clusters via the /v1 API.
+"use strict";
+
+const api = "../v1";
+const tokenKey = "dvstore-token";
+
+// el returns a new element with the attributes and children, text children are never parsed as html.
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs || {})) {
+    if (key.startsWith("on")) {
+      node.addEventListener(key.slice(2), value);
+    } else {
+      node.setAttribute(key, value);
+    }
+  }
+  for (const child of children) {
+    if (child === null || child === undefined) {
+      continue;
+    }
+    node.append(child instanceof Node ? child : String(child));
+  }
+  return node;
+}
+
+async function get(path) {
+  const headers = {};
+  const token = sessionStorage.getItem(tokenKey);
+  if (token) {
+    headers["Authorization"] = "Bearer " + token;
+  }
+
+  const resp = await fetch(api + path, { headers });
+  const body = await resp.json().catch(() => null);
+  if (!resp.ok) {
+    throw new Error((body && body.message) || resp.statusText);
+  }
+  return body;
+}
+
+function render(...nodes) {
+  document.getElementById("main").replaceChildren(...nodes);
+}
+
+function renderError(err) {
+  render(el("section", {}, el("p", { class: "error" }, err.message)));
+}
+
+function yesNo(value) {
+  return el("span", { class: value ? "yes" : "no" }, value ? "yes" : "no");
+}
+
+function clusterLink(hash, text) {
+  return el("a", { href: "#/dv/" + hash, class: "mono" }, text || hash);
+}
+
+// renderSearch renders the search form and the results of the name query or config hash prefix,
+// or a page of all definitions if there is no query.
+async function renderSearch(query, offset, cursor) {
+  const input = el("input", { type: "search", value: query, placeholder: "Name or 0x config hash prefix", size: 48 });
+  const form = el("form", {
+    onsubmit: (e) => {
+      e.preventDefault();
+      location.hash = "#/?q=" + encodeURIComponent(input.value.trim());
+    },
+  }, input, " ", el("button", { type: "submit" }, "Search"));
+
+  const results = el("section", {}, "Loading…");
+  render(el("section", {}, form), results);
+
+  if (!query) {
+    try {
+      const page = await get("/dv" + (cursor ? "?cursor=" + encodeURIComponent(cursor) : ""));
+      const table = el("table", {}, el("tr", {}, el("th", {}, "Name"), el("th", {}, "Config hash"),
+        el("th", {}, "Validators"), el("th", {}, "Operators")));
+      for (const def of page.definitions) {
+        table.append(el("tr", {}, el("td", {}, def.name), el("td", {}, clusterLink(def.config_hash)),
+          el("td", {}, def.num_validators), el("td", {}, (def.operators || []).length)));
+      }
+      results.replaceChildren(table);
+      if (page.next_cursor) {
+        results.append(el("p", {}, el("a", { href: "#/?cursor=" + encodeURIComponent(page.next_cursor) }, "Next page")));
+      }
+    } catch (err) {
+      results.replaceChildren(el("p", { class: "error" }, err.message));
+    }
+    return;
+  }
+
+  try {
+    const table = el("table", {}, el("tr", {}, el("th", {}, "Name"), el("th", {}, "Config hash"),
+      el("th", {}, "Network"), el("th", {}, "Status")));
+
+    let next = 0;
+    if (/^(0x)?[0-9a-fA-F]+$/.test(query)) {
+      for (const def of await get("/dv/prefix/" + query)) {
+        table.append(el("tr", {}, el("td", {}, def.name), el("td", {}, clusterLink(def.config_hash)),
+          el("td", {}, ""), el("td", {}, "")));
+      }
+    } else {
+      const page = await get("/dv?q=" + encodeURIComponent(query) + "&offset=" + offset);
+      for (const res of page.results) {
+        table.append(el("tr", {}, el("td", {}, res.name), el("td", {}, clusterLink(res.config_hash)),
+          el("td", {}, res.network), el("td", {}, el("span", { class: "status" }, res.status))));
+      }
+      next = page.next_offset || 0;
+    }
+
+    results.replaceChildren(table);
+    if (next) {
+      results.append(el("p", {}, el("a", { href: "#/?q=" + encodeURIComponent(query) + "&offset=" + next }, "Next page")));
+    }
+  } catch (err) {
+    results.replaceChildren(el("p", { class: "error" }, err.message));
+  }
+}
+
+// renderCluster renders the definition's state, operator and deposit progress, history and links to its artifacts.
+async function renderCluster(hash) {
+  render(el("section", {}, "Loading…"));
+
+  let cluster;
+  try {
+    cluster = await get("/cluster/" + hash);
+  } catch (err) {
+    renderError(err);
+    return;
+  }
+
+  const def = cluster.definition;
+  const state = cluster.state;
+
+  const overview = el("section", {},
+    el("h2", {}, def.name || "Unnamed cluster"),
+    el("table", {},
+      el("tr", {}, el("th", {}, "Config hash"), el("td", { class: "mono" }, def.config_hash)),
+      el("tr", {}, el("th", {}, "Status"), el("td", {}, el("span", { class: "status" }, state.status))),
+      el("tr", {}, el("th", {}, "Network"), el("td", {}, state.network)),
+      el("tr", {}, el("th", {}, "Version"), el("td", {}, state.version)),
+      el("tr", {}, el("th", {}, "Validators"), el("td", {}, def.num_validators)),
+      el("tr", {}, el("th", {}, "Threshold"), el("td", {}, def.threshold + " of " + cluster.operators.length)),
+      state.owner ? el("tr", {}, el("th", {}, "Owner"), el("td", { class: "mono" }, state.owner)) : null,
+      state.join_by ? el("tr", {}, el("th", {}, "Join by"), el("td", {}, state.join_by)) : null,
+    ),
+    el("p", {},
+      el("a", { href: api + "/dv/" + hash, target: "_blank" }, "Definition"), " · ",
+      cluster.lock ? el("a", { href: api + "/dv/" + hash + "/lock", target: "_blank" }, "Lock") : "No lock", " · ",
+      cluster.lock ? el("a", { href: api + "/dv/" + hash + "/deposit", target: "_blank" }, "Deposit data") : "No deposit data",
+    ),
+  );
+
+  const operators = el("table", {}, el("tr", {}, el("th", {}, "Operator"), el("th", {}, "Signed"), el("th", {}, "Declined")));
+  for (const op of cluster.operators) {
+    operators.append(el("tr", {}, el("td", { class: "mono" }, op.address), el("td", {}, yesNo(op.accepted)),
+      el("td", {}, op.declined ? el("span", { class: "no" }, "declined") : "")));
+  }
+
+  const sections = [overview, el("section", {}, el("h3", {}, "Operators"), operators)];
+
+  if (cluster.deposits && cluster.deposits.length > 0) {
+    const deposits = el("table", {}, el("tr", {}, el("th", {}, "Validator"), el("th", {}, "Partial signatures"), el("th", {}, "Aggregated")));
+    for (const dep of cluster.deposits) {
+      deposits.append(el("tr", {}, el("td", { class: "mono" }, dep.pubkey), el("td", {}, dep.partials), el("td", {}, yesNo(dep.aggregated))));
+    }
+    sections.push(el("section", {}, el("h3", {}, "Deposits"), deposits));
+  }
+
+  const history = el("section", {}, el("h3", {}, "History"), "Loading…");
+  sections.push(history);
+  render(...sections);
+
+  try {
+    renderHistory(history, hash, await get("/dv/" + hash + "/history"));
+  } catch (err) {
+    history.replaceChildren(el("h3", {}, "History"), el("p", { class: "error" }, err.message));
+  }
+}
+
+// renderHistory renders the definition's events and a diff between two selected revisions.
+function renderHistory(section, hash, events) {
+  const table = el("table", {}, el("tr", {}, el("th", {}, "Revision"), el("th", {}, "Event"), el("th", {}, "Time")));
+  const from = el("select", {});
+  const to = el("select", {});
+  for (const event of events) {
+    table.append(el("tr", {}, el("td", {}, event.revision), el("td", {}, event.type), el("td", {}, event.timestamp)));
+    from.append(el("option", { value: event.revision }, event.revision + " " + event.type));
+    to.append(el("option", { value: event.revision }, event.revision + " " + event.type));
+  }
+  if (events.length > 1) {
+    from.value = events[events.length - 2].revision;
+    to.value = events[events.length - 1].revision;
+  }
+
+  const diff = el("div", {});
+  const compare = el("button", {
+    onclick: async () => {
+      diff.replaceChildren("Loading…");
+      try {
+        const [a, b] = await Promise.all([
+          get("/dv/" + hash + "/history/" + from.value),
+          get("/dv/" + hash + "/history/" + to.value),
+        ]);
+        diff.replaceChildren(renderDiff(flatten(a), flatten(b)));
+      } catch (err) {
+        diff.replaceChildren(el("p", { class: "error" }, err.message));
+      }
+    },
+  }, "Diff");
+
+  section.replaceChildren(el("h3", {}, "History"), table,
+    events.length > 1 ? el("p", {}, "Compare ", from, " with ", to, " ", compare) : null, diff);
+}
+
+// flatten returns the json value's leaves by path, e.g. {"definition.operators.0.enr": "enr:..."}.
+function flatten(value, prefix, out) {
+  out = out || {};
+  if (value !== null && typeof value === "object") {
+    for (const [key, child] of Object.entries(value)) {
+      flatten(child, prefix ? prefix + "." + key : key, out);
+    }
+  } else {
+    out[prefix] = value;
+  }
+  return out;
+}
+
+// renderDiff renders the changed leaves between the flattened revisions.
+function renderDiff(before, after) {
+  const paths = [...new Set([...Object.keys(before), ...Object.keys(after)])].sort();
+  const table = el("table", {}, el("tr", {}, el("th", {}, "Field"), el("th", {}, "Before"), el("th", {}, "After")));
+  let changes = 0;
+  for (const path of paths) {
+    if (before[path] === after[path]) {
+      continue;
+    }
+    changes++;
+    table.append(el("tr", {}, el("td", { class: "mono" }, path),
+      el("td", { class: "mono diff-removed" }, path in before ? String(before[path]) : ""),
+      el("td", { class: "mono diff-added" }, path in after ? String(after[path]) : "")));
+  }
+  return changes ? table : el("p", {}, "No changes.");
+}
+
+function route() {
+  const [path, search] = location.hash.replace(/^#/, "").split("?");
+  const params = new URLSearchParams(search || "");
+
+  const match = (path || "").match(/^\/dv\/(0x[0-9a-fA-F]+)$/);
+  if (match) {
+    renderCluster(match[1]);
+    return;
+  }
+
+  renderSearch(params.get("q") || "", parseInt(params.get("offset") || "0", 10), params.get("cursor") || "");
+}
+
+document.getElementById("token").value = sessionStorage.getItem(tokenKey) || "";
+document.getElementById("token-form").addEventListener("submit", (e) => {
+  e.preventDefault();
+  sessionStorage.setItem(tokenKey, document.getElementById("token").value);
+  route();
+});
+window.addEventListener("hashchange", route);
+route();
@@ -0,0 +1,132 @@
+package router
+
+import (
+	"context"
+	"github.com/corverroos/dvstore/service"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// errUploadsDisabled is returned by the upload endpoints if chunked uploads are disabled.
+var errUploadsDisabled = apiError{
+	StatusCode: http.StatusNotFound,
+	Message:    "chunked uploads disabled",
+}
+
+// committedUpload is the response of committing a chunked upload.
+type committedUpload struct {
+	Upload service.Upload `json:"upload"`
+	// Result is the response of creating the definition or locking it, if any.
+	Result interface{} `json:"result,omitempty"`
+}
+
+func initUpload(uploads service.Uploads, kind service.UploadKind) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if uploads == nil {
+			return nil, errUploadsDisabled
+		}
+
+		var hash []byte
+		if kind == service.UploadLock {
+			hash, err = configHash(params)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return uploads.Init(ctx, kind, hash, principalFromCtx(ctx))
+	}
+}
+
+func getUpload(uploads service.Uploads) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if uploads == nil {
+			return nil, errUploadsDisabled
+		}
+
+		return uploads.Get(ctx, params["id"], principalFromCtx(ctx))
+	}
+}
+
+func appendUploadPart(uploads service.Uploads) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if uploads == nil {
+			return nil, errUploadsDisabled
+		}
+
+		index, err := strconv.Atoi(params["index"])
+		if err != nil {
+			return nil, apiError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "invalid upload part index",
+				Err:        err,
+			}
+		}
+
+		var req struct {
+			Data []byte `json:"data"` // Base64 encoded part.
+		}
+		if err := unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+
+		return nil, uploads.Append(ctx, params["id"], principalFromCtx(ctx), index, req.Data)
+	}
+}
+
+// commitUpload returns a handler assembling the upload and creating or locking the definition with the payload
+// via the handler of the upload's kind. The upload is deleted if successful, otherwise it may be retried or
+// aborted until it expires.
+func commitUpload(uploads service.Uploads, createHandler, lockHandler handlerFunc, limits JSONLimits) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if uploads == nil {
+			return nil, errUploadsDisabled
+		}
+
+		var req struct {
+			SHA256 hexBytes `json:"sha256"`
+		}
+		if err := unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+
+		principal := principalFromCtx(ctx)
+
+		payload, upload, err := uploads.Assemble(ctx, params["id"], principal, req.SHA256)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkLimits(payload, limits); err != nil {
+			return nil, err
+		}
+
+		var result interface{}
+		switch upload.Kind {
+		case service.UploadDefinition:
+			result, err = createHandler(ctx, nil, query, payload)
+		case service.UploadLock:
+			result, err = lockHandler(ctx, map[string]string{"config_hash": upload.ConfigHash}, query, payload)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := uploads.Delete(ctx, upload.ID, principal); err != nil {
+			return nil, err
+		}
+
+		return committedUpload{Upload: upload, Result: result}, nil
+	}
+}
+
+func abortUpload(uploads service.Uploads) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		if uploads == nil {
+			return nil, errUploadsDisabled
+		}
+
+		return nil, uploads.Delete(ctx, params["id"], principalFromCtx(ctx))
+	}
+}
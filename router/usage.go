@@ -0,0 +1,134 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// anonymousSubject is the usage subject of unauthenticated requests, aggregated to bound the number of subjects.
+const anonymousSubject = "anonymous"
+
+// Usage is the request usage of a client subject, an API key, HMAC key or OIDC user, since the instance started.
+type Usage struct {
+	Subject   string    `json:"subject"`
+	Requests  int64     `json:"requests"`
+	Errors    int64     `json:"errors"`
+	ErrorRate float64   `json:"error_rate"`
+	BytesIn   int64     `json:"bytes_in"`
+	BytesOut  int64     `json:"bytes_out"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// usageTracker tracks request usage by client subject.
+// State is in-memory so usage is per instance and is cleared on restart; aggregate across instances via the metrics.
+type usageTracker struct {
+	mu    sync.Mutex
+	usage map[string]*Usage
+}
+
+// newUsageTracker returns a new usage tracker or nil if access control, and therefore client subjects, is disabled.
+func newUsageTracker(auth *authorizer) *usageTracker {
+	if auth == nil {
+		return nil
+	}
+
+	return &usageTracker{usage: make(map[string]*Usage)}
+}
+
+// Record records a request of the subject.
+func (u *usageTracker) Record(subject string, statusCode int, bytesIn, bytesOut int64) {
+	isErr := statusCode/100 == 4 || statusCode/100 == 5
+	observeUsage(subject, isErr, bytesIn, bytesOut)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	usage, ok := u.usage[subject]
+	if !ok {
+		usage = &Usage{Subject: subject}
+		u.usage[subject] = usage
+	}
+
+	usage.Requests++
+	if isErr {
+		usage.Errors++
+	}
+	usage.BytesIn += bytesIn
+	usage.BytesOut += bytesOut
+	usage.LastSeen = time.Now()
+}
+
+// List returns the usage of all subjects ordered by descending requests.
+func (u *usageTracker) List() []Usage {
+	if u == nil {
+		return []Usage{}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	resp := make([]Usage, 0, len(u.usage))
+	for _, usage := range u.usage {
+		usage := *usage
+		usage.ErrorRate = float64(usage.Errors) / float64(usage.Requests)
+		resp = append(resp, usage)
+	}
+
+	sort.Slice(resp, func(i, j int) bool {
+		if resp[i].Requests != resp[j].Requests {
+			return resp[i].Requests > resp[j].Requests
+		}
+
+		return resp[i].Subject < resp[j].Subject
+	})
+
+	return resp
+}
+
+// Middleware returns a handler recording the usage of the request's authenticated subject.
+// It must wrap handlers after the authorizer identified the subject.
+func (u *usageTracker) Middleware(next http.Handler) http.Handler {
+	if u == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject, ok := r.Context().Value(subjectKey{}).(string)
+		if !ok {
+			subject = anonymousSubject
+		}
+
+		var bytesIn int64
+		if r.ContentLength > 0 {
+			bytesIn = r.ContentLength
+		}
+
+		cw := &countingWriter{statusWriter: &statusWriter{ResponseWriter: w, statusCode: http.StatusOK}}
+		next.ServeHTTP(cw, r)
+
+		u.Record(subject, cw.statusCode, bytesIn, cw.bytes)
+	})
+}
+
+// countingWriter is a http.ResponseWriter that records the response status code and number of bytes written.
+type countingWriter struct {
+	*statusWriter
+	bytes int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.statusWriter.Write(b)
+	w.bytes += int64(n)
+
+	return n, err
+}
+
+func listUsage(usage *usageTracker) handlerFunc {
+	return func(ctx context.Context, params map[string]string, query url.Values, body []byte) (res interface{}, err error) {
+		return usage.List(), nil
+	}
+}
@@ -0,0 +1,56 @@
+package router
+
+import (
+	"context"
+	"github.com/corverroos/dvstore/service"
+	"github.com/obolnetwork/charon/cluster"
+	"net/http"
+	"net/url"
+)
+
+// acceptVersionHeader is the request header of the definition version clients expect in responses.
+const acceptVersionHeader = "Accept-Version"
+
+type acceptVersionKey struct{}
+
+// withAcceptVersion returns a copy of the context with the request's Accept-Version header, if any.
+func withAcceptVersion(ctx context.Context, r *http.Request) context.Context {
+	version := r.Header.Get(acceptVersionHeader)
+	if version == "" {
+		return ctx
+	}
+
+	return context.WithValue(ctx, acceptVersionKey{}, version)
+}
+
+// acceptedVersion returns the definition version requested via the version query parameter or
+// the Accept-Version header, the query parameter taking precedence. It returns false if none was requested.
+func acceptedVersion(ctx context.Context, query url.Values) (string, bool) {
+	if version := query.Get("version"); version != "" {
+		return version, true
+	}
+
+	version, ok := ctx.Value(acceptVersionKey{}).(string)
+
+	return version, ok
+}
+
+// convertVersion returns the definition converted to the requested version, if any.
+// It returns 406 Not Acceptable if the definition can't be converted.
+func convertVersion(ctx context.Context, query url.Values, def cluster.Definition) (cluster.Definition, error) {
+	version, ok := acceptedVersion(ctx, query)
+	if !ok {
+		return def, nil
+	}
+
+	resp, err := service.ConvertDefinition(def, version)
+	if err != nil {
+		return cluster.Definition{}, apiError{
+			StatusCode: http.StatusNotAcceptable,
+			Message:    err.Error(),
+			Err:        err,
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,122 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"gopkg.in/yaml.v3"
+	"net/http"
+	"strings"
+)
+
+// yamlContentType is the media type of YAML encoded request and response bodies.
+const yamlContentType = "application/yaml"
+
+// yamlContentTypes are the accepted YAML media types, including the unregistered ones used by older tools.
+var yamlContentTypes = []string{yamlContentType, "application/x-yaml", "text/yaml"}
+
+// isYAML returns true if the content type or accept header contains a YAML media type.
+func isYAML(header string) bool {
+	for _, contentType := range yamlContentTypes {
+		if strings.Contains(header, contentType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// yamlAccepted returns true if the request accepts YAML encoded responses.
+func yamlAccepted(r *http.Request) bool {
+	return isYAML(r.Header.Get("Accept"))
+}
+
+// yamlToJSON returns the json encoding of the YAML request body, so handlers only have to decode json.
+func yamlToJSON(body []byte) ([]byte, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(body, &v); err != nil {
+		return nil, apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    "Invalid yaml body",
+			Err:        err,
+		}
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		// Only mappings with non-string keys aren't representable as json.
+		return nil, apiError{
+			StatusCode: http.StatusBadRequest,
+			Message:    "Invalid yaml body, mapping keys must be strings",
+			Err:        err,
+		}
+	}
+
+	return b, nil
+}
+
+// jsonToYAML returns the YAML encoding of the json, retaining its key order.
+func jsonToYAML(b []byte) ([]byte, error) {
+	// YAML is a superset of json, so parsing json yields its ordered node tree.
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return nil, errors.Wrap(err, "parse json as yaml")
+	}
+	resetStyle(&node)
+
+	resp, err := yaml.Marshal(&node)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal yaml")
+	}
+
+	return resp, nil
+}
+
+// resetStyle recursively resets the json flow and quoting styles of the node, so it is encoded in block style.
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
+
+// writeYAMLResponse writes the 200 OK response and YAML encoded response body.
+// The response is encoded as json first, so YAML responses have the same fields as json responses.
+func writeYAMLResponse(ctx context.Context, w http.ResponseWriter, endpoint string, response interface{}) {
+	response = writeETag(w, response)
+
+	cacheControl := "no-cache"
+	if imm, ok := response.(immutable); ok {
+		cacheControl = "public, max-age=31536000, immutable"
+		response = imm.Body
+	}
+
+	if response == nil {
+		w.Header().Set("Cache-Control", cacheControl)
+		w.WriteHeader(http.StatusOK)
+
+		return
+	}
+
+	b, err := json.Marshal(response)
+	if err != nil {
+		writeError(ctx, w, endpoint, errors.Wrap(err, "marshal response body"))
+		return
+	}
+
+	b, err = jsonToYAML(b)
+	if err != nil {
+		writeError(ctx, w, endpoint, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("Content-Type", yamlContentType)
+	signBody(ctx, w, b)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err = w.Write(b); err != nil {
+		log.Error(ctx, "Failed writing api response", err)
+	}
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"sync"
+	"time"
+)
+
+// ReindexProgress is the progress of the latest background reindex.
+type ReindexProgress struct {
+	Running  bool      `json:"running"`
+	Total    int       `json:"total"`
+	Created  int       `json:"created"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// CleanupOptions are the options of an on-demand cleanup.
+type CleanupOptions struct {
+	// DryRun reports the actions that would be taken without taking them.
+	DryRun bool `json:"dry_run"`
+	// Compact runs the mongo compact command on the definitions collection to release unused disk space.
+	Compact bool `json:"compact"`
+}
+
+// Inconsistency is a definition document failing a consistency check.
+type Inconsistency struct {
+	ConfigHash string `json:"config_hash"`
+	Problem    string `json:"problem"`
+}
+
+// CleanupReport is the structured report of the actions taken by a cleanup.
+type CleanupReport struct {
+	DryRun bool `json:"dry_run"`
+	// Checked is the number of definition documents checked.
+	Checked int `json:"checked"`
+	// Expired are the config hashes of the expired drafts deleted, or that would be deleted if a dry run.
+	Expired []string `json:"expired"`
+	// Inconsistent are the definition documents failing consistency checks. They are reported, never modified.
+	Inconsistent []Inconsistency `json:"inconsistent"`
+	// Compacted is true if the definitions collection was compacted.
+	Compacted bool `json:"compacted"`
+}
+
+type Admin interface {
+	// Reindex starts (re)creating missing definitions collection indexes in the background.
+	// It returns ErrConflict if a reindex is already running.
+	Reindex(ctx context.Context) (ReindexProgress, error)
+	// ReindexProgress returns the progress of the latest reindex.
+	ReindexProgress() ReindexProgress
+	// Cleanup deletes expired drafts, checks the consistency of all definition documents and
+	// optionally compacts the definitions collection.
+	Cleanup(ctx context.Context, opts CleanupOptions) (CleanupReport, error)
+	// CancelOverdue cancels the draft definitions whose join deadline passed and returns their config hashes.
+	CancelOverdue(ctx context.Context) ([]string, error)
+	// ExpireStale deletes the definitions that never became ready within the definition TTL and returns their config hashes.
+	ExpireStale(ctx context.Context) ([]string, error)
+	// PurgeTrash permanently deletes the definitions deleted longer than the trash retention ago and returns their count.
+	PurgeTrash(ctx context.Context) (int, error)
+	// CheckAlarms evaluates the quota and retention alarms, notifying alarms that start firing.
+	CheckAlarms(ctx context.Context) error
+	// Alarms returns the firing alarms.
+	Alarms() []Alarm
+	// SamplePendingOperators samples the number of operators yet to join each draft definition.
+	SamplePendingOperators(ctx context.Context) error
+}
+
+func NewAdmin(table *mongo.Collection, conf DefinitionConfig) Admin {
+	store := newMongoStore(table, conf)
+
+	return &adminImpl{
+		table: table,
+		store: store,
+		defs: definitionImpl{
+			store: store,
+			conf:  conf,
+		},
+		alarms: make(map[string]Alarm),
+	}
+}
+
+type adminImpl struct {
+	table *mongo.Collection
+	store mongoStore
+	defs  definitionImpl
+
+	mu       sync.Mutex
+	progress ReindexProgress
+	alarms   map[string]Alarm // Firing alarms by name.
+	storage  storageSample    // Previous storage size sample.
+}
+
+func (a *adminImpl) Reindex(ctx context.Context) (ReindexProgress, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.progress.Running {
+		return ReindexProgress{}, errors.Wrap(ErrConflict, "reindex already running")
+	}
+
+	a.progress = ReindexProgress{
+		Running: true,
+		Total:   len(indexes),
+		Started: time.Now(),
+	}
+
+	// Detach from the request context since the reindex outlives the request.
+	go a.reindex(log.WithTopic(context.Background(), "reindex"))
+
+	return a.progress, nil
+}
+
+// reindex creates the indexes one at a time, updating the progress after each.
+// Creating an existing index is a no-op.
+func (a *adminImpl) reindex(ctx context.Context) {
+	log.Info(ctx, "Reindex started", z.Int("total", len(indexes)))
+
+	var err error
+	for _, index := range indexes {
+		if _, err = a.table.Indexes().CreateOne(ctx, index); err != nil {
+			err = errors.Wrap(err, "failed to create index")
+			break
+		}
+
+		a.mu.Lock()
+		a.progress.Created++
+		a.mu.Unlock()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.progress.Running = false
+	a.progress.Finished = time.Now()
+	if err != nil {
+		a.progress.Error = err.Error()
+		log.Error(ctx, "Reindex failed", err)
+
+		return
+	}
+
+	log.Info(ctx, "Reindex completed", z.Int("created", a.progress.Created))
+}
+
+func (a *adminImpl) ReindexProgress() ReindexProgress {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.progress
+}
+
+func (a *adminImpl) Cleanup(ctx context.Context, opts CleanupOptions) (CleanupReport, error) {
+	resp := CleanupReport{
+		DryRun:       opts.DryRun,
+		Expired:      []string{},
+		Inconsistent: []Inconsistency{},
+	}
+
+	cursor, err := a.table.Find(ctx, bson.D{})
+	if err != nil {
+		return CleanupReport{}, errors.Wrap(err, "failed to find definitions")
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc definitionDoc
+		if err := cursor.Decode(&doc); err != nil {
+			return CleanupReport{}, errors.Wrap(err, "failed to decode definition")
+		}
+		resp.Checked++
+
+		configHash := fmt.Sprintf("%#x", doc.ConfigHash)
+
+		if a.defs.expired(doc) {
+			resp.Expired = append(resp.Expired, configHash)
+			if opts.DryRun {
+				continue
+			}
+
+			// Only delete if still a draft of the same revision, i.e., not concurrently joined.
+			res, err := a.table.DeleteOne(ctx, bson.D{{"config_hash", doc.ConfigHash}, {"revision", doc.Revision}, {"status", StatusDraft}})
+			if err != nil {
+				return CleanupReport{}, errors.Wrap(err, "failed to delete expired definition")
+			} else if res.DeletedCount > 0 {
+				a.defs.appendEvent(ctx, EventDeleted, doc.ConfigHash, doc.Revision+1, nil)
+			}
+
+			continue
+		}
+
+		for _, problem := range checkConsistency(doc) {
+			resp.Inconsistent = append(resp.Inconsistent, Inconsistency{
+				ConfigHash: configHash,
+				Problem:    problem,
+			})
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return CleanupReport{}, errors.Wrap(err, "failed to iterate definitions")
+	}
+
+	if opts.Compact && !opts.DryRun {
+		res := a.table.Database().RunCommand(ctx, bson.D{{"compact", a.table.Name()}})
+		if err := res.Err(); err != nil {
+			return CleanupReport{}, errors.Wrap(err, "failed to compact definitions")
+		}
+		resp.Compacted = true
+	}
+
+	log.Info(ctx, "Cleanup completed",
+		z.Bool("dry_run", opts.DryRun),
+		z.Int("checked", resp.Checked),
+		z.Int("expired", len(resp.Expired)),
+		z.Int("inconsistent", len(resp.Inconsistent)),
+		z.Bool("compacted", resp.Compacted))
+
+	return resp, nil
+}
+
+// checkConsistency returns the consistency problems of the definition document.
+func checkConsistency(doc definitionDoc) []string {
+	var resp []string
+
+	if !bytes.Equal(doc.ConfigHash, doc.Definition.ConfigHash) {
+		resp = append(resp, "config hash doesn't match definition")
+	}
+
+	// Draft definition hashes are only valid once all operators joined, so only final definitions are verified.
+	if doc.Status.Final() {
+		if err := doc.Definition.VerifyHashes(); err != nil {
+			resp = append(resp, "invalid definition hashes: "+err.Error())
+		}
+	}
+
+	switch {
+	case doc.Status == StatusLocked && doc.Lock == nil:
+		resp = append(resp, "locked without lock")
+	case doc.Status != StatusLocked && doc.Lock != nil:
+		resp = append(resp, "lock without locked status")
+	case doc.Lock != nil && !bytes.Equal(doc.Lock.Definition.ConfigHash, doc.ConfigHash):
+		resp = append(resp, "lock config hash doesn't match definition")
+	}
+
+	return resp
+}
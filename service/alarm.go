@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// Alarm names.
+const (
+	AlarmOwnerQuota     = "owner_quota"
+	AlarmStorageGrowth  = "storage_growth"
+	AlarmExpiringDrafts = "expiring_drafts"
+)
+
+// AlarmConfig defines the quota and retention alarms. Alarms with zero thresholds are disabled.
+type AlarmConfig struct {
+	// OwnerQuota is the maximum number of definitions per creator address. It is not enforced if zero.
+	OwnerQuota int
+	// QuotaWarnRatio is the ratio of the owner quota above which the owner quota alarm fires.
+	QuotaWarnRatio float64
+	// StorageGrowth is the growth of the definitions collection storage in bytes per hour above which
+	// the storage growth alarm fires.
+	StorageGrowth int64
+	// ExpiryWarning is the period before draft expiry in which drafts are about to expire.
+	ExpiryWarning time.Duration
+	// ExpiringDrafts is the number of drafts about to expire above which the expiring drafts alarm fires.
+	ExpiringDrafts int
+}
+
+// Alarm is a firing alarm.
+type Alarm struct {
+	Name    string    `json:"name"`
+	Message string    `json:"message"`
+	Since   time.Time `json:"since"`
+}
+
+// storageSample is a sample of the definitions collection storage size.
+type storageSample struct {
+	Bytes int64
+	Time  time.Time
+}
+
+// verifyOwnerQuota returns ErrInvalidState if the owner reached its definition quota, or ErrQuotaExceeded if it
+// reached its quota of active definitions, including the pending definitions being created.
+func (d definitionImpl) verifyOwnerQuota(ctx context.Context, owner string, pending int) error {
+	if owner == "" {
+		return nil
+	}
+
+	if d.conf.Alarms.OwnerQuota > 0 {
+		count, err := d.countOwned(ctx, owner, nil)
+		if err != nil {
+			return err
+		} else if count+pending >= d.conf.Alarms.OwnerQuota {
+			return errors.Wrap(ErrInvalidState, "owner definition quota exceeded",
+				z.Str("owner", owner), z.Int("quota", d.conf.Alarms.OwnerQuota))
+		}
+	}
+
+	if d.conf.CreatorQuota > 0 {
+		count, err := d.countOwned(ctx, owner, activeStatuses)
+		if err != nil {
+			return err
+		} else if active := count + pending; active >= d.conf.CreatorQuota {
+			return errors.Wrap(ErrQuotaExceeded, "creator quota exceeded",
+				z.Str("creator", owner), z.Int("active", active), z.Int("quota", d.conf.CreatorQuota))
+		}
+	}
+
+	return nil
+}
+
+func (a *adminImpl) Alarms() []Alarm {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	resp := []Alarm{}
+	for _, name := range []string{AlarmOwnerQuota, AlarmStorageGrowth, AlarmExpiringDrafts} {
+		if alarm, ok := a.alarms[name]; ok {
+			resp = append(resp, alarm)
+		}
+	}
+
+	return resp
+}
+
+func (a *adminImpl) CheckAlarms(ctx context.Context) error {
+	conf := a.defs.conf.Alarms
+
+	checks := []struct {
+		Name    string
+		Enabled bool
+		Check   func(context.Context) (string, error)
+	}{
+		{Name: AlarmOwnerQuota, Enabled: conf.OwnerQuota > 0 && conf.QuotaWarnRatio > 0, Check: a.checkOwnerQuota},
+		{Name: AlarmStorageGrowth, Enabled: conf.StorageGrowth > 0, Check: a.checkStorageGrowth},
+		{Name: AlarmExpiringDrafts, Enabled: conf.ExpiringDrafts > 0 && a.defs.conf.DraftExpiry > 0, Check: a.checkExpiringDrafts},
+	}
+
+	for _, check := range checks {
+		if !check.Enabled {
+			continue
+		}
+
+		msg, err := check.Check(ctx)
+		if err != nil {
+			return err
+		}
+
+		a.setAlarm(ctx, check.Name, msg)
+	}
+
+	return nil
+}
+
+// setAlarm updates the alarm state, firing the alarm if the message is non-empty and resolving it otherwise.
+// Notifications are only sent when the alarm starts firing.
+func (a *adminImpl) setAlarm(ctx context.Context, name string, msg string) {
+	a.mu.Lock()
+	_, firing := a.alarms[name]
+	if msg == "" {
+		delete(a.alarms, name)
+	} else if !firing {
+		a.alarms[name] = Alarm{Name: name, Message: msg, Since: time.Now()}
+	}
+	a.mu.Unlock()
+
+	if msg == "" {
+		alarmGauge.WithLabelValues(name).Set(0)
+		if firing {
+			log.Info(ctx, "Alarm resolved", z.Str("alarm", name))
+		}
+
+		return
+	}
+
+	alarmGauge.WithLabelValues(name).Set(1)
+	if firing {
+		return
+	}
+
+	log.Warn(ctx, "Alarm firing", nil, z.Str("alarm", name), z.Str("message", msg))
+
+	if a.defs.conf.Notifier == nil {
+		return
+	}
+
+	if err := a.defs.conf.Notifier.Alarm(ctx, name, msg); err != nil {
+		log.Warn(ctx, "Failed notifying alarm", err, z.Str("alarm", name))
+	}
+}
+
+// checkOwnerQuota returns an alarm message if any owner's definition count exceeds the quota warn ratio.
+func (a *adminImpl) checkOwnerQuota(ctx context.Context) (string, error) {
+	conf := a.defs.conf.Alarms
+	threshold := int(float64(conf.OwnerQuota) * conf.QuotaWarnRatio)
+
+	cursor, err := a.table.Aggregate(ctx, bson.A{
+		bson.D{{"$match", bson.D{{"owner", bson.D{{"$ne", ""}}}}}},
+		bson.D{{"$group", bson.D{{"_id", "$owner"}, {"count", bson.D{{"$sum", 1}}}}}},
+		bson.D{{"$match", bson.D{{"count", bson.D{{"$gte", threshold}}}}}},
+		bson.D{{"$sort", bson.D{{"count", -1}}}},
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to aggregate owner counts")
+	}
+
+	var owners []struct {
+		Owner string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := cursor.All(ctx, &owners); err != nil {
+		return "", errors.Wrap(err, "failed to decode owner counts")
+	}
+
+	ownersNearQuota.Set(float64(len(owners)))
+
+	if len(owners) == 0 {
+		return "", nil
+	}
+
+	return fmt.Sprintf("%d owners are approaching their quota of %d definitions, the largest %s has %d",
+		len(owners), conf.OwnerQuota, owners[0].Owner, owners[0].Count), nil
+}
+
+// checkStorageGrowth returns an alarm message if the definitions collection storage grew faster than the threshold
+// since the previous sample.
+func (a *adminImpl) checkStorageGrowth(ctx context.Context) (string, error) {
+	res := a.table.Database().RunCommand(ctx, bson.D{{"collStats", a.table.Name()}})
+
+	var stats struct {
+		StorageSize int64 `bson:"storageSize"`
+	}
+	if err := res.Decode(&stats); err != nil {
+		return "", errors.Wrap(err, "failed to get collection stats")
+	}
+
+	storageBytes.Set(float64(stats.StorageSize))
+
+	sample := storageSample{Bytes: stats.StorageSize, Time: time.Now()}
+
+	a.mu.Lock()
+	prev := a.storage
+	a.storage = sample
+	a.mu.Unlock()
+
+	if prev.Time.IsZero() {
+		return "", nil
+	}
+
+	perHour := float64(sample.Bytes-prev.Bytes) / sample.Time.Sub(prev.Time).Hours()
+	if perHour <= float64(a.defs.conf.Alarms.StorageGrowth) {
+		return "", nil
+	}
+
+	return fmt.Sprintf("Definitions storage is growing %.0f bytes per hour, exceeding the threshold of %d",
+		perHour, a.defs.conf.Alarms.StorageGrowth), nil
+}
+
+// checkExpiringDrafts returns an alarm message if the number of drafts expiring within the warning period
+// exceeds the threshold.
+func (a *adminImpl) checkExpiringDrafts(ctx context.Context) (string, error) {
+	cursor, err := a.table.Find(ctx, bson.D{{"status", StatusDraft}, {"pinned", bson.D{{"$ne", true}}}},
+		options.Find().SetProjection(bson.D{{"definition.timestamp", 1}}))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find drafts")
+	}
+	defer cursor.Close(ctx)
+
+	var expiring int
+	for cursor.Next(ctx) {
+		var doc struct {
+			Definition struct {
+				Timestamp string `bson:"timestamp"`
+			} `bson:"definition"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return "", errors.Wrap(err, "failed to decode draft")
+		}
+
+		timestamp, err := time.Parse(time.RFC3339, doc.Definition.Timestamp)
+		if err != nil {
+			continue // Older definition versions may not contain a timestamp.
+		}
+
+		remaining := a.defs.conf.DraftExpiry - time.Since(timestamp)
+		if remaining > 0 && remaining <= a.defs.conf.Alarms.ExpiryWarning {
+			expiring++
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return "", errors.Wrap(err, "failed to iterate drafts")
+	}
+
+	expiringDrafts.Set(float64(expiring))
+
+	if expiring <= a.defs.conf.Alarms.ExpiringDrafts {
+		return "", nil
+	}
+
+	return fmt.Sprintf("%d drafts expire within %s, exceeding the threshold of %d",
+		expiring, a.defs.conf.Alarms.ExpiryWarning, a.defs.conf.Alarms.ExpiringDrafts), nil
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// archiveVersion is the version of the archive format.
+	archiveVersion = 1
+	// archiveManifest is the name of the archive's manifest file, the first file of the archive.
+	archiveManifest = "manifest.json"
+	// archiveClusters is the directory of the archive's cluster files.
+	archiveClusters = "clusters/"
+	// archivePageSize is the number of definitions listed per page when writing archives.
+	archivePageSize = 100
+)
+
+// ArchiveManifest describes an archive of all published clusters of a dvstore instance, used for backups and
+// migrating instances. An archive is a gzipped tar file containing the following json files:
+//   - manifest.json: the ArchiveManifest.
+//   - clusters/<config_hash>.json: an ArchiveEntry per cluster.
+type ArchiveManifest struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// ArchiveEntry is an archived cluster: its bundle including the definition, lock and change log,
+// and its audit entries.
+type ArchiveEntry struct {
+	Bundle Bundle       `json:"bundle"`
+	Audit  []AuditEntry `json:"audit"`
+}
+
+// ArchiveReport is the report of importing an archive.
+type ArchiveReport struct {
+	Imported int `json:"imported"`
+	// Skipped is the number of clusters not imported since they already exist.
+	Skipped int `json:"skipped"`
+	// Failed are the clusters that failed to import.
+	Failed []ArchiveFailure `json:"failed"`
+}
+
+// ArchiveFailure is a cluster that failed to import.
+type ArchiveFailure struct {
+	ConfigHash string `json:"config_hash"`
+	Error      string `json:"error"`
+}
+
+// WriteArchive writes the archive of all published clusters of the context's tenant and their audit entries to w
+// and returns the number of clusters written. Unpublished definitions are private working copies and are excluded.
+// The audit may be nil if not supported by the storage.
+func WriteArchive(ctx context.Context, w io.Writer, defs Definition, audit Audit) (int, error) {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	now := time.Now().UTC()
+
+	writeFile := func(name string, v interface{}) error {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "marshal archive file", z.Str("name", name))
+		}
+
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0o644,
+			Size:     int64(len(b)),
+			ModTime:  now,
+		}); err != nil {
+			return errors.Wrap(err, "write archive header", z.Str("name", name))
+		} else if _, err := tw.Write(b); err != nil {
+			return errors.Wrap(err, "write archive file", z.Str("name", name))
+		}
+
+		return nil
+	}
+
+	if err := writeFile(archiveManifest, ArchiveManifest{Version: archiveVersion, ExportedAt: now}); err != nil {
+		return 0, err
+	}
+
+	var (
+		count  int
+		cursor string
+	)
+	for {
+		page, err := defs.List(ctx, ListFilter{}, cursor, archivePageSize)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, def := range page.Definitions {
+			bundle, err := defs.Export(ctx, def.ConfigHash)
+			if err != nil {
+				return 0, err
+			}
+
+			entry := ArchiveEntry{Bundle: bundle, Audit: []AuditEntry{}}
+			if audit != nil {
+				entry.Audit, err = audit.List(ctx, def.ConfigHash)
+				if err != nil {
+					return 0, err
+				}
+			}
+
+			if err := writeFile(fmt.Sprintf("%s%#x.json", archiveClusters, def.ConfigHash), entry); err != nil {
+				return 0, err
+			}
+			count++
+		}
+
+		cursor = page.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return 0, errors.Wrap(err, "close archive")
+	} else if err := gw.Close(); err != nil {
+		return 0, errors.Wrap(err, "close archive gzip")
+	}
+
+	return count, nil
+}
+
+// ReadArchive imports the clusters and their audit entries of the archive read from r into the context's tenant.
+// Clusters that already exist are skipped, clusters failing to import are reported rather than aborting the import.
+// The audit may be nil if not supported by the storage, in which case archived audit entries are dropped.
+func ReadArchive(ctx context.Context, r io.Reader, defs Definition, audit Audit) (ArchiveReport, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return ArchiveReport{}, errors.Wrap(ErrInvalidRequest, "invalid archive gzip", z.Err(err))
+	}
+	defer gr.Close()
+
+	var (
+		tr       = tar.NewReader(gr)
+		manifest bool
+		resp     = ArchiveReport{Failed: []ArchiveFailure{}}
+	)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return ArchiveReport{}, errors.Wrap(ErrInvalidRequest, "invalid archive tar", z.Err(err))
+		} else if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return ArchiveReport{}, errors.Wrap(ErrInvalidRequest, "read archive file", z.Str("name", header.Name), z.Err(err))
+		}
+
+		if header.Name == archiveManifest {
+			var m ArchiveManifest
+			if err := json.Unmarshal(b, &m); err != nil {
+				return ArchiveReport{}, errors.Wrap(ErrInvalidRequest, "invalid archive manifest", z.Err(err))
+			} else if m.Version != archiveVersion {
+				return ArchiveReport{}, errors.Wrap(ErrInvalidRequest, "unsupported archive version", z.Int("version", m.Version))
+			}
+			manifest = true
+
+			continue
+		} else if !strings.HasPrefix(header.Name, archiveClusters) {
+			continue
+		} else if !manifest {
+			return ArchiveReport{}, errors.Wrap(ErrInvalidRequest, "archive manifest missing")
+		}
+
+		var entry ArchiveEntry
+		if err := json.Unmarshal(b, &entry); err != nil {
+			return ArchiveReport{}, errors.Wrap(ErrInvalidRequest, "invalid archive entry", z.Str("name", header.Name), z.Err(err))
+		}
+
+		if err := importEntry(ctx, defs, audit, entry); errors.Is(err, ErrConflict) {
+			resp.Skipped++
+		} else if err != nil {
+			resp.Failed = append(resp.Failed, ArchiveFailure{
+				ConfigHash: fmt.Sprintf("%#x", entry.Bundle.Definition.ConfigHash),
+				Error:      err.Error(),
+			})
+		} else {
+			resp.Imported++
+		}
+	}
+
+	if !manifest {
+		return ArchiveReport{}, errors.Wrap(ErrInvalidRequest, "archive manifest missing")
+	}
+
+	return resp, nil
+}
+
+// importEntry imports the archived cluster followed by its audit entries.
+func importEntry(ctx context.Context, defs Definition, audit Audit, entry ArchiveEntry) error {
+	configHash := fmt.Sprintf("%#x", entry.Bundle.Definition.ConfigHash)
+	for _, auditEntry := range entry.Audit {
+		if auditEntry.ConfigHash != configHash {
+			return errors.Wrap(ErrInvalidRequest, "audit entry config hash mismatch", z.Str("audit_config_hash", auditEntry.ConfigHash))
+		}
+	}
+
+	if err := defs.Import(ctx, entry.Bundle); err != nil {
+		return err
+	}
+
+	if audit == nil {
+		return nil
+	}
+
+	for _, auditEntry := range entry.Audit {
+		if err := audit.Record(ctx, auditEntry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
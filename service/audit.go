@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// auditCollection is the name of the collection of audit entries, in the same database as the definitions.
+const auditCollection = "audit"
+
+// auditIndexes are the audit collection indexes.
+var auditIndexes = []mongo.IndexModel{
+	{Keys: bson.D{{"config_hash", 1}, {"time", 1}}},
+}
+
+type Audit interface {
+	// Record stores the audit entry.
+	Record(ctx context.Context, entry AuditEntry) error
+	// List returns the audit entries of the definition ordered by time.
+	List(ctx context.Context, configHash []byte) ([]AuditEntry, error)
+}
+
+// AuditEntry is a record of a definition mutation and the request that caused it.
+type AuditEntry struct {
+	ConfigHash string    `json:"config_hash" bson:"config_hash"`
+	Time       time.Time `json:"time" bson:"time"`
+	Type       EventType `json:"type" bson:"type"`
+	// Principal is the authenticated principal of the request, empty if anonymous or not caused by a request.
+	Principal string `json:"principal,omitempty" bson:"principal,omitempty"`
+	RemoteIP  string `json:"remote_ip,omitempty" bson:"remote_ip,omitempty"`
+	// BodyHash is the hex sha256 hash of the request body, empty if the request had no body.
+	BodyHash string `json:"body_hash,omitempty" bson:"body_hash,omitempty"`
+	// RequestID is the ID of the request, correlating the entry with the server logs.
+	RequestID string `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	// Before is the revision before the mutation, nil if the definition didn't exist.
+	Before *int `json:"before_revision,omitempty" bson:"before_revision,omitempty"`
+	// After is the revision after the mutation, nil if the definition was deleted.
+	After *int `json:"after_revision,omitempty" bson:"after_revision,omitempty"`
+}
+
+// Actor identifies the request causing definition mutations.
+type Actor struct {
+	Principal string
+	RemoteIP  string
+	BodyHash  string
+	RequestID string
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of the context with the actor of the mutations, recorded in their audit entries.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// actorFromCtx returns the context's actor, empty if the mutation wasn't caused by a request.
+func actorFromCtx(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
+
+// NewAudit returns the audit entries stored in the audit collection of the database.
+// The database must be migrated via Migrate.
+func NewAudit(db *mongo.Database) Audit {
+	return auditImpl{table: db.Collection(auditCollection)}
+}
+
+type auditImpl struct {
+	table *mongo.Collection
+}
+
+func (a auditImpl) Record(ctx context.Context, entry AuditEntry) error {
+	if _, err := a.table.InsertOne(ctx, entry); err != nil {
+		return errors.Wrap(err, "failed to insert audit entry")
+	}
+
+	return nil
+}
+
+func (a auditImpl) List(ctx context.Context, configHash []byte) ([]AuditEntry, error) {
+	cursor, err := a.table.Find(ctx, bson.D{{"config_hash", fmt.Sprintf("%#x", configHash)}}, options.Find().SetSort(bson.D{{"time", 1}}))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find audit entries")
+	}
+	defer cursor.Close(ctx)
+
+	resp := []AuditEntry{}
+	for cursor.Next(ctx) {
+		var entry AuditEntry
+		if err := cursor.Decode(&entry); err != nil {
+			return nil, errors.Wrap(err, "failed to decode audit entry")
+		}
+		resp = append(resp, entry)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to iterate audit entries")
+	}
+
+	return resp, nil
+}
+
+// auditRevisions returns the revisions before and after the mutation of the type resulting in the revision.
+func auditRevisions(typ EventType, revision int) (before *int, after *int) {
+	prev := revision - 1
+
+	switch typ {
+	case EventCreated, EventRestored, EventImported:
+		return nil, &revision
+	case EventDeleted:
+		return &prev, nil
+	default:
+		return &prev, &revision
+	}
+}
+
+// audit records the mutation of the type resulting in the revision by the context's actor.
+// Failing to record is logged rather than failing the already applied mutation.
+func (d definitionImpl) audit(ctx context.Context, typ EventType, configHash []byte, revision int) {
+	if err := d.recordAudit(ctx, typ, configHash, revision); err != nil {
+		log.Warn(ctx, "Failed recording audit entry", err, z.Str("type", string(typ)), z.Hex("config_hash", configHash))
+	}
+}
+
+// recordAudit records the mutation of the type resulting in the revision by the context's actor, if auditing is enabled.
+func (d definitionImpl) recordAudit(ctx context.Context, typ EventType, configHash []byte, revision int) error {
+	if d.conf.Audit == nil {
+		return nil
+	}
+
+	actor := actorFromCtx(ctx)
+	before, after := auditRevisions(typ, revision)
+
+	return d.conf.Audit.Record(ctx, AuditEntry{
+		ConfigHash: fmt.Sprintf("%#x", configHash),
+		Time:       time.Now(),
+		Type:       typ,
+		Principal:  actor.Principal,
+		RemoteIP:   actor.RemoteIP,
+		BodyHash:   actor.BodyHash,
+		RequestID:  actor.RequestID,
+		Before:     before,
+		After:      after,
+	})
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/cluster"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BatchCreate is a definition created in a batch and its create options.
+type BatchCreate struct {
+	Definition cluster.Definition
+	Options    CreateOptions
+}
+
+// BatchResult is the result of creating a definition in a batch.
+type BatchResult struct {
+	// ConfigHash is the config hash of the definition, computed for unpublished definitions.
+	ConfigHash []byte
+	Created    Created
+	// Err is the reason the definition wasn't created, nil if created or identical to an existing definition.
+	Err error
+}
+
+func (d definitionImpl) CreateBatch(ctx context.Context, batch []BatchCreate) ([]BatchResult, error) {
+	var (
+		resp    = make([]BatchResult, len(batch))
+		docs    []*definitionDoc
+		indexes []int                  // Batch indexes of the stored documents.
+		pending = make(map[string]int) // Definitions being created by owner.
+	)
+	for i, create := range batch {
+		resp[i].ConfigHash = create.Definition.ConfigHash
+
+		doc, created, exists, err := d.prepareCreate(ctx, create.Definition, create.Options, pending[create.Definition.Creator.Address], len(docs))
+		if err != nil {
+			resp[i].Err = err
+			continue
+		} else if exists {
+			continue
+		}
+
+		resp[i].ConfigHash = doc.ConfigHash
+		resp[i].Created = created
+		pending[doc.Owner]++
+		docs = append(docs, &doc)
+		indexes = append(indexes, i)
+	}
+
+	if len(docs) == 0 {
+		return resp, nil
+	}
+
+	failed, err := d.store.insertMany(ctx, docs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, doc := range docs {
+		res := &resp[indexes[i]]
+
+		if err := failed[i]; err != nil {
+			res.Created = Created{}
+			if !errors.Is(err, errDuplicate) {
+				res.Err = err
+			} else if exists, err := d.verifyIdentical(ctx, doc.Definition); err != nil {
+				// Concurrently created or duplicated in the batch.
+				res.Err = err
+			} else if !exists {
+				res.Err = errors.Wrap(ErrConflict, "definition already exists in another tenant")
+			}
+
+			continue
+		}
+
+		tenantDefinitions.WithLabelValues(tenantLabel(ctx)).Inc()
+		d.appendEvent(ctx, EventCreated, doc.ConfigHash, doc.Revision, doc)
+	}
+
+	return resp, nil
+}
+
+// insertMany inserts the documents in a single unordered bulk write, which continues after failed documents.
+func (m mongoStore) insertMany(ctx context.Context, docs []*definitionDoc) ([]error, error) {
+	resp := make([]error, len(docs))
+
+	var stored []interface{}
+	for _, doc := range docs {
+		compressed, err := m.compress(*doc)
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, compressed)
+	}
+
+	ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opInsert)
+	defer cancel()
+
+	// Unordered bulk inserts report failed documents as write errors by index.
+	_, err := m.table.InsertMany(ctx, stored, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			if mongo.IsDuplicateKeyError(writeErr.WriteError) {
+				resp[writeErr.Index] = errDuplicate
+			} else {
+				resp[writeErr.Index] = wrapDBErr(writeErr.WriteError, opInsert, "failed to create definition")
+			}
+		}
+	} else if err != nil {
+		return nil, wrapDBErr(err, opInsert, "failed to create definitions")
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lru is a concurrency safe least recently used cache of immutable values by key.
+// A nil or zero size cache never caches anything.
+type lru struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // Front is most recently used.
+	elems map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+// newLRU returns a new cache of the provided size or nil if size is zero.
+func newLRU(size int) *lru {
+	if size <= 0 {
+		return nil
+	}
+
+	return &lru{
+		size:  size,
+		order: list.New(),
+		elems: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached value of the key and true or false if not cached.
+func (c *lru) Get(key string) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.elems[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+
+	return elem.Value.(lruEntry).value, true
+}
+
+// Add caches the value of the key, evicting the least recently used value if full.
+func (c *lru) Add(key string, value interface{}) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.elems[key]; ok {
+		elem.Value = lruEntry{key: key, value: value}
+		c.order.MoveToFront(elem)
+
+		return
+	}
+
+	c.elems[key] = c.order.PushFront(lruEntry{key: key, value: value})
+
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.elems, oldest.Value.(lruEntry).key)
+	}
+}
+
+// Remove evicts the key from the cache.
+func (c *lru) Remove(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.elems[key]; ok {
+		c.order.Remove(elem)
+		delete(c.elems, key)
+	}
+}
+
+// Purge evicts all keys from the cache.
+func (c *lru) Purge() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.elems = make(map[string]*list.Element)
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"github.com/obolnetwork/charon/cluster"
+	"strings"
+)
+
+// canonicalDefinition returns the definition in canonical form with lowercase 0x-hex addresses,
+// so definitions submitted by clients using different hex cases are stored identically.
+// Legacy definition versions hash the raw address strings, so the definition is returned
+// unmodified if normalization would change its hashes.
+func canonicalDefinition(def cluster.Definition) cluster.Definition {
+	canonical := def
+	canonical.FeeRecipientAddress = strings.ToLower(def.FeeRecipientAddress)
+	canonical.WithdrawalAddress = strings.ToLower(def.WithdrawalAddress)
+	canonical.Creator.Address = strings.ToLower(def.Creator.Address)
+
+	canonical.Operators = make([]cluster.Operator, 0, len(def.Operators))
+	for _, op := range def.Operators {
+		op.Address = strings.ToLower(op.Address)
+		canonical.Operators = append(canonical.Operators, op)
+	}
+
+	if err := canonical.VerifyHashes(); err != nil {
+		return def
+	}
+
+	return canonical
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"net/http"
+	"time"
+)
+
+// namespaceExistsCode is the mongo error code of creating a collection that already exists.
+const namespaceExistsCode = 48
+
+type Captures interface {
+	// Record stores the captured request, evicting the oldest captures when the collection is full.
+	Record(ctx context.Context, capture Capture) error
+	// List returns the most recent captures, newest first.
+	List(ctx context.Context, limit int) ([]Capture, error)
+	// Get returns the capture by id.
+	Get(ctx context.Context, id string) (Capture, error)
+}
+
+// Capture is an anonymized failed request and its response. It doesn't contain credentials or client addresses.
+type Capture struct {
+	ID       string      `json:"id" bson:"_id"`
+	Time     time.Time   `json:"time" bson:"time"`
+	Endpoint string      `json:"endpoint" bson:"endpoint"`
+	Method   string      `json:"method" bson:"method"`
+	Path     string      `json:"path" bson:"path"`
+	Query    string      `json:"query,omitempty" bson:"query,omitempty"`
+	Header   http.Header `json:"header,omitempty" bson:"header,omitempty"`
+	Body     []byte      `json:"body,omitempty" bson:"body,omitempty"`
+	// Truncated is true if the request or response body exceeded the maximum captured size.
+	Truncated  bool   `json:"truncated,omitempty" bson:"truncated,omitempty"`
+	StatusCode int    `json:"status_code" bson:"status_code"`
+	Response   []byte `json:"response,omitempty" bson:"response,omitempty"`
+}
+
+// NewCaptures returns the captures stored in the capped collection of the database, creating it with the maximum
+// size in bytes if it doesn't exist. The size of an existing collection isn't changed.
+func NewCaptures(ctx context.Context, db *mongo.Database, name string, sizeBytes int64) (Captures, error) {
+	err := db.CreateCollection(ctx, name, options.CreateCollection().SetCapped(true).SetSizeInBytes(sizeBytes))
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == namespaceExistsCode {
+		err = nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create captures collection")
+	}
+
+	return capturesImpl{table: db.Collection(name)}, nil
+}
+
+type capturesImpl struct {
+	table *mongo.Collection
+}
+
+func (c capturesImpl) Record(ctx context.Context, capture Capture) error {
+	capture.ID = primitive.NewObjectID().Hex()
+
+	if _, err := c.table.InsertOne(ctx, capture); err != nil {
+		return errors.Wrap(err, "failed to insert capture")
+	}
+
+	return nil
+}
+
+func (c capturesImpl) List(ctx context.Context, limit int) ([]Capture, error) {
+	// Capped collections retain insertion order, so reverse natural order is newest first.
+	cursor, err := c.table.Find(ctx, bson.D{}, options.Find().
+		SetSort(bson.D{{"$natural", -1}}).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find captures")
+	}
+
+	resp := []Capture{}
+	if err := cursor.All(ctx, &resp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode captures")
+	}
+
+	return resp, nil
+}
+
+func (c capturesImpl) Get(ctx context.Context, id string) (Capture, error) {
+	var resp Capture
+	err := c.table.FindOne(ctx, bson.D{{"_id", id}}).Decode(&resp)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Capture{}, errors.Wrap(ErrNotFound, "capture not found")
+	} else if err != nil {
+		return Capture{}, errors.Wrap(err, "failed to get capture")
+	}
+
+	return resp, nil
+}
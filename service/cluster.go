@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/obolnetwork/charon/cluster"
+	"strings"
+)
+
+// Cluster aggregates the full lifecycle of a cluster: its definition, ceremony state, operator progress,
+// lock and deposit progress.
+type Cluster struct {
+	Definition cluster.Definition `json:"definition"`
+	State      State              `json:"state"`
+	Operators  []OperatorProgress `json:"operators"`
+	Lock       *cluster.Lock      `json:"lock,omitempty"`
+	Deposits   []DepositProgress  `json:"deposits,omitempty"`
+}
+
+// OperatorProgress is the invitation progress of an operator.
+type OperatorProgress struct {
+	Address string `json:"address"`
+	// Accepted is true if the operator populated its ENR and signatures.
+	Accepted bool `json:"accepted"`
+	// Declined is true if the operator declined to join the cluster.
+	Declined bool `json:"declined"`
+}
+
+// DepositProgress is the deposit signature progress of a distributed validator.
+type DepositProgress struct {
+	PubKey string `json:"pubkey"`
+	// Partials is the number of partial deposit signatures submitted.
+	Partials int `json:"partials"`
+	// Aggregated is true if the deposit signature was aggregated.
+	Aggregated bool `json:"aggregated"`
+}
+
+func (d definitionImpl) Cluster(ctx context.Context, configHash []byte) (Cluster, error) {
+	doc, err := d.getDoc(ctx, configHash)
+	if err != nil {
+		return Cluster{}, err
+	}
+
+	return d.cluster(doc), nil
+}
+
+// cluster returns the full lifecycle of the definition document.
+func (d definitionImpl) cluster(doc definitionDoc) Cluster {
+	resp := Cluster{
+		Definition: doc.Definition,
+		State:      d.state(doc),
+		Lock:       doc.Lock,
+	}
+
+	for _, op := range doc.Definition.Operators {
+		var declined bool
+		for _, address := range doc.Declined {
+			if strings.EqualFold(address, op.Address) {
+				declined = true
+				break
+			}
+		}
+
+		resp.Operators = append(resp.Operators, OperatorProgress{
+			Address:  op.Address,
+			Accepted: op.ENR != "",
+			Declined: declined,
+		})
+	}
+
+	if doc.Lock == nil {
+		return resp
+	}
+
+	for _, val := range doc.Lock.Validators {
+		pubkey := fmt.Sprintf("%#x", val.PubKey)
+		_, aggregated := doc.DepositSignatures[pubkey]
+
+		resp.Deposits = append(resp.Deposits, DepositProgress{
+			PubKey:     pubkey,
+			Partials:   len(doc.DepositPartials[pubkey]),
+			Aggregated: aggregated,
+		})
+	}
+
+	return resp
+}
+
+// Summary is a redacted public summary of a cluster excluding ENRs, addresses and signatures.
+type Summary struct {
+	Name          string `json:"name"`
+	ConfigHash    string `json:"config_hash"`
+	Network       string `json:"network"`
+	Status        Status `json:"status"`
+	NumOperators  int    `json:"num_operators"`
+	NumJoined     int    `json:"num_joined"`
+	Threshold     int    `json:"threshold"`
+	NumValidators int    `json:"num_validators"`
+	Timestamp     string `json:"timestamp"`
+}
+
+func (d definitionImpl) Summary(ctx context.Context, configHash []byte) (Summary, error) {
+	doc, err := d.getDoc(ctx, configHash)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	state := d.state(doc)
+
+	var joined int
+	for _, op := range doc.Definition.Operators {
+		if op.ENR != "" {
+			joined++
+		}
+	}
+
+	return Summary{
+		Name:          doc.Definition.Name,
+		ConfigHash:    fmt.Sprintf("%#x", doc.ConfigHash),
+		Network:       state.Network,
+		Status:        state.Status,
+		NumOperators:  len(doc.Definition.Operators),
+		NumJoined:     joined,
+		Threshold:     doc.Definition.Threshold,
+		NumValidators: doc.Definition.NumValidators,
+		Timestamp:     doc.Definition.Timestamp,
+	}, nil
+}
+
+// Completion is the operator completion summary of a cluster definition.
+type Completion struct {
+	Status Status `json:"status"`
+	// Signed are the addresses of operators that populated their ENRs and signatures.
+	Signed []string `json:"signed"`
+	// Outstanding are the addresses of operators that haven't signed yet.
+	Outstanding    []string `json:"outstanding"`
+	NumSigned      int      `json:"num_signed"`
+	NumOutstanding int      `json:"num_outstanding"`
+	Threshold      int      `json:"threshold"`
+	NumValidators  int      `json:"num_validators"`
+	// Ready is true if all operators signed and the DKG ceremony can start.
+	Ready bool `json:"ready"`
+}
+
+func (d definitionImpl) Completion(ctx context.Context, configHash []byte) (Completion, error) {
+	doc, err := d.getDoc(ctx, configHash)
+	if err != nil {
+		return Completion{}, err
+	}
+
+	resp := Completion{
+		Status:        d.state(doc).Status,
+		Signed:        []string{},
+		Outstanding:   []string{},
+		Threshold:     doc.Definition.Threshold,
+		NumValidators: doc.Definition.NumValidators,
+	}
+
+	for _, op := range doc.Definition.Operators {
+		if op.ENR != "" {
+			resp.Signed = append(resp.Signed, op.Address)
+		} else {
+			resp.Outstanding = append(resp.Outstanding, op.Address)
+		}
+	}
+
+	resp.NumSigned = len(resp.Signed)
+	resp.NumOutstanding = len(resp.Outstanding)
+	resp.Ready = allJoined(doc.Definition) && (resp.Status == StatusDraft || resp.Status == StatusReady)
+
+	return resp, nil
+}
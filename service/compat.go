@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// Capabilities are the optional features supported by the mongo deployment. Mongo API compatible
+// databases like Amazon DocumentDB and Azure Cosmos DB don't support all features of MongoDB.
+type Capabilities struct {
+	// ChangeStreams is true if change streams are supported, requiring a replica set.
+	ChangeStreams bool
+}
+
+// DetectCapabilities returns the optional features supported by the deployment of the collection.
+func DetectCapabilities(ctx context.Context, table *mongo.Collection) Capabilities {
+	var resp Capabilities
+
+	stream, err := table.Watch(ctx, mongo.Pipeline{}, options.ChangeStream().SetMaxAwaitTime(time.Millisecond))
+	if err == nil {
+		resp.ChangeStreams = true
+		_ = stream.Close(ctx)
+	}
+
+	return resp
+}
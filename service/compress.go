@@ -0,0 +1,91 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/cluster"
+	"go.mongodb.org/mongo-driver/bson"
+	"io"
+)
+
+// compressedCluster is the gzip-compressed canonical json blob of a compressed definition document.
+type compressedCluster struct {
+	Definition cluster.Definition `json:"definition"`
+	Lock       *cluster.Lock      `json:"lock,omitempty"`
+}
+
+// compress returns the document to store, with its definition and lock compressed if the definition has at least
+// the configured number of validators. Compressed documents retain the definition's scalar fields, its operator
+// addresses and the lock's validator public keys as separate queryable fields. Unpublished definitions are never
+// compressed since they may not be valid json definitions.
+func (m mongoStore) compress(doc definitionDoc) (definitionDoc, error) {
+	doc.Compressed = nil
+	doc.OperatorAddresses = nil
+	if doc.ValidatorChunks == 0 {
+		doc.ValidatorPubkeys = nil // Sharded locks retain the public keys of their chunked validators.
+	}
+	doc = stripSharded(doc)
+
+	if m.conf.CompressValidators == 0 || doc.Definition.NumValidators < m.conf.CompressValidators || doc.Status == StatusUnpublished {
+		return doc, nil
+	}
+
+	b, err := json.Marshal(compressedCluster{Definition: doc.Definition, Lock: doc.Lock})
+	if err != nil {
+		return definitionDoc{}, errors.Wrap(err, "marshal compressed cluster")
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return definitionDoc{}, errors.Wrap(err, "compress cluster")
+	} else if err := zw.Close(); err != nil {
+		return definitionDoc{}, errors.Wrap(err, "close compressor")
+	}
+
+	doc.Compressed = buf.Bytes()
+	for _, op := range doc.Definition.Operators {
+		doc.OperatorAddresses = append(doc.OperatorAddresses, op.Address)
+	}
+	doc.Definition.Operators = nil
+	if doc.Lock != nil {
+		for _, val := range doc.Lock.Validators {
+			doc.ValidatorPubkeys = append(doc.ValidatorPubkeys, val.PubKey)
+		}
+		doc.Lock = nil
+	}
+
+	return doc, nil
+}
+
+// UnmarshalBSON decodes the document, decompressing its definition and lock if compressed.
+func (doc *definitionDoc) UnmarshalBSON(b []byte) error {
+	type plain definitionDoc // Plain type without this method avoiding infinite recursion.
+	if err := bson.Unmarshal(b, (*plain)(doc)); err != nil {
+		return err
+	} else if len(doc.Compressed) == 0 {
+		return nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(doc.Compressed))
+	if err != nil {
+		return errors.Wrap(err, "new decompressor")
+	}
+
+	b, err = io.ReadAll(zr)
+	if err != nil {
+		return errors.Wrap(err, "decompress cluster")
+	}
+
+	var resp compressedCluster
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return errors.Wrap(err, "unmarshal compressed cluster")
+	}
+
+	doc.Definition = resp.Definition
+	doc.Lock = resp.Lock
+
+	return nil
+}
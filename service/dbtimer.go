@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/event"
+	"sync/atomic"
+	"time"
+)
+
+type dbTimerKey struct{}
+
+// WithDBTimer returns a copy of the context that accumulates the duration of mongo commands executed with it.
+func WithDBTimer(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dbTimerKey{}, new(int64))
+}
+
+// DBTime returns the accumulated duration of mongo commands executed with the context
+// or zero if the context doesn't contain a timer, see WithDBTimer.
+func DBTime(ctx context.Context) time.Duration {
+	nanos, ok := ctx.Value(dbTimerKey{}).(*int64)
+	if !ok {
+		return 0
+	}
+
+	return time.Duration(atomic.LoadInt64(nanos))
+}
+
+// NewCommandMonitor returns a mongo command monitor that accumulates command durations in context timers
+// and observes command latencies.
+func NewCommandMonitor() *event.CommandMonitor {
+	add := func(ctx context.Context, e event.CommandFinishedEvent) {
+		dbLatency.WithLabelValues(e.CommandName).Observe(time.Duration(e.DurationNanos).Seconds())
+		if nanos, ok := ctx.Value(dbTimerKey{}).(*int64); ok {
+			atomic.AddInt64(nanos, e.DurationNanos)
+		}
+	}
+
+	return &event.CommandMonitor{
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			add(ctx, e.CommandFinishedEvent)
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			add(ctx, e.CommandFinishedEvent)
+		},
+	}
+}
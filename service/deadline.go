@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+// overdue returns true if the definition is a draft whose join deadline passed before all operators joined.
+func overdue(doc definitionDoc) bool {
+	return doc.Status == StatusDraft && !doc.JoinBy.IsZero() && time.Now().After(doc.JoinBy)
+}
+
+// CancelOverdue cancels the draft definitions whose join deadline passed and returns their config hashes.
+func (a *adminImpl) CancelOverdue(ctx context.Context) ([]string, error) {
+	cursor, err := a.table.Find(ctx, bson.D{
+		{"status", StatusDraft},
+		{"join_by", bson.D{{"$lt", time.Now()}}},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find overdue definitions")
+	}
+	defer cursor.Close(ctx)
+
+	resp := []string{}
+	for cursor.Next(ctx) {
+		var doc definitionDoc
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, errors.Wrap(err, "failed to decode definition")
+		}
+
+		err := a.defs.update(ctx, doc.ConfigHash, EventCancelled, func(doc *definitionDoc) error {
+			if !overdue(*doc) {
+				return errors.Wrap(ErrInvalidState, "definition not overdue") // Concurrently completed.
+			}
+			doc.Status = StatusCancelled
+
+			return nil
+		})
+		if errors.Is(err, ErrInvalidState) || errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		resp = append(resp, fmt.Sprintf("%#x", doc.ConfigHash))
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to iterate overdue definitions")
+	}
+
+	return resp, nil
+}
@@ -1,77 +1,1278 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	eth2v1 "github.com/attestantio/go-eth2-client/api/v1"
+	eth2p0 "github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/corverroos/dvstore/ipfs"
+	"github.com/corverroos/dvstore/notify"
+	"github.com/corverroos/dvstore/objstore"
 	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
 	"github.com/obolnetwork/charon/cluster"
+	"github.com/obolnetwork/charon/eth2util"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+	"time"
+)
+
+const (
+	// maxUpdateAttempts is the number of times an update is attempted if the definition is concurrently modified.
+	maxUpdateAttempts = 3
+	// notifyTimeout is the maximum duration of sending notifications.
+	notifyTimeout = time.Minute
+	// configHashLen is the length of config hashes in bytes.
+	configHashLen = 32
 )
 
 type Definition interface {
-	Get(ctx context.Context, configHash []byte) (cluster.Definition, error)
-	Delete(ctx context.Context, configHash []byte) error
-	Create(ctx context.Context, def cluster.Definition) error
-	AddOperator(ctx context.Context, configHash []byte, forkVersion []byte, operator cluster.Operator) error
+	// Get returns the definition, true if it is final, i.e., it can no longer change, and its stored version.
+	Get(ctx context.Context, configHash []byte) (cluster.Definition, bool, Version, error)
+	// GetMany returns the definitions of the config hashes that exist, omitting those not found.
+	GetMany(ctx context.Context, configHashes [][]byte) ([]cluster.Definition, error)
+	// GetByPrefix returns up to limit definitions whose config hash starts with the hex prefix.
+	GetByPrefix(ctx context.Context, hexPrefix string, limit int) ([]cluster.Definition, error)
+	// List returns a page of published definitions matching the filter ordered by config hash, starting after
+	// the cursor of the previous page or from the first definition if the cursor is empty.
+	List(ctx context.Context, filter ListFilter, cursor string, limit int) (DefinitionPage, error)
+	// Search returns a page of published definitions whose name matches the text query, ordered by relevance.
+	Search(ctx context.Context, query string, offset, limit int) (SearchPage, error)
+	// Delete deletes the definition on behalf of the principal. It returns ErrInvalidState if the definition is pinned.
+	// If delete approval is enabled, deleting a final definition only requests its deletion, which must then be
+	// approved by deleting it again as another authenticated principal, or confirmed by the requester after the
+	// cooling-off period. Requesting and approving deletions require an authenticated principal.
+	// If request signatures are enforced, the request must be signed by the signer address, which must be the
+	// definition's creator, or one of its operators if it has no creator.
+	Delete(ctx context.Context, configHash []byte, principal string, signer string, auth RequestAuth) (Deletion, error)
+	// Restore restores the latest deleted definition from the trash. It returns ErrNotFound if the definition isn't
+	// in the trash and ErrConflict if it already exists.
+	Restore(ctx context.Context, configHash []byte) error
+	// CancelDeletion cancels the pending deletion of the definition on behalf of the principal, which must be the
+	// principal that requested it or an administrator, unless the request is signed by the signer address of the
+	// definition's creator.
+	CancelDeletion(ctx context.Context, configHash []byte, principal string, signer string, auth RequestAuth) error
+	// Pin pins or unpins the definition. Pinned definitions can't be deleted and never expire. Unless the context is
+	// of an administrator, the request must be signed by the signer address of the definition's creator.
+	Pin(ctx context.Context, configHash []byte, pinned bool, signer string, auth RequestAuth) error
+	// Create stores the draft definition in canonical form. Creating an identical existing definition is a no-op,
+	// it returns ErrConflict if a different definition with the config hash exists.
+	Create(ctx context.Context, def cluster.Definition, opts CreateOptions) (Created, error)
+	// CreateBatch creates the definitions like Create in a single bulk write, returning the result of each
+	// in batch order. Definitions are created independently, a failed definition doesn't fail the batch.
+	CreateBatch(ctx context.Context, batch []BatchCreate) ([]BatchResult, error)
+	// GetUnpublished returns the unpublished definition and its stored version.
+	GetUnpublished(ctx context.Context, configHash []byte) (cluster.Definition, Version, error)
+	// Revise replaces the unpublished definition on behalf of its creator, returning the new config hash computed from
+	// it. The revised definition must have the same creator, which must sign the request.
+	Revise(ctx context.Context, configHash []byte, def cluster.Definition, auth RequestAuth) ([]byte, error)
+	// UpdateMetadata applies the patch to the creator-editable metadata of the unpublished or draft definition
+	// before any operator signed it, returning the updated metadata. The request must be signed by the creator.
+	UpdateMetadata(ctx context.Context, configHash []byte, patch MetadataPatch, auth RequestAuth) (Metadata, error)
+	// Publish fully validates the unpublished definition and transitions it to draft, making it visible to operators.
+	Publish(ctx context.Context, configHash []byte) error
+	// AddOperator accepts the cluster invitation on behalf of the operator by populating its ENR and signatures.
+	// The fork version must match the definition's fork version. The optional version is the definition version
+	// produced by the operator's charon, it is checked for compatibility with the definition's version.
+	AddOperator(ctx context.Context, configHash []byte, forkVersion []byte, version string, operator cluster.Operator, auth RequestAuth) error
+	// Invite mints a single-use invite token bound to the operator address on behalf of the creator, replacing any
+	// previous token of the operator. Once a token is minted, all operators must join with their token.
+	// If request signatures are enforced, the request must be signed by the creator.
+	Invite(ctx context.Context, configHash []byte, address string, auth RequestAuth) (Invite, error)
+	// Decline declines the cluster invitation on behalf of the operator.
+	Decline(ctx context.Context, configHash []byte, operator cluster.Operator, auth RequestAuth) error
+	// RemoveOperator removes the operator with the address from the unpublished or draft definition on behalf of
+	// its creator, returning the new config hash. Since all config signatures become invalid, operators must join
+	// again and the definition reverts to unpublished until the creator signs and publishes it again.
+	// If request signatures are enforced, the request must be signed by the creator.
+	RemoveOperator(ctx context.Context, configHash []byte, address string, auth RequestAuth) ([]byte, error)
+	// Finalize transitions a draft definition to ready once all operators accepted.
+	Finalize(ctx context.Context, configHash []byte) error
+	// Lock transitions a ready definition to locked by storing the cluster lock resulting from the DKG ceremony.
+	Lock(ctx context.Context, configHash []byte, lock cluster.Lock) error
+	GetLock(ctx context.Context, configHash []byte) (cluster.Lock, error)
+	// Validators returns a page of the lock's distributed validators starting at the offset, only reading the
+	// chunks containing the page if the lock's validators are sharded.
+	Validators(ctx context.Context, configHash []byte, offset, limit int) (ValidatorPage, error)
+	State(ctx context.Context, configHash []byte) (State, error)
+	// AddDepositSignatures stores the operator's partial deposit signatures by 0x-hex validator public key,
+	// aggregating them once threshold partial signatures are available for a validator.
+	AddDepositSignatures(ctx context.Context, configHash []byte, shareIdx int, partialSigs map[string][]byte) error
+	// DepositData returns the deposit data json of all validators with aggregated deposit signatures.
+	DepositData(ctx context.Context, configHash []byte) ([]byte, error)
+	// GetValidator returns a reference to the cluster lock containing the distributed validator public key.
+	GetValidator(ctx context.Context, pubkey []byte) (ValidatorRef, error)
+	// ProposeExit proposes a coordinated exit of the validator at the epoch on behalf of the operator,
+	// replacing any existing proposal for the validator.
+	// The request must be signed by the operator.
+	ProposeExit(ctx context.Context, configHash []byte, pubkey string, epoch uint64, address string, auth RequestAuth) error
+	// ConfirmExit confirms the proposed exit of the validator at the epoch on behalf of the operator.
+	// The request must be signed by the operator.
+	ConfirmExit(ctx context.Context, configHash []byte, pubkey string, epoch uint64, address string, auth RequestAuth) error
+	// AddExitSignature stores the operator's partial signature of the agreed exit of the validator,
+	// aggregating the exit signature once threshold partial signatures are available.
+	AddExitSignature(ctx context.Context, configHash []byte, partial PartialExit) error
+	// SignedExit returns the signed voluntary exit of the validator with the aggregated exit signature.
+	SignedExit(ctx context.Context, configHash []byte, pubkey string) (*eth2p0.SignedVoluntaryExit, error)
+	// Exits returns the coordinated exits of the cluster.
+	Exits(ctx context.Context, configHash []byte) ([]Exit, error)
+	// AddRegistrations stores the signed builder registrations of the cluster's validators,
+	// ignoring registrations not newer than those already stored.
+	AddRegistrations(ctx context.Context, configHash []byte, regs []*eth2v1.SignedValidatorRegistration) error
+	// Registrations returns the unexpired builder registrations of the cluster's validators.
+	Registrations(ctx context.Context, configHash []byte) ([]*eth2v1.SignedValidatorRegistration, error)
+	// GetRegistration returns the unexpired builder registration of the distributed validator public key.
+	GetRegistration(ctx context.Context, pubkey []byte) (*eth2v1.SignedValidatorRegistration, error)
+	// Cluster returns the full lifecycle of the cluster.
+	Cluster(ctx context.Context, configHash []byte) (Cluster, error)
+	// Summary returns the redacted public summary of the cluster.
+	Summary(ctx context.Context, configHash []byte) (Summary, error)
+	// Completion returns the operator completion summary of the cluster.
+	Completion(ctx context.Context, configHash []byte) (Completion, error)
+	// History returns the definition's append-only change log.
+	History(ctx context.Context, configHash []byte) ([]Event, error)
+	// AtRevision returns the definition's full lifecycle at the revision, reconstructed from its change log.
+	AtRevision(ctx context.Context, configHash []byte, revision int) (Cluster, error)
+	// Diff returns the fields of the cluster that changed between the revisions.
+	Diff(ctx context.Context, configHash []byte, from, to int) (Diff, error)
+	// ArchiveLocation returns the object storage locations of the cluster's snapshots.
+	ArchiveLocation(ctx context.Context, configHash []byte) (ArchiveLocation, error)
+	// Export returns a portable bundle of the cluster, signed by the server if an export key is configured.
+	Export(ctx context.Context, configHash []byte) (Bundle, error)
+	// Import stores the cluster of the exported bundle after verifying it.
+	Import(ctx context.Context, bundle Bundle) error
+	// Lineage returns the resize and reshare lineage of the definition.
+	Lineage(ctx context.Context, configHash []byte) (Lineage, error)
+	// Stats returns the definition counts by cluster type and status.
+	Stats(ctx context.Context, filter StatsFilter) (Stats, error)
+	// Sync keeps the caches consistent with mutations by other replicas until the context is cancelled.
+	Sync(ctx context.Context) error
+}
+
+// CreateOptions are the optional parameters of creating a definition.
+type CreateOptions struct {
+	// Parent is the config hash of the cluster resized or reshared by the definition.
+	Parent []byte
+	// InviteTokens mints a single-use invite token per operator slot that operators must present to join.
+	InviteTokens bool
+	// Unpublished stores the definition without signature verification, computing its hashes,
+	// so the creator can iterate on it before publishing it to operators.
+	Unpublished bool
+	// JoinBy is the deadline by which all operators must join, after which the definition is cancelled.
+	// The definition has no deadline if zero.
+	JoinBy time.Time
+}
+
+// Created is the result of creating a definition.
+type Created struct {
+	// InviteTokens are the minted invite tokens by operator slot, only returned to the creator once.
+	InviteTokens []string `json:"invite_tokens,omitempty"`
+}
+
+// ValidatorRef references the cluster lock containing a distributed validator.
+type ValidatorRef struct {
+	ConfigHash string `json:"config_hash"`
+	LockHash   string `json:"lock_hash"`
+	// Index is the index of the validator in the cluster lock.
+	Index int `json:"validator_index"`
+}
+
+// DefinitionConfig defines the definition service configuration.
+type DefinitionConfig struct {
+	// DraftExpiry is the age after which draft definitions expire based on their embedded timestamp.
+	// Definitions do not expire if zero.
+	DraftExpiry time.Duration
+	// DefinitionTTL is the age since creation after which unpublished and draft definitions that never became
+	// ready are deleted. Definitions are not deleted if zero.
+	DefinitionTTL time.Duration
+	// BlockExpired rejects operators accepting expired draft definitions.
+	BlockExpired bool
+	// DeleteApproval requires deletion of final definitions to be requested and then approved by another principal
+	// or confirmed after the DeleteCoolOff period.
+	DeleteApproval bool
+	// DeleteCoolOff is the period after which the requester may confirm its own deletion request.
+	DeleteCoolOff time.Duration
+	// RegistrationExpiry is the age after which builder registrations expire based on their timestamp.
+	// Registrations do not expire if zero.
+	RegistrationExpiry time.Duration
+	// RequestWindow is the maximum age of signed request timestamps. Requests are always signed and their timestamps
+	// increase monotonically per address, timestamps aren't bounded by age if zero.
+	RequestWindow time.Duration
+	// RequireInvites rejects operators joining definitions without invite tokens.
+	RequireInvites bool
+	// InviteTTL is the duration after which minted invite tokens expire. Tokens never expire if zero.
+	InviteTTL time.Duration
+	// RejectIncompatible rejects operators joining with an incompatible definition version instead of warning.
+	RejectIncompatible bool
+	// AutoFinalize transitions draft definitions to ready when the last operator joins, instead of
+	// requiring them to be finalized explicitly. Definitions that can't be finalized remain drafts.
+	AutoFinalize bool
+	// RequireCreator rejects publishing definitions without a creator, ensuring every definition is attributable
+	// to the address that signed it. Unpublished definitions may omit the creator until published.
+	RequireCreator bool
+	// BFTThreshold rejects definitions whose threshold isn't the byzantine fault tolerant threshold of the operator count.
+	BFTThreshold bool
+	// MinThresholdRatio rejects definitions whose threshold is below this ratio of the operator count.
+	// It is not enforced if zero.
+	MinThresholdRatio float64
+	// Audit records definition mutations. Mutations are not audited if nil.
+	Audit Audit
+	// Notifier is notified when all operators joined a definition. Notifications are disabled if nil.
+	Notifier notify.Notifier
+	// Publisher publishes definitions when all operators joined and locks when stored to IPFS, storing their CIDs.
+	// Publishing is disabled if nil.
+	Publisher ipfs.Publisher
+	// Archiver archives snapshots of definitions when all operators joined and locks when stored to object storage,
+	// storing their locations. Archiving is disabled if nil.
+	Archiver objstore.Archiver
+	// CacheSize is the number of final definitions and locks cached in memory. Caching is disabled if zero.
+	CacheSize int
+	// DBTimeouts are the deadlines of individual mongo operations.
+	DBTimeouts DBTimeouts
+	// Retry is the retry policy of mongo operations failing with transient errors.
+	Retry RetryPolicy
+	// Alarms configures the owner quota and the quota and retention alarms.
+	Alarms AlarmConfig
+	// TenantQuota is the maximum number of definitions per tenant, excluding the default tenant.
+	// It is not enforced if zero.
+	TenantQuota int
+	// CreatorQuota is the maximum number of active, i.e. unpublished, draft or ready, definitions per creator address.
+	// It is not enforced if zero.
+	CreatorQuota int
+	// ExportKey signs exported bundles. Bundles are not signed if nil.
+	ExportKey ed25519.PrivateKey
+	// ImportTrustedKeys are the ed25519 public keys of servers whose signed bundles are imported.
+	// Unsigned bundles and bundles signed by any key are imported if empty.
+	ImportTrustedKeys []ed25519.PublicKey
+	// TrashRetention is the period deleted definitions are retained in the trash for restoring before being purged.
+	// Definitions are deleted permanently if zero.
+	TrashRetention time.Duration
+	// Transactions applies mutations atomically with their change log events and audit entries
+	// in mongo transactions. It requires a replica set or sharded cluster.
+	Transactions bool
+	// CompressValidators is the number of validators from which definitions and locks are stored gzip-compressed.
+	// Definitions are not compressed if zero.
+	CompressValidators int
+	// ShardValidators is the number of validators from which the validators of locks are stored in chunks in a
+	// separate collection, keeping the definition document's size independent of the cluster size.
+	// Validators are not sharded if zero.
+	ShardValidators int
+	// Networks are the networks whose definitions are accepted, e.g. "mainnet". All known networks are accepted if empty.
+	Networks []string
 }
 
-func NewDefinition(table *mongo.Collection) Definition {
+// indexes are the definitions collection indexes.
+var indexes = []mongo.IndexModel{
+	{Keys: bson.D{{"lock.validators.pubkey", 1}}},
+	{Keys: bson.D{{"validator_pubkeys", 1}}},
+	{Keys: bson.D{{"definition.operators.address", 1}}},
+	{Keys: bson.D{{"operator_addresses", 1}}},
+	{Keys: bson.D{{"parent", 1}}},
+	{Keys: bson.D{{"type", 1}, {"status", 1}}},
+	{Keys: bson.D{{"status", 1}, {"join_by", 1}}},
+	{Keys: bson.D{{"definition.name", "text"}}},
+	{Keys: bson.D{{"owner", 1}}},
+}
+
+// CreateIndexes creates the definitions, definition events, trash, audit and validator chunks collection indexes
+// if they do not already exist.
+func CreateIndexes(ctx context.Context, table *mongo.Collection) error {
+	_, err := table.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return errors.Wrap(err, "failed to create indexes")
+	}
+
+	_, err = table.Database().Collection(eventsCollection).Indexes().CreateMany(ctx, eventIndexes)
+	if err != nil {
+		return errors.Wrap(err, "failed to create event indexes")
+	}
+
+	_, err = table.Database().Collection(trashCollection).Indexes().CreateMany(ctx, trashIndexes)
+	if err != nil {
+		return errors.Wrap(err, "failed to create trash indexes")
+	}
+
+	_, err = table.Database().Collection(auditCollection).Indexes().CreateMany(ctx, auditIndexes)
+	if err != nil {
+		return errors.Wrap(err, "failed to create audit indexes")
+	}
+
+	_, err = table.Database().Collection(validatorChunksCollection).Indexes().CreateMany(ctx, validatorChunkIndexes)
+	if err != nil {
+		return errors.Wrap(err, "failed to create validator chunk indexes")
+	}
+
+	return nil
+}
+
+// NewDefinition returns a new definition service storing definitions in the table, their change log in the
+// definition events collection and deleted definitions in the trash collection of the same database.
+func NewDefinition(table *mongo.Collection, conf DefinitionConfig) Definition {
+	return newDefinition(newMongoStore(table, conf), conf)
+}
+
+// newDefinition returns a new definition service persisting definitions in the document store.
+func newDefinition(store docStore, conf DefinitionConfig) Definition {
 	return &definitionImpl{
-		table: table,
+		store:     store,
+		conf:      conf,
+		defCache:  newLRU(conf.CacheSize),
+		lockCache: newLRU(conf.CacheSize),
+	}
+}
+
+// newMongoStore returns the document store of the mongo database of the definitions table.
+func newMongoStore(table *mongo.Collection, conf DefinitionConfig) mongoStore {
+	return mongoStore{
+		table:  table,
+		events: table.Database().Collection(eventsCollection),
+		trash:  table.Database().Collection(trashCollection),
+		conf:   conf,
 	}
 }
 
+// mongoStore is the docStore of a mongo database, storing definitions in the definitions table, their change log
+// in the definition events collection and deleted definitions in the trash collection. Definitions with many
+// validators are compressed and sharded as configured.
+type mongoStore struct {
+	table  *mongo.Collection
+	events *mongo.Collection
+	trash  *mongo.Collection
+	conf   DefinitionConfig
+}
+
+// definitionDoc is the mongo document of a cluster definition and its DKG ceremony state.
+type definitionDoc struct {
+	ConfigHash []byte      `bson:"config_hash"`
+	Revision   int         `bson:"revision"`
+	Status     Status      `bson:"status"`
+	Version    string      `bson:"version"` // Definition version that produced the document.
+	Type       ClusterType `bson:"type"`
+	Owner      string      `bson:"owner"`            // Verified creator address, empty if the definition has no creator.
+	Tenant     string      `bson:"tenant,omitempty"` // Tenant that created the definition, empty for the default tenant.
+	Declined   []string    `bson:"declined"`
+	Parent     []byte      `bson:"parent,omitempty"`  // Config hash of the resized or reshared parent cluster.
+	JoinBy     time.Time   `bson:"join_by,omitempty"` // Deadline by which all operators must join, zero if none.
+	Pinned     bool        `bson:"pinned,omitempty"`  // Pinned definitions can't be deleted and never expire.
+	// UpdatedAt is when the document was last modified, zero if not modified since modifications are tracked.
+	UpdatedAt time.Time `bson:"updated_at,omitempty"`
+	// PendingDeletion is the deletion request awaiting approval, nil if none.
+	PendingDeletion *pendingDeletion `bson:"pending_deletion,omitempty"`
+	// Metadata is the creator-editable metadata, nil if never edited.
+	Metadata *Metadata `bson:"metadata,omitempty"`
+	// InviteTokens are the operator invite tokens by operator slot, empty if operators join without tokens.
+	InviteTokens []inviteToken      `bson:"invite_tokens,omitempty"`
+	Definition   cluster.Definition `bson:"definition"`
+	Lock         *cluster.Lock      `bson:"lock,omitempty"`
+	// Compressed is the gzip-compressed json definition and lock, empty if not compressed.
+	// The definition of compressed documents only contains its scalar fields and the lock is nil.
+	Compressed []byte `bson:"compressed,omitempty"`
+	// ValidatorPubkeys are the distributed validator public keys of compressed or sharded locks.
+	ValidatorPubkeys [][]byte `bson:"validator_pubkeys,omitempty"`
+	// ValidatorChunks is the number of chunks the lock's validators are stored in, zero if not sharded.
+	// The lock of sharded documents is stored without its validators.
+	ValidatorChunks int `bson:"validator_chunks,omitempty"`
+	// OperatorAddresses are the operator addresses of compressed definitions.
+	OperatorAddresses []string `bson:"operator_addresses,omitempty"`
+	// DepositPartials are the partial deposit signatures by 0x-hex validator public key and share index.
+	DepositPartials map[string]map[string][]byte `bson:"deposit_partials,omitempty"`
+	// DepositSignatures are the aggregated deposit signatures by 0x-hex validator public key.
+	DepositSignatures map[string][]byte `bson:"deposit_signatures,omitempty"`
+	// Exits are the coordinated validator exits proposed by operators.
+	Exits []Exit `bson:"exits,omitempty"`
+	// Registrations are the json encoded signed builder registrations by 0x-hex validator public key.
+	Registrations map[string][]byte `bson:"registrations,omitempty"`
+	// Nonces are the latest signed request timestamps by lowercase operator address.
+	Nonces map[string]int64 `bson:"nonces,omitempty"`
+	// DefinitionCID is the IPFS CID of the completed definition's json, empty if not published.
+	DefinitionCID string `bson:"definition_cid,omitempty"`
+	// LockCID is the IPFS CID of the lock's json, empty if not published.
+	LockCID string `bson:"lock_cid,omitempty"`
+	// DefinitionArchive is the object storage location of the completed definition's snapshot, empty if not archived.
+	DefinitionArchive string `bson:"definition_archive,omitempty"`
+	// LockArchive is the object storage location of the lock's snapshot, empty if not archived.
+	LockArchive string `bson:"lock_archive,omitempty"`
+}
+
 type definitionImpl struct {
-	table *mongo.Collection
+	store docStore
+	conf  DefinitionConfig
+	// defCache caches final definitions and their version by config hash.
+	defCache *lru
+	// lockCache caches locks by config hash.
+	lockCache *lru
 }
 
-func (d definitionImpl) Get(ctx context.Context, configHash []byte) (cluster.Definition, error) {
-	res := d.table.FindOne(ctx, bson.D{{"config_hash", configHash}})
-	if errors.Is(res.Err(), mongo.ErrNoDocuments) {
-		return cluster.Definition{}, errors.Wrap(ErrNotFound, "definition not found")
-	} else if res.Err() != nil {
-		return cluster.Definition{}, errors.Wrap(res.Err(), "failed to get definition")
+func (d definitionImpl) Get(ctx context.Context, configHash []byte) (cluster.Definition, bool, Version, error) {
+	if cached, ok := d.defCache.Get(cacheKey(ctx, configHash)); ok {
+		return cached.(cachedDefinition).Definition, true, cached.(cachedDefinition).Version, nil
 	}
 
-	var def cluster.Definition
-	err := res.Decode(&def)
+	doc, err := d.getDoc(ctx, configHash)
 	if err != nil {
-		return cluster.Definition{}, errors.Wrap(err, "failed to decode definition")
+		return cluster.Definition{}, false, Version{}, err
+	} else if doc.Status == StatusUnpublished {
+		return cluster.Definition{}, false, Version{}, errors.Wrap(ErrNotFound, "definition not published")
 	}
 
-	return def, nil
+	final := doc.Status.Final()
+	if final {
+		d.defCache.Add(cacheKey(ctx, configHash), cachedDefinition{Definition: doc.Definition, Version: doc.version()})
+	}
+
+	return doc.Definition, final, doc.version(), nil
 }
 
-func (d definitionImpl) Delete(ctx context.Context, configHash []byte) error {
-	res, err := d.table.DeleteOne(ctx, bson.D{{"config_hash", configHash}})
+func (d definitionImpl) GetMany(ctx context.Context, configHashes [][]byte) ([]cluster.Definition, error) {
+	var (
+		resp   []cluster.Definition
+		missed [][]byte
+	)
+	for _, configHash := range configHashes {
+		if cached, ok := d.defCache.Get(cacheKey(ctx, configHash)); ok {
+			resp = append(resp, cached.(cachedDefinition).Definition)
+		} else {
+			missed = append(missed, configHash)
+		}
+	}
+
+	if len(missed) == 0 {
+		return resp, nil
+	}
+
+	docs, err := d.store.findMany(ctx, missed)
 	if err != nil {
-		return errors.Wrap(err, "failed to delete definition")
-	} else if res.DeletedCount == 0 {
-		return errors.Wrap(ErrNotFound, "definition not found")
+		return nil, err
 	}
 
-	return nil
+	for _, doc := range docs {
+		if doc.Status.Final() {
+			d.defCache.Add(cacheKey(ctx, doc.ConfigHash), cachedDefinition{Definition: doc.Definition, Version: doc.version()})
+		}
+		resp = append(resp, doc.Definition)
+	}
+
+	return resp, nil
+}
+
+func (m mongoStore) findMany(ctx context.Context, configHashes [][]byte) ([]definitionDoc, error) {
+	ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opFind)
+	defer cancel()
+
+	cursor, err := m.table.Find(ctx, bson.D{
+		{"config_hash", bson.D{{"$in", configHashes}}},
+		{"status", bson.D{{"$ne", StatusUnpublished}}},
+		tenantMatch(ctx),
+	})
+	if err != nil {
+		return nil, wrapDBErr(err, opFind, "failed to find definitions")
+	}
+
+	var docs []definitionDoc
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, wrapDBErr(err, opFind, "failed to decode definitions")
+	}
+
+	return docs, nil
+}
+
+func (d definitionImpl) GetByPrefix(ctx context.Context, hexPrefix string, limit int) ([]cluster.Definition, error) {
+	from, to, err := prefixRange(hexPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := d.store.findRange(ctx, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]cluster.Definition, 0, len(docs))
+	for _, doc := range docs {
+		resp = append(resp, doc.Definition)
+	}
+
+	return resp, nil
+}
+
+func (m mongoStore) findRange(ctx context.Context, from, to []byte, limit int) ([]definitionDoc, error) {
+	ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opFind)
+	defer cancel()
+
+	cursor, err := m.table.Find(ctx,
+		bson.D{
+			{"config_hash", bson.D{{"$gte", from}, {"$lte", to}}},
+			{"status", bson.D{{"$ne", StatusUnpublished}}},
+			tenantMatch(ctx),
+		},
+		options.Find().SetLimit(int64(limit)).SetSort(bson.D{{"config_hash", 1}}))
+	if err != nil {
+		return nil, wrapDBErr(err, opFind, "failed to find definitions")
+	}
+
+	var docs []definitionDoc
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, wrapDBErr(err, opFind, "failed to decode definitions")
+	}
+
+	return docs, nil
 }
 
-func (d definitionImpl) Create(ctx context.Context, def cluster.Definition) error {
-	_, err := d.table.InsertOne(ctx, def)
+// prefixRange returns the inclusive range of config hashes starting with the hex prefix.
+// Mongo orders binary values by length before contents, so the range bounds are padded to the config hash length.
+func prefixRange(hexPrefix string) ([]byte, []byte, error) {
+	hexPrefix = strings.TrimPrefix(strings.ToLower(hexPrefix), "0x")
+	if len(hexPrefix) > configHashLen*2 {
+		return nil, nil, errors.Wrap(ErrInvalidRequest, "prefix longer than config hash", z.Str("prefix", hexPrefix))
+	}
+
+	from, err := hex.DecodeString(hexPrefix + strings.Repeat("0", configHashLen*2-len(hexPrefix)))
+	if err != nil {
+		return nil, nil, errors.Wrap(ErrInvalidRequest, "invalid hex prefix", z.Str("prefix", hexPrefix))
+	}
+
+	to, err := hex.DecodeString(hexPrefix + strings.Repeat("f", configHashLen*2-len(hexPrefix)))
+	if err != nil {
+		return nil, nil, errors.Wrap(ErrInvalidRequest, "invalid hex prefix", z.Str("prefix", hexPrefix))
+	}
+
+	return from, to, nil
+}
+
+func (d definitionImpl) Pin(ctx context.Context, configHash []byte, pinned bool, signer string, auth RequestAuth) error {
+	typ := EventPinned
+	if !pinned {
+		typ = EventUnpinned
+	}
+
+	return d.update(ctx, configHash, typ, func(doc *definitionDoc) error {
+		if doc.Pinned == pinned {
+			return errors.Wrap(ErrConflict, "definition pin unchanged", z.Bool("pinned", pinned))
+		}
+
+		if !isAdmin(ctx) {
+			if doc.Owner == "" {
+				return errors.Wrap(ErrInvalidState, "definition has no creator to authorize pins")
+			} else if !strings.EqualFold(signer, doc.Owner) {
+				return errors.Wrap(ErrInvalidRequest, "pin request not signed by creator", z.Str("address", signer))
+			} else if err := d.verifyRequest(doc, doc.Owner, actionPin, pinPayload(pinned), auth); err != nil {
+				return err
+			}
+		}
+		doc.Pinned = pinned
+
+		return nil
+	})
+}
+
+func (d definitionImpl) Create(ctx context.Context, def cluster.Definition, opts CreateOptions) (Created, error) {
+	doc, resp, exists, err := d.prepareCreate(ctx, def, opts, 0, 0)
 	if err != nil {
-		return errors.Wrap(err, "failed to create definition")
+		return Created{}, err
+	} else if exists {
+		return Created{}, nil
+	}
+
+	err = d.store.transact(ctx, func(ctx context.Context) error {
+		if err := d.store.insert(ctx, &doc); err != nil {
+			return err
+		}
+
+		return d.recordEvent(ctx, EventCreated, doc.ConfigHash, doc.Revision, &doc)
+	})
+	if errors.Is(err, errDuplicate) {
+		// Concurrently created, or created by another tenant.
+		if exists, err := d.verifyIdentical(ctx, doc.Definition); err != nil {
+			return Created{}, err
+		} else if !exists {
+			return Created{}, errors.Wrap(ErrConflict, "definition already exists in another tenant")
+		}
+
+		return Created{}, nil
+	} else if err != nil {
+		return Created{}, err
+	}
+
+	tenantDefinitions.WithLabelValues(tenantLabel(ctx)).Inc()
+
+	return resp, nil
+}
+
+func (m mongoStore) insert(ctx context.Context, doc *definitionDoc) error {
+	if err := m.shard(ctx, doc); err != nil {
+		return err
+	}
+
+	stored, err := m.compress(*doc)
+	if err != nil {
+		return err
+	}
+
+	err = m.conf.Retry.do(ctx, opInsert, func(ctx context.Context) error {
+		ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opInsert)
+		defer cancel()
+
+		_, err := m.table.InsertOne(ctx, stored)
+
+		return err
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return errDuplicate
+	} else if err != nil {
+		return wrapDBErr(err, opInsert, "failed to insert definition")
 	}
 
 	return nil
 }
 
-func (d definitionImpl) AddOperator(ctx context.Context, configHash []byte, forkVersion []byte, operator cluster.Operator) error {
-	res := d.table.FindOneAndUpdate(ctx,
-		bson.D{{"config_hash", configHash}},
-		bson.D{{"$addToSet", bson.D{{"operators", operator}}}},
+// prepareCreate verifies the definition and returns the document to insert and the created result,
+// or true if an identical definition already exists. PendingOwner and pendingTenant are the number of definitions
+// of the same owner and of the tenant being created concurrently in a batch, counting towards their quotas.
+func (d definitionImpl) prepareCreate(ctx context.Context, def cluster.Definition, opts CreateOptions, pendingOwner, pendingTenant int) (definitionDoc, Created, bool, error) {
+	def = canonicalDefinition(def)
+
+	if err := d.verifyForkVersion(def.ForkVersion); err != nil {
+		return definitionDoc{}, Created{}, false, err
+	} else if err := verifyInvariants(def); err != nil {
+		return definitionDoc{}, Created{}, false, err
+	} else if err := d.verifyThreshold(def); err != nil {
+		return definitionDoc{}, Created{}, false, err
+	} else if !opts.JoinBy.IsZero() && !opts.JoinBy.After(time.Now()) {
+		return definitionDoc{}, Created{}, false, errors.Wrap(ErrInvalidRequest, "join deadline in the past", z.Any("join_by", opts.JoinBy))
+	}
+
+	if len(opts.Parent) > 0 {
+		if err := d.verifyParent(ctx, def, opts.Parent); err != nil {
+			return definitionDoc{}, Created{}, false, err
+		}
+	}
+
+	status := StatusDraft
+	if opts.Unpublished {
+		var err error
+		def, err = def.SetDefinitionHashes()
+		if err != nil {
+			return definitionDoc{}, Created{}, false, errors.Wrap(ErrInvalidRequest, "invalid definition", z.Err(err))
+		}
+		status = StatusUnpublished
+	} else if err := d.verifyCreator(def); err != nil {
+		return definitionDoc{}, Created{}, false, err
+	}
+
+	// Retried requests are idempotent, checked before the quota the existing definition counts towards.
+	if exists, err := d.verifyIdentical(ctx, def); err != nil {
+		return definitionDoc{}, Created{}, false, err
+	} else if exists {
+		return definitionDoc{}, Created{}, true, nil
+	}
+
+	if err := d.verifyOwnerQuota(ctx, def.Creator.Address, pendingOwner); err != nil {
+		return definitionDoc{}, Created{}, false, err
+	} else if err := d.verifyTenantQuota(ctx, pendingTenant); err != nil {
+		return definitionDoc{}, Created{}, false, err
+	}
+
+	var (
+		resp    Created
+		invites []inviteToken
+	)
+	if opts.InviteTokens {
+		var err error
+		resp.InviteTokens, invites, err = newInviteTokens(len(def.Operators))
+		if err != nil {
+			return definitionDoc{}, Created{}, false, err
+		}
+	}
+
+	return definitionDoc{
+		ConfigHash:   def.ConfigHash,
+		Status:       status,
+		Version:      def.Version,
+		Type:         clusterType(def),
+		Owner:        def.Creator.Address,
+		Tenant:       tenantFromCtx(ctx),
+		Parent:       opts.Parent,
+		JoinBy:       opts.JoinBy,
+		InviteTokens: invites,
+		Definition:   def,
+		UpdatedAt:    modifiedNow(),
+	}, resp, false, nil
+}
+
+// verifyIdentical returns true if the definition already exists. It returns ErrConflict if the existing definition
+// isn't identical.
+func (d definitionImpl) verifyIdentical(ctx context.Context, def cluster.Definition) (bool, error) {
+	doc, err := d.getDoc(ctx, def.ConfigHash)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	existing, err := json.Marshal(doc.Definition)
+	if err != nil {
+		return false, errors.Wrap(err, "marshal existing definition")
+	}
+
+	created, err := json.Marshal(def)
+	if err != nil {
+		return false, errors.Wrap(err, "marshal definition")
+	}
+
+	if !bytes.Equal(existing, created) {
+		return false, errors.Wrap(ErrConflict, "different definition with config hash already exists",
+			z.Str("status", string(doc.Status)))
+	}
+
+	return true, nil
+}
+
+func (d definitionImpl) AddOperator(ctx context.Context, configHash []byte, forkVersion []byte, version string, operator cluster.Operator, auth RequestAuth) error {
+	var (
+		completed bool
+		def       cluster.Definition
 	)
+	err := d.update(ctx, configHash, EventOperatorAdded, func(doc *definitionDoc) error {
+		if doc.Status != StatusDraft {
+			return errors.Wrap(ErrInvalidState, "definition not accepting operators", z.Str("status", string(doc.Status)))
+		} else if d.conf.BlockExpired && d.expired(*doc) {
+			return errors.Wrap(ErrInvalidState, "definition expired", z.Str("timestamp", doc.Definition.Timestamp))
+		} else if overdue(*doc) {
+			return errors.Wrap(ErrInvalidState, "join deadline passed", z.Any("join_by", doc.JoinBy))
+		} else if !bytes.Equal(forkVersion, doc.Definition.ForkVersion) {
+			return errors.Wrap(ErrInvalidRequest, "fork version mismatch",
+				z.Hex("expected", doc.Definition.ForkVersion), z.Hex("actual", forkVersion))
+		} else if err := d.verifyForkVersion(forkVersion); err != nil {
+			// Definitions created before the network was disallowed can't be joined either.
+			return err
+		} else if version != "" && version != doc.Definition.Version {
+			if d.conf.RejectIncompatible {
+				return errors.Wrap(ErrInvalidRequest, "incompatible definition version",
+					z.Str("expected", doc.Definition.Version), z.Str("actual", version))
+			}
+			log.Warn(ctx, "Operator joining with incompatible definition version", nil,
+				z.Str("address", operator.Address), z.Str("expected", doc.Definition.Version), z.Str("actual", version))
+		}
+
+		idx, ok := operatorIndex(doc.Definition, operator.Address)
+		if !ok {
+			return errors.Wrap(ErrInvalidRequest, "operator not in definition", z.Str("address", operator.Address))
+		}
+
+		if len(doc.InviteTokens) == 0 && d.conf.RequireInvites {
+			return errors.Wrap(ErrInvalidRequest, "invite token required", z.Str("address", operator.Address))
+		} else if len(doc.InviteTokens) > 0 {
+			// Invite tokens bind the operator to its slot.
+			var err error
+			idx, err = inviteSlot(doc, operator.Address, auth.InviteToken)
+			if err != nil {
+				return err
+			}
+			doc.InviteTokens[idx].Used = true
+		}
+
+		operator.Address = doc.Definition.Operators[idx].Address // Retain the original address encoding.
+		if err := d.verifyRequest(doc, operator.Address, actionAddOperator, addOperatorPayload(forkVersion, version, operator), auth); err != nil {
+			return err
+		} else if err := verifyOperatorConfigSignature(doc.Definition, operator); err != nil {
+			return err
+		} else if err := verifyOperatorENR(doc.Definition, operator); err != nil {
+			return err
+		}
+
+		wasComplete := allJoined(doc.Definition)
+
+		doc.Definition.Operators[idx] = operator
+		doc.Declined = removeAddress(doc.Declined, operator.Address)
+		if err := verifyInvariants(doc.Definition); err != nil {
+			return err // Another operator joined with the same ENR.
+		}
+
+		var err error
+		doc.Definition, err = doc.Definition.SetDefinitionHashes()
+		if err != nil {
+			return errors.Wrap(err, "failed to set definition hashes")
+		}
+
+		completed = !wasComplete && allJoined(doc.Definition)
+		def = doc.Definition
+
+		if completed && d.conf.AutoFinalize {
+			if err := verifyFinalizable(doc); err != nil {
+				log.Warn(ctx, "Not finalizing completed definition", err, z.Hex("config_hash", doc.ConfigHash))
+			} else {
+				doc.Status = StatusReady
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if completed {
+		observeCompletion(def)
+		d.publishAsync(ctx, configHash, "definition", def)
+		d.archiveAsync(ctx, configHash, "definition", def)
+	}
+
+	if completed && d.conf.Notifier != nil {
+		// Notify asynchronously, continuing the trace of the request without its cancellation.
+		notifyCtx := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+		go d.notifyComplete(log.WithTopic(notifyCtx, "notify"), def)
+	}
+
+	return nil
+}
+
+// notifyComplete notifies that all operators joined the definition, logging any failure.
+func (d definitionImpl) notifyComplete(ctx context.Context, def cluster.Definition) {
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+
+	if err := d.conf.Notifier.Complete(ctx, def); err != nil {
+		log.Warn(ctx, "Failed notifying cluster completion", err, z.Hex("config_hash", def.ConfigHash))
+	}
+}
+
+func (d definitionImpl) Decline(ctx context.Context, configHash []byte, operator cluster.Operator, auth RequestAuth) error {
+	return d.update(ctx, configHash, EventOperatorDeclined, func(doc *definitionDoc) error {
+		if doc.Status != StatusDraft {
+			return errors.Wrap(ErrInvalidState, "definition not accepting operators", z.Str("status", string(doc.Status)))
+		}
+
+		idx, ok := operatorIndex(doc.Definition, operator.Address)
+		if !ok {
+			return errors.Wrap(ErrInvalidRequest, "operator not in definition", z.Str("address", operator.Address))
+		}
+
+		// Operators decline by signing a decline request, proving that they control the address. Their config
+		// signature isn't proof, since it is public once they joined and absent in definitions before v1.3.
+		address := doc.Definition.Operators[idx].Address
+		if err := d.verifyRequest(doc, address, actionDecline, addressPayload(address), auth); err != nil {
+			return err
+		}
+
+		// Clear any previously accepted invitation.
+		doc.Definition.Operators[idx] = cluster.Operator{Address: address}
+		doc.Declined = append(removeAddress(doc.Declined, address), address)
+
+		var err error
+		doc.Definition, err = doc.Definition.SetDefinitionHashes()
+		if err != nil {
+			return errors.Wrap(err, "failed to set definition hashes")
+		}
+
+		return nil
+	})
+}
+
+func (d definitionImpl) Finalize(ctx context.Context, configHash []byte) error {
+	return d.update(ctx, configHash, EventFinalized, func(doc *definitionDoc) error {
+		if !doc.Status.CanTransition(StatusReady) {
+			return errors.Wrap(ErrInvalidState, "definition cannot be finalized", z.Str("status", string(doc.Status)))
+		} else if err := verifyFinalizable(doc); err != nil {
+			return err
+		}
+
+		doc.Status = StatusReady
+
+		return nil
+	})
+}
+
+// verifyFinalizable returns ErrInvalidState if not all operators accepted the definition with valid signatures.
+func verifyFinalizable(doc *definitionDoc) error {
+	if len(doc.Declined) > 0 {
+		return errors.Wrap(ErrInvalidState, "operators declined", z.Any("declined", doc.Declined))
+	}
+
+	for _, operator := range doc.Definition.Operators {
+		if operator.ENR == "" {
+			return errors.Wrap(ErrInvalidState, "operator not accepted", z.Str("address", operator.Address))
+		}
+
+		if err := verifyOperatorConfigSignature(doc.Definition, operator); err != nil {
+			return errors.Wrap(ErrInvalidState, "operator config signature invalid", z.Str("address", operator.Address), z.Err(err))
+		} else if err := verifyOperatorENR(doc.Definition, operator); err != nil {
+			return errors.Wrap(ErrInvalidState, "operator enr invalid", z.Str("address", operator.Address), z.Err(err))
+		}
+	}
+
+	if err := doc.Definition.VerifyHashes(); err != nil {
+		return errors.Wrap(ErrInvalidState, "invalid definition hashes", z.Err(err))
+	}
+
+	if err := doc.Definition.VerifySignatures(); err != nil {
+		return errors.Wrap(ErrInvalidState, "invalid definition signatures", z.Err(err))
+	}
+
+	return nil
+}
+
+func (d definitionImpl) Lock(ctx context.Context, configHash []byte, lock cluster.Lock) error {
+	err := d.update(ctx, configHash, EventLocked, func(doc *definitionDoc) error {
+		if !doc.Status.CanTransition(StatusLocked) {
+			return errors.Wrap(ErrInvalidState, "definition cannot be locked", z.Str("status", string(doc.Status)))
+		}
+
+		if !bytes.Equal(lock.ConfigHash, doc.ConfigHash) {
+			return errors.Wrap(ErrInvalidRequest, "lock config hash mismatch")
+		} else if !bytes.Equal(lock.DefinitionHash, doc.Definition.DefinitionHash) {
+			return errors.Wrap(ErrInvalidRequest, "lock definition hash mismatch")
+		}
+
+		if err := lock.VerifyHashes(); err != nil {
+			return errors.Wrap(ErrInvalidRequest, "invalid lock hashes", z.Err(err))
+		}
+
+		if err := lock.VerifySignatures(); err != nil {
+			return errors.Wrap(ErrInvalidRequest, "invalid lock signatures", z.Err(err))
+		}
+
+		doc.Status = StatusLocked
+		doc.Lock = &lock
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	d.publishAsync(ctx, configHash, "lock", lock)
+	d.archiveAsync(ctx, configHash, "lock", lock)
+
+	return nil
+}
+
+func (d definitionImpl) GetLock(ctx context.Context, configHash []byte) (cluster.Lock, error) {
+	if lock, ok := d.lockCache.Get(cacheKey(ctx, configHash)); ok {
+		return lock.(cluster.Lock), nil
+	}
+
+	doc, err := d.getDoc(ctx, configHash)
+	if err != nil {
+		return cluster.Lock{}, err
+	} else if doc.Lock == nil {
+		return cluster.Lock{}, errors.Wrap(ErrNotFound, "lock not found")
+	}
+
+	d.lockCache.Add(cacheKey(ctx, configHash), *doc.Lock)
+
+	return *doc.Lock, nil
+}
+
+func (d definitionImpl) State(ctx context.Context, configHash []byte) (State, error) {
+	doc, err := d.getDoc(ctx, configHash)
+	if err != nil {
+		return State{}, err
+	}
+
+	return d.state(doc), nil
+}
+
+// state returns the DKG ceremony state of the definition document.
+func (d definitionImpl) state(doc definitionDoc) State {
+	network, _ := eth2util.ForkVersionToNetwork(doc.Definition.ForkVersion) // Empty if unknown.
+
+	status := doc.Status
+	if overdue(doc) {
+		status = StatusCancelled // Until cancelled by the join deadline sweep.
+	} else if d.expired(doc) {
+		status = StatusExpired
+	}
+
+	var joinBy *time.Time
+	if !doc.JoinBy.IsZero() {
+		joinBy = &doc.JoinBy
+	}
+
+	return State{
+		Status:          status,
+		Revision:        doc.Revision,
+		Network:         network,
+		Version:         doc.Definition.Version,
+		Type:            doc.Type,
+		Owner:           doc.Owner,
+		Declined:        doc.Declined,
+		JoinBy:          joinBy,
+		Pinned:          doc.Pinned,
+		PendingDeletion: doc.PendingDeletion.deletion(d.conf.DeleteCoolOff),
+		Metadata:        doc.Metadata,
+		DefinitionCID:   doc.DefinitionCID,
+		LockCID:         doc.LockCID,
+	}
+}
+
+func (d definitionImpl) GetValidator(ctx context.Context, pubkey []byte) (ValidatorRef, error) {
+	doc, err := d.getValidatorDoc(ctx, pubkey)
+	if err != nil {
+		return ValidatorRef{}, err
+	}
+
+	for i, val := range doc.Lock.Validators {
+		if !bytes.Equal(val.PubKey, pubkey) {
+			continue
+		}
+
+		return ValidatorRef{
+			ConfigHash: fmt.Sprintf("%#x", doc.ConfigHash),
+			LockHash:   fmt.Sprintf("%#x", doc.Lock.LockHash),
+			Index:      i,
+		}, nil
+	}
+
+	return ValidatorRef{}, errors.New("validator not in lock") // This should never happen.
+}
+
+// getValidatorDoc returns the locked definition document containing the distributed validator public key.
+func (d definitionImpl) getValidatorDoc(ctx context.Context, pubkey []byte) (definitionDoc, error) {
+	doc, err := d.store.findValidator(ctx, pubkey)
+	if err != nil {
+		return definitionDoc{}, err
+	} else if doc.Lock == nil {
+		return definitionDoc{}, errors.New("missing lock") // This should never happen.
+	}
+
+	return doc, nil
+}
+
+func (m mongoStore) findValidator(ctx context.Context, pubkey []byte) (definitionDoc, error) {
+	ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opFind)
+	defer cancel()
+
+	res := m.table.FindOne(ctx, bson.D{{"$or", bson.A{
+		bson.D{{"lock.validators.pubkey", pubkey}},
+		bson.D{{"validator_pubkeys", pubkey}}, // Compressed locks.
+	}}, tenantMatch(ctx)})
 	if errors.Is(res.Err(), mongo.ErrNoDocuments) {
-		return errors.Wrap(ErrNotFound, "definition not found")
+		return definitionDoc{}, errors.Wrap(ErrNotFound, "validator not found")
 	} else if res.Err() != nil {
-		return errors.Wrap(res.Err(), "failed to get definition")
+		return definitionDoc{}, wrapDBErr(res.Err(), opFind, "failed to get validator")
 	}
 
-	return nil
+	var doc definitionDoc
+	if err := res.Decode(&doc); err != nil {
+		return definitionDoc{}, errors.Wrap(err, "failed to decode definition")
+	}
+
+	if err := m.loadValidators(ctx, &doc); err != nil {
+		return definitionDoc{}, err
+	}
+
+	return doc, nil
+}
+
+// expired returns true if the definition is an unpinned draft with an embedded timestamp older than the configured expiry.
+func (d definitionImpl) expired(doc definitionDoc) bool {
+	if d.conf.DraftExpiry == 0 || doc.Status != StatusDraft || doc.Pinned {
+		return false
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, doc.Definition.Timestamp)
+	if err != nil {
+		return false // Older definition versions may not contain a timestamp.
+	}
+
+	return time.Since(timestamp) > d.conf.DraftExpiry
+}
+
+// getDoc returns the definition document by config hash, including the validators of its sharded lock.
+func (d definitionImpl) getDoc(ctx context.Context, configHash []byte) (definitionDoc, error) {
+	return d.store.find(ctx, configHash)
+}
+
+func (m mongoStore) find(ctx context.Context, configHash []byte) (definitionDoc, error) {
+	doc, err := m.findStored(ctx, configHash)
+	if err != nil {
+		return definitionDoc{}, err
+	}
+
+	if err := m.loadValidators(ctx, &doc); err != nil {
+		return definitionDoc{}, err
+	}
+
+	return doc, nil
+}
+
+// findStored returns the definition document by config hash as stored, i.e., excluding the validators of its
+// sharded lock.
+func (m mongoStore) findStored(ctx context.Context, configHash []byte) (definitionDoc, error) {
+	var res *mongo.SingleResult
+	err := m.conf.Retry.do(ctx, opFind, func(ctx context.Context) error {
+		ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opFind)
+		defer cancel()
+
+		res = m.table.FindOne(ctx, bson.D{{"config_hash", configHash}, tenantMatch(ctx)})
+
+		return res.Err()
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return definitionDoc{}, errors.Wrap(ErrNotFound, "definition not found")
+	} else if err != nil {
+		return definitionDoc{}, wrapDBErr(err, opFind, "failed to get definition")
+	}
+
+	var doc definitionDoc
+	err = res.Decode(&doc)
+	if err != nil {
+		return definitionDoc{}, errors.Wrap(err, "failed to decode definition")
+	}
+
+	return doc, nil
+}
+
+// update applies fn to the definition document and replaces it, retrying if it was concurrently modified.
+// It returns ErrPreconditionFailed if the definition doesn't match the context's precondition. The mutation is appended to the definition's change log as an event of the type.
+func (d definitionImpl) update(ctx context.Context, configHash []byte, typ EventType, fn func(*definitionDoc) error) error {
+	for i := 0; i < maxUpdateAttempts; i++ {
+		doc, err := d.getDoc(ctx, configHash)
+		if err != nil {
+			return err
+		} else if err := verifyIfMatch(ctx, doc); err != nil {
+			return err
+		}
+
+		if err := fn(&doc); err != nil {
+			return err
+		}
+
+		if matched, err := d.replace(ctx, configHash, typ, &doc); err != nil {
+			return err
+		} else if matched {
+			return nil
+		}
+		// Concurrently modified, try again.
+	}
+
+	return errors.Wrap(ErrConflict, "definition concurrently modified")
+}
+
+// replace stores the modified document read with the config hash as its next revision, unless the definition was
+// concurrently modified, in which case it returns false. The mutation is appended to the definition's change log
+// as an event of the type. The cached definition and lock are invalidated, since pins, metadata and pending
+// deletions change the document's revision and thereby its entity tag, even if the definition is final.
+func (d definitionImpl) replace(ctx context.Context, configHash []byte, typ EventType, doc *definitionDoc) (bool, error) {
+	revision := doc.Revision
+	doc.Revision++
+	doc.UpdatedAt = modifiedNow()
+
+	var matched bool
+	err := d.store.transact(ctx, func(ctx context.Context) error {
+		var err error
+		matched, err = d.store.replace(ctx, configHash, revision, doc)
+		if err != nil || !matched {
+			return err
+		}
+
+		return d.recordEvent(ctx, typ, doc.ConfigHash, doc.Revision, doc) // Config hash changes when revising unpublished definitions.
+	})
+	if err != nil {
+		return false, err
+	} else if matched {
+		d.invalidate(ctx, configHash)
+	}
+
+	return matched, nil
+}
+
+// invalidate removes the cached definition and lock of the config hash, so subsequent reads of this replica
+// don't serve them stale. Other replicas invalidate theirs when watching the change log.
+func (d definitionImpl) invalidate(ctx context.Context, configHash []byte) {
+	d.defCache.Remove(cacheKey(ctx, configHash))
+	d.lockCache.Remove(cacheKey(ctx, configHash))
+}
+
+func (m mongoStore) replace(ctx context.Context, configHash []byte, revision int, doc *definitionDoc) (bool, error) {
+	if err := m.shard(ctx, doc); err != nil {
+		return false, err
+	}
+
+	stored, err := m.compress(*doc)
+	if err != nil {
+		return false, err
+	}
+
+	var res *mongo.UpdateResult
+	err = m.conf.Retry.do(ctx, opUpdate, func(ctx context.Context) error {
+		ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opUpdate)
+		defer cancel()
+
+		var err error
+		res, err = m.table.ReplaceOne(ctx, bson.D{{"config_hash", configHash}, {"revision", revision}}, stored)
+
+		return err
+	})
+	if err != nil {
+		return false, wrapDBErr(err, opUpdate, "failed to update definition")
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+// cachedDefinition is a cached final definition.
+type cachedDefinition struct {
+	Definition cluster.Definition
+	Version    Version
+}
+
+// Version is the stored version of a definition, identifying the state of its document.
+type Version struct {
+	// Revision is the revision of the definition document, incremented by every change, see State.Revision.
+	Revision int
+	// UpdatedAt is when the definition was last modified.
+	UpdatedAt time.Time
+}
+
+// version returns the stored version of the document.
+func (doc definitionDoc) version() Version {
+	return Version{Revision: doc.Revision, UpdatedAt: doc.UpdatedAt}
+}
+
+// modifiedNow returns the current modification time, truncated to the millisecond precision of mongo dates.
+func modifiedNow() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// allJoined returns true if all operators of the definition accepted the invitation by populating their ENRs.
+func allJoined(def cluster.Definition) bool {
+	for _, op := range def.Operators {
+		if op.ENR == "" {
+			return false
+		}
+	}
+
+	return len(def.Operators) > 0
+}
+
+// operatorIndex returns the index of the operator with the address in the definition or false if not present.
+func operatorIndex(def cluster.Definition, address string) (int, bool) {
+	for i, operator := range def.Operators {
+		if strings.EqualFold(operator.Address, address) {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// removeAddress returns the addresses excluding the provided address.
+func removeAddress(addresses []string, address string) []string {
+	var resp []string
+	for _, a := range addresses {
+		if !strings.EqualFold(a, address) {
+			resp = append(resp, a)
+		}
+	}
+
+	return resp
 }
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"go.mongodb.org/mongo-driver/bson"
+	"strings"
+	"time"
+)
+
+// Deletion is the result of deleting a definition.
+type Deletion struct {
+	// Pending is true if the deletion of the final definition awaits approval.
+	Pending bool `json:"pending"`
+	// RequestedBy is the principal that requested the pending deletion.
+	RequestedBy string    `json:"requested_by,omitempty"`
+	RequestedAt time.Time `json:"requested_at,omitempty"`
+	// ConfirmableAt is the time after which the requester may confirm the pending deletion itself.
+	ConfirmableAt time.Time `json:"confirmable_at,omitempty"`
+}
+
+// pendingDeletion is the mongo document of a deletion request awaiting approval.
+type pendingDeletion struct {
+	RequestedBy string    `bson:"requested_by"`
+	RequestedAt time.Time `bson:"requested_at"`
+}
+
+// deletion returns the pending deletion response or nil if no deletion is pending.
+func (p *pendingDeletion) deletion(coolOff time.Duration) *Deletion {
+	if p == nil {
+		return nil
+	}
+
+	return &Deletion{
+		Pending:       true,
+		RequestedBy:   p.RequestedBy,
+		RequestedAt:   p.RequestedAt,
+		ConfirmableAt: p.RequestedAt.Add(coolOff),
+	}
+}
+
+func (d definitionImpl) Delete(ctx context.Context, configHash []byte, principal string, signer string, auth RequestAuth) (Deletion, error) {
+	doc, err := d.getDoc(ctx, configHash)
+	if err != nil {
+		return Deletion{}, err
+	} else if err := verifyIfMatch(ctx, doc); err != nil {
+		return Deletion{}, err
+	} else if doc.Pinned {
+		return Deletion{}, errors.Wrap(ErrInvalidState, "definition pinned")
+	}
+
+	// The signed revision is the read revision, which the deletion must match. The request's nonce is stored with
+	// the pending deletion or the trash copy.
+	if !isAdmin(ctx) {
+		if err := d.verifyDeleteRequest(&doc, signer, auth); err != nil {
+			return Deletion{}, err
+		}
+	}
+
+	if d.conf.DeleteApproval && doc.Status.Final() {
+		// Approval is only meaningful between distinct authenticated principals, anonymous requests, including all
+		// requests if no authenticators are configured, can't tell principals apart.
+		if principal == "" {
+			return Deletion{}, errors.Wrap(ErrInvalidRequest, "deleting final definitions requires an authenticated principal")
+		} else if doc.PendingDeletion == nil {
+			return d.requestDeletion(ctx, doc, principal)
+		}
+
+		pending := doc.PendingDeletion
+		if principal == pending.RequestedBy && time.Since(pending.RequestedAt) < d.conf.DeleteCoolOff {
+			return Deletion{}, errors.Wrap(ErrInvalidState, "deletion awaiting approval by another principal",
+				z.Any("confirmable_at", pending.RequestedAt.Add(d.conf.DeleteCoolOff)))
+		}
+	}
+
+	// Only delete the read revision, i.e., not if concurrently modified, pinned or the deletion cancelled.
+	var removed bool
+	err = d.store.transact(ctx, func(ctx context.Context) error {
+		var err error
+		removed, err = d.store.remove(ctx, doc, doc.Revision, principal, d.conf.TrashRetention > 0)
+		if err != nil || !removed {
+			return err
+		}
+
+		return d.recordEvent(ctx, EventDeleted, configHash, doc.Revision+1, nil)
+	})
+	if err != nil {
+		return Deletion{}, err
+	} else if !removed {
+		return Deletion{}, errors.Wrap(ErrConflict, "definition concurrently modified")
+	}
+
+	d.invalidate(ctx, configHash)
+	definitionsDeleted.WithLabelValues(string(doc.Status)).Inc()
+
+	return Deletion{}, nil
+}
+
+func (m mongoStore) remove(ctx context.Context, doc definitionDoc, revision int, principal string, trash bool) (bool, error) {
+	ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opUpdate)
+	defer cancel()
+
+	if trash {
+		return m.trashDefinition(ctx, doc, revision, principal)
+	}
+
+	res, err := m.table.DeleteOne(ctx, bson.D{{"config_hash", doc.ConfigHash}, {"revision", revision}})
+	if err != nil {
+		return false, wrapDBErr(err, opUpdate, "failed to delete definition")
+	}
+
+	return res.DeletedCount > 0, nil
+}
+
+// requestDeletion marks the final definition document as pending deletion by the principal, unless it was
+// concurrently modified.
+func (d definitionImpl) requestDeletion(ctx context.Context, doc definitionDoc, principal string) (Deletion, error) {
+	pending := &pendingDeletion{
+		RequestedBy: principal,
+		RequestedAt: time.Now().UTC().Truncate(time.Millisecond), // Mongo dates have millisecond precision.
+	}
+	doc.PendingDeletion = pending
+
+	if ok, err := d.replace(ctx, doc.ConfigHash, EventDeletionRequested, &doc); err != nil {
+		return Deletion{}, err
+	} else if !ok {
+		return Deletion{}, errors.Wrap(ErrConflict, "definition concurrently modified")
+	}
+
+	return *pending.deletion(d.conf.DeleteCoolOff), nil
+}
+
+func (d definitionImpl) CancelDeletion(ctx context.Context, configHash []byte, principal string, signer string, auth RequestAuth) error {
+	return d.update(ctx, configHash, EventDeletionCancelled, func(doc *definitionDoc) error {
+		pending := doc.PendingDeletion
+		if pending == nil {
+			return errors.Wrap(ErrNotFound, "no pending deletion")
+		}
+
+		// Admins and the requester may cancel directly, anyone else must be the creator signing the request.
+		if !isAdmin(ctx) && (principal == "" || principal != pending.RequestedBy) {
+			if doc.Owner == "" {
+				return errors.Wrap(ErrInvalidRequest, "deletion may only be cancelled by its requester")
+			} else if !strings.EqualFold(signer, doc.Owner) {
+				return errors.Wrap(ErrInvalidRequest, "cancel deletion request not signed by creator", z.Str("address", signer))
+			} else if err := d.verifyRequest(doc, doc.Owner, actionCancelDeletion, addressPayload(signer), auth); err != nil {
+				return err
+			}
+		}
+		doc.PendingDeletion = nil
+
+		return nil
+	})
+}
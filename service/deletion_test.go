@@ -0,0 +1,221 @@
+package service_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"github.com/corverroos/dvstore/service"
+	"github.com/corverroos/dvstore/testutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/obolnetwork/charon/app/errors"
+	"testing"
+	"time"
+)
+
+func TestDeleteApproval(t *testing.T) {
+	type step struct {
+		principal   string
+		wantErr     error
+		wantPending bool
+	}
+
+	tests := []struct {
+		name        string
+		coolOff     time.Duration
+		steps       []step
+		wantDeleted bool
+	}{
+		{
+			name:    "anonymous request",
+			coolOff: time.Hour,
+			steps:   []step{{principal: "", wantErr: service.ErrInvalidRequest}},
+		},
+		{
+			name:        "approved by another principal",
+			coolOff:     time.Hour,
+			steps:       []step{{principal: "alice", wantPending: true}, {principal: "bob"}},
+			wantDeleted: true,
+		},
+		{
+			name:    "anonymous approval",
+			coolOff: time.Hour,
+			steps:   []step{{principal: "alice", wantPending: true}, {principal: "", wantErr: service.ErrInvalidRequest}},
+		},
+		{
+			name:    "requester within cool-off",
+			coolOff: time.Hour,
+			steps:   []step{{principal: "alice", wantPending: true}, {principal: "alice", wantErr: service.ErrInvalidState}},
+		},
+		{
+			name:        "requester after cool-off",
+			coolOff:     time.Nanosecond,
+			steps:       []step{{principal: "alice", wantPending: true}, {principal: "alice"}},
+			wantDeleted: true,
+		},
+	}
+
+	f := testutil.NewFixture(t, 1, 3, 4, 0)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			defs := newFinalized(t, service.DefinitionConfig{DeleteApproval: true, DeleteCoolOff: test.coolOff}, f)
+			sign := newSigner(t, f)
+
+			for i, step := range test.steps {
+				state, err := defs.State(ctx, f.Draft.ConfigHash)
+				if err != nil {
+					t.Fatalf("state: %v", err)
+				}
+
+				auth := sign(func(key *ecdsa.PrivateKey, ts int64) (service.RequestAuth, error) {
+					return service.SignDelete(key, f.Draft, state.Revision, ts)
+				})
+
+				deletion, err := defs.Delete(ctx, f.Draft.ConfigHash, step.principal, creatorAddress(f), auth)
+				if step.wantErr != nil {
+					if !errors.Is(err, step.wantErr) {
+						t.Fatalf("step %d: unexpected error: got %v, want %v", i, err, step.wantErr)
+					}
+
+					continue
+				} else if err != nil {
+					t.Fatalf("step %d: unexpected error: %v", i, err)
+				} else if deletion.Pending != step.wantPending {
+					t.Fatalf("step %d: unexpected pending: got %v, want %v", i, deletion.Pending, step.wantPending)
+				}
+			}
+
+			_, err := defs.State(ctx, f.Draft.ConfigHash)
+			if deleted := errors.Is(err, service.ErrNotFound); deleted != test.wantDeleted {
+				t.Fatalf("unexpected deleted: got %v, want %v (%v)", deleted, test.wantDeleted, err)
+			}
+		})
+	}
+}
+
+func TestCancelDeletion(t *testing.T) {
+	f := testutil.NewFixture(t, 1, 3, 4, 0)
+
+	tests := []struct {
+		name      string
+		admin     bool
+		principal string
+		// key signs the request, nil if unsigned.
+		key     *ecdsa.PrivateKey
+		wantErr bool
+	}{
+		{name: "requester", principal: "alice"},
+		{name: "admin", admin: true, principal: "bob"},
+		{name: "creator signed", principal: "bob", key: f.OperatorKeys[0]},
+		{name: "other principal", principal: "bob", wantErr: true},
+		{name: "anonymous", principal: "", wantErr: true},
+		{name: "operator signed", principal: "bob", key: f.OperatorKeys[1], wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			defs := newFinalized(t, service.DefinitionConfig{DeleteApproval: true, DeleteCoolOff: time.Hour}, f)
+			sign := newSigner(t, f)
+
+			state, err := defs.State(ctx, f.Draft.ConfigHash)
+			if err != nil {
+				t.Fatalf("state: %v", err)
+			}
+
+			auth := sign(func(key *ecdsa.PrivateKey, ts int64) (service.RequestAuth, error) {
+				return service.SignDelete(key, f.Draft, state.Revision, ts)
+			})
+			if _, err := defs.Delete(ctx, f.Draft.ConfigHash, "alice", creatorAddress(f), auth); err != nil {
+				t.Fatalf("request deletion: %v", err)
+			}
+
+			var (
+				signer     string
+				cancelAuth service.RequestAuth
+			)
+			if test.key != nil {
+				signer = crypto.PubkeyToAddress(test.key.PublicKey).Hex()
+				cancelAuth, err = service.SignCancelDeletion(test.key, f.Draft, time.Now().Unix()+100)
+				if err != nil {
+					t.Fatalf("sign cancel deletion: %v", err)
+				}
+			}
+
+			cancelCtx := ctx
+			if test.admin {
+				cancelCtx = service.WithAdmin(ctx)
+			}
+
+			err = defs.CancelDeletion(cancelCtx, f.Draft.ConfigHash, test.principal, signer, cancelAuth)
+			if test.wantErr && !errors.Is(err, service.ErrInvalidRequest) {
+				t.Fatalf("unexpected error: got %v, want %v", err, service.ErrInvalidRequest)
+			} else if !test.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			state, err = defs.State(ctx, f.Draft.ConfigHash)
+			if err != nil {
+				t.Fatalf("state: %v", err)
+			} else if pending := state.PendingDeletion != nil; pending != test.wantErr {
+				t.Fatalf("unexpected pending deletion: got %v, want %v", pending, test.wantErr)
+			}
+		})
+	}
+}
+
+// newFinalized returns the definition service of a new in-memory storage driver with the configuration, storing
+// the fixture's definition after all operators joined and it was finalized.
+func newFinalized(t *testing.T, conf service.DefinitionConfig, f testutil.Fixture) service.Definition {
+	t.Helper()
+
+	ctx := context.Background()
+	defs := testutil.NewDefinition(conf)
+
+	if _, err := defs.Create(ctx, f.Draft, service.CreateOptions{}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	for i := range f.Draft.Operators {
+		auth, err := service.SignAddOperator(f.OperatorKeys[i], f.Draft, f.Operator(i), time.Now().Unix())
+		if err != nil {
+			t.Fatalf("sign add operator: %v", err)
+		}
+
+		err = defs.AddOperator(ctx, f.Draft.ConfigHash, f.Draft.ForkVersion, f.Draft.Version, f.Operator(i), auth)
+		if err != nil {
+			t.Fatalf("add operator %d: %v", i, err)
+		}
+	}
+
+	if err := defs.Finalize(ctx, f.Draft.ConfigHash); err != nil {
+		t.Fatalf("finalize: %v", err)
+	}
+
+	return defs
+}
+
+// newSigner returns a function signing requests by the fixture's creator key with increasing timestamps,
+// all after the requests of newFinalized.
+func newSigner(t *testing.T, f testutil.Fixture) func(func(*ecdsa.PrivateKey, int64) (service.RequestAuth, error)) service.RequestAuth {
+	t.Helper()
+
+	ts := time.Now().Unix()
+
+	return func(sign func(*ecdsa.PrivateKey, int64) (service.RequestAuth, error)) service.RequestAuth {
+		t.Helper()
+
+		ts++
+		auth, err := sign(f.OperatorKeys[0], ts)
+		if err != nil {
+			t.Fatalf("sign request: %v", err)
+		}
+
+		return auth
+	}
+}
+
+// creatorAddress returns the address of the fixture's creator, its first operator.
+func creatorAddress(f testutil.Fixture) string {
+	return crypto.PubkeyToAddress(f.OperatorKeys[0].PublicKey).Hex()
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	eth2p0 "github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/coinbase/kryptology/pkg/signatures/bls/bls_sig"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"github.com/obolnetwork/charon/eth2util"
+	"github.com/obolnetwork/charon/eth2util/deposit"
+	"github.com/obolnetwork/charon/tbls"
+	"github.com/obolnetwork/charon/tbls/tblsconv"
+	"strconv"
+	"strings"
+)
+
+func (d definitionImpl) AddDepositSignatures(ctx context.Context, configHash []byte, shareIdx int, partialSigs map[string][]byte) error {
+	return d.update(ctx, configHash, EventSignatureAdded, func(doc *definitionDoc) error {
+		if doc.Status != StatusLocked || doc.Lock == nil {
+			return errors.Wrap(ErrInvalidState, "definition not locked", z.Str("status", string(doc.Status)))
+		} else if shareIdx < 1 || shareIdx > len(doc.Lock.Operators) {
+			return errors.Wrap(ErrInvalidRequest, "invalid share index", z.Int("share_idx", shareIdx))
+		}
+
+		network, err := eth2util.ForkVersionToNetwork(doc.Lock.ForkVersion)
+		if err != nil {
+			return errors.Wrap(err, "lock network")
+		}
+
+		if doc.DepositPartials == nil {
+			doc.DepositPartials = make(map[string]map[string][]byte)
+		}
+		if doc.DepositSignatures == nil {
+			doc.DepositSignatures = make(map[string][]byte)
+		}
+
+		for pubkeyHex, sig := range partialSigs {
+			val, ok := lockValidator(*doc.Lock, pubkeyHex)
+			if !ok {
+				return errors.Wrap(ErrInvalidRequest, "validator not in lock", z.Str("pubkey", pubkeyHex))
+			}
+
+			pubshare, err := val.PublicShare(shareIdx - 1)
+			if err != nil {
+				return errors.Wrap(err, "validator public share")
+			}
+
+			pubkey := fmt.Sprintf("%#x", val.PubKey)
+			if err := verifyDepositSignature(val.PubKey, pubshare, doc.Lock.WithdrawalAddress, network, sig); err != nil {
+				return errors.Wrap(ErrInvalidRequest, "invalid partial deposit signature", z.Str("pubkey", pubkey), z.Err(err))
+			}
+
+			if doc.DepositPartials[pubkey] == nil {
+				doc.DepositPartials[pubkey] = make(map[string][]byte)
+			}
+			doc.DepositPartials[pubkey][strconv.Itoa(shareIdx)] = sig
+
+			if _, ok := doc.DepositSignatures[pubkey]; ok || len(doc.DepositPartials[pubkey]) < doc.Lock.Threshold {
+				continue
+			}
+
+			aggSig, err := aggregateDepositSignatures(val, doc.DepositPartials[pubkey], doc.Lock.WithdrawalAddress, network)
+			if err != nil {
+				return err
+			}
+			doc.DepositSignatures[pubkey] = aggSig
+		}
+
+		return nil
+	})
+}
+
+func (d definitionImpl) DepositData(ctx context.Context, configHash []byte) ([]byte, error) {
+	doc, err := d.getDoc(ctx, configHash)
+	if err != nil {
+		return nil, err
+	} else if doc.Lock == nil || len(doc.DepositSignatures) == 0 {
+		return nil, errors.Wrap(ErrNotFound, "deposit data not found")
+	}
+
+	network, err := eth2util.ForkVersionToNetwork(doc.Lock.ForkVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "lock network")
+	}
+
+	msgSigs := make(map[eth2p0.BLSPubKey]eth2p0.BLSSignature)
+	for pubkeyHex, sig := range doc.DepositSignatures {
+		b, err := hex.DecodeString(strings.TrimPrefix(pubkeyHex, "0x"))
+		if err != nil {
+			return nil, errors.Wrap(err, "decode pubkey")
+		}
+
+		var (
+			pubkey  eth2p0.BLSPubKey
+			eth2Sig eth2p0.BLSSignature
+		)
+		copy(pubkey[:], b)
+		copy(eth2Sig[:], sig)
+		msgSigs[pubkey] = eth2Sig
+	}
+
+	resp, err := deposit.MarshalDepositData(msgSigs, doc.Lock.WithdrawalAddress, network)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal deposit data")
+	}
+
+	return resp, nil
+}
+
+// lockValidator returns the distributed validator with the 0x-hex public key or false if not in the lock.
+func lockValidator(lock cluster.Lock, pubkeyHex string) (cluster.DistValidator, bool) {
+	pubkey, err := hex.DecodeString(strings.TrimPrefix(pubkeyHex, "0x"))
+	if err != nil {
+		return cluster.DistValidator{}, false
+	}
+
+	for _, val := range lock.Validators {
+		if bytes.Equal(val.PubKey, pubkey) {
+			return val, true
+		}
+	}
+
+	return cluster.DistValidator{}, false
+}
+
+// verifyDepositSignature returns an error if the signature isn't a valid deposit message signature by the public key.
+func verifyDepositSignature(pubkey []byte, signer *bls_sig.PublicKey, withdrawalAddr string, network string, sig []byte) error {
+	var eth2Pubkey eth2p0.BLSPubKey
+	copy(eth2Pubkey[:], pubkey)
+
+	root, err := deposit.GetMessageSigningRoot(eth2Pubkey, withdrawalAddr, network)
+	if err != nil {
+		return err
+	}
+
+	return verifySignature(signer, root[:], sig)
+}
+
+// verifySignature returns an error if the signature isn't a valid signature of the signing root by the signer.
+func verifySignature(signer *bls_sig.PublicKey, root []byte, sig []byte) error {
+	blsSig, err := tblsconv.SigFromBytes(sig)
+	if err != nil {
+		return err
+	}
+
+	ok, err := tbls.Verify(signer, root, blsSig)
+	if err != nil {
+		return err
+	} else if !ok {
+		return errors.New("signature verification failed")
+	}
+
+	return nil
+}
+
+// aggregateDepositSignatures returns the threshold aggregated deposit signature of the validator's partial signatures by share index.
+func aggregateDepositSignatures(val cluster.DistValidator, partials map[string][]byte, withdrawalAddr string, network string) ([]byte, error) {
+	resp, err := aggregateSignatures(partials)
+	if err != nil {
+		return nil, err
+	}
+
+	pubkey, err := val.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// Sanity check the aggregated signature against the validator public key.
+	if err := verifyDepositSignature(val.PubKey, pubkey, withdrawalAddr, network, resp); err != nil {
+		return nil, errors.Wrap(err, "verify aggregate deposit signature")
+	}
+
+	return resp, nil
+}
+
+// aggregateSignatures returns the threshold aggregated signature of the partial signatures by share index.
+func aggregateSignatures(partials map[string][]byte) ([]byte, error) {
+	var partialSigs []*bls_sig.PartialSignature
+	for shareIdx, sig := range partials {
+		idx, err := strconv.Atoi(shareIdx)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse share index")
+		}
+
+		blsSig, err := tblsconv.SigFromBytes(sig)
+		if err != nil {
+			return nil, err
+		}
+
+		partialSigs = append(partialSigs, &bls_sig.PartialSignature{
+			Identifier: byte(idx),
+			Signature:  blsSig.Value,
+		})
+	}
+
+	aggSig, err := tbls.Aggregate(partialSigs)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := aggSig.MarshalBinary()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal signature")
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/obolnetwork/charon/app/errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Change operations, named after their JSON Patch equivalents.
+const (
+	ChangeAdd     = "add"
+	ChangeRemove  = "remove"
+	ChangeReplace = "replace"
+)
+
+// Change is a changed field between two revisions of a cluster.
+type Change struct {
+	Op string `json:"op"`
+	// Path is the JSON Pointer of the changed field in the cluster's json, e.g. "/definition/operators/1/enr".
+	Path string `json:"path"`
+	// From is the value at the from revision, omitted if added.
+	From interface{} `json:"from,omitempty"`
+	// To is the value at the to revision, omitted if removed.
+	To interface{} `json:"to,omitempty"`
+}
+
+// Diff is the structured diff between two revisions of a cluster.
+type Diff struct {
+	From    int      `json:"from"`
+	To      int      `json:"to"`
+	Changes []Change `json:"changes"`
+}
+
+// Diff returns the fields of the cluster that changed from the revision to the other, reconstructed from its change log.
+func (d definitionImpl) Diff(ctx context.Context, configHash []byte, from, to int) (Diff, error) {
+	fromCluster, err := d.AtRevision(ctx, configHash, from)
+	if err != nil {
+		return Diff{}, err
+	}
+
+	toCluster, err := d.AtRevision(ctx, configHash, to)
+	if err != nil {
+		return Diff{}, err
+	}
+
+	before, err := toJSONValue(fromCluster)
+	if err != nil {
+		return Diff{}, err
+	}
+
+	after, err := toJSONValue(toCluster)
+	if err != nil {
+		return Diff{}, err
+	}
+
+	changes := []Change{} // Empty rather than null if unchanged.
+	diffJSON("", before, after, &changes)
+
+	return Diff{From: from, To: to, Changes: changes}, nil
+}
+
+// toJSONValue returns the generic json representation of the value, retaining numbers as json.Number.
+func toJSONValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal cluster")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var resp interface{}
+	if err := dec.Decode(&resp); err != nil {
+		return nil, errors.Wrap(err, "unmarshal cluster")
+	}
+
+	return resp, nil
+}
+
+// diffJSON appends the changes from the generic json value to the other at the path, recursing into
+// objects and arrays so only the changed leaves are reported. Array elements are compared by index.
+func diffJSON(path string, from, to interface{}, changes *[]Change) {
+	switch fromVal := from.(type) {
+	case map[string]interface{}:
+		toVal, ok := to.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make(map[string]bool)
+		for k := range fromVal {
+			keys[k] = true
+		}
+		for k := range toVal {
+			keys[k] = true
+		}
+
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		for _, k := range sorted {
+			childPath := path + "/" + escapePointer(k)
+			fromChild, inFrom := fromVal[k]
+			toChild, inTo := toVal[k]
+
+			switch {
+			case !inFrom:
+				*changes = append(*changes, Change{Op: ChangeAdd, Path: childPath, To: toChild})
+			case !inTo:
+				*changes = append(*changes, Change{Op: ChangeRemove, Path: childPath, From: fromChild})
+			default:
+				diffJSON(childPath, fromChild, toChild, changes)
+			}
+		}
+
+		return
+	case []interface{}:
+		toVal, ok := to.([]interface{})
+		if !ok {
+			break
+		}
+
+		for i := 0; i < len(fromVal) || i < len(toVal); i++ {
+			childPath := path + "/" + strconv.Itoa(i)
+
+			switch {
+			case i >= len(fromVal):
+				*changes = append(*changes, Change{Op: ChangeAdd, Path: childPath, To: toVal[i]})
+			case i >= len(toVal):
+				*changes = append(*changes, Change{Op: ChangeRemove, Path: childPath, From: fromVal[i]})
+			default:
+				diffJSON(childPath, fromVal[i], toVal[i], changes)
+			}
+		}
+
+		return
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, Change{Op: ChangeReplace, Path: path, From: from, To: to})
+	}
+}
+
+// escapePointer escapes the object key as a JSON Pointer reference token.
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,344 @@
+package service
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	ethmath "github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"github.com/obolnetwork/charon/eth2util"
+	"github.com/obolnetwork/charon/eth2util/enr"
+	"strings"
+)
+
+const (
+	// k1SigLen is the length of secp256k1 signatures.
+	k1SigLen = 65
+	// k1RecIdx is the secp256k1 signature recovery id index.
+	k1RecIdx = 64
+)
+
+// eip712Type defines an EIP712 typed data structure containing a single string field.
+// These mirror the charon cluster package EIP712 types which are not exported.
+type eip712Type struct {
+	PrimaryType string
+	Field       string
+}
+
+var (
+	// eip712V1x3ConfigHash defines the EIP712 structure of the operator config signature for v1.3.
+	eip712V1x3ConfigHash = eip712Type{PrimaryType: "ConfigHash", Field: "config_hash"}
+
+	// eip712OperatorConfigHash defines the EIP712 structure of the operator config signature for v1.4 and later.
+	eip712OperatorConfigHash = eip712Type{PrimaryType: "OperatorConfigHash", Field: "operator_config_hash"}
+
+	// eip712CreatorConfigHash defines the EIP712 structure of the creator config signature for v1.4 and later.
+	eip712CreatorConfigHash = eip712Type{PrimaryType: "CreatorConfigHash", Field: "creator_config_hash"}
+
+	// eip712ENR defines the EIP712 structure of the operator enr signature.
+	eip712ENR = eip712Type{PrimaryType: "ENR", Field: "enr"}
+
+	// eip712Request defines the EIP712 structure of dvstore mutation request signatures.
+	// The request is formatted as "<action> <0x-hex config hash> <0x-hex payload hash> <unix timestamp>", see
+	// requestSubject.
+	eip712Request = eip712Type{PrimaryType: "Request", Field: "request"}
+)
+
+// supportsEIP712Sigs returns true if the definition version contains EIP712 signatures, i.e., v1.3 and later.
+func supportsEIP712Sigs(version string) bool {
+	switch version {
+	case "v1.0.0", "v1.1.0", "v1.2.0":
+		return false
+	default:
+		return true
+	}
+}
+
+// verifyOperatorConfigSignature returns an error if the operator config signature of the definition config hash is missing or invalid.
+// It is a noop for definition versions that do not support EIP712 signatures.
+func verifyOperatorConfigSignature(def cluster.Definition, operator cluster.Operator) error {
+	if !supportsEIP712Sigs(def.Version) {
+		return nil
+	}
+
+	if len(operator.ConfigSignature) == 0 {
+		return errors.Wrap(ErrInvalidRequest, "missing operator config signature", z.Str("address", operator.Address))
+	}
+
+	typ := eip712OperatorConfigHash
+	if def.Version == "v1.3.0" {
+		typ = eip712V1x3ConfigHash
+	}
+
+	err := verifyEIP712(typ, def.ForkVersion, fmt.Sprintf("%#x", def.ConfigHash), operator.Address, operator.ConfigSignature)
+	if err != nil {
+		return errors.Wrap(ErrInvalidRequest, "invalid operator config signature", z.Str("address", operator.Address), z.Err(err))
+	}
+
+	return nil
+}
+
+// verifyCreator returns an error if the definition has no creator but creators are required,
+// or if the creator config signature is missing or not by the creator address.
+func (d definitionImpl) verifyCreator(def cluster.Definition) error {
+	if def.Creator.Address == "" {
+		if d.conf.RequireCreator {
+			return errors.Wrap(ErrInvalidRequest, "missing definition creator")
+		}
+
+		return nil
+	}
+
+	return verifyCreatorSignature(def)
+}
+
+// verifyCreatorSignature returns an error if the creator config signature of the definition config hash is missing or invalid.
+func verifyCreatorSignature(def cluster.Definition) error {
+	if len(def.Creator.ConfigSignature) == 0 {
+		return errors.Wrap(ErrInvalidRequest, "missing creator config signature", z.Str("address", def.Creator.Address))
+	}
+
+	err := verifyEIP712(eip712CreatorConfigHash, def.ForkVersion, fmt.Sprintf("%#x", def.ConfigHash), def.Creator.Address, def.Creator.ConfigSignature)
+	if err != nil {
+		return errors.Wrap(ErrInvalidRequest, "invalid creator config signature", z.Str("address", def.Creator.Address), z.Err(err))
+	}
+
+	return nil
+}
+
+// verifyOperatorENR returns an error if the operator ENR is malformed or if the operator enr signature
+// doesn't bind the operator address to the ENR. The enr signature is only verified for definition versions
+// that support EIP712 signatures.
+func verifyOperatorENR(def cluster.Definition, operator cluster.Operator) error {
+	if _, err := enr.Parse(operator.ENR); err != nil {
+		return errors.Wrap(ErrInvalidRequest, "invalid operator enr", z.Str("address", operator.Address), z.Err(err))
+	}
+
+	if !supportsEIP712Sigs(def.Version) {
+		return nil
+	}
+
+	if len(operator.ENRSignature) == 0 {
+		return errors.Wrap(ErrInvalidRequest, "missing operator enr signature", z.Str("address", operator.Address))
+	}
+
+	err := verifyEIP712(eip712ENR, def.ForkVersion, operator.ENR, operator.Address, operator.ENRSignature)
+	if err != nil {
+		return errors.Wrap(ErrInvalidRequest, "invalid operator enr signature", z.Str("address", operator.Address), z.Err(err))
+	}
+
+	return nil
+}
+
+// SignCreator returns the definition with the creator config signature of the key.
+// The definition hash must be recalculated afterwards.
+func SignCreator(key *ecdsa.PrivateKey, def cluster.Definition) (cluster.Definition, error) {
+	var err error
+	def.Creator.ConfigSignature, err = signEIP712(key, eip712CreatorConfigHash, def.ForkVersion, fmt.Sprintf("%#x", def.ConfigHash))
+	if err != nil {
+		return cluster.Definition{}, err
+	}
+
+	return def, nil
+}
+
+// SignOperator returns the operator with the config and enr signatures of the key, as submitted when joining.
+func SignOperator(key *ecdsa.PrivateKey, def cluster.Definition, operator cluster.Operator) (cluster.Operator, error) {
+	typ := eip712OperatorConfigHash
+	if def.Version == "v1.3.0" {
+		typ = eip712V1x3ConfigHash
+	}
+
+	var err error
+	operator.ConfigSignature, err = signEIP712(key, typ, def.ForkVersion, fmt.Sprintf("%#x", def.ConfigHash))
+	if err != nil {
+		return cluster.Operator{}, err
+	}
+
+	operator.ENRSignature, err = signEIP712(key, eip712ENR, def.ForkVersion, operator.ENR)
+	if err != nil {
+		return cluster.Operator{}, err
+	}
+
+	return operator, nil
+}
+
+// SignAddOperator returns the request auth of the operator key joining the definition at the timestamp with the
+// operator, as submitted with the definition's fork version and version.
+func SignAddOperator(key *ecdsa.PrivateKey, def cluster.Definition, operator cluster.Operator, timestamp int64) (RequestAuth, error) {
+	return signRequest(key, def, actionAddOperator, addOperatorPayload(def.ForkVersion, def.Version, operator), timestamp)
+}
+
+// SignDecline returns the request auth of the operator key declining the definition at the timestamp.
+func SignDecline(key *ecdsa.PrivateKey, def cluster.Definition, timestamp int64) (RequestAuth, error) {
+	return signRequest(key, def, actionDecline, addressPayload(keyAddress(key)), timestamp)
+}
+
+// SignDelete returns the request auth of the creator or operator key deleting the definition at its revision,
+// see State.Revision, and the timestamp.
+func SignDelete(key *ecdsa.PrivateKey, def cluster.Definition, revision int, timestamp int64) (RequestAuth, error) {
+	return signRequest(key, def, actionDelete, deletePayload(keyAddress(key), revision), timestamp)
+}
+
+// SignCancelDeletion returns the request auth of the creator key cancelling the pending deletion of the definition
+// at the timestamp.
+func SignCancelDeletion(key *ecdsa.PrivateKey, def cluster.Definition, timestamp int64) (RequestAuth, error) {
+	return signRequest(key, def, actionCancelDeletion, addressPayload(keyAddress(key)), timestamp)
+}
+
+// SignPin returns the request auth of the creator key pinning or unpinning the definition at the timestamp.
+func SignPin(key *ecdsa.PrivateKey, def cluster.Definition, pinned bool, timestamp int64) (RequestAuth, error) {
+	return signRequest(key, def, actionPin, pinPayload(pinned), timestamp)
+}
+
+// SignRemoveOperator returns the request auth of the creator key removing the operator address from the definition
+// at the timestamp.
+func SignRemoveOperator(key *ecdsa.PrivateKey, def cluster.Definition, address string, timestamp int64) (RequestAuth, error) {
+	return signRequest(key, def, actionRemoveOperator, addressPayload(address), timestamp)
+}
+
+// SignInvite returns the request auth of the creator key inviting the operator address to the definition at the
+// timestamp.
+func SignInvite(key *ecdsa.PrivateKey, def cluster.Definition, address string, timestamp int64) (RequestAuth, error) {
+	return signRequest(key, def, actionInvite, addressPayload(address), timestamp)
+}
+
+// SignRevise returns the request auth of the creator key revising the unpublished definition to the revised
+// definition at the timestamp.
+func SignRevise(key *ecdsa.PrivateKey, def cluster.Definition, revised cluster.Definition, timestamp int64) (RequestAuth, error) {
+	return signRequest(key, def, actionRevise, revisePayload(revised.ConfigHash), timestamp)
+}
+
+// SignUpdateMetadata returns the request auth of the creator key applying the metadata patch to the definition
+// at the timestamp.
+func SignUpdateMetadata(key *ecdsa.PrivateKey, def cluster.Definition, patch MetadataPatch, timestamp int64) (RequestAuth, error) {
+	return signRequest(key, def, actionUpdateMetadata, metadataPayload(patch), timestamp)
+}
+
+// SignProposeExit returns the request auth of the operator key proposing the exit of the validator at the epoch
+// at the timestamp.
+func SignProposeExit(key *ecdsa.PrivateKey, def cluster.Definition, pubkey []byte, epoch uint64, timestamp int64) (RequestAuth, error) {
+	return signRequest(key, def, actionProposeExit, exitPayload(keyAddress(key), pubkey, epoch), timestamp)
+}
+
+// SignConfirmExit returns the request auth of the operator key confirming the exit of the validator at the epoch
+// at the timestamp.
+func SignConfirmExit(key *ecdsa.PrivateKey, def cluster.Definition, pubkey []byte, epoch uint64, timestamp int64) (RequestAuth, error) {
+	return signRequest(key, def, actionConfirmExit, exitPayload(keyAddress(key), pubkey, epoch), timestamp)
+}
+
+// signRequest returns the request auth of the key signing the action on the definition with the payload at the
+// timestamp.
+func signRequest(key *ecdsa.PrivateKey, def cluster.Definition, action string, payload requestPayload, timestamp int64) (RequestAuth, error) {
+	value := fmt.Sprintf("%s %d", requestSubject(action, def.ConfigHash, payload), timestamp)
+
+	sig, err := signEIP712(key, eip712Request, def.ForkVersion, value)
+	if err != nil {
+		return RequestAuth{}, err
+	}
+
+	return RequestAuth{Timestamp: timestamp, Signature: sig}, nil
+}
+
+// keyAddress returns the address of the key.
+func keyAddress(key *ecdsa.PrivateKey) string {
+	return crypto.PubkeyToAddress(key.PublicKey).Hex()
+}
+
+// signEIP712 returns the signature of the EIP712 typed value by the key.
+func signEIP712(key *ecdsa.PrivateKey, typ eip712Type, forkVersion []byte, value string) ([]byte, error) {
+	digest, err := digestEIP712(typ, forkVersion, value)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := crypto.Sign(digest, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "sign EIP712")
+	}
+
+	return sig, nil
+}
+
+// verifyEIP712 returns an error if the signature of the EIP712 typed value wasn't signed by the address.
+func verifyEIP712(typ eip712Type, forkVersion []byte, value string, address string, sig []byte) error {
+	digest, err := digestEIP712(typ, forkVersion, value)
+	if err != nil {
+		return err
+	}
+
+	if len(sig) != k1SigLen {
+		return errors.New("invalid signature length", z.Int("siglen", len(sig)))
+	}
+
+	// Copy the signature since the recovery id may be normalised.
+	sig = append([]byte(nil), sig...)
+
+	// Metamask signatures end with 27 or 28 while go-ethereum signatures end with 0 or 1 and both are correct.
+	switch sig[k1RecIdx] {
+	case 0, 1:
+	case 27, 28:
+		sig[k1RecIdx] -= 27
+	default:
+		return errors.New("invalid recovery id", z.Any("id", sig[k1RecIdx]))
+	}
+
+	pubkey, err := crypto.SigToPub(digest, sig)
+	if err != nil {
+		return errors.Wrap(err, "pubkey from signature")
+	}
+
+	expected, err := hex.DecodeString(strings.TrimPrefix(address, "0x"))
+	if err != nil {
+		return errors.Wrap(err, "decode address")
+	}
+
+	actual := crypto.PubkeyToAddress(*pubkey)
+	if !bytes.Equal(expected, actual[:]) {
+		return errors.New("signature address mismatch")
+	}
+
+	return nil
+}
+
+// digestEIP712 returns the EIP712 digest of the typed value using the Obol domain of the fork version's chain.
+func digestEIP712(typ eip712Type, forkVersion []byte, value string) ([]byte, error) {
+	chainID, err := eth2util.ForkVersionToChainID(forkVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	data := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": []apitypes.Type{
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+			},
+			typ.PrimaryType: []apitypes.Type{
+				{Name: typ.Field, Type: "string"},
+			},
+		},
+		PrimaryType: typ.PrimaryType,
+		Message: apitypes.TypedDataMessage{
+			typ.Field: value,
+		},
+		Domain: apitypes.TypedDataDomain{
+			Name:    "Obol",
+			Version: "1",
+			ChainId: ethmath.NewHexOrDecimal256(chainID),
+		},
+	}
+
+	digest, _, err := apitypes.TypedDataAndHash(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "hash EIP712")
+	}
+
+	return digest, nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"github.com/ethereum/go-ethereum/crypto"
+	"strings"
+	"testing"
+)
+
+func TestVerifyEIP712(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	other, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	mainnet := []byte{0x00, 0x00, 0x00, 0x00}
+	goerli := []byte{0x00, 0x00, 0x10, 0x20}
+
+	sig, err := signEIP712(key, eip712Request, mainnet, "request")
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	// Metamask signatures end with 27 or 28.
+	metamask := append([]byte(nil), sig...)
+	metamask[k1RecIdx] += 27
+
+	invalidRecID := append([]byte(nil), sig...)
+	invalidRecID[k1RecIdx] = 2
+
+	tests := []struct {
+		name        string
+		typ         eip712Type
+		forkVersion []byte
+		value       string
+		address     string
+		sig         []byte
+		wantErr     bool
+	}{
+		{name: "valid", typ: eip712Request, forkVersion: mainnet, value: "request", address: keyAddress(key), sig: sig},
+		{name: "lowercase address", typ: eip712Request, forkVersion: mainnet, value: "request", address: strings.ToLower(keyAddress(key)), sig: sig},
+		{name: "metamask recovery id", typ: eip712Request, forkVersion: mainnet, value: "request", address: keyAddress(key), sig: metamask},
+		{name: "other address", typ: eip712Request, forkVersion: mainnet, value: "request", address: keyAddress(other), sig: sig, wantErr: true},
+		{name: "other value", typ: eip712Request, forkVersion: mainnet, value: "other", address: keyAddress(key), sig: sig, wantErr: true},
+		{name: "other type", typ: eip712ENR, forkVersion: mainnet, value: "request", address: keyAddress(key), sig: sig, wantErr: true},
+		{name: "other chain", typ: eip712Request, forkVersion: goerli, value: "request", address: keyAddress(key), sig: sig, wantErr: true},
+		{name: "unknown fork version", typ: eip712Request, forkVersion: []byte{1, 2, 3, 4}, value: "request", address: keyAddress(key), sig: sig, wantErr: true},
+		{name: "truncated signature", typ: eip712Request, forkVersion: mainnet, value: "request", address: keyAddress(key), sig: sig[:k1SigLen-1], wantErr: true},
+		{name: "invalid recovery id", typ: eip712Request, forkVersion: mainnet, value: "request", address: keyAddress(key), sig: invalidRecID, wantErr: true},
+		{name: "invalid address", typ: eip712Request, forkVersion: mainnet, value: "request", address: "0xinvalid", sig: sig, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := verifyEIP712(test.typ, test.forkVersion, test.value, test.address, test.sig)
+			if test.wantErr && err == nil {
+				t.Fatalf("expected error")
+			} else if !test.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	if metamask[k1RecIdx] != sig[k1RecIdx]+27 {
+		t.Fatalf("verifying normalised the caller's signature")
+	}
+}
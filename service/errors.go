@@ -3,5 +3,12 @@ package service
 import "github.com/obolnetwork/charon/app/errors"
 
 var (
-	ErrNotFound = errors.New("not found")
+	ErrNotFound           = errors.New("not found")
+	ErrInvalidRequest     = errors.New("invalid request")
+	ErrInvalidState       = errors.New("invalid state")
+	ErrConflict           = errors.New("conflict")
+	ErrTimeout            = errors.New("database timeout")
+	ErrUnsupported        = errors.New("unsupported by storage driver")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrQuotaExceeded      = errors.New("quota exceeded")
 )
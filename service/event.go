@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// eventsCollection is the name of the collection of definition events, in the same database as the definitions.
+const eventsCollection = "definition_events"
+
+// EventType is the type of definition mutation.
+type EventType string
+
+const (
+	EventCreated            EventType = "created"
+	EventOperatorAdded      EventType = "operator_added"
+	EventOperatorDeclined   EventType = "operator_declined"
+	EventOperatorRemoved    EventType = "operator_removed"
+	EventFinalized          EventType = "finalized"
+	EventLocked             EventType = "locked"
+	EventSignatureAdded     EventType = "signature_added"
+	EventExitProposed       EventType = "exit_proposed"
+	EventExitConfirmed      EventType = "exit_confirmed"
+	EventRegistrationsAdded EventType = "registrations_added"
+	EventDeleted            EventType = "deleted"
+	EventImported           EventType = "imported"
+	EventRevised            EventType = "revised"
+	EventPublished          EventType = "published"
+	EventRestored           EventType = "restored"
+	EventCancelled          EventType = "cancelled"
+	EventPinned             EventType = "pinned"
+	EventUnpinned           EventType = "unpinned"
+	EventDeletionRequested  EventType = "deletion_requested"
+	EventDeletionCancelled  EventType = "deletion_cancelled"
+	EventMetadataUpdated    EventType = "metadata_updated"
+	EventInviteMinted       EventType = "invite_minted"
+)
+
+// Event is a definition mutation in the append-only change log.
+type Event struct {
+	Revision  int       `json:"revision"`
+	Type      EventType `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// eventDoc is the mongo document of a definition event. It contains the resulting definition document,
+// so the definition at any revision is derived from its event without replaying the full log.
+type eventDoc struct {
+	ConfigHash []byte         `bson:"config_hash"`
+	Revision   int            `bson:"revision"`
+	Type       EventType      `bson:"type"`
+	Timestamp  time.Time      `bson:"timestamp"`
+	Document   *definitionDoc `bson:"document,omitempty"` // Nil if deleted.
+	Tenant     string         `bson:"tenant,omitempty"`   // Tenant of the definition, empty for the default tenant.
+}
+
+// eventIndexes are the definition events collection indexes.
+var eventIndexes = []mongo.IndexModel{
+	{Keys: bson.D{{"config_hash", 1}, {"timestamp", 1}}},
+}
+
+// appendEvent appends the mutation resulting in the document revision to the definition's change log
+// and records it in the audit log.
+// The definitions collection is the current state projection of the log. Failing to append is logged
+// rather than failing the already applied mutation.
+func (d definitionImpl) appendEvent(ctx context.Context, typ EventType, configHash []byte, revision int, doc *definitionDoc) {
+	d.appendEventAt(ctx, typ, configHash, revision, time.Now(), doc)
+	d.audit(ctx, typ, configHash, revision)
+}
+
+// appendEventAt appends the mutation that occurred at the timestamp to the definition's change log.
+func (d definitionImpl) appendEventAt(ctx context.Context, typ EventType, configHash []byte, revision int, timestamp time.Time, doc *definitionDoc) {
+	if err := d.insertEvent(ctx, typ, configHash, revision, timestamp, doc); err != nil {
+		log.Warn(ctx, "Failed appending definition event", err, z.Str("type", string(typ)), z.Hex("config_hash", configHash))
+	}
+}
+
+// insertEvent inserts the mutation that occurred at the timestamp into the definition's change log.
+func (d definitionImpl) insertEvent(ctx context.Context, typ EventType, configHash []byte, revision int, timestamp time.Time, doc *definitionDoc) error {
+	return d.store.insertEvent(ctx, eventDoc{
+		ConfigHash: configHash,
+		Revision:   revision,
+		Type:       typ,
+		Timestamp:  timestamp,
+		Document:   doc,
+		Tenant:     tenantFromCtx(ctx),
+	})
+}
+
+func (m mongoStore) insertEvent(ctx context.Context, event eventDoc) error {
+	if event.Document != nil {
+		stored, err := m.compress(*event.Document)
+		if err != nil {
+			return errors.Wrap(err, "failed compressing definition event")
+		}
+		event.Document = &stored
+	}
+
+	if _, err := m.events.InsertOne(ctx, event); err != nil {
+		return wrapDBErr(err, opInsert, "failed to insert definition event")
+	}
+
+	return nil
+}
+
+// History returns the definition's change log ordered by time.
+func (d definitionImpl) History(ctx context.Context, configHash []byte) ([]Event, error) {
+	docs, err := d.store.findEvents(ctx, configHash)
+	if err != nil {
+		return nil, err
+	} else if len(docs) == 0 {
+		return nil, errors.Wrap(ErrNotFound, "definition history not found")
+	}
+
+	resp := make([]Event, 0, len(docs))
+	for _, doc := range docs {
+		resp = append(resp, Event{
+			Revision:  doc.Revision,
+			Type:      doc.Type,
+			Timestamp: doc.Timestamp,
+		})
+	}
+
+	return resp, nil
+}
+
+// AtRevision returns the definition's full lifecycle at the revision, reconstructed from its change log.
+// The latest event of the revision is used if the definition was deleted and recreated.
+func (d definitionImpl) AtRevision(ctx context.Context, configHash []byte, revision int) (Cluster, error) {
+	doc, err := d.store.findRevision(ctx, configHash, revision)
+	if err != nil {
+		return Cluster{}, err
+	}
+
+	return d.cluster(doc), nil
+}
+
+func (m mongoStore) findEvents(ctx context.Context, configHash []byte) ([]eventDoc, error) {
+	ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opFind)
+	defer cancel()
+
+	cursor, err := m.events.Find(ctx, bson.D{{"config_hash", configHash}, tenantMatch(ctx)},
+		options.Find().SetSort(bson.D{{"timestamp", 1}}).SetProjection(bson.D{{"document", 0}}))
+	if err != nil {
+		return nil, wrapDBErr(err, opFind, "failed to find events")
+	}
+
+	var resp []eventDoc
+	if err := cursor.All(ctx, &resp); err != nil {
+		return nil, wrapDBErr(err, opFind, "failed to decode events")
+	}
+
+	return resp, nil
+}
+
+func (m mongoStore) findRevision(ctx context.Context, configHash []byte, revision int) (definitionDoc, error) {
+	ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opFind)
+	defer cancel()
+
+	var event eventDoc
+	err := m.events.FindOne(ctx,
+		bson.D{{"config_hash", configHash}, {"revision", revision}, {"document", bson.D{{"$ne", nil}}}, tenantMatch(ctx)},
+		options.FindOne().SetSort(bson.D{{"timestamp", -1}})).Decode(&event)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return definitionDoc{}, errors.Wrap(ErrNotFound, "definition revision not found", z.Int("revision", revision))
+	} else if err != nil {
+		return definitionDoc{}, wrapDBErr(err, opFind, "failed to find revision")
+	}
+
+	if err := m.loadValidators(ctx, event.Document); err != nil {
+		return definitionDoc{}, err
+	}
+
+	return *event.Document, nil
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	eth2p0 "github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/eth2util"
+	"strconv"
+	"strings"
+)
+
+// Exit is a coordinated voluntary exit of a distributed validator.
+type Exit struct {
+	// PubKey is the 0x-hex distributed validator public key.
+	PubKey string `json:"pubkey" bson:"pubkey"`
+	// Epoch is the proposed exit epoch.
+	Epoch uint64 `json:"epoch" bson:"epoch"`
+	// ProposedBy is the address of the operator that proposed the exit.
+	ProposedBy string `json:"proposed_by" bson:"proposed_by"`
+	// Confirmations are the addresses of operators that confirmed the exit, including the proposer.
+	Confirmations []string `json:"confirmations" bson:"confirmations"`
+	// Agreed is true if threshold operators confirmed the exit.
+	Agreed bool `json:"agreed" bson:"agreed"`
+	// ValidatorIndex is the beacon chain index of the validator, set by the first partial exit signature.
+	ValidatorIndex uint64 `json:"validator_index,string,omitempty" bson:"validator_index,omitempty"`
+	// ForkVersion is the 0x-hex fork version of the exit signing domain, set by the first partial exit signature.
+	ForkVersion string `json:"fork_version,omitempty" bson:"fork_version,omitempty"`
+	// Partials are the partial exit signatures by share index.
+	Partials map[string][]byte `json:"-" bson:"partials,omitempty"`
+	// Signature is the 0x-hex aggregated exit signature, empty until threshold partial exit signatures are available.
+	Signature string `json:"signature,omitempty" bson:"signature,omitempty"`
+}
+
+// PartialExit is an operator's partial signature of the voluntary exit of a distributed validator at the agreed epoch.
+type PartialExit struct {
+	// PubKey is the 0x-hex distributed validator public key.
+	PubKey string
+	// ShareIdx is the operator's 1-indexed key share index.
+	ShareIdx int
+	// ValidatorIndex is the beacon chain index of the validator.
+	ValidatorIndex uint64
+	// ForkVersion is the fork version of the exit signing domain, i.e., of the exit epoch's fork or Capella since Deneb.
+	ForkVersion []byte
+	// Signature is the partial signature of the voluntary exit by the operator's key share.
+	Signature []byte
+}
+
+// exitDomainType is the DOMAIN_VOLUNTARY_EXIT domain type of voluntary exits.
+var exitDomainType = eth2p0.DomainType([4]byte{0x04, 0x00, 0x00, 0x00})
+
+// genesisValidatorsRoots are the genesis validators roots by network, part of the voluntary exit signing domain.
+var genesisValidatorsRoots = map[string]string{
+	"mainnet": "0x4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95",
+	"goerli":  "0x043db0d9a83813551ee2f33450d23797757d430911a9320530ad8a0eabc43efb",
+	"gnosis":  "0xf5dcb5564e829aab27264b9becd5dfaa017085611224cb3036f573368dbb9d47",
+	"sepolia": "0xd8ea171f3c94aea21ebc42a1ed61052acf3f9209c00e4efbaaddac09ed9b8078",
+}
+
+func (d definitionImpl) ProposeExit(ctx context.Context, configHash []byte, pubkey string, epoch uint64, address string, auth RequestAuth) error {
+	return d.update(ctx, configHash, EventExitProposed, func(doc *definitionDoc) error {
+		if doc.Status != StatusLocked || doc.Lock == nil {
+			return errors.Wrap(ErrInvalidState, "definition not locked", z.Str("status", string(doc.Status)))
+		}
+
+		val, ok := lockValidator(*doc.Lock, pubkey)
+		if !ok {
+			return errors.Wrap(ErrInvalidRequest, "validator not in lock", z.Str("pubkey", pubkey))
+		}
+
+		idx, ok := operatorIndex(doc.Definition, address)
+		if !ok {
+			return errors.Wrap(ErrInvalidRequest, "operator not in definition", z.Str("address", address))
+		}
+		address = doc.Definition.Operators[idx].Address
+
+		if err := d.verifyRequest(doc, address, actionProposeExit, exitPayload(address, val.PubKey, epoch), auth); err != nil {
+			return err
+		}
+
+		exit := Exit{
+			PubKey:        fmt.Sprintf("%#x", val.PubKey),
+			Epoch:         epoch,
+			ProposedBy:    address,
+			Confirmations: []string{address},
+			Agreed:        doc.Lock.Threshold <= 1,
+		}
+
+		for i, e := range doc.Exits {
+			if e.PubKey != exit.PubKey {
+				continue
+			} else if e.Agreed {
+				return errors.Wrap(ErrInvalidState, "exit already agreed", z.Str("pubkey", exit.PubKey))
+			}
+
+			// Replace the existing proposal, resetting confirmations.
+			doc.Exits[i] = exit
+
+			return nil
+		}
+
+		doc.Exits = append(doc.Exits, exit)
+
+		return nil
+	})
+}
+
+func (d definitionImpl) ConfirmExit(ctx context.Context, configHash []byte, pubkey string, epoch uint64, address string, auth RequestAuth) error {
+	return d.update(ctx, configHash, EventExitConfirmed, func(doc *definitionDoc) error {
+		if doc.Status != StatusLocked || doc.Lock == nil {
+			return errors.Wrap(ErrInvalidState, "definition not locked", z.Str("status", string(doc.Status)))
+		}
+
+		val, ok := lockValidator(*doc.Lock, pubkey)
+		if !ok {
+			return errors.Wrap(ErrInvalidRequest, "validator not in lock", z.Str("pubkey", pubkey))
+		}
+
+		idx, ok := operatorIndex(doc.Definition, address)
+		if !ok {
+			return errors.Wrap(ErrInvalidRequest, "operator not in definition", z.Str("address", address))
+		}
+		address = doc.Definition.Operators[idx].Address
+
+		if err := d.verifyRequest(doc, address, actionConfirmExit, exitPayload(address, val.PubKey, epoch), auth); err != nil {
+			return err
+		}
+
+		for i, e := range doc.Exits {
+			if e.PubKey != fmt.Sprintf("%#x", val.PubKey) {
+				continue
+			} else if e.Epoch != epoch {
+				return errors.Wrap(ErrInvalidRequest, "exit epoch mismatch", z.U64("proposed", e.Epoch), z.U64("confirmed", epoch))
+			}
+
+			for _, confirmed := range e.Confirmations {
+				if strings.EqualFold(confirmed, address) {
+					return nil // Already confirmed.
+				}
+			}
+
+			e.Confirmations = append(e.Confirmations, address)
+			e.Agreed = len(e.Confirmations) >= doc.Lock.Threshold
+			doc.Exits[i] = e
+
+			return nil
+		}
+
+		return errors.Wrap(ErrNotFound, "exit not proposed", z.Str("pubkey", pubkey))
+	})
+}
+
+func (d definitionImpl) AddExitSignature(ctx context.Context, configHash []byte, partial PartialExit) error {
+	return d.update(ctx, configHash, EventSignatureAdded, func(doc *definitionDoc) error {
+		if doc.Status != StatusLocked || doc.Lock == nil {
+			return errors.Wrap(ErrInvalidState, "definition not locked", z.Str("status", string(doc.Status)))
+		} else if partial.ShareIdx < 1 || partial.ShareIdx > len(doc.Lock.Operators) {
+			return errors.Wrap(ErrInvalidRequest, "invalid share index", z.Int("share_idx", partial.ShareIdx))
+		}
+
+		network, err := eth2util.ForkVersionToNetwork(doc.Lock.ForkVersion)
+		if err != nil {
+			return errors.Wrap(err, "lock network")
+		}
+
+		val, ok := lockValidator(*doc.Lock, partial.PubKey)
+		if !ok {
+			return errors.Wrap(ErrInvalidRequest, "validator not in lock", z.Str("pubkey", partial.PubKey))
+		}
+
+		pubkey := fmt.Sprintf("%#x", val.PubKey)
+		forkVersion := fmt.Sprintf("%#x", partial.ForkVersion)
+		for i, e := range doc.Exits {
+			if e.PubKey != pubkey {
+				continue
+			} else if !e.Agreed {
+				return errors.Wrap(ErrInvalidState, "exit not agreed", z.Str("pubkey", pubkey))
+			} else if e.Signature != "" {
+				return nil // Already aggregated.
+			}
+
+			// All partial signatures must sign the same exit message.
+			if len(e.Partials) > 0 && (e.ValidatorIndex != partial.ValidatorIndex || e.ForkVersion != forkVersion) {
+				return errors.Wrap(ErrInvalidRequest, "exit message mismatch",
+					z.U64("validator_index", e.ValidatorIndex), z.Str("fork_version", e.ForkVersion))
+			}
+
+			root, err := exitSigningRoot(e.Epoch, partial.ValidatorIndex, partial.ForkVersion, network)
+			if err != nil {
+				return err
+			}
+
+			pubshare, err := val.PublicShare(partial.ShareIdx - 1)
+			if err != nil {
+				return errors.Wrap(err, "validator public share")
+			}
+
+			if err := verifySignature(pubshare, root[:], partial.Signature); err != nil {
+				return errors.Wrap(ErrInvalidRequest, "invalid partial exit signature", z.Str("pubkey", pubkey), z.Err(err))
+			}
+
+			e.ValidatorIndex = partial.ValidatorIndex
+			e.ForkVersion = forkVersion
+			if e.Partials == nil {
+				e.Partials = make(map[string][]byte)
+			}
+			e.Partials[strconv.Itoa(partial.ShareIdx)] = partial.Signature
+
+			if len(e.Partials) >= doc.Lock.Threshold {
+				aggSig, err := aggregateSignatures(e.Partials)
+				if err != nil {
+					return err
+				}
+
+				signer, err := val.PublicKey()
+				if err != nil {
+					return err
+				}
+
+				// Sanity check the aggregated signature against the validator public key.
+				if err := verifySignature(signer, root[:], aggSig); err != nil {
+					return errors.Wrap(err, "verify aggregate exit signature")
+				}
+
+				e.Signature = fmt.Sprintf("%#x", aggSig)
+			}
+			doc.Exits[i] = e
+
+			return nil
+		}
+
+		return errors.Wrap(ErrNotFound, "exit not proposed", z.Str("pubkey", pubkey))
+	})
+}
+
+func (d definitionImpl) SignedExit(ctx context.Context, configHash []byte, pubkey string) (*eth2p0.SignedVoluntaryExit, error) {
+	doc, err := d.getDoc(ctx, configHash)
+	if err != nil {
+		return nil, err
+	} else if doc.Lock == nil {
+		return nil, errors.Wrap(ErrNotFound, "signed exit not found")
+	}
+
+	val, ok := lockValidator(*doc.Lock, pubkey)
+	if !ok {
+		return nil, errors.Wrap(ErrNotFound, "validator not in lock", z.Str("pubkey", pubkey))
+	}
+
+	for _, e := range doc.Exits {
+		if e.PubKey != fmt.Sprintf("%#x", val.PubKey) {
+			continue
+		} else if e.Signature == "" {
+			break
+		}
+
+		sig, err := hex.DecodeString(strings.TrimPrefix(e.Signature, "0x"))
+		if err != nil {
+			return nil, errors.Wrap(err, "decode exit signature")
+		}
+
+		resp := &eth2p0.SignedVoluntaryExit{
+			Message: &eth2p0.VoluntaryExit{
+				Epoch:          eth2p0.Epoch(e.Epoch),
+				ValidatorIndex: eth2p0.ValidatorIndex(e.ValidatorIndex),
+			},
+		}
+		copy(resp.Signature[:], sig)
+
+		return resp, nil
+	}
+
+	return nil, errors.Wrap(ErrNotFound, "signed exit not found", z.Str("pubkey", pubkey))
+}
+
+// exitSigningRoot returns the signing root of the voluntary exit of the validator at the epoch,
+// using the exit domain of the fork version and the network's genesis validators root.
+func exitSigningRoot(epoch uint64, validatorIndex uint64, forkVersion []byte, network string) ([32]byte, error) {
+	gvr, ok := genesisValidatorsRoots[network]
+	if !ok {
+		return [32]byte{}, errors.Wrap(ErrUnsupported, "exits not supported on network", z.Str("network", network))
+	} else if len(forkVersion) != 4 {
+		return [32]byte{}, errors.Wrap(ErrInvalidRequest, "invalid fork version", z.Hex("fork_version", forkVersion))
+	}
+
+	forkData := &eth2p0.ForkData{}
+	copy(forkData.CurrentVersion[:], forkVersion)
+	root, err := hex.DecodeString(strings.TrimPrefix(gvr, "0x"))
+	if err != nil {
+		return [32]byte{}, errors.Wrap(err, "decode genesis validators root")
+	}
+	copy(forkData.GenesisValidatorsRoot[:], root)
+
+	forkRoot, err := forkData.HashTreeRoot()
+	if err != nil {
+		return [32]byte{}, errors.Wrap(err, "hash fork data")
+	}
+
+	var domain eth2p0.Domain
+	copy(domain[0:], exitDomainType[:])
+	copy(domain[4:], forkRoot[:])
+
+	msgRoot, err := (&eth2p0.VoluntaryExit{
+		Epoch:          eth2p0.Epoch(epoch),
+		ValidatorIndex: eth2p0.ValidatorIndex(validatorIndex),
+	}).HashTreeRoot()
+	if err != nil {
+		return [32]byte{}, errors.Wrap(err, "hash exit")
+	}
+
+	resp, err := (&eth2p0.SigningData{ObjectRoot: msgRoot, Domain: domain}).HashTreeRoot()
+	if err != nil {
+		return [32]byte{}, errors.Wrap(err, "hash signing data")
+	}
+
+	return resp, nil
+}
+
+func (d definitionImpl) Exits(ctx context.Context, configHash []byte) ([]Exit, error) {
+	doc, err := d.getDoc(ctx, configHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.Exits, nil
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"strings"
+	"time"
+)
+
+// bundleVersion is the version of the export bundle format.
+const bundleVersion = 1
+
+// Bundle is a portable export of a single cluster, used to move clusters between dvstore instances.
+type Bundle struct {
+	Version    int                `json:"version"`
+	Status     Status             `json:"status"`
+	Declined   []string           `json:"declined,omitempty"`
+	Definition cluster.Definition `json:"definition"`
+	Lock       *cluster.Lock      `json:"lock,omitempty"`
+	// DepositSignatures are the aggregated 0x-hex deposit signatures by 0x-hex validator public key.
+	DepositSignatures map[string]string `json:"deposit_signatures,omitempty"`
+	Exits             []Exit            `json:"exits,omitempty"`
+	// History is the audit trail of the cluster on the exporting instance.
+	History    []Event          `json:"history"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Signature  *BundleSignature `json:"signature,omitempty"`
+}
+
+// BundleSignature is the exporting server's ed25519 signature of the bundle excluding the signature.
+type BundleSignature struct {
+	PublicKey string `json:"public_key"`
+	Signature string `json:"signature"`
+}
+
+// signingRoot returns the bytes signed by the exporting server.
+func (b Bundle) signingRoot() ([]byte, error) {
+	b.Signature = nil
+
+	resp, err := json.Marshal(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal bundle")
+	}
+
+	return resp, nil
+}
+
+func (d definitionImpl) Export(ctx context.Context, configHash []byte) (Bundle, error) {
+	doc, err := d.getDoc(ctx, configHash)
+	if err != nil {
+		return Bundle{}, err
+	}
+
+	history, err := d.History(ctx, configHash)
+	if errors.Is(err, ErrNotFound) {
+		history = []Event{} // Created before the change log.
+	} else if err != nil {
+		return Bundle{}, err
+	}
+
+	resp := Bundle{
+		Version:           bundleVersion,
+		Status:            doc.Status,
+		Declined:          doc.Declined,
+		Definition:        doc.Definition,
+		Lock:              doc.Lock,
+		DepositSignatures: make(map[string]string),
+		Exits:             doc.Exits,
+		History:           history,
+		ExportedAt:        time.Now().UTC(),
+	}
+	for pubkey, sig := range doc.DepositSignatures {
+		resp.DepositSignatures[pubkey] = fmt.Sprintf("%#x", sig)
+	}
+
+	if d.conf.ExportKey == nil {
+		return resp, nil
+	}
+
+	root, err := resp.signingRoot()
+	if err != nil {
+		return Bundle{}, err
+	}
+
+	resp.Signature = &BundleSignature{
+		PublicKey: fmt.Sprintf("%#x", []byte(d.conf.ExportKey.Public().(ed25519.PublicKey))),
+		Signature: fmt.Sprintf("%#x", ed25519.Sign(d.conf.ExportKey, root)),
+	}
+
+	return resp, nil
+}
+
+func (d definitionImpl) Import(ctx context.Context, bundle Bundle) error {
+	if err := d.verifyBundle(bundle); err != nil {
+		return err
+	}
+
+	configHash := bundle.Definition.ConfigHash
+	if _, err := d.getDoc(ctx, configHash); err == nil {
+		return errors.Wrap(ErrConflict, "definition already exists", z.Hex("config_hash", configHash))
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	doc := definitionDoc{
+		ConfigHash:        configHash,
+		Status:            bundle.Status,
+		Version:           bundle.Definition.Version,
+		Type:              clusterType(bundle.Definition),
+		Owner:             bundle.Definition.Creator.Address,
+		Tenant:            tenantFromCtx(ctx),
+		Declined:          bundle.Declined,
+		Definition:        bundle.Definition,
+		Lock:              bundle.Lock,
+		DepositSignatures: make(map[string][]byte),
+		Exits:             bundle.Exits,
+		UpdatedAt:         modifiedNow(),
+	}
+	for pubkey, sig := range bundle.DepositSignatures {
+		b, err := hex.DecodeString(strings.TrimPrefix(sig, "0x"))
+		if err != nil {
+			return errors.Wrap(ErrInvalidRequest, "invalid deposit signature hex", z.Str("pubkey", pubkey))
+		}
+		doc.DepositSignatures[pubkey] = b
+	}
+
+	if err := d.store.insert(ctx, &doc); errors.Is(err, errDuplicate) {
+		return errors.Wrap(ErrConflict, "definition concurrently created", z.Hex("config_hash", configHash))
+	} else if err != nil {
+		return err
+	}
+
+	// Preserve the exported audit trail followed by the import itself.
+	for _, event := range bundle.History {
+		d.appendEventAt(ctx, event.Type, configHash, event.Revision, event.Timestamp, nil)
+	}
+	d.appendEvent(ctx, EventImported, configHash, doc.Revision, &doc)
+
+	return nil
+}
+
+// verifyBundle returns an error if the bundle signature, if any, is invalid or not by a trusted key,
+// or if the definition or lock is invalid.
+func (d definitionImpl) verifyBundle(bundle Bundle) error {
+	if bundle.Version != bundleVersion {
+		return errors.Wrap(ErrInvalidRequest, "unsupported bundle version", z.Int("version", bundle.Version))
+	}
+
+	if bundle.Signature == nil {
+		if len(d.conf.ImportTrustedKeys) > 0 {
+			return errors.Wrap(ErrInvalidRequest, "unsigned bundle")
+		}
+	} else if err := d.verifyBundleSignature(bundle); err != nil {
+		return err
+	}
+
+	switch bundle.Status {
+	case StatusDraft, StatusCancelled:
+		if bundle.Lock != nil {
+			return errors.Wrap(ErrInvalidRequest, "unlocked bundle with lock")
+		}
+	case StatusReady, StatusLocked:
+		if err := bundle.Definition.VerifyHashes(); err != nil {
+			return errors.Wrap(ErrInvalidRequest, "invalid definition hashes", z.Err(err))
+		} else if err := bundle.Definition.VerifySignatures(); err != nil {
+			return errors.Wrap(ErrInvalidRequest, "invalid definition signatures", z.Err(err))
+		}
+	default:
+		return errors.Wrap(ErrInvalidRequest, "invalid bundle status", z.Str("status", string(bundle.Status)))
+	}
+
+	if bundle.Status != StatusLocked {
+		return nil
+	} else if bundle.Lock == nil {
+		return errors.Wrap(ErrInvalidRequest, "locked bundle without lock")
+	}
+
+	if !bytes.Equal(bundle.Lock.ConfigHash, bundle.Definition.ConfigHash) {
+		return errors.Wrap(ErrInvalidRequest, "lock config hash mismatch")
+	} else if err := bundle.Lock.VerifyHashes(); err != nil {
+		return errors.Wrap(ErrInvalidRequest, "invalid lock hashes", z.Err(err))
+	} else if err := bundle.Lock.VerifySignatures(); err != nil {
+		return errors.Wrap(ErrInvalidRequest, "invalid lock signatures", z.Err(err))
+	}
+
+	return nil
+}
+
+// verifyBundleSignature returns an error if the bundle signature is invalid or, if trusted keys
+// are configured, not by a trusted key.
+func (d definitionImpl) verifyBundleSignature(bundle Bundle) error {
+	pubkey, err := hex.DecodeString(strings.TrimPrefix(bundle.Signature.PublicKey, "0x"))
+	if err != nil || len(pubkey) != ed25519.PublicKeySize {
+		return errors.Wrap(ErrInvalidRequest, "invalid bundle public key")
+	}
+
+	sig, err := hex.DecodeString(strings.TrimPrefix(bundle.Signature.Signature, "0x"))
+	if err != nil {
+		return errors.Wrap(ErrInvalidRequest, "invalid bundle signature hex")
+	}
+
+	root, err := bundle.signingRoot()
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(pubkey, root, sig) {
+		return errors.Wrap(ErrInvalidRequest, "invalid bundle signature")
+	}
+
+	if len(d.conf.ImportTrustedKeys) == 0 {
+		return nil
+	}
+
+	for _, trusted := range d.conf.ImportTrustedKeys {
+		if trusted.Equal(ed25519.PublicKey(pubkey)) {
+			return nil
+		}
+	}
+
+	return errors.Wrap(ErrInvalidRequest, "bundle not signed by trusted key")
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/eth2util"
+	"go.mongodb.org/mongo-driver/bson"
+	"strings"
+	"time"
+)
+
+// filterFields are the fields of list filter expressions, true if the document field it matches is indexed.
+// Filters without an indexed field scan the definitions in config hash order until a page of matches is found.
+var filterFields = map[string]bool{
+	"name":           false,
+	"creator":        true, // Matches the owner.
+	"fork_version":   false,
+	"network":        false, // Matches the network's fork version.
+	"status":         true,
+	"created_after":  false,
+	"created_before": false,
+}
+
+// ParseListFilter parses the list filter expression of comma separated field=value matchers, all of which must
+// match, e.g. "creator=0xabc...,status=locked,created_after=2023-01-01T00:00:00Z". The supported fields are name,
+// creator, fork_version, network, status, created_after and created_before.
+func ParseListFilter(expr string) (ListFilter, error) {
+	var resp ListFilter
+	for _, term := range strings.Split(expr, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		field, value, ok := strings.Cut(term, "=")
+		field, value = strings.TrimSpace(field), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return ListFilter{}, errors.Wrap(ErrInvalidRequest, "invalid filter term, expected field=value", z.Str("term", term))
+		} else if _, ok := filterFields[field]; !ok {
+			return ListFilter{}, errors.Wrap(ErrInvalidRequest, "unknown filter field", z.Str("field", field))
+		}
+
+		var err error
+		switch field {
+		case "name":
+			resp.Name = value
+		case "creator":
+			if !common.IsHexAddress(value) {
+				return ListFilter{}, errors.Wrap(ErrInvalidRequest, "invalid filter creator address", z.Str("address", value))
+			}
+			resp.Creator = value
+		case "fork_version":
+			resp.ForkVersion, err = hex.DecodeString(strings.TrimPrefix(value, "0x"))
+			if err != nil {
+				return ListFilter{}, errors.Wrap(ErrInvalidRequest, "invalid filter fork version", z.Str("fork_version", value))
+			}
+		case "network":
+			resp.ForkVersion, err = eth2util.NetworkToForkVersionBytes(value)
+			if err != nil {
+				return ListFilter{}, errors.Wrap(ErrInvalidRequest, "unknown filter network", z.Str("network", value))
+			}
+		case "status":
+			switch resp.Status = Status(value); resp.Status {
+			case StatusDraft, StatusReady, StatusLocked, StatusCancelled: // Listed statuses that are stored.
+			default:
+				return ListFilter{}, errors.Wrap(ErrInvalidRequest, "invalid filter status", z.Str("status", value))
+			}
+		case "created_after":
+			resp.CreatedAfter, err = time.Parse(time.RFC3339, value)
+			if err != nil {
+				return ListFilter{}, errors.Wrap(ErrInvalidRequest, "invalid filter created_after, expected RFC3339", z.Str("value", value))
+			}
+		case "created_before":
+			resp.CreatedBefore, err = time.Parse(time.RFC3339, value)
+			if err != nil {
+				return ListFilter{}, errors.Wrap(ErrInvalidRequest, "invalid filter created_before, expected RFC3339", z.Str("value", value))
+			}
+		}
+	}
+
+	return resp, nil
+}
+
+// fields returns the filter fields that are set.
+func (f ListFilter) fields() []string {
+	var resp []string
+	if f.Name != "" {
+		resp = append(resp, "name")
+	}
+	if f.Creator != "" {
+		resp = append(resp, "creator")
+	}
+	if len(f.ForkVersion) > 0 {
+		resp = append(resp, "fork_version")
+	}
+	if f.Status != "" {
+		resp = append(resp, "status")
+	}
+	if !f.CreatedAfter.IsZero() {
+		resp = append(resp, "created_after")
+	}
+	if !f.CreatedBefore.IsZero() {
+		resp = append(resp, "created_before")
+	}
+
+	return resp
+}
+
+// compile returns the mongo matchers of the filter expression fields, excluding the operator filter.
+// Definition timestamps are RFC3339 strings, compared in UTC since they order lexicographically.
+func (f ListFilter) compile() bson.D {
+	var resp bson.D
+	if f.Name != "" {
+		resp = append(resp, bson.E{Key: "definition.name", Value: f.Name})
+	}
+	if f.Creator != "" {
+		resp = append(resp, ownerMatch(f.Creator))
+	}
+	if len(f.ForkVersion) > 0 {
+		resp = append(resp, bson.E{Key: "definition.forkversion", Value: f.ForkVersion})
+	}
+	if f.Status != "" {
+		resp = append(resp, bson.E{Key: "status", Value: f.Status})
+	}
+
+	var created bson.D
+	if !f.CreatedAfter.IsZero() {
+		created = append(created, bson.E{Key: "$gt", Value: f.CreatedAfter.UTC().Format(time.RFC3339)})
+	}
+	if !f.CreatedBefore.IsZero() {
+		created = append(created, bson.E{Key: "$lt", Value: f.CreatedBefore.UTC().Format(time.RFC3339)})
+	}
+	if len(created) > 0 {
+		resp = append(resp, bson.E{Key: "definition.timestamp", Value: created})
+	}
+
+	return resp
+}
+
+// adviseIndexes logs a warning if the filter only matches fields without an index, since listing then scans
+// definitions in config hash order until a page of matches is found. The operator filter is always indexed.
+func (f ListFilter) adviseIndexes(ctx context.Context) {
+	fields := f.fields()
+	if len(fields) == 0 || f.Operator != "" {
+		return
+	}
+
+	for _, field := range fields {
+		if filterFields[field] {
+			return
+		}
+	}
+
+	log.Warn(ctx, "List filter not covered by an index, consider adding a selective indexed field", nil,
+		z.Any("fields", fields))
+}
+
+// matches returns true if the published definition document matches the filter. It mirrors the operator filter
+// and compile for stores without query support.
+func (f ListFilter) matches(doc definitionDoc) bool {
+	if f.Operator != "" {
+		if _, ok := operatorIndex(doc.Definition, f.Operator); !ok {
+			return false
+		}
+	}
+
+	if f.Name != "" && doc.Definition.Name != f.Name {
+		return false
+	} else if f.Creator != "" && !strings.EqualFold(doc.Owner, f.Creator) {
+		return false
+	} else if len(f.ForkVersion) > 0 && !bytes.Equal(doc.Definition.ForkVersion, f.ForkVersion) {
+		return false
+	} else if f.Status != "" && doc.Status != f.Status {
+		return false
+	}
+
+	if !f.CreatedAfter.IsZero() || !f.CreatedBefore.IsZero() {
+		timestamp, err := time.Parse(time.RFC3339, doc.Definition.Timestamp)
+		if err != nil {
+			return false // Older definition versions may not contain a timestamp.
+		} else if !f.CreatedAfter.IsZero() && !timestamp.After(f.CreatedAfter) {
+			return false
+		} else if !f.CreatedBefore.IsZero() && !timestamp.Before(f.CreatedBefore) {
+			return false
+		}
+	}
+
+	return true
+}
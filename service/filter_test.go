@@ -0,0 +1,171 @@
+package service
+
+import (
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/cluster"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const filterAddress = "0xAbCdEf0123456789aBcDeF0123456789AbCdEf01"
+
+func TestParseListFilter(t *testing.T) {
+	after := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		expr    string
+		want    ListFilter
+		wantErr bool
+	}{
+		{name: "empty", expr: ""},
+		{name: "name", expr: "name=test cluster", want: ListFilter{Name: "test cluster"}},
+		{name: "creator", expr: "creator=" + filterAddress, want: ListFilter{Creator: filterAddress}},
+		{name: "fork version", expr: "fork_version=0x00001020", want: ListFilter{ForkVersion: []byte{0x00, 0x00, 0x10, 0x20}}},
+		{name: "network", expr: "network=goerli", want: ListFilter{ForkVersion: []byte{0x00, 0x00, 0x10, 0x20}}},
+		{name: "status", expr: "status=locked", want: ListFilter{Status: StatusLocked}},
+		{
+			name: "created range",
+			expr: "created_after=2023-01-01T00:00:00Z,created_before=2023-06-01T12:00:00Z",
+			want: ListFilter{CreatedAfter: after, CreatedBefore: before},
+		},
+		{
+			name: "spaces and empty terms",
+			expr: " name = test , ,status= draft ",
+			want: ListFilter{Name: "test", Status: StatusDraft},
+		},
+		{name: "missing value", expr: "name=", wantErr: true},
+		{name: "missing separator", expr: "name", wantErr: true},
+		{name: "unknown field", expr: "owner=" + filterAddress, wantErr: true},
+		{name: "invalid creator", expr: "creator=0x1234", wantErr: true},
+		{name: "invalid fork version", expr: "fork_version=0xzz", wantErr: true},
+		{name: "unknown network", expr: "network=unknown", wantErr: true},
+		{name: "derived status", expr: "status=expired", wantErr: true},
+		{name: "unpublished status", expr: "status=unpublished", wantErr: true},
+		{name: "invalid created_after", expr: "created_after=2023-01-01", wantErr: true},
+		{name: "invalid created_before", expr: "created_before=yesterday", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseListFilter(test.expr)
+			if test.wantErr {
+				if !errors.Is(err, ErrInvalidRequest) {
+					t.Fatalf("unexpected error: got %v, want %v", err, ErrInvalidRequest)
+				}
+
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("unexpected filter: got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestListFilterCompile(t *testing.T) {
+	// Timestamps are compared in UTC.
+	after := time.Date(2023, 1, 1, 2, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+
+	tests := []struct {
+		name   string
+		filter ListFilter
+		want   bson.D
+	}{
+		{name: "empty", filter: ListFilter{}},
+		{name: "operator excluded", filter: ListFilter{Operator: filterAddress}},
+		{
+			name:   "fields",
+			filter: ListFilter{Name: "test", Creator: filterAddress, ForkVersion: []byte{0x00, 0x00, 0x10, 0x20}, Status: StatusReady},
+			want: bson.D{
+				{Key: "definition.name", Value: "test"},
+				ownerMatch(filterAddress),
+				{Key: "definition.forkversion", Value: []byte{0x00, 0x00, 0x10, 0x20}},
+				{Key: "status", Value: StatusReady},
+			},
+		},
+		{
+			name:   "created after",
+			filter: ListFilter{CreatedAfter: after},
+			want: bson.D{
+				{Key: "definition.timestamp", Value: bson.D{{Key: "$gt", Value: "2023-01-01T00:00:00Z"}}},
+			},
+		},
+		{
+			name:   "created range",
+			filter: ListFilter{CreatedAfter: after, CreatedBefore: after.Add(time.Hour)},
+			want: bson.D{
+				{Key: "definition.timestamp", Value: bson.D{
+					{Key: "$gt", Value: "2023-01-01T00:00:00Z"},
+					{Key: "$lt", Value: "2023-01-01T01:00:00Z"},
+				}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.filter.compile(); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("unexpected matchers: got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestListFilterMatches(t *testing.T) {
+	doc := definitionDoc{
+		Status: StatusLocked,
+		Owner:  filterAddress,
+		Definition: cluster.Definition{
+			Name:        "test",
+			ForkVersion: []byte{0x00, 0x00, 0x10, 0x20},
+			Timestamp:   "2023-03-01T00:00:00Z",
+			Operators:   []cluster.Operator{{Address: filterAddress}},
+		},
+	}
+	created := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		filter ListFilter
+		doc    definitionDoc
+		want   bool
+	}{
+		{name: "empty", want: true},
+		{name: "operator", filter: ListFilter{Operator: filterAddress}, want: true},
+		{name: "other operator", filter: ListFilter{Operator: "0x0000000000000000000000000000000000000001"}},
+		{name: "name", filter: ListFilter{Name: "test"}, want: true},
+		{name: "other name", filter: ListFilter{Name: "other"}},
+		{name: "creator any case", filter: ListFilter{Creator: "0xabcdef0123456789abcdef0123456789abcdef01"}, want: true},
+		{name: "fork version", filter: ListFilter{ForkVersion: []byte{0x00, 0x00, 0x10, 0x20}}, want: true},
+		{name: "other fork version", filter: ListFilter{ForkVersion: []byte{0x00, 0x00, 0x00, 0x00}}},
+		{name: "status", filter: ListFilter{Status: StatusLocked}, want: true},
+		{name: "other status", filter: ListFilter{Status: StatusDraft}},
+		{name: "created after", filter: ListFilter{CreatedAfter: created.Add(-time.Second)}, want: true},
+		{name: "created after exclusive", filter: ListFilter{CreatedAfter: created}},
+		{name: "created before", filter: ListFilter{CreatedBefore: created.Add(time.Second)}, want: true},
+		{name: "created before exclusive", filter: ListFilter{CreatedBefore: created}},
+		{
+			name:   "no timestamp",
+			filter: ListFilter{CreatedAfter: created.Add(-time.Second)},
+			doc:    definitionDoc{Status: StatusLocked},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := doc
+			if test.doc.Status != "" {
+				d = test.doc
+			}
+
+			if got := test.filter.matches(d); got != test.want {
+				t.Fatalf("unexpected match: got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"time"
+)
+
+// ReplicaSet is the health of the mongo replica set.
+type ReplicaSet struct {
+	Name string `json:"name"`
+	// PrimaryReachable is true if a healthy primary is reachable, required for writes.
+	PrimaryReachable bool `json:"primary_reachable"`
+	// MaxLagSeconds is the replication lag of the most lagging healthy secondary behind the primary.
+	MaxLagSeconds float64 `json:"max_lag_seconds"`
+	// LastWrite is the wall time of the last write committed to a majority of the replica set.
+	LastWrite time.Time          `json:"last_write"`
+	Members   []ReplicaSetMember `json:"members"`
+}
+
+// ReplicaSetMember is the health of a single replica set member.
+type ReplicaSetMember struct {
+	Name       string  `json:"name"`
+	State      string  `json:"state"`
+	Healthy    bool    `json:"healthy"`
+	LagSeconds float64 `json:"lag_seconds"`
+}
+
+type Health interface {
+	// Ready returns an error if mongo isn't reachable.
+	Ready(ctx context.Context) error
+	// Host returns the address of the mongo host serving primary traffic.
+	Host(ctx context.Context) (string, error)
+	// ReplicaSet returns the health of the replica set. It returns an error if mongo isn't a replica set.
+	ReplicaSet(ctx context.Context) (ReplicaSet, error)
+}
+
+func NewHealth(client *mongo.Client) Health {
+	return healthImpl{client: client}
+}
+
+type healthImpl struct {
+	client *mongo.Client
+}
+
+func (h healthImpl) Ready(ctx context.Context) error {
+	if err := h.client.Ping(ctx, readpref.Nearest()); err != nil {
+		return errors.Wrap(err, "failed to ping mongo")
+	}
+
+	return nil
+}
+
+func (h healthImpl) Host(ctx context.Context) (string, error) {
+	var hello struct {
+		Me string `bson:"me"`
+	}
+
+	res := h.client.Database("admin").RunCommand(ctx, bson.D{{"hello", 1}})
+	if err := res.Decode(&hello); err != nil {
+		return "", errors.Wrap(err, "failed to get mongo host")
+	}
+
+	return hello.Me, nil
+}
+
+func (h healthImpl) ReplicaSet(ctx context.Context) (ReplicaSet, error) {
+	var status struct {
+		Set     string `bson:"set"`
+		Optimes struct {
+			LastCommittedWallTime time.Time `bson:"lastCommittedWallTime"`
+		} `bson:"optimes"`
+		Members []struct {
+			Name       string    `bson:"name"`
+			Health     float64   `bson:"health"`
+			StateStr   string    `bson:"stateStr"`
+			OptimeDate time.Time `bson:"optimeDate"`
+		} `bson:"members"`
+	}
+
+	res := h.client.Database("admin").RunCommand(ctx, bson.D{{"replSetGetStatus", 1}})
+	if err := res.Decode(&status); err != nil {
+		return ReplicaSet{}, errors.Wrap(err, "failed to get replica set status")
+	}
+
+	var primaryOptime time.Time
+	for _, member := range status.Members {
+		if member.StateStr == "PRIMARY" && member.Health == 1 {
+			primaryOptime = member.OptimeDate
+		}
+	}
+
+	resp := ReplicaSet{
+		Name:             status.Set,
+		PrimaryReachable: !primaryOptime.IsZero(),
+		LastWrite:        status.Optimes.LastCommittedWallTime,
+		Members:          []ReplicaSetMember{},
+	}
+	for _, member := range status.Members {
+		healthy := member.Health == 1
+
+		var lag float64
+		if healthy && resp.PrimaryReachable && member.StateStr == "SECONDARY" {
+			lag = primaryOptime.Sub(member.OptimeDate).Seconds()
+			if lag > resp.MaxLagSeconds {
+				resp.MaxLagSeconds = lag
+			}
+		}
+
+		resp.Members = append(resp.Members, ReplicaSetMember{
+			Name:       member.Name,
+			State:      member.StateStr,
+			Healthy:    healthy,
+			LagSeconds: lag,
+		})
+	}
+
+	return resp, nil
+}
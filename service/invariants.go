@@ -0,0 +1,28 @@
+package service
+
+import (
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+)
+
+// verifyInvariants returns ErrInvalidRequest if the definition violates the invariants charon requires of clusters,
+// since such definitions fail at DKG time: at least one validator and operator, a threshold between one and
+// the number of operators, and unique operator addresses and ENRs.
+// It is verified when definitions are created and whenever their operators change.
+func verifyInvariants(def cluster.Definition) error {
+	nodes := len(def.Operators)
+
+	if def.NumValidators <= 0 {
+		return errors.Wrap(ErrInvalidRequest, "number of validators not positive", z.Int("num_validators", def.NumValidators))
+	} else if nodes == 0 {
+		return errors.Wrap(ErrInvalidRequest, "definition without operators")
+	} else if def.Threshold <= 0 {
+		return errors.Wrap(ErrInvalidRequest, "threshold not positive", z.Int("threshold", def.Threshold))
+	} else if def.Threshold > nodes {
+		return errors.Wrap(ErrInvalidRequest, "threshold exceeds number of operators",
+			z.Int("threshold", def.Threshold), z.Int("operators", nodes))
+	}
+
+	return verifyUniqueOperators(def)
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"strings"
+	"time"
+)
+
+// inviteTokenLen is the number of random bytes in an invite token.
+const inviteTokenLen = 32
+
+// Invite is a minted single-use invite token bound to an operator address of a definition.
+type Invite struct {
+	Token   string `json:"token"`
+	Address string `json:"address"`
+	// ExpiresAt is when the token expires, nil if it never expires.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// inviteToken is a single-use operator invite token bound to an operator slot.
+type inviteToken struct {
+	// Hash is the sha256 hash of the token, the token itself is never stored. It is empty if no token was
+	// minted for the slot yet.
+	Hash []byte `bson:"hash"`
+	Used bool   `bson:"used"`
+	// ExpiresAt is when the token expires, zero if it never expires.
+	ExpiresAt time.Time `bson:"expires_at,omitempty"`
+}
+
+// newInviteTokens returns n random hex invite tokens and their stored representation.
+func newInviteTokens(n int) ([]string, []inviteToken, error) {
+	var (
+		tokens []string
+		stored []inviteToken
+	)
+	for i := 0; i < n; i++ {
+		token, invite, err := newInviteToken(time.Time{})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tokens = append(tokens, token)
+		stored = append(stored, invite)
+	}
+
+	return tokens, stored, nil
+}
+
+// newInviteToken returns a random hex invite token expiring at the time, or never if zero, and its stored representation.
+func newInviteToken(expiresAt time.Time) (string, inviteToken, error) {
+	b := make([]byte, inviteTokenLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", inviteToken{}, errors.Wrap(err, "generate invite token")
+	}
+
+	token := hex.EncodeToString(b)
+	hash := sha256.Sum256([]byte(token))
+
+	return token, inviteToken{Hash: hash[:], ExpiresAt: expiresAt}, nil
+}
+
+// inviteSlot returns the index of the operator slot bound to the invite token,
+// or an error if the token is invalid, already used or bound to another address.
+func inviteSlot(doc *definitionDoc, address string, token string) (int, error) {
+	if token == "" {
+		return 0, errors.Wrap(ErrInvalidRequest, "missing invite token", z.Str("address", address))
+	}
+
+	hash := sha256.Sum256([]byte(token))
+	for i, invite := range doc.InviteTokens {
+		if len(invite.Hash) == 0 || string(invite.Hash) != string(hash[:]) {
+			continue
+		} else if !strings.EqualFold(doc.Definition.Operators[i].Address, address) {
+			return 0, errors.Wrap(ErrInvalidRequest, "invite token bound to another operator", z.Str("address", address))
+		} else if invite.Used {
+			return 0, errors.Wrap(ErrInvalidRequest, "invite token already used", z.Str("address", address))
+		} else if !invite.ExpiresAt.IsZero() && time.Now().After(invite.ExpiresAt) {
+			return 0, errors.Wrap(ErrInvalidRequest, "invite token expired", z.Str("address", address))
+		}
+
+		return i, nil
+	}
+
+	return 0, errors.Wrap(ErrInvalidRequest, "invalid invite token", z.Str("address", address))
+}
+
+func (d definitionImpl) Invite(ctx context.Context, configHash []byte, address string, auth RequestAuth) (Invite, error) {
+	var resp Invite
+	err := d.update(ctx, configHash, EventInviteMinted, func(doc *definitionDoc) error {
+		if doc.Status != StatusUnpublished && doc.Status != StatusDraft {
+			return errors.Wrap(ErrInvalidState, "operators can only be invited before finalization", z.Str("status", string(doc.Status)))
+		}
+
+		if doc.Owner == "" {
+			return errors.Wrap(ErrInvalidState, "definition has no creator to authorize invites")
+		} else if err := d.verifyRequest(doc, doc.Owner, actionInvite, addressPayload(address), auth); err != nil {
+			return err
+		}
+
+		idx, ok := operatorIndex(doc.Definition, address)
+		if !ok {
+			return errors.Wrap(ErrInvalidRequest, "operator not in definition", z.Str("address", address))
+		} else if doc.Definition.Operators[idx].ENR != "" {
+			return errors.Wrap(ErrInvalidState, "operator already joined", z.Str("address", address))
+		}
+
+		var expiresAt time.Time
+		if d.conf.InviteTTL > 0 {
+			expiresAt = time.Now().Add(d.conf.InviteTTL).UTC().Truncate(time.Millisecond) // Mongo dates have millisecond precision.
+			resp.ExpiresAt = &expiresAt
+		}
+
+		token, invite, err := newInviteToken(expiresAt)
+		if err != nil {
+			return err
+		}
+
+		// Once any token is minted, all operators must join with a token bound to their slot.
+		if len(doc.InviteTokens) == 0 {
+			doc.InviteTokens = make([]inviteToken, len(doc.Definition.Operators))
+		}
+		doc.InviteTokens[idx] = invite // Replaces any previous token of the slot.
+
+		resp.Token = token
+		resp.Address = doc.Definition.Operators[idx].Address
+
+		return nil
+	})
+	if err != nil {
+		return Invite{}, err
+	}
+
+	return resp, nil
+}
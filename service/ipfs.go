@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// publishTimeout is the maximum duration of publishing to IPFS.
+const publishTimeout = 2 * time.Minute
+
+// publishAsync publishes the value's json to IPFS in the background and stores its CID in the field of the
+// definition document, continuing the trace of the request without its cancellation. It does nothing if
+// publishing is disabled.
+func (d definitionImpl) publishAsync(ctx context.Context, configHash []byte, field string, v interface{}) {
+	if d.conf.Publisher == nil {
+		return
+	}
+
+	publishCtx := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+	go func() {
+		ctx := log.WithTopic(publishCtx, "ipfs")
+		if err := d.publish(ctx, configHash, field, v); err != nil {
+			log.Warn(ctx, "Failed publishing to ipfs", err, z.Str("field", field), z.Hex("config_hash", configHash))
+		}
+	}()
+}
+
+// publish publishes the value's json to IPFS and stores its CID in the field of the definition document.
+// The CID is derived from the content, so storing it doesn't change the definition revision.
+func (d definitionImpl) publish(ctx context.Context, configHash []byte, field string, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "marshal published json")
+	}
+
+	name := fmt.Sprintf("%s-%#x.json", field, configHash)
+	cid, err := d.conf.Publisher.Publish(ctx, name, b)
+	if err != nil {
+		return err
+	}
+
+	if err := d.store.setRef(ctx, configHash, field+"_cid", cid); err != nil {
+		return err
+	}
+
+	log.Info(ctx, "Published to ipfs", z.Str("field", field), z.Str("cid", cid), z.Hex("config_hash", configHash))
+
+	return nil
+}
+
+func (m mongoStore) setRef(ctx context.Context, configHash []byte, field string, value string) error {
+	ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opUpdate)
+	defer cancel()
+
+	_, err := m.table.UpdateOne(ctx, bson.D{{"config_hash", configHash}}, bson.D{{"$set", bson.D{{field, value}}}})
+	if err != nil {
+		return wrapDBErr(err, opUpdate, "failed to store reference", z.Str("field", field))
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Iterator iterates over stored documents as the underlying mongo cursor advances,
+// avoiding decoding all documents into memory at once.
+type Iterator struct {
+	cursor *mongo.Cursor
+	decode func(*mongo.Cursor) (interface{}, error)
+
+	// values are the values of iterators over in-memory values without a cursor.
+	values []interface{}
+	idx    int
+}
+
+// newIterator returns an iterator over the cursor using decode to convert the current document to a response value.
+func newIterator(cursor *mongo.Cursor, decode func(*mongo.Cursor) (interface{}, error)) *Iterator {
+	return &Iterator{
+		cursor: cursor,
+		decode: decode,
+	}
+}
+
+// newSliceIterator returns an iterator over the in-memory values.
+func newSliceIterator(values []interface{}) *Iterator {
+	return &Iterator{
+		values: values,
+		idx:    -1,
+	}
+}
+
+// Next advances the iterator and returns true if a value is available.
+// It returns false when exhausted or on error, see Err.
+func (i *Iterator) Next(ctx context.Context) bool {
+	if i.cursor == nil {
+		i.idx++
+		return i.idx < len(i.values)
+	}
+
+	return i.cursor.Next(ctx)
+}
+
+// Value returns the current value.
+func (i *Iterator) Value() (interface{}, error) {
+	if i.cursor == nil {
+		return i.values[i.idx], nil
+	}
+
+	return i.decode(i.cursor)
+}
+
+// Err returns the error that stopped iteration, if any.
+func (i *Iterator) Err() error {
+	if i.cursor == nil {
+		return nil
+	} else if err := i.cursor.Err(); err != nil {
+		return errors.Wrap(err, "iterate cursor")
+	}
+
+	return nil
+}
+
+// Close closes the underlying cursor.
+func (i *Iterator) Close(ctx context.Context) error {
+	if i.cursor == nil {
+		return nil
+	} else if err := i.cursor.Close(ctx); err != nil {
+		return errors.Wrap(err, "close cursor")
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxLineageDepth bounds the number of ancestors traversed when resolving a lineage.
+const maxLineageDepth = 100
+
+// Lineage is the resize and reshare lineage of a definition.
+type Lineage struct {
+	// Ancestors are the 0x-hex config hashes of the parent clusters, oldest first.
+	Ancestors []string `json:"ancestors"`
+	// Children are the 0x-hex config hashes of definitions resizing or resharing this cluster.
+	Children []string `json:"children"`
+}
+
+func (d definitionImpl) Lineage(ctx context.Context, configHash []byte) (Lineage, error) {
+	doc, err := d.getDoc(ctx, configHash)
+	if err != nil {
+		return Lineage{}, err
+	}
+
+	resp := Lineage{
+		Ancestors: []string{},
+		Children:  []string{},
+	}
+
+	for parent := doc.Parent; len(parent) > 0; {
+		if len(resp.Ancestors) >= maxLineageDepth {
+			return Lineage{}, errors.New("lineage too deep")
+		}
+
+		resp.Ancestors = append([]string{fmt.Sprintf("%#x", parent)}, resp.Ancestors...)
+
+		parentDoc, err := d.getDoc(ctx, parent)
+		if err != nil {
+			return Lineage{}, err
+		}
+		parent = parentDoc.Parent
+	}
+
+	children, err := d.store.findChildren(ctx, configHash)
+	if err != nil {
+		return Lineage{}, err
+	}
+
+	for _, child := range children {
+		resp.Children = append(resp.Children, fmt.Sprintf("%#x", child))
+	}
+
+	return resp, nil
+}
+
+func (m mongoStore) findChildren(ctx context.Context, parent []byte) ([][]byte, error) {
+	ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opFind)
+	defer cancel()
+
+	cursor, err := m.table.Find(ctx, bson.D{{"parent", parent}, tenantMatch(ctx)},
+		options.Find().SetProjection(bson.D{{"config_hash", 1}}))
+	if err != nil {
+		return nil, wrapDBErr(err, opFind, "failed to find children")
+	}
+
+	var children []definitionDoc
+	if err := cursor.All(ctx, &children); err != nil {
+		return nil, wrapDBErr(err, opFind, "failed to decode children")
+	}
+
+	var resp [][]byte
+	for _, child := range children {
+		resp = append(resp, child.ConfigHash)
+	}
+
+	return resp, nil
+}
+
+// verifyParent returns an error if the definition isn't a valid resize or reshare of the parent cluster.
+// The parent must be locked on the same network with the same number of validators and at least
+// a threshold of its operators must be included in the definition so the existing key shares can be reshared.
+func (d definitionImpl) verifyParent(ctx context.Context, def cluster.Definition, parent []byte) error {
+	doc, err := d.getDoc(ctx, parent)
+	if errors.Is(err, ErrNotFound) {
+		return errors.Wrap(ErrInvalidRequest, "parent definition not found", z.Hex("parent", parent))
+	} else if err != nil {
+		return err
+	}
+
+	if doc.Status != StatusLocked || doc.Lock == nil {
+		return errors.Wrap(ErrInvalidRequest, "parent definition not locked", z.Str("status", string(doc.Status)))
+	} else if !bytes.Equal(doc.Definition.ForkVersion, def.ForkVersion) {
+		return errors.Wrap(ErrInvalidRequest, "parent fork version mismatch")
+	} else if doc.Definition.NumValidators != def.NumValidators {
+		return errors.Wrap(ErrInvalidRequest, "parent number of validators mismatch",
+			z.Int("parent", doc.Definition.NumValidators), z.Int("child", def.NumValidators))
+	}
+
+	var overlap int
+	for _, op := range doc.Definition.Operators {
+		if _, ok := operatorIndex(def, op.Address); ok {
+			overlap++
+		}
+	}
+
+	if overlap < doc.Lock.Threshold {
+		return errors.Wrap(ErrInvalidRequest, "insufficient parent operators in definition",
+			z.Int("overlap", overlap), z.Int("threshold", doc.Lock.Threshold))
+	}
+
+	return nil
+}
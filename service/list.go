@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+	"time"
+)
+
+// ListFilter filters listed definitions, empty fields don't filter.
+type ListFilter struct {
+	// Operator is the 0x-hex address of an operator of the definition.
+	Operator string
+	// ForkVersion is the fork version of the definition's network.
+	ForkVersion []byte
+	// Name is the exact name of the definition.
+	Name string
+	// Creator is the 0x-hex address of the definition's verified creator.
+	Creator string
+	// Status is the stored status of the definition.
+	Status Status
+	// CreatedAfter and CreatedBefore exclusively bound the definition's embedded timestamp.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// DefinitionPage is a page of definitions ordered by config hash.
+type DefinitionPage struct {
+	Definitions []cluster.Definition `json:"definitions"`
+	// NextCursor is the cursor of the next page, empty if this is the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func (d definitionImpl) List(ctx context.Context, filter ListFilter, cursor string, limit int) (DefinitionPage, error) {
+	if limit <= 0 {
+		return DefinitionPage{}, errors.Wrap(ErrInvalidRequest, "invalid list limit")
+	}
+
+	var after []byte
+	if cursor != "" {
+		var err error
+		after, err = hex.DecodeString(strings.TrimPrefix(cursor, "0x"))
+		if err != nil || len(after) != configHashLen {
+			return DefinitionPage{}, errors.Wrap(ErrInvalidRequest, "invalid list cursor", z.Str("cursor", cursor))
+		}
+	}
+
+	if filter.Operator != "" && !common.IsHexAddress(filter.Operator) {
+		return DefinitionPage{}, errors.Wrap(ErrInvalidRequest, "invalid operator address", z.Str("address", filter.Operator))
+	}
+
+	filter.adviseIndexes(ctx)
+
+	// Fetch an additional definition to determine whether a next page exists.
+	docs, err := d.store.list(ctx, filter, after, limit+1)
+	if err != nil {
+		return DefinitionPage{}, err
+	}
+
+	resp := DefinitionPage{Definitions: make([]cluster.Definition, 0, len(docs))}
+	if len(docs) > limit {
+		docs = docs[:limit]
+		resp.NextCursor = fmt.Sprintf("%#x", docs[limit-1].ConfigHash)
+	}
+
+	for _, doc := range docs {
+		resp.Definitions = append(resp.Definitions, doc.Definition)
+	}
+
+	return resp, nil
+}
+
+func (m mongoStore) list(ctx context.Context, filter ListFilter, after []byte, limit int) ([]definitionDoc, error) {
+	match := bson.D{{"status", bson.D{{"$ne", StatusUnpublished}}}, tenantMatch(ctx)}
+	if len(after) > 0 {
+		match = append(match, bson.E{Key: "config_hash", Value: bson.D{{"$gt", after}}})
+	}
+
+	if filter.Operator != "" {
+		// Addresses are stored as provided by creators, so match both the checksummed and lowercase forms.
+		addresses := bson.D{{"$in", bson.A{
+			common.HexToAddress(filter.Operator).Hex(),
+			strings.ToLower(common.HexToAddress(filter.Operator).Hex()),
+		}}}
+		match = append(match, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{"definition.operators.address", addresses}},
+			bson.D{{"operator_addresses", addresses}}, // Compressed documents.
+		}})
+	}
+
+	match = append(match, filter.compile()...)
+
+	ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opFind)
+	defer cancel()
+
+	cur, err := m.table.Find(ctx, match, options.Find().SetLimit(int64(limit)).SetSort(bson.D{{"config_hash", 1}}))
+	if err != nil {
+		return nil, wrapDBErr(err, opFind, "failed to list definitions")
+	}
+
+	var docs []definitionDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, wrapDBErr(err, opFind, "failed to decode definitions")
+	}
+
+	return docs, nil
+}
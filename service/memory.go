@@ -0,0 +1,573 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"sync"
+	"time"
+)
+
+// errMemUnsupported is returned by functionality not supported by the in-memory storage.
+var errMemUnsupported = errors.Wrap(ErrUnsupported, "not supported by in-memory storage")
+
+// newMemStore returns a new empty in-memory document store.
+func newMemStore() *memStore {
+	return &memStore{
+		docs: make(map[string][]byte),
+	}
+}
+
+// memStore is the in-memory docStore. Documents are stored bson encoded like in mongo, so callers never share
+// stored documents. Deleted documents are retained in the trash until restart.
+type memStore struct {
+	mu     sync.Mutex
+	docs   map[string][]byte // Encoded definition documents by config hash.
+	trash  []memTrashed      // Deleted documents in order of deletion.
+	events [][]byte          // Encoded events in order of insertion.
+}
+
+// memTrashed is a deleted document of the in-memory store.
+type memTrashed struct {
+	ConfigHash []byte
+	DeletedAt  time.Time
+	DeletedBy  string
+	Document   []byte
+}
+
+// decodeDoc returns the decoded definition document.
+func decodeDoc(raw []byte) (definitionDoc, error) {
+	var doc definitionDoc
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return definitionDoc{}, errors.Wrap(err, "failed to decode definition")
+	}
+
+	return doc, nil
+}
+
+// get returns the document of the context's tenant with the config hash, it must be called while holding the lock.
+func (s *memStore) get(ctx context.Context, configHash []byte) (definitionDoc, error) {
+	raw, ok := s.docs[string(configHash)]
+	if !ok {
+		return definitionDoc{}, errors.Wrap(ErrNotFound, "definition not found")
+	}
+
+	doc, err := decodeDoc(raw)
+	if err != nil {
+		return definitionDoc{}, err
+	} else if doc.Tenant != tenantFromCtx(ctx) {
+		return definitionDoc{}, errors.Wrap(ErrNotFound, "definition not found")
+	}
+
+	return doc, nil
+}
+
+// all returns the documents matching the function ordered by config hash, it must be called while holding the lock.
+// The tenant of the documents is not filtered.
+func (s *memStore) all(fn func(doc definitionDoc) bool) ([]definitionDoc, error) {
+	var hashes []string
+	for configHash := range s.docs {
+		hashes = append(hashes, configHash)
+	}
+	sort.Strings(hashes)
+
+	var resp []definitionDoc
+	for _, configHash := range hashes {
+		doc, err := decodeDoc(s.docs[configHash])
+		if err != nil {
+			return nil, err
+		} else if fn(doc) {
+			resp = append(resp, doc)
+		}
+	}
+
+	return resp, nil
+}
+
+// published returns the published documents of the context's tenant matching the function ordered by config hash,
+// it must be called while holding the lock.
+func (s *memStore) published(ctx context.Context, fn func(doc definitionDoc) bool) ([]definitionDoc, error) {
+	tenant := tenantFromCtx(ctx)
+
+	return s.all(func(doc definitionDoc) bool {
+		return doc.Tenant == tenant && doc.Status != StatusUnpublished && fn(doc)
+	})
+}
+
+// put stores the encoded document, it must be called while holding the lock.
+func (s *memStore) put(doc definitionDoc) error {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode definition")
+	}
+	s.docs[string(doc.ConfigHash)] = raw
+
+	return nil
+}
+
+func (s *memStore) find(ctx context.Context, configHash []byte) (definitionDoc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.get(ctx, configHash)
+}
+
+func (s *memStore) findMany(ctx context.Context, configHashes [][]byte) ([]definitionDoc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.published(ctx, func(doc definitionDoc) bool {
+		for _, configHash := range configHashes {
+			if bytes.Equal(doc.ConfigHash, configHash) {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+func (s *memStore) findRange(ctx context.Context, from, to []byte, limit int) ([]definitionDoc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	docs, err := s.published(ctx, func(doc definitionDoc) bool {
+		return bytes.Compare(doc.ConfigHash, from) >= 0 && bytes.Compare(doc.ConfigHash, to) <= 0
+	})
+	if err != nil {
+		return nil, err
+	} else if len(docs) > limit {
+		docs = docs[:limit]
+	}
+
+	return docs, nil
+}
+
+func (s *memStore) findValidator(ctx context.Context, pubkey []byte) (definitionDoc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant := tenantFromCtx(ctx)
+	docs, err := s.all(func(doc definitionDoc) bool {
+		if doc.Tenant != tenant || doc.Lock == nil {
+			return false
+		}
+
+		for _, val := range doc.Lock.Validators {
+			if bytes.Equal(val.PubKey, pubkey) {
+				return true
+			}
+		}
+
+		return false
+	})
+	if err != nil {
+		return definitionDoc{}, err
+	} else if len(docs) == 0 {
+		return definitionDoc{}, errors.Wrap(ErrNotFound, "validator not found")
+	}
+
+	return docs[0], nil
+}
+
+func (s *memStore) findChildren(ctx context.Context, parent []byte) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant := tenantFromCtx(ctx)
+	docs, err := s.all(func(doc definitionDoc) bool {
+		return doc.Tenant == tenant && len(doc.Parent) > 0 && bytes.Equal(doc.Parent, parent)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp [][]byte
+	for _, doc := range docs {
+		resp = append(resp, doc.ConfigHash)
+	}
+
+	return resp, nil
+}
+
+func (s *memStore) list(ctx context.Context, filter ListFilter, after []byte, limit int) ([]definitionDoc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	docs, err := s.published(ctx, func(doc definitionDoc) bool {
+		return bytes.Compare(doc.ConfigHash, after) > 0 && filter.matches(doc)
+	})
+	if err != nil {
+		return nil, err
+	} else if len(docs) > limit {
+		docs = docs[:limit]
+	}
+
+	return docs, nil
+}
+
+func (s *memStore) validators(ctx context.Context, configHash []byte, offset, limit int) (ValidatorPage, error) {
+	doc, err := s.find(ctx, configHash)
+	if err != nil {
+		return ValidatorPage{}, err
+	} else if doc.Lock == nil {
+		return ValidatorPage{}, errors.Wrap(ErrNotFound, "lock not found")
+	}
+
+	return validatorPage(doc.Lock.Validators, offset, limit), nil
+}
+
+func (s *memStore) count(_ context.Context, filter countFilter) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	docs, err := s.all(filter.matches)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(docs), nil
+}
+
+func (s *memStore) stats(ctx context.Context, filter StatsFilter) (Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant := tenantFromCtx(ctx)
+	docs, err := s.all(func(doc definitionDoc) bool {
+		return doc.Tenant == tenant
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+
+	resp := Stats{
+		ByType:   make(map[ClusterType]int),
+		ByStatus: make(map[Status]int),
+	}
+	for _, doc := range docs {
+		resp.add(doc, filter)
+	}
+
+	return resp, nil
+}
+
+func (s *memStore) insert(_ context.Context, doc *definitionDoc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.docs[string(doc.ConfigHash)]; ok {
+		return errDuplicate
+	}
+
+	return s.put(*doc)
+}
+
+func (s *memStore) insertMany(ctx context.Context, docs []*definitionDoc) ([]error, error) {
+	resp := make([]error, len(docs))
+	for i, doc := range docs {
+		resp[i] = s.insert(ctx, doc)
+	}
+
+	return resp, nil
+}
+
+func (s *memStore) replace(_ context.Context, configHash []byte, revision int, doc *definitionDoc) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	raw, ok := s.docs[string(configHash)]
+	if !ok {
+		return false, nil
+	}
+
+	existing, err := decodeDoc(raw)
+	if err != nil {
+		return false, err
+	} else if existing.Revision != revision {
+		return false, nil
+	}
+
+	// Revising unpublished definitions changes their config hash.
+	if !bytes.Equal(doc.ConfigHash, configHash) {
+		if _, ok := s.docs[string(doc.ConfigHash)]; ok {
+			return false, errors.Wrap(ErrConflict, "definition with config hash already exists")
+		}
+		delete(s.docs, string(configHash))
+	}
+
+	return true, s.put(*doc)
+}
+
+func (s *memStore) setRef(_ context.Context, configHash []byte, field string, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	raw, ok := s.docs[string(configHash)]
+	if !ok {
+		return nil // Concurrently deleted.
+	}
+
+	doc, err := decodeDoc(raw)
+	if err != nil {
+		return err
+	} else if err := doc.setRef(field, value); err != nil {
+		return err
+	}
+
+	return s.put(doc)
+}
+
+func (s *memStore) remove(_ context.Context, doc definitionDoc, revision int, principal string, trash bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	raw, ok := s.docs[string(doc.ConfigHash)]
+	if !ok {
+		return false, nil
+	}
+
+	existing, err := decodeDoc(raw)
+	if err != nil {
+		return false, err
+	} else if existing.Revision != revision {
+		return false, nil
+	}
+
+	if trash {
+		trashed, err := bson.Marshal(doc)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to encode definition")
+		}
+
+		s.trash = append(s.trash, memTrashed{
+			ConfigHash: doc.ConfigHash,
+			DeletedAt:  time.Now().UTC(),
+			DeletedBy:  principal,
+			Document:   trashed,
+		})
+	}
+	delete(s.docs, string(doc.ConfigHash))
+
+	return true, nil
+}
+
+func (s *memStore) restore(ctx context.Context, configHash []byte) (definitionDoc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The trash is in order of deletion, so the latest deletion is last.
+	for i := len(s.trash) - 1; i >= 0; i-- {
+		if !bytes.Equal(s.trash[i].ConfigHash, configHash) {
+			continue
+		}
+
+		doc, err := decodeDoc(s.trash[i].Document)
+		if err != nil {
+			return definitionDoc{}, err
+		} else if doc.Tenant != tenantFromCtx(ctx) {
+			break
+		} else if _, ok := s.docs[string(configHash)]; ok {
+			return definitionDoc{}, errors.Wrap(ErrConflict, "definition already exists")
+		}
+
+		s.docs[string(configHash)] = s.trash[i].Document
+		s.trash = append(s.trash[:i], s.trash[i+1:]...)
+
+		return doc, nil
+	}
+
+	return definitionDoc{}, errors.Wrap(ErrNotFound, "deleted definition not found")
+}
+
+func (s *memStore) insertEvent(_ context.Context, event eventDoc) error {
+	raw, err := bson.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode definition event")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, raw)
+
+	return nil
+}
+
+// findEventDocs returns the events of the context's tenant with the config hash in order of insertion, it must be
+// called while holding the lock.
+func (s *memStore) findEventDocs(ctx context.Context, configHash []byte) ([]eventDoc, error) {
+	var resp []eventDoc
+	for _, raw := range s.events {
+		var event eventDoc
+		if err := bson.Unmarshal(raw, &event); err != nil {
+			return nil, errors.Wrap(err, "failed to decode definition event")
+		} else if !bytes.Equal(event.ConfigHash, configHash) || event.Tenant != tenantFromCtx(ctx) {
+			continue
+		}
+		resp = append(resp, event)
+	}
+
+	return resp, nil
+}
+
+func (s *memStore) findEvents(ctx context.Context, configHash []byte) ([]eventDoc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events, err := s.findEventDocs(ctx, configHash)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range events {
+		events[i].Document = nil
+	}
+
+	return events, nil
+}
+
+func (s *memStore) findRevision(ctx context.Context, configHash []byte, revision int) (definitionDoc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events, err := s.findEventDocs(ctx, configHash)
+	if err != nil {
+		return definitionDoc{}, err
+	}
+
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Revision == revision && events[i].Document != nil {
+			return *events[i].Document, nil
+		}
+	}
+
+	return definitionDoc{}, errors.Wrap(ErrNotFound, "definition revision not found", z.Int("revision", revision))
+}
+
+// watch blocks until the context is cancelled since the in-memory store isn't shared by replicas.
+func (*memStore) watch(ctx context.Context, opened func(), _ func(string, []byte)) error {
+	opened()
+	<-ctx.Done()
+
+	return ctx.Err()
+}
+
+// transact calls fn directly since the in-memory store doesn't support transactions.
+func (*memStore) transact(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// NewMemTemplate returns a new empty in-memory template service.
+func NewMemTemplate() Template {
+	return &memTemplate{
+		tmpls: make(map[string]TemplateDoc),
+	}
+}
+
+// memTemplate is an in-memory Template.
+type memTemplate struct {
+	mu    sync.Mutex
+	tmpls map[string]TemplateDoc // By ID.
+}
+
+func (t *memTemplate) Get(_ context.Context, id string) (TemplateDoc, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tmpl, ok := t.tmpls[id]
+	if !ok {
+		return TemplateDoc{}, errors.Wrap(ErrNotFound, "template not found")
+	}
+
+	return tmpl, nil
+}
+
+func (t *memTemplate) List(context.Context) (*Iterator, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var ids []string
+	for id := range t.tmpls {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids) // Object IDs are ordered by creation time.
+
+	var values []interface{}
+	for _, id := range ids {
+		values = append(values, t.tmpls[id])
+	}
+
+	return newSliceIterator(values), nil
+}
+
+func (t *memTemplate) Create(_ context.Context, tmpl TemplateDoc) (TemplateDoc, error) {
+	if err := verifyTemplate(tmpl); err != nil {
+		return TemplateDoc{}, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tmpl.ID = primitive.NewObjectID().Hex()
+	t.tmpls[tmpl.ID] = tmpl
+
+	return tmpl, nil
+}
+
+func (t *memTemplate) Update(_ context.Context, tmpl TemplateDoc) error {
+	if err := verifyTemplate(tmpl); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.tmpls[tmpl.ID]; !ok {
+		return errors.Wrap(ErrNotFound, "template not found")
+	}
+	t.tmpls[tmpl.ID] = tmpl
+
+	return nil
+}
+
+func (t *memTemplate) Delete(_ context.Context, id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.tmpls[id]; !ok {
+		return errors.Wrap(ErrNotFound, "template not found")
+	}
+	delete(t.tmpls, id)
+
+	return nil
+}
+
+func (t *memTemplate) Stamp(ctx context.Context, id string, fields StampFields) (cluster.Definition, error) {
+	tmpl, err := t.Get(ctx, id)
+	if err != nil {
+		return cluster.Definition{}, err
+	}
+
+	return stamp(tmpl, fields)
+}
+
+// memHealth is the Health of the in-memory storage, which is always ready.
+type memHealth struct{}
+
+func (memHealth) Ready(context.Context) error {
+	return nil
+}
+
+func (memHealth) Host(context.Context) (string, error) {
+	return "memory", nil
+}
+
+func (memHealth) ReplicaSet(context.Context) (ReplicaSet, error) {
+	return ReplicaSet{}, errors.Wrap(errMemUnsupported, "in-memory storage has no replica set")
+}
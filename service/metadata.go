@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"net/url"
+)
+
+const (
+	// maxDashboardURLLen is the maximum length of metadata dashboard URLs.
+	maxDashboardURLLen = 2048
+	// maxDescriptionLen is the maximum length of metadata descriptions.
+	maxDescriptionLen = 1024
+)
+
+// Metadata is the creator-editable metadata of a definition. It isn't part of the definition,
+// so editing it doesn't change the config hash.
+type Metadata struct {
+	// DashboardURL is the URL of the cluster's monitoring dashboard.
+	DashboardURL string `json:"dashboard_url,omitempty" bson:"dashboard_url,omitempty"`
+	Description  string `json:"description,omitempty" bson:"description,omitempty"`
+}
+
+// MetadataPatch is a partial update of the metadata. Nil fields are unchanged, empty fields are cleared.
+type MetadataPatch struct {
+	DashboardURL *string `json:"dashboard_url"`
+	Description  *string `json:"description"`
+}
+
+// apply returns the metadata with the patch applied.
+func (p MetadataPatch) apply(meta Metadata) Metadata {
+	if p.DashboardURL != nil {
+		meta.DashboardURL = *p.DashboardURL
+	}
+	if p.Description != nil {
+		meta.Description = *p.Description
+	}
+
+	return meta
+}
+
+// verifyMetadata returns ErrInvalidRequest if the metadata is invalid.
+func verifyMetadata(meta Metadata) error {
+	if meta.DashboardURL != "" {
+		u, err := url.Parse(meta.DashboardURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return errors.Wrap(ErrInvalidRequest, "invalid dashboard url, expected http(s) url", z.Str("url", meta.DashboardURL))
+		} else if len(meta.DashboardURL) > maxDashboardURLLen {
+			return errors.Wrap(ErrInvalidRequest, "dashboard url too long", z.Int("max", maxDashboardURLLen))
+		}
+	}
+
+	if len(meta.Description) > maxDescriptionLen {
+		return errors.Wrap(ErrInvalidRequest, "description too long", z.Int("max", maxDescriptionLen))
+	}
+
+	return nil
+}
+
+// operatorSigned returns true if any operator of the definition joined or signed it.
+func operatorSigned(def cluster.Definition) bool {
+	for _, op := range def.Operators {
+		if op.ENR != "" || len(op.ConfigSignature) > 0 || len(op.ENRSignature) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d definitionImpl) UpdateMetadata(ctx context.Context, configHash []byte, patch MetadataPatch, auth RequestAuth) (Metadata, error) {
+	var meta Metadata
+	err := d.update(ctx, configHash, EventMetadataUpdated, func(doc *definitionDoc) error {
+		if doc.Status != StatusUnpublished && doc.Status != StatusDraft {
+			return errors.Wrap(ErrInvalidState, "definition metadata not editable", z.Str("status", string(doc.Status)))
+		} else if operatorSigned(doc.Definition) {
+			return errors.Wrap(ErrInvalidState, "definition metadata not editable after operators signed")
+		}
+
+		if doc.Owner == "" {
+			return errors.Wrap(ErrInvalidState, "definition has no creator to authorize metadata updates")
+		} else if err := d.verifyRequest(doc, doc.Owner, actionUpdateMetadata, metadataPayload(patch), auth); err != nil {
+			return err
+		}
+
+		// The invariant hashed fields must remain valid, unpublished definitions are only validated when published.
+		if doc.Status != StatusUnpublished {
+			if err := doc.Definition.VerifyHashes(); err != nil {
+				return errors.Wrap(ErrInvalidState, "invalid definition hashes", z.Err(err))
+			}
+		}
+
+		var existing Metadata
+		if doc.Metadata != nil {
+			existing = *doc.Metadata
+		}
+
+		meta = patch.apply(existing)
+		if err := verifyMetadata(meta); err != nil {
+			return err
+		}
+		doc.Metadata = &meta
+
+		return nil
+	})
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	return meta, nil
+}
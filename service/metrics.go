@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/promauto"
+	"github.com/obolnetwork/charon/cluster"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+var (
+	dbTimeouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dvstore",
+		Subsystem: "service",
+		Name:      "db_timeout_total",
+		Help:      "The total number of mongo operations exceeding their deadline by operation",
+	}, []string{"op"})
+
+	dbRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dvstore",
+		Subsystem: "service",
+		Name:      "db_retry_total",
+		Help:      "The total number of mongo operations retried after transient errors by operation",
+	}, []string{"op"})
+
+	dbLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "dvstore",
+		Subsystem: "service",
+		Name:      "db_command_latency_seconds",
+		Help:      "The mongo command latencies in seconds by command",
+	}, []string{"command"})
+
+	alarmGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dvstore",
+		Subsystem: "service",
+		Name:      "alarm_firing",
+		Help:      "Whether the alarm is firing by alarm name",
+	}, []string{"alarm"})
+
+	ownersNearQuota = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "dvstore",
+		Subsystem: "service",
+		Name:      "owners_near_quota",
+		Help:      "The number of owners whose definition count exceeds the quota warn ratio",
+	})
+
+	storageBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "dvstore",
+		Subsystem: "service",
+		Name:      "definitions_storage_bytes",
+		Help:      "The storage size of the definitions collection in bytes",
+	})
+
+	expiredDefinitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dvstore",
+		Subsystem: "service",
+		Name:      "definitions_expired_total",
+		Help:      "The total number of definitions deleted for exceeding the definition TTL by status",
+	}, []string{"status"})
+
+	tenantDefinitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dvstore",
+		Subsystem: "service",
+		Name:      "tenant_definitions_created_total",
+		Help:      "The total number of definitions created by tenant",
+	}, []string{"tenant"})
+
+	expiringDrafts = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "dvstore",
+		Subsystem: "service",
+		Name:      "expiring_drafts",
+		Help:      "The number of drafts expiring within the expiry warning period",
+	})
+
+	definitionsDeleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dvstore",
+		Subsystem: "service",
+		Name:      "definitions_deleted_total",
+		Help:      "The total number of definitions deleted on request by status",
+	}, []string{"status"})
+
+	completionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "dvstore",
+		Subsystem: "service",
+		Name:      "completion_duration_seconds",
+		Help:      "The durations in seconds from definition creation until all operators joined by cluster type",
+		Buckets:   prometheus.ExponentialBuckets(60, 4, 9), // 1 minute to 45 days.
+	}, []string{"type"})
+
+	pendingOperators = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dvstore",
+		Subsystem: "service",
+		Name:      "pending_operators",
+		Help:      "The number of operators yet to join by draft config hash",
+	}, []string{"config_hash"})
+)
+
+// observeCompletion records the duration from the definition's creation until all its operators joined.
+func observeCompletion(def cluster.Definition) {
+	created, err := time.Parse(time.RFC3339, def.Timestamp)
+	if err != nil {
+		return // Older definition versions may not contain a timestamp.
+	}
+
+	completionDuration.WithLabelValues(string(clusterType(def))).Observe(time.Since(created).Seconds())
+}
+
+func (a *adminImpl) SamplePendingOperators(ctx context.Context) error {
+	cursor, err := a.table.Find(ctx, bson.D{{"status", StatusDraft}},
+		options.Find().SetProjection(bson.D{{"config_hash", 1}, {"definition.operators", 1}}))
+	if err != nil {
+		return errors.Wrap(err, "failed to find drafts")
+	}
+	defer cursor.Close(ctx)
+
+	pending := make(map[string]int)
+	for cursor.Next(ctx) {
+		var doc definitionDoc
+		if err := cursor.Decode(&doc); err != nil {
+			return errors.Wrap(err, "failed to decode draft")
+		}
+
+		var count int
+		for _, op := range doc.Definition.Operators {
+			if op.ENR == "" {
+				count++
+			}
+		}
+		if count > 0 {
+			pending[fmt.Sprintf("%#x", doc.ConfigHash)] = count
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return errors.Wrap(err, "failed to iterate drafts")
+	}
+
+	// Reset so that completed, cancelled and deleted drafts are removed rather than reporting stale counts.
+	pendingOperators.Reset()
+	for configHash, count := range pending {
+		pendingOperators.WithLabelValues(configHash).Set(float64(count))
+	}
+
+	return nil
+}
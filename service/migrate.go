@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const (
+	// migrationsCollection is the name of the collection recording the applied migrations.
+	migrationsCollection = "migrations"
+	// deadLetterTTL is the age after which undeliverable notifications are removed.
+	deadLetterTTL = 30 * 24 * time.Hour
+)
+
+// Migration is a versioned schema change of the database.
+type Migration struct {
+	Version   int       `json:"version" bson:"_id"`
+	Name      string    `json:"name" bson:"name"`
+	AppliedAt time.Time `json:"applied_at,omitempty" bson:"applied_at"`
+
+	up func(ctx context.Context, db *mongo.Database) error
+}
+
+// migrations are the schema changes in the order they are applied. Migrations must be idempotent since
+// instances starting concurrently may apply the same migration. Append new migrations, never modify applied ones.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_indexes",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			return CreateIndexes(ctx, db.Collection(definitionsCollection))
+		},
+	},
+	{
+		Version: 2,
+		Name:    "unique_config_hash",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(definitionsCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{"config_hash", 1}},
+				Options: options.Index().SetUnique(true),
+			})
+			if err != nil {
+				return errors.Wrap(err, "failed to create unique config hash index, delete duplicate definitions first")
+			}
+
+			return nil
+		},
+	},
+	{
+		Version: 3,
+		Name:    "dead_letter_ttl",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("dead_letters").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{"created_at", 1}},
+				Options: options.Index().SetExpireAfterSeconds(int32(deadLetterTTL.Seconds())),
+			})
+			if err != nil {
+				return errors.Wrap(err, "failed to create dead letter ttl index")
+			}
+
+			return nil
+		},
+	},
+	{
+		Version: 4,
+		Name:    "wrap_definitions",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			return wrapDefinitions(ctx, db.Collection(definitionsCollection))
+		},
+	},
+}
+
+// wrapDefinitions wraps the flat cluster definitions stored before definitions had a ceremony status in definition
+// documents. They are migrated as draft definitions, since operators may still be joining.
+func wrapDefinitions(ctx context.Context, table *mongo.Collection) error {
+	flat := bson.D{{"definition", bson.D{{"$exists", false}}}}
+
+	cursor, err := table.Find(ctx, flat)
+	if err != nil {
+		return errors.Wrap(err, "failed to find flat definitions")
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var def cluster.Definition
+		if err := cursor.Decode(&def); err != nil {
+			return errors.Wrap(err, "failed to decode flat definition")
+		}
+
+		doc := definitionDoc{
+			ConfigHash: def.ConfigHash,
+			Status:     StatusDraft,
+			Version:    def.Version,
+			Type:       clusterType(def),
+			Definition: def,
+			UpdatedAt:  modifiedNow(),
+		}
+		if def.Creator.Address != "" && verifyCreatorSignature(def) == nil {
+			doc.Owner = def.Creator.Address
+		}
+
+		// Only replace the flat document, i.e., not if concurrently migrated by another instance.
+		filter := append(bson.D{{"_id", cursor.Current.Lookup("_id")}}, flat...)
+		if _, err := table.ReplaceOne(ctx, filter, doc); err != nil {
+			return errors.Wrap(err, "failed to wrap definition", z.Hex("config_hash", def.ConfigHash))
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return errors.Wrap(err, "failed to iterate flat definitions")
+	}
+
+	return nil
+}
+
+// PendingMigrations returns the migrations not yet applied to the database in the order they are applied.
+func PendingMigrations(ctx context.Context, db *mongo.Database) ([]Migration, error) {
+	cursor, err := db.Collection(migrationsCollection).Find(ctx, bson.D{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find applied migrations")
+	}
+	defer cursor.Close(ctx)
+
+	applied := make(map[int]bool)
+	for cursor.Next(ctx) {
+		var m Migration
+		if err := cursor.Decode(&m); err != nil {
+			return nil, errors.Wrap(err, "failed to decode migration")
+		}
+		applied[m.Version] = true
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to iterate applied migrations")
+	}
+
+	var resp []Migration
+	for _, m := range migrations {
+		if !applied[m.Version] {
+			resp = append(resp, m)
+		}
+	}
+
+	return resp, nil
+}
+
+// Migrate applies the pending migrations to the database in order, recording each once applied,
+// and returns the applied migrations. It stops at the first failing migration.
+func Migrate(ctx context.Context, db *mongo.Database) ([]Migration, error) {
+	pending, err := PendingMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp []Migration
+	for _, m := range pending {
+		if err := m.up(ctx, db); err != nil {
+			return resp, errors.Wrap(err, "migration failed", z.Int("version", m.Version), z.Str("name", m.Name))
+		}
+
+		m.AppliedAt = time.Now().UTC()
+		_, err := db.Collection(migrationsCollection).InsertOne(ctx, m)
+		if err != nil && !mongo.IsDuplicateKeyError(err) { // Concurrently applied by another instance.
+			return resp, errors.Wrap(err, "failed to record migration", z.Int("version", m.Version))
+		}
+
+		resp = append(resp, m)
+	}
+
+	return resp, nil
+}
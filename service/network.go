@@ -0,0 +1,40 @@
+package service
+
+import (
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/eth2util"
+	"strings"
+)
+
+// verifyForkVersion returns an error if the fork version does not map to a known network,
+// or to one of the allowed networks if configured.
+func (d definitionImpl) verifyForkVersion(forkVersion []byte) error {
+	network, err := eth2util.ForkVersionToNetwork(forkVersion)
+	if err != nil {
+		return errors.Wrap(ErrInvalidRequest, "unsupported fork version", z.Hex("fork_version", forkVersion))
+	} else if len(d.conf.Networks) == 0 {
+		return nil
+	}
+
+	for _, allowed := range d.conf.Networks {
+		if network == allowed {
+			return nil
+		}
+	}
+
+	return errors.Wrap(ErrInvalidRequest, fmt.Sprintf("network %s not supported, supported networks [%s]",
+		network, strings.Join(d.conf.Networks, ", ")))
+}
+
+// VerifyNetworks returns an error if any of the networks is unknown.
+func VerifyNetworks(networks []string) error {
+	for _, network := range networks {
+		if _, err := eth2util.NetworkToForkVersion(network); err != nil {
+			return errors.Wrap(err, "unknown network", z.Str("network", network))
+		}
+	}
+
+	return nil
+}
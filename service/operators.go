@@ -0,0 +1,115 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"strings"
+)
+
+// verifyUniqueOperators returns an error listing the duplicate operator addresses and ENRs of the definition,
+// since the DKG ceremony of such definitions will fail. Solo clusters are operated by a single address
+// so only their ENRs must be unique.
+func verifyUniqueOperators(def cluster.Definition) error {
+	var (
+		dups      []string
+		addresses = make(map[string]int)
+		enrs      = make(map[string]int)
+		solo      = clusterType(def) == ClusterTypeSolo
+	)
+	for _, op := range def.Operators {
+		if op.Address != "" && !solo {
+			addresses[strings.ToLower(op.Address)]++
+			if addresses[strings.ToLower(op.Address)] == 2 {
+				dups = append(dups, op.Address)
+			}
+		}
+
+		if op.ENR != "" {
+			enrs[op.ENR]++
+			if enrs[op.ENR] == 2 {
+				dups = append(dups, op.ENR)
+			}
+		}
+	}
+
+	if len(dups) > 0 {
+		return errors.Wrap(ErrInvalidRequest, fmt.Sprintf("duplicate operators [%s]", strings.Join(dups, ", ")))
+	}
+
+	return nil
+}
+
+func (d definitionImpl) RemoveOperator(ctx context.Context, configHash []byte, address string, auth RequestAuth) ([]byte, error) {
+	var removed []byte
+	err := d.update(ctx, configHash, EventOperatorRemoved, func(doc *definitionDoc) error {
+		if doc.Status != StatusUnpublished && doc.Status != StatusDraft {
+			return errors.Wrap(ErrInvalidState, "operators can only be removed before finalization", z.Str("status", string(doc.Status)))
+		}
+
+		if doc.Owner == "" {
+			return errors.Wrap(ErrInvalidState, "definition has no creator to authorize operator removal")
+		} else if err := d.verifyRequest(doc, doc.Owner, actionRemoveOperator, addressPayload(address), auth); err != nil {
+			return err
+		}
+
+		idx, ok := operatorIndex(doc.Definition, address)
+		if !ok {
+			return errors.Wrap(ErrInvalidRequest, "operator not in definition", z.Str("address", address))
+		}
+
+		// Removing an operator changes the config hash, invalidating the creator's and all operators' signatures.
+		def := doc.Definition
+		def.Operators = nil
+		for i, op := range doc.Definition.Operators {
+			if i != idx {
+				def.Operators = append(def.Operators, cluster.Operator{Address: op.Address})
+			}
+		}
+		def.Creator.ConfigSignature = nil
+
+		if err := verifyInvariants(def); err != nil {
+			return err
+		} else if err := d.verifyThreshold(def); err != nil {
+			return err
+		}
+
+		def, err := def.SetDefinitionHashes()
+		if err != nil {
+			return errors.Wrap(err, "failed to set definition hashes")
+		}
+
+		if !bytes.Equal(def.ConfigHash, configHash) {
+			if _, err := d.getDoc(ctx, def.ConfigHash); err == nil {
+				return errors.Wrap(ErrConflict, "definition without operator already exists", z.Hex("config_hash", def.ConfigHash))
+			} else if !errors.Is(err, ErrNotFound) {
+				return err
+			}
+		}
+
+		if len(doc.InviteTokens) > 0 {
+			// Invite tokens remain bound to the remaining operators' slots, and may be used to join again.
+			doc.InviteTokens = append(doc.InviteTokens[:idx:idx], doc.InviteTokens[idx+1:]...)
+			for i := range doc.InviteTokens {
+				doc.InviteTokens[i].Used = false
+			}
+		}
+
+		doc.ConfigHash = def.ConfigHash
+		doc.Type = clusterType(def)
+		doc.Definition = def
+		doc.Declined = nil
+		doc.Status = StatusUnpublished
+		removed = def.ConfigHash
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return removed, nil
+}
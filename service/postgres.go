@@ -0,0 +1,798 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"time"
+)
+
+// errPGUnsupported is returned by functionality not supported by the postgres storage.
+var errPGUnsupported = errors.Wrap(ErrUnsupported, "not supported by postgres storage")
+
+// pgSchema creates the postgres tables if they don't exist. Definition documents are stored bson encoded like in
+// mongo next to the columns they are queried by, validators reference the definitions locking them.
+const pgSchema = `
+CREATE TABLE IF NOT EXISTS definitions (
+	config_hash BYTEA PRIMARY KEY,
+	tenant      TEXT NOT NULL,
+	owner       TEXT NOT NULL,
+	status      TEXT NOT NULL,
+	revision    INTEGER NOT NULL,
+	parent      BYTEA,
+	doc         BYTEA NOT NULL
+);
+CREATE INDEX IF NOT EXISTS definitions_tenant ON definitions (tenant, config_hash);
+CREATE INDEX IF NOT EXISTS definitions_owner ON definitions (owner);
+CREATE INDEX IF NOT EXISTS definitions_parent ON definitions (parent);
+CREATE TABLE IF NOT EXISTS validators (
+	pubkey      BYTEA NOT NULL,
+	config_hash BYTEA NOT NULL REFERENCES definitions ON DELETE CASCADE ON UPDATE CASCADE,
+	PRIMARY KEY (config_hash, pubkey)
+);
+CREATE INDEX IF NOT EXISTS validators_pubkey ON validators (pubkey);
+CREATE TABLE IF NOT EXISTS definitions_trash (
+	id          BIGSERIAL PRIMARY KEY,
+	config_hash BYTEA NOT NULL,
+	tenant      TEXT NOT NULL,
+	deleted_at  TIMESTAMPTZ NOT NULL,
+	deleted_by  TEXT NOT NULL,
+	doc         BYTEA NOT NULL
+);
+CREATE INDEX IF NOT EXISTS definitions_trash_config_hash ON definitions_trash (config_hash, deleted_at);
+CREATE TABLE IF NOT EXISTS definition_events (
+	id          BIGSERIAL PRIMARY KEY,
+	config_hash BYTEA NOT NULL,
+	tenant      TEXT NOT NULL,
+	revision    INTEGER NOT NULL,
+	event       BYTEA NOT NULL
+);
+CREATE INDEX IF NOT EXISTS definition_events_config_hash ON definition_events (config_hash, id);
+CREATE TABLE IF NOT EXISTS templates (
+	id  TEXT PRIMARY KEY,
+	doc JSONB NOT NULL
+);`
+
+// pgWatchPeriod is the period the postgres store polls for events appended by other replicas.
+const pgWatchPeriod = time.Second
+
+// pgWatchOverlap is the number of previously polled event ids polled again, since ids of concurrently committed
+// events may become visible out of order. Evicting cached definitions again is harmless.
+const pgWatchOverlap = 1000
+
+// MigratePostgres creates the postgres tables if they don't exist.
+func MigratePostgres(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, pgSchema); err != nil {
+		return errors.Wrap(err, "failed to create postgres tables")
+	}
+
+	return nil
+}
+
+// pgConn is a postgres connection or transaction.
+type pgConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// pgTx calls the function in a transaction, committing it if the function succeeds and rolling it back otherwise.
+func pgTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapDBErr(err, opUpdate, "failed to begin postgres transaction")
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return wrapDBErr(err, opUpdate, "failed to commit postgres transaction")
+	}
+
+	return nil
+}
+
+// pgTxKey is the context key of the postgres transaction of pgStore.transact.
+type pgTxKey struct{}
+
+// newPGStore returns the document store of the postgres database, its tables must be created via MigratePostgres.
+func newPGStore(db *sql.DB, timeouts DBTimeouts) pgStore {
+	return pgStore{db: db, timeouts: timeouts}
+}
+
+// pgStore is the postgres docStore, storing definitions in the definitions table, their change log in the
+// definition events table and deleted definitions in the definitions trash table.
+type pgStore struct {
+	db       *sql.DB
+	timeouts DBTimeouts
+}
+
+// conn returns the context's transaction or the database.
+func (s pgStore) conn(ctx context.Context) pgConn {
+	if tx, ok := ctx.Value(pgTxKey{}).(*sql.Tx); ok {
+		return tx
+	}
+
+	return s.db
+}
+
+// query returns the decoded definition documents of the query.
+func (s pgStore) query(ctx context.Context, query string, args ...interface{}) ([]definitionDoc, error) {
+	ctx, cancel := s.timeouts.withTimeout(ctx, opFind)
+	defer cancel()
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, wrapDBErr(err, opFind, "failed to query definitions")
+	}
+	defer rows.Close()
+
+	var resp []definitionDoc
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, wrapDBErr(err, opFind, "failed to scan definition")
+		}
+
+		doc, err := decodeDoc(raw)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, doc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapDBErr(err, opFind, "failed to iterate definitions")
+	}
+
+	return resp, nil
+}
+
+// queryOne returns the first decoded definition document of the query or ErrNotFound with the message.
+func (s pgStore) queryOne(ctx context.Context, notFound string, query string, args ...interface{}) (definitionDoc, error) {
+	docs, err := s.query(ctx, query, args...)
+	if err != nil {
+		return definitionDoc{}, err
+	} else if len(docs) == 0 {
+		return definitionDoc{}, errors.Wrap(ErrNotFound, notFound)
+	}
+
+	return docs[0], nil
+}
+
+func (s pgStore) find(ctx context.Context, configHash []byte) (definitionDoc, error) {
+	return s.queryOne(ctx, "definition not found",
+		`SELECT doc FROM definitions WHERE config_hash = $1 AND tenant = $2`, configHash, tenantFromCtx(ctx))
+}
+
+func (s pgStore) findMany(ctx context.Context, configHashes [][]byte) ([]definitionDoc, error) {
+	return s.query(ctx, `SELECT doc FROM definitions WHERE config_hash = ANY($1) AND tenant = $2 AND status <> $3`,
+		pq.ByteaArray(configHashes), tenantFromCtx(ctx), string(StatusUnpublished))
+}
+
+func (s pgStore) findRange(ctx context.Context, from, to []byte, limit int) ([]definitionDoc, error) {
+	return s.query(ctx, `SELECT doc FROM definitions WHERE config_hash BETWEEN $1 AND $2 AND tenant = $3 AND status <> $4 `+
+		`ORDER BY config_hash LIMIT $5`, from, to, tenantFromCtx(ctx), string(StatusUnpublished), limit)
+}
+
+func (s pgStore) findValidator(ctx context.Context, pubkey []byte) (definitionDoc, error) {
+	return s.queryOne(ctx, "validator not found",
+		`SELECT d.doc FROM validators v JOIN definitions d USING (config_hash) WHERE v.pubkey = $1 AND d.tenant = $2 `+
+			`ORDER BY config_hash LIMIT 1`, pubkey, tenantFromCtx(ctx))
+}
+
+func (s pgStore) findChildren(ctx context.Context, parent []byte) ([][]byte, error) {
+	docs, err := s.query(ctx, `SELECT doc FROM definitions WHERE parent = $1 AND tenant = $2`, parent, tenantFromCtx(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp [][]byte
+	for _, doc := range docs {
+		resp = append(resp, doc.ConfigHash)
+	}
+
+	return resp, nil
+}
+
+func (s pgStore) list(ctx context.Context, filter ListFilter, after []byte, limit int) ([]definitionDoc, error) {
+	args := []interface{}{after, tenantFromCtx(ctx), string(StatusUnpublished)}
+	query := `SELECT doc FROM definitions WHERE config_hash > $1 AND tenant = $2 AND status <> $3`
+	if filter.Status != "" {
+		args = append(args, string(filter.Status))
+		query += ` AND status = $4`
+	}
+	query += ` ORDER BY config_hash`
+
+	// The remaining filters match the decoded documents.
+	docs, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp []definitionDoc
+	for _, doc := range docs {
+		if len(resp) == limit {
+			break
+		} else if filter.matches(doc) {
+			resp = append(resp, doc)
+		}
+	}
+
+	return resp, nil
+}
+
+func (s pgStore) validators(ctx context.Context, configHash []byte, offset, limit int) (ValidatorPage, error) {
+	doc, err := s.find(ctx, configHash)
+	if err != nil {
+		return ValidatorPage{}, err
+	} else if doc.Lock == nil {
+		return ValidatorPage{}, errors.Wrap(ErrNotFound, "lock not found")
+	}
+
+	return validatorPage(doc.Lock.Validators, offset, limit), nil
+}
+
+func (s pgStore) count(ctx context.Context, filter countFilter) (int, error) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	if filter.Owner != "" {
+		args = append(args, strings.ToLower(filter.Owner))
+		conds = append(conds, `owner = $1`)
+	}
+	if filter.Tenant != "" {
+		args = append(args, filter.Tenant)
+		conds = append(conds, fmt.Sprintf(`tenant = $%d`, len(args)))
+	}
+	if len(filter.Statuses) > 0 {
+		var statuses []string
+		for _, status := range filter.Statuses {
+			statuses = append(statuses, string(status))
+		}
+		args = append(args, pq.StringArray(statuses))
+		conds = append(conds, fmt.Sprintf(`status = ANY($%d)`, len(args)))
+	}
+
+	query := `SELECT count(*) FROM definitions`
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, ` AND `)
+	}
+
+	ctx, cancel := s.timeouts.withTimeout(ctx, opFind)
+	defer cancel()
+
+	var count int
+	if err := s.conn(ctx).QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, wrapDBErr(err, opFind, "failed to count definitions")
+	}
+
+	return count, nil
+}
+
+func (s pgStore) stats(ctx context.Context, filter StatsFilter) (Stats, error) {
+	docs, err := s.query(ctx, `SELECT doc FROM definitions WHERE tenant = $1`, tenantFromCtx(ctx))
+	if err != nil {
+		return Stats{}, err
+	}
+
+	resp := Stats{
+		ByType:   make(map[ClusterType]int),
+		ByStatus: make(map[Status]int),
+	}
+	for _, doc := range docs {
+		resp.add(doc, filter)
+	}
+
+	return resp, nil
+}
+
+// put inserts the document or updates the row of the config hash with it, including its validators.
+// It returns errDuplicate if inserting a config hash that exists.
+func (s pgStore) put(ctx context.Context, conn pgConn, configHash []byte, doc definitionDoc, insert bool) error {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode definition")
+	}
+
+	args := []interface{}{doc.ConfigHash, doc.Tenant, strings.ToLower(doc.Owner), string(doc.Status), doc.Revision,
+		doc.Parent, raw}
+	if insert {
+		res, err := conn.ExecContext(ctx, `INSERT INTO definitions (config_hash, tenant, owner, status, revision, parent, doc) `+
+			`VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (config_hash) DO NOTHING`, args...)
+		if err != nil {
+			return wrapDBErr(err, opInsert, "failed to insert definition")
+		} else if n, err := res.RowsAffected(); err != nil {
+			return wrapDBErr(err, opInsert, "failed to insert definition")
+		} else if n == 0 {
+			return errDuplicate
+		}
+	} else {
+		_, err := conn.ExecContext(ctx, `UPDATE definitions SET config_hash = $1, tenant = $2, owner = $3, status = $4, `+
+			`revision = $5, parent = $6, doc = $7 WHERE config_hash = $8`, append(args, configHash)...)
+		if err != nil {
+			return wrapDBErr(err, opUpdate, "failed to update definition")
+		}
+	}
+
+	if doc.Lock == nil {
+		return nil
+	}
+
+	var pubkeys [][]byte
+	for _, val := range doc.Lock.Validators {
+		pubkeys = append(pubkeys, val.PubKey)
+	}
+
+	_, err = conn.ExecContext(ctx, `INSERT INTO validators (pubkey, config_hash) SELECT unnest($1::bytea[]), $2 ON CONFLICT DO NOTHING`,
+		pq.ByteaArray(pubkeys), doc.ConfigHash)
+	if err != nil {
+		return wrapDBErr(err, opUpdate, "failed to insert validators")
+	}
+
+	return nil
+}
+
+func (s pgStore) insert(ctx context.Context, doc *definitionDoc) error {
+	ctx, cancel := s.timeouts.withTimeout(ctx, opInsert)
+	defer cancel()
+
+	return s.transact(ctx, func(ctx context.Context) error {
+		return s.put(ctx, s.conn(ctx), doc.ConfigHash, *doc, true)
+	})
+}
+
+func (s pgStore) insertMany(ctx context.Context, docs []*definitionDoc) ([]error, error) {
+	resp := make([]error, len(docs))
+	for i, doc := range docs {
+		resp[i] = s.insert(ctx, doc)
+	}
+
+	return resp, nil
+}
+
+func (s pgStore) replace(ctx context.Context, configHash []byte, revision int, doc *definitionDoc) (bool, error) {
+	ctx, cancel := s.timeouts.withTimeout(ctx, opUpdate)
+	defer cancel()
+
+	var matched bool
+	err := s.transact(ctx, func(ctx context.Context) error {
+		conn := s.conn(ctx)
+
+		var stored int
+		err := conn.QueryRowContext(ctx, `SELECT revision FROM definitions WHERE config_hash = $1 FOR UPDATE`, configHash).Scan(&stored)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		} else if err != nil {
+			return wrapDBErr(err, opUpdate, "failed to get definition")
+		} else if stored != revision {
+			return nil
+		}
+		matched = true
+
+		return s.put(ctx, conn, configHash, *doc, false)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return matched, nil
+}
+
+func (s pgStore) setRef(ctx context.Context, configHash []byte, field string, value string) error {
+	return s.transact(ctx, func(ctx context.Context) error {
+		conn := s.conn(ctx)
+
+		var raw []byte
+		err := conn.QueryRowContext(ctx, `SELECT doc FROM definitions WHERE config_hash = $1 FOR UPDATE`, configHash).Scan(&raw)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil // Concurrently deleted.
+		} else if err != nil {
+			return wrapDBErr(err, opUpdate, "failed to get definition")
+		}
+
+		doc, err := decodeDoc(raw)
+		if err != nil {
+			return err
+		} else if err := doc.setRef(field, value); err != nil {
+			return err
+		}
+
+		raw, err = bson.Marshal(doc)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode definition")
+		}
+
+		if _, err := conn.ExecContext(ctx, `UPDATE definitions SET doc = $2 WHERE config_hash = $1`, configHash, raw); err != nil {
+			return wrapDBErr(err, opUpdate, "failed to store reference", z.Str("field", field))
+		}
+
+		return nil
+	})
+}
+
+func (s pgStore) remove(ctx context.Context, doc definitionDoc, revision int, principal string, trash bool) (bool, error) {
+	ctx, cancel := s.timeouts.withTimeout(ctx, opUpdate)
+	defer cancel()
+
+	var removed bool
+	err := s.transact(ctx, func(ctx context.Context) error {
+		conn := s.conn(ctx)
+
+		// Validators are deleted with their definition.
+		res, err := conn.ExecContext(ctx, `DELETE FROM definitions WHERE config_hash = $1 AND revision = $2`, doc.ConfigHash, revision)
+		if err != nil {
+			return wrapDBErr(err, opUpdate, "failed to delete definition")
+		} else if n, err := res.RowsAffected(); err != nil {
+			return wrapDBErr(err, opUpdate, "failed to delete definition")
+		} else if n == 0 || !trash {
+			removed = n > 0
+			return nil
+		}
+		removed = true
+
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode definition")
+		}
+
+		_, err = conn.ExecContext(ctx, `INSERT INTO definitions_trash (config_hash, tenant, deleted_at, deleted_by, doc) `+
+			`VALUES ($1, $2, now(), $3, $4)`, doc.ConfigHash, doc.Tenant, principal, raw)
+		if err != nil {
+			return wrapDBErr(err, opInsert, "failed to trash definition")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return removed, nil
+}
+
+func (s pgStore) restore(ctx context.Context, configHash []byte) (definitionDoc, error) {
+	ctx, cancel := s.timeouts.withTimeout(ctx, opUpdate)
+	defer cancel()
+
+	var doc definitionDoc
+	err := s.transact(ctx, func(ctx context.Context) error {
+		conn := s.conn(ctx)
+
+		var (
+			id  int64
+			raw []byte
+		)
+		err := conn.QueryRowContext(ctx, `SELECT id, doc FROM definitions_trash WHERE config_hash = $1 AND tenant = $2 `+
+			`ORDER BY deleted_at DESC LIMIT 1 FOR UPDATE`, configHash, tenantFromCtx(ctx)).Scan(&id, &raw)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.Wrap(ErrNotFound, "deleted definition not found")
+		} else if err != nil {
+			return wrapDBErr(err, opFind, "failed to get deleted definition")
+		}
+
+		doc, err = decodeDoc(raw)
+		if err != nil {
+			return err
+		}
+
+		if err := s.put(ctx, conn, configHash, doc, true); errors.Is(err, errDuplicate) {
+			return errors.Wrap(ErrConflict, "definition already exists")
+		} else if err != nil {
+			return err
+		}
+
+		if _, err := conn.ExecContext(ctx, `DELETE FROM definitions_trash WHERE id = $1`, id); err != nil {
+			return wrapDBErr(err, opUpdate, "failed to remove restored definition from trash")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return definitionDoc{}, err
+	}
+
+	return doc, nil
+}
+
+func (s pgStore) insertEvent(ctx context.Context, event eventDoc) error {
+	raw, err := bson.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode definition event")
+	}
+
+	ctx, cancel := s.timeouts.withTimeout(ctx, opInsert)
+	defer cancel()
+
+	_, err = s.conn(ctx).ExecContext(ctx, `INSERT INTO definition_events (config_hash, tenant, revision, event) VALUES ($1, $2, $3, $4)`,
+		event.ConfigHash, event.Tenant, event.Revision, raw)
+	if err != nil {
+		return wrapDBErr(err, opInsert, "failed to insert definition event")
+	}
+
+	return nil
+}
+
+// queryEvents returns the decoded events of the query.
+func (s pgStore) queryEvents(ctx context.Context, query string, args ...interface{}) ([]eventDoc, error) {
+	ctx, cancel := s.timeouts.withTimeout(ctx, opFind)
+	defer cancel()
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, wrapDBErr(err, opFind, "failed to find events")
+	}
+	defer rows.Close()
+
+	var resp []eventDoc
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, wrapDBErr(err, opFind, "failed to scan event")
+		}
+
+		var event eventDoc
+		if err := bson.Unmarshal(raw, &event); err != nil {
+			return nil, errors.Wrap(err, "failed to decode definition event")
+		}
+		resp = append(resp, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapDBErr(err, opFind, "failed to iterate events")
+	}
+
+	return resp, nil
+}
+
+func (s pgStore) findEvents(ctx context.Context, configHash []byte) ([]eventDoc, error) {
+	events, err := s.queryEvents(ctx, `SELECT event FROM definition_events WHERE config_hash = $1 AND tenant = $2 ORDER BY id`,
+		configHash, tenantFromCtx(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range events {
+		events[i].Document = nil
+	}
+
+	return events, nil
+}
+
+func (s pgStore) findRevision(ctx context.Context, configHash []byte, revision int) (definitionDoc, error) {
+	events, err := s.queryEvents(ctx, `SELECT event FROM definition_events WHERE config_hash = $1 AND tenant = $2 AND revision = $3 `+
+		`ORDER BY id DESC`, configHash, tenantFromCtx(ctx), revision)
+	if err != nil {
+		return definitionDoc{}, err
+	}
+
+	for _, event := range events {
+		if event.Document != nil {
+			return *event.Document, nil
+		}
+	}
+
+	return definitionDoc{}, errors.Wrap(ErrNotFound, "definition revision not found", z.Int("revision", revision))
+}
+
+// watch polls the definition events table for events appended by any replica.
+func (s pgStore) watch(ctx context.Context, opened func(), fn func(tenant string, configHash []byte)) error {
+	var last int64
+	if err := s.db.QueryRowContext(ctx, `SELECT coalesce(max(id), 0) FROM definition_events`).Scan(&last); err != nil {
+		return errors.Wrap(err, "failed to get latest definition event")
+	}
+
+	opened()
+
+	ticker := time.NewTicker(pgWatchPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		rows, err := s.db.QueryContext(ctx, `SELECT id, tenant, config_hash FROM definition_events WHERE id > $1 ORDER BY id`,
+			last-pgWatchOverlap)
+		if err != nil {
+			return errors.Wrap(err, "failed to poll definition events")
+		}
+
+		for rows.Next() {
+			var (
+				tenant     string
+				configHash []byte
+				id         int64
+			)
+			if err := rows.Scan(&id, &tenant, &configHash); err != nil {
+				rows.Close()
+				return errors.Wrap(err, "failed to scan definition event")
+			}
+
+			fn(tenant, configHash)
+			if id > last {
+				last = id
+			}
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return errors.Wrap(err, "failed to poll definition events")
+		}
+	}
+}
+
+// transact calls fn in a postgres transaction, unless already in one, so the definition mutation and its change log
+// event are applied atomically.
+func (s pgStore) transact(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(pgTxKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	return pgTx(ctx, s.db, func(tx *sql.Tx) error {
+		return fn(context.WithValue(ctx, pgTxKey{}, tx))
+	})
+}
+
+// NewPGTemplate returns the postgres template service of the database, its tables must be created via
+// MigratePostgres.
+func NewPGTemplate(db *sql.DB) Template {
+	return pgTemplate{db: db}
+}
+
+// pgTemplate is a postgres Template.
+type pgTemplate struct {
+	db *sql.DB
+}
+
+func (t pgTemplate) Get(ctx context.Context, id string) (TemplateDoc, error) {
+	var raw []byte
+	if err := t.db.QueryRowContext(ctx, `SELECT doc FROM templates WHERE id = $1`, id).Scan(&raw); errors.Is(err, sql.ErrNoRows) {
+		return TemplateDoc{}, errors.Wrap(ErrNotFound, "template not found")
+	} else if err != nil {
+		return TemplateDoc{}, errors.Wrap(err, "failed to get template")
+	}
+
+	var tmpl TemplateDoc
+	if err := json.Unmarshal(raw, &tmpl); err != nil {
+		return TemplateDoc{}, errors.Wrap(err, "unmarshal template")
+	}
+
+	return tmpl, nil
+}
+
+func (t pgTemplate) List(ctx context.Context) (*Iterator, error) {
+	rows, err := t.db.QueryContext(ctx, `SELECT doc FROM templates ORDER BY id`) // Object IDs are ordered by creation time.
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list templates")
+	}
+	defer rows.Close()
+
+	var values []interface{}
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, errors.Wrap(err, "failed to scan template")
+		}
+
+		var tmpl TemplateDoc
+		if err := json.Unmarshal(raw, &tmpl); err != nil {
+			return nil, errors.Wrap(err, "unmarshal template")
+		}
+		values = append(values, tmpl)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to list templates")
+	}
+
+	return newSliceIterator(values), nil
+}
+
+func (t pgTemplate) Create(ctx context.Context, tmpl TemplateDoc) (TemplateDoc, error) {
+	if err := verifyTemplate(tmpl); err != nil {
+		return TemplateDoc{}, err
+	}
+
+	tmpl.ID = primitive.NewObjectID().Hex()
+
+	raw, err := json.Marshal(tmpl)
+	if err != nil {
+		return TemplateDoc{}, errors.Wrap(err, "marshal template")
+	}
+
+	if _, err := t.db.ExecContext(ctx, `INSERT INTO templates (id, doc) VALUES ($1, $2)`, tmpl.ID, string(raw)); err != nil {
+		return TemplateDoc{}, errors.Wrap(err, "failed to create template")
+	}
+
+	return tmpl, nil
+}
+
+func (t pgTemplate) Update(ctx context.Context, tmpl TemplateDoc) error {
+	if err := verifyTemplate(tmpl); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(tmpl)
+	if err != nil {
+		return errors.Wrap(err, "marshal template")
+	}
+
+	res, err := t.db.ExecContext(ctx, `UPDATE templates SET doc = $2 WHERE id = $1`, tmpl.ID, string(raw))
+	if err != nil {
+		return errors.Wrap(err, "failed to update template")
+	}
+
+	return verifyTemplateAffected(res)
+}
+
+func (t pgTemplate) Delete(ctx context.Context, id string) error {
+	res, err := t.db.ExecContext(ctx, `DELETE FROM templates WHERE id = $1`, id)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete template")
+	}
+
+	return verifyTemplateAffected(res)
+}
+
+func (t pgTemplate) Stamp(ctx context.Context, id string, fields StampFields) (cluster.Definition, error) {
+	tmpl, err := t.Get(ctx, id)
+	if err != nil {
+		return cluster.Definition{}, err
+	}
+
+	return stamp(tmpl, fields)
+}
+
+// verifyTemplateAffected returns ErrNotFound if the template statement didn't affect any rows.
+func verifyTemplateAffected(res sql.Result) error {
+	if n, err := res.RowsAffected(); err != nil {
+		return errors.Wrap(err, "failed to get affected templates")
+	} else if n == 0 {
+		return errors.Wrap(ErrNotFound, "template not found")
+	}
+
+	return nil
+}
+
+// pgHealth is the Health of the postgres storage.
+type pgHealth struct {
+	db *sql.DB
+}
+
+func (h pgHealth) Ready(ctx context.Context) error {
+	if err := h.db.PingContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to ping postgres")
+	}
+
+	return nil
+}
+
+func (h pgHealth) Host(ctx context.Context) (string, error) {
+	var host string
+	err := h.db.QueryRowContext(ctx, `SELECT coalesce(host(inet_server_addr()), 'localhost') || ':' || current_setting('port')`).Scan(&host)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get postgres host")
+	}
+
+	return host, nil
+}
+
+func (pgHealth) ReplicaSet(context.Context) (ReplicaSet, error) {
+	return ReplicaSet{}, errors.Wrap(errPGUnsupported, "postgres storage has no replica set")
+}
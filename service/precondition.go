@@ -0,0 +1,41 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+)
+
+type ifMatchKey struct{}
+
+// ifMatch is the precondition of conditional mutations, the revision and definition hash the client read.
+type ifMatch struct {
+	Revision       int
+	DefinitionHash []byte
+}
+
+// WithIfMatch returns a copy of the context that makes mutations conditional on the revision and definition hash,
+// i.e., mutations fail with ErrPreconditionFailed if the definition changed since the client read it.
+func WithIfMatch(ctx context.Context, revision int, definitionHash []byte) context.Context {
+	return context.WithValue(ctx, ifMatchKey{}, ifMatch{Revision: revision, DefinitionHash: definitionHash})
+}
+
+// hasIfMatch returns true if the context contains a precondition, see WithIfMatch.
+func hasIfMatch(ctx context.Context) bool {
+	_, ok := ctx.Value(ifMatchKey{}).(ifMatch)
+	return ok
+}
+
+// verifyIfMatch returns ErrPreconditionFailed if the context contains a precondition that doesn't match the
+// definition document. The revision changes with every change of the document, including those not changing the
+// definition hash, e.g., pins and pending deletions.
+func verifyIfMatch(ctx context.Context, doc definitionDoc) error {
+	expected, ok := ctx.Value(ifMatchKey{}).(ifMatch)
+	if !ok || (expected.Revision == doc.Revision && bytes.Equal(expected.DefinitionHash, doc.Definition.DefinitionHash)) {
+		return nil
+	}
+
+	return errors.Wrap(ErrPreconditionFailed, "definition modified",
+		z.Int("revision", doc.Revision), z.Hex("definition_hash", doc.Definition.DefinitionHash))
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/cluster"
+	"testing"
+)
+
+func TestVerifyIfMatch(t *testing.T) {
+	doc := definitionDoc{
+		Revision:   3,
+		Definition: cluster.Definition{DefinitionHash: []byte{0x01, 0x02}},
+	}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantErr bool
+	}{
+		{name: "no precondition", ctx: context.Background()},
+		{name: "match", ctx: WithIfMatch(context.Background(), 3, []byte{0x01, 0x02})},
+		{name: "other revision", ctx: WithIfMatch(context.Background(), 2, []byte{0x01, 0x02}), wantErr: true},
+		{name: "other definition hash", ctx: WithIfMatch(context.Background(), 3, []byte{0x01, 0x03}), wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := verifyIfMatch(test.ctx, doc)
+			if test.wantErr && !errors.Is(err, ErrPreconditionFailed) {
+				t.Fatalf("unexpected error: got %v, want %v", err, ErrPreconditionFailed)
+			} else if !test.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"strings"
+)
+
+func (d definitionImpl) GetUnpublished(ctx context.Context, configHash []byte) (cluster.Definition, Version, error) {
+	doc, err := d.getDoc(ctx, configHash)
+	if err != nil {
+		return cluster.Definition{}, Version{}, err
+	} else if doc.Status != StatusUnpublished {
+		return cluster.Definition{}, Version{}, errors.Wrap(ErrNotFound, "unpublished definition not found")
+	}
+
+	return doc.Definition, doc.version(), nil
+}
+
+func (d definitionImpl) Revise(ctx context.Context, configHash []byte, def cluster.Definition, auth RequestAuth) ([]byte, error) {
+	def = canonicalDefinition(def)
+
+	def, err := def.SetDefinitionHashes()
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidRequest, "invalid definition", z.Err(err))
+	}
+
+	if err := d.verifyForkVersion(def.ForkVersion); err != nil {
+		return nil, err
+	} else if err := verifyInvariants(def); err != nil {
+		return nil, err
+	} else if err := d.verifyThreshold(def); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(def.ConfigHash, configHash) {
+		if _, err := d.getDoc(ctx, def.ConfigHash); err == nil {
+			return nil, errors.Wrap(ErrConflict, "revised definition already exists", z.Hex("config_hash", def.ConfigHash))
+		} else if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	err = d.update(ctx, configHash, EventRevised, func(doc *definitionDoc) error {
+		if doc.Status != StatusUnpublished {
+			return errors.Wrap(ErrInvalidState, "only unpublished definitions can be revised", z.Str("status", string(doc.Status)))
+		}
+
+		// Revisions can't transfer ownership, the creator must remain the same.
+		if doc.Owner == "" {
+			return errors.Wrap(ErrInvalidState, "definition has no creator to authorize revisions")
+		} else if !strings.EqualFold(def.Creator.Address, doc.Owner) {
+			return errors.Wrap(ErrInvalidRequest, "revised definition creator mismatch",
+				z.Str("expected", doc.Owner), z.Str("actual", def.Creator.Address))
+		} else if err := d.verifyRequest(doc, doc.Owner, actionRevise, revisePayload(def.ConfigHash), auth); err != nil {
+			return err
+		}
+
+		doc.ConfigHash = def.ConfigHash
+		doc.Version = def.Version
+		doc.Type = clusterType(def)
+		doc.Definition = def
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return def.ConfigHash, nil
+}
+
+func (d definitionImpl) Publish(ctx context.Context, configHash []byte) error {
+	return d.update(ctx, configHash, EventPublished, func(doc *definitionDoc) error {
+		if !doc.Status.CanTransition(StatusDraft) {
+			return errors.Wrap(ErrInvalidState, "definition cannot be published", z.Str("status", string(doc.Status)))
+		}
+
+		if err := doc.Definition.VerifyHashes(); err != nil {
+			return errors.Wrap(ErrInvalidRequest, "invalid definition hashes", z.Err(err))
+		} else if err := doc.Definition.VerifySignatures(); err != nil {
+			return errors.Wrap(ErrInvalidRequest, "invalid definition signatures", z.Err(err))
+		}
+
+		if err := d.verifyCreator(doc.Definition); err != nil {
+			return err
+		}
+
+		doc.Status = StatusDraft
+
+		return nil
+	})
+}
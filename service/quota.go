@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"strings"
+)
+
+// activeStatuses are the statuses of definitions counting towards the creator quota. Locked definitions completed
+// the ceremony and cancelled definitions never will, while deleted definitions are moved to the trash.
+var activeStatuses = []Status{StatusUnpublished, StatusDraft, StatusReady}
+
+// ownerMatch returns the matcher of definitions owned by the creator address. Creator addresses are stored as
+// provided, so it matches both the checksummed and lowercase forms.
+func ownerMatch(owner string) bson.E {
+	address := common.HexToAddress(owner).Hex()
+	return bson.E{Key: "owner", Value: bson.D{{"$in", bson.A{address, strings.ToLower(address)}}}}
+}
+
+// countOwned returns the number of definitions owned by the creator address, only counting definitions with the
+// statuses if provided.
+func (d definitionImpl) countOwned(ctx context.Context, owner string, statuses []Status) (int, error) {
+	return d.store.count(ctx, countFilter{Owner: owner, Statuses: statuses})
+}
+
+func (m mongoStore) count(ctx context.Context, filter countFilter) (int, error) {
+	ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opFind)
+	defer cancel()
+
+	match := bson.D{}
+	if filter.Owner != "" {
+		match = append(match, ownerMatch(filter.Owner))
+	}
+	if filter.Tenant != "" {
+		match = append(match, bson.E{Key: "tenant", Value: filter.Tenant})
+	}
+	if len(filter.Statuses) > 0 {
+		match = append(match, bson.E{Key: "status", Value: bson.D{{"$in", filter.Statuses}}})
+	}
+
+	count, err := m.table.CountDocuments(ctx, match)
+	if err != nil {
+		return 0, wrapDBErr(err, opFind, "failed to count definitions")
+	}
+
+	return int(count), nil
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RateLimits interface {
+	// List returns all rate limit overrides.
+	List(ctx context.Context) ([]RateLimit, error)
+	// Set creates or replaces the rate limit override of the subject.
+	Set(ctx context.Context, limit RateLimit) error
+	// Delete removes the rate limit override of the subject, reverting it to the default limits.
+	Delete(ctx context.Context, subject string) error
+}
+
+// RateLimit is a request rate limit override of a client subject, e.g. "key:<hash>", "hmac:<id>" or "ip:<addr>".
+type RateLimit struct {
+	Subject string `json:"subject" bson:"_id"`
+	// Rate is the number of requests per second. Requests are not limited if zero.
+	Rate float64 `json:"rate" bson:"rate"`
+	// Burst is the maximum number of requests allowed at once.
+	Burst int `json:"burst" bson:"burst"`
+}
+
+func NewRateLimits(table *mongo.Collection) RateLimits {
+	return rateLimitsImpl{table: table}
+}
+
+type rateLimitsImpl struct {
+	table *mongo.Collection
+}
+
+func (l rateLimitsImpl) List(ctx context.Context) ([]RateLimit, error) {
+	cursor, err := l.table.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{"_id", 1}}))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find rate limits")
+	}
+
+	resp := []RateLimit{}
+	if err := cursor.All(ctx, &resp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode rate limits")
+	}
+
+	return resp, nil
+}
+
+func (l rateLimitsImpl) Set(ctx context.Context, limit RateLimit) error {
+	if limit.Subject == "" {
+		return errors.Wrap(ErrInvalidRequest, "empty rate limit subject")
+	} else if limit.Rate < 0 || limit.Burst < 0 {
+		return errors.Wrap(ErrInvalidRequest, "negative rate limit", z.Str("subject", limit.Subject))
+	} else if limit.Rate > 0 && limit.Burst == 0 {
+		return errors.Wrap(ErrInvalidRequest, "zero rate limit burst", z.Str("subject", limit.Subject))
+	}
+
+	_, err := l.table.ReplaceOne(ctx, bson.D{{"_id", limit.Subject}}, limit, options.Replace().SetUpsert(true))
+	if err != nil {
+		return errors.Wrap(err, "failed to set rate limit")
+	}
+
+	return nil
+}
+
+func (l rateLimitsImpl) Delete(ctx context.Context, subject string) error {
+	res, err := l.table.DeleteOne(ctx, bson.D{{"_id", subject}})
+	if err != nil {
+		return errors.Wrap(err, "failed to delete rate limit")
+	} else if res.DeletedCount == 0 {
+		return errors.Wrap(ErrNotFound, "rate limit not found")
+	}
+
+	return nil
+}
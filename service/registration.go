@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	eth2v1 "github.com/attestantio/go-eth2-client/api/v1"
+	eth2p0 "github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/tbls"
+	"github.com/obolnetwork/charon/tbls/tblsconv"
+	"time"
+)
+
+// builderDomainType is the DOMAIN_APPLICATION_BUILDER domain type of validator registrations.
+var builderDomainType = eth2p0.DomainType([4]byte{0x00, 0x00, 0x00, 0x01})
+
+func (d definitionImpl) AddRegistrations(ctx context.Context, configHash []byte, regs []*eth2v1.SignedValidatorRegistration) error {
+	return d.update(ctx, configHash, EventRegistrationsAdded, func(doc *definitionDoc) error {
+		if doc.Status != StatusLocked || doc.Lock == nil {
+			return errors.Wrap(ErrInvalidState, "definition not locked", z.Str("status", string(doc.Status)))
+		}
+
+		if doc.Registrations == nil {
+			doc.Registrations = make(map[string][]byte)
+		}
+
+		for _, reg := range regs {
+			if reg == nil || reg.Message == nil {
+				return errors.Wrap(ErrInvalidRequest, "missing registration message")
+			}
+
+			pubkey := fmt.Sprintf("%#x", reg.Message.Pubkey)
+			val, ok := lockValidator(*doc.Lock, pubkey)
+			if !ok {
+				return errors.Wrap(ErrInvalidRequest, "validator not in lock", z.Str("pubkey", pubkey))
+			}
+
+			if err := verifyRegistration(val.PubKey, doc.Lock.ForkVersion, reg); err != nil {
+				return errors.Wrap(ErrInvalidRequest, "invalid registration signature", z.Str("pubkey", pubkey), z.Err(err))
+			}
+
+			// Only replace existing registrations with newer ones.
+			if existing, ok := doc.Registrations[pubkey]; ok {
+				prev, err := unmarshalRegistration(existing)
+				if err != nil {
+					return err
+				} else if !reg.Message.Timestamp.After(prev.Message.Timestamp) {
+					continue
+				}
+			}
+
+			b, err := json.Marshal(reg)
+			if err != nil {
+				return errors.Wrap(err, "marshal registration")
+			}
+
+			doc.Registrations[pubkey] = b
+		}
+
+		return nil
+	})
+}
+
+func (d definitionImpl) Registrations(ctx context.Context, configHash []byte) ([]*eth2v1.SignedValidatorRegistration, error) {
+	doc, err := d.getDoc(ctx, configHash)
+	if err != nil {
+		return nil, err
+	} else if doc.Lock == nil {
+		return nil, errors.Wrap(ErrInvalidState, "definition not locked", z.Str("status", string(doc.Status)))
+	}
+
+	// Return registrations in lock validator order.
+	resp := make([]*eth2v1.SignedValidatorRegistration, 0, len(doc.Registrations))
+	for _, val := range doc.Lock.Validators {
+		b, ok := doc.Registrations[fmt.Sprintf("%#x", val.PubKey)]
+		if !ok {
+			continue
+		}
+
+		reg, err := unmarshalRegistration(b)
+		if err != nil {
+			return nil, err
+		} else if d.registrationExpired(reg) {
+			continue
+		}
+
+		resp = append(resp, reg)
+	}
+
+	return resp, nil
+}
+
+func (d definitionImpl) GetRegistration(ctx context.Context, pubkey []byte) (*eth2v1.SignedValidatorRegistration, error) {
+	doc, err := d.getValidatorDoc(ctx, pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	b, ok := doc.Registrations[fmt.Sprintf("%#x", pubkey)]
+	if !ok {
+		return nil, errors.Wrap(ErrNotFound, "registration not found")
+	}
+
+	reg, err := unmarshalRegistration(b)
+	if err != nil {
+		return nil, err
+	} else if d.registrationExpired(reg) {
+		return nil, errors.Wrap(ErrNotFound, "registration expired")
+	}
+
+	return reg, nil
+}
+
+// registrationExpired returns true if the registration timestamp is older than the configured expiry.
+func (d definitionImpl) registrationExpired(reg *eth2v1.SignedValidatorRegistration) bool {
+	if d.conf.RegistrationExpiry == 0 {
+		return false
+	}
+
+	return time.Since(reg.Message.Timestamp) > d.conf.RegistrationExpiry
+}
+
+// unmarshalRegistration returns the signed validator registration from its stored json encoding.
+func unmarshalRegistration(b []byte) (*eth2v1.SignedValidatorRegistration, error) {
+	reg := new(eth2v1.SignedValidatorRegistration)
+	if err := json.Unmarshal(b, reg); err != nil {
+		return nil, errors.Wrap(err, "unmarshal registration")
+	}
+
+	return reg, nil
+}
+
+// verifyRegistration returns an error if the registration isn't signed by the distributed validator public key
+// using the builder domain of the genesis fork version.
+func verifyRegistration(pubkey []byte, forkVersion []byte, reg *eth2v1.SignedValidatorRegistration) error {
+	forkData := &eth2p0.ForkData{
+		GenesisValidatorsRoot: eth2p0.Root{}, // GenesisValidatorsRoot is zero for builder domain.
+	}
+	copy(forkData.CurrentVersion[:], forkVersion)
+
+	forkRoot, err := forkData.HashTreeRoot()
+	if err != nil {
+		return errors.Wrap(err, "hash fork data")
+	}
+
+	var domain eth2p0.Domain
+	copy(domain[0:], builderDomainType[:])
+	copy(domain[4:], forkRoot[:])
+
+	msgRoot, err := reg.Message.HashTreeRoot()
+	if err != nil {
+		return errors.Wrap(err, "hash registration")
+	}
+
+	root, err := (&eth2p0.SigningData{ObjectRoot: msgRoot, Domain: domain}).HashTreeRoot()
+	if err != nil {
+		return errors.Wrap(err, "hash signing data")
+	}
+
+	signer, err := tblsconv.KeyFromBytes(pubkey)
+	if err != nil {
+		return err
+	}
+
+	sig, err := tblsconv.SigFromBytes(reg.Signature[:])
+	if err != nil {
+		return err
+	}
+
+	ok, err := tbls.Verify(signer, root[:], sig)
+	if err != nil {
+		return err
+	} else if !ok {
+		return errors.New("signature verification failed")
+	}
+
+	return nil
+}
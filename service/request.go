@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RequestAuth authenticates a mutation request by an operator and protects it from replay.
+type RequestAuth struct {
+	// Timestamp is the unix timestamp in seconds at which the request was signed.
+	// It must be within the configured request window and increase monotonically per address.
+	Timestamp int64
+	// Signature is the EIP712 signature of the request by the operator address.
+	Signature []byte
+	// InviteToken is the operator's single-use invite token, required if the definition was created with invite tokens.
+	InviteToken string
+}
+
+// Request actions included in signed requests.
+const (
+	actionAddOperator    = "add_operator"
+	actionDecline        = "decline"
+	actionDelete         = "delete"
+	actionCancelDeletion = "cancel_deletion"
+	actionPin            = "pin"
+	actionRemoveOperator = "remove_operator"
+	actionInvite         = "invite"
+	actionProposeExit    = "propose_exit"
+	actionConfirmExit    = "confirm_exit"
+	actionRevise         = "revise"
+	actionUpdateMetadata = "update_metadata"
+)
+
+type adminKey struct{}
+
+// WithAdmin returns a copy of the context of an administrator, e.g. the admin commands or admin API clients, whose
+// deletions, deletion cancellations and pins don't require a request signature.
+func WithAdmin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminKey{}, true)
+}
+
+// isAdmin returns true if the context is of an administrator, see WithAdmin.
+func isAdmin(ctx context.Context) bool {
+	ok, _ := ctx.Value(adminKey{}).(bool)
+	return ok
+}
+
+// requestPayload is the payload of a signed request, i.e., its body fields excluding the request authentication, as
+// strings with byte fields 0x-hex encoded and addresses lowercase. Signing its hash with the request binds the
+// signature to the payload, so it can't authorize any other payload.
+type requestPayload map[string]string
+
+// hash returns the sha256 hash of the payload's canonical encoding, the json object with keys in sorted order.
+func (p requestPayload) hash() []byte {
+	b, _ := json.Marshal(p) // Marshalling string maps never fails.
+	h := sha256.Sum256(b)
+
+	return h[:]
+}
+
+// addressPayload returns the payload of requests about the operator address, i.e., declining, inviting or removing it.
+func addressPayload(address string) requestPayload {
+	return requestPayload{"address": strings.ToLower(address)}
+}
+
+// addOperatorPayload returns the payload of the operator joining the definition of the fork version and version.
+func addOperatorPayload(forkVersion []byte, version string, operator cluster.Operator) requestPayload {
+	return requestPayload{
+		"address":          strings.ToLower(operator.Address),
+		"enr":              operator.ENR,
+		"config_signature": fmt.Sprintf("%#x", operator.ConfigSignature),
+		"enr_signature":    fmt.Sprintf("%#x", operator.ENRSignature),
+		"fork_version":     fmt.Sprintf("%#x", forkVersion),
+		"version":          version,
+	}
+}
+
+// deletePayload returns the payload of the address deleting the definition at the revision. Binding the revision
+// prevents replaying the request once the definition changed, since the definition and its nonces are deleted.
+func deletePayload(address string, revision int) requestPayload {
+	return requestPayload{
+		"address":  strings.ToLower(address),
+		"revision": strconv.Itoa(revision),
+	}
+}
+
+// exitPayload returns the payload of the operator address proposing or confirming the exit of the validator at
+// the epoch.
+func exitPayload(address string, pubkey []byte, epoch uint64) requestPayload {
+	return requestPayload{
+		"address": strings.ToLower(address),
+		"pubkey":  fmt.Sprintf("%#x", pubkey),
+		"epoch":   strconv.FormatUint(epoch, 10),
+	}
+}
+
+// revisePayload returns the payload of revising the definition to the config hash, which hashes the whole revised
+// definition.
+func revisePayload(configHash []byte) requestPayload {
+	return requestPayload{"config_hash": fmt.Sprintf("%#x", configHash)}
+}
+
+// pinPayload returns the payload of pinning or unpinning the definition.
+func pinPayload(pinned bool) requestPayload {
+	return requestPayload{"pinned": strconv.FormatBool(pinned)}
+}
+
+// metadataPayload returns the payload of the metadata patch, omitting unchanged fields.
+func metadataPayload(patch MetadataPatch) requestPayload {
+	payload := make(requestPayload)
+	if patch.DashboardURL != nil {
+		payload["dashboard_url"] = *patch.DashboardURL
+	}
+	if patch.Description != nil {
+		payload["description"] = *patch.Description
+	}
+
+	return payload
+}
+
+// requestSubject returns the signed subject of the action on the definition with the payload, formatted as
+// "<action> <0x-hex config hash> <0x-hex payload hash>".
+func requestSubject(action string, configHash []byte, payload requestPayload) string {
+	return fmt.Sprintf("%s %#x %#x", action, configHash, payload.hash())
+}
+
+// verifyRequest returns an error if the request of the action with the payload isn't signed by the address or is
+// replayed. It records the request timestamp as the address's latest nonce.
+func (d definitionImpl) verifyRequest(doc *definitionDoc, address string, action string, payload requestPayload, auth RequestAuth) error {
+	return d.verifySignedRequest(doc, address, requestSubject(action, doc.ConfigHash, payload), auth)
+}
+
+// verifySignedRequest returns an error if the request subject isn't signed by the address at the request timestamp
+// or is replayed. Request timestamps must increase monotonically per address and, if a request window is configured,
+// be within it. It records the request timestamp as the address's latest nonce.
+func (d definitionImpl) verifySignedRequest(doc *definitionDoc, address string, subject string, auth RequestAuth) error {
+	if len(auth.Signature) == 0 {
+		return errors.Wrap(ErrInvalidRequest, "missing request signature", z.Str("address", address))
+	}
+
+	if d.conf.RequestWindow > 0 {
+		timestamp := time.Unix(auth.Timestamp, 0)
+		if age := time.Since(timestamp); age > d.conf.RequestWindow || age < -d.conf.RequestWindow {
+			return errors.Wrap(ErrInvalidRequest, "request timestamp outside window", z.Any("timestamp", timestamp))
+		}
+	}
+
+	key := strings.ToLower(address)
+	if auth.Timestamp <= doc.Nonces[key] {
+		return errors.Wrap(ErrInvalidRequest, "request replayed", z.Str("address", address))
+	}
+
+	value := fmt.Sprintf("%s %d", subject, auth.Timestamp)
+	if err := verifyEIP712(eip712Request, doc.Definition.ForkVersion, value, address, auth.Signature); err != nil {
+		return errors.Wrap(ErrInvalidRequest, "invalid request signature", z.Str("address", address), z.Err(err))
+	}
+
+	if doc.Nonces == nil {
+		doc.Nonces = make(map[string]int64)
+	}
+	doc.Nonces[key] = auth.Timestamp
+
+	return nil
+}
+
+// verifyDeleteRequest returns an error if the delete request isn't signed by the definition's creator, or by one of
+// its operators if the definition has no creator, at the document's revision. It records the request timestamp as
+// the signer's latest nonce.
+func (d definitionImpl) verifyDeleteRequest(doc *definitionDoc, signer string, auth RequestAuth) error {
+	if doc.Owner != "" {
+		if !strings.EqualFold(signer, doc.Owner) {
+			return errors.Wrap(ErrInvalidRequest, "delete request not signed by creator", z.Str("address", signer))
+		}
+	} else if _, ok := operatorIndex(doc.Definition, signer); !ok {
+		return errors.Wrap(ErrInvalidRequest, "delete request not signed by operator", z.Str("address", signer))
+	}
+
+	return d.verifyRequest(doc, signer, actionDelete, deletePayload(signer, doc.Revision), auth)
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/cluster"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyRequest(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	other, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	def := cluster.Definition{
+		ForkVersion: []byte{0x00, 0x00, 0x00, 0x00},
+		ConfigHash:  bytes.Repeat([]byte{0x01}, configHashLen),
+	}
+	address := keyAddress(key)
+	now := time.Now().Unix()
+
+	sign := func(key *ecdsa.PrivateKey, action string, timestamp int64) RequestAuth {
+		t.Helper()
+
+		auth, err := signRequest(key, def, action, addressPayload(address), timestamp)
+		if err != nil {
+			t.Fatalf("sign request: %v", err)
+		}
+
+		return auth
+	}
+
+	tests := []struct {
+		name string
+		// window is the configured request window, zero if timestamps aren't bounded.
+		window time.Duration
+		// nonce is the address's latest request timestamp, zero if none.
+		nonce   int64
+		auth    RequestAuth
+		wantErr string
+	}{
+		{name: "valid", auth: sign(key, actionDecline, now)},
+		{name: "valid within window", window: time.Minute, auth: sign(key, actionDecline, now-30)},
+		{name: "old without window", auth: sign(key, actionDecline, now-3600)},
+		{name: "newer than nonce", nonce: now - 1, auth: sign(key, actionDecline, now)},
+		{name: "missing signature", auth: RequestAuth{Timestamp: now}, wantErr: "missing request signature"},
+		{name: "expired", window: time.Minute, auth: sign(key, actionDecline, now-120), wantErr: "request timestamp outside window"},
+		{name: "future", window: time.Minute, auth: sign(key, actionDecline, now+120), wantErr: "request timestamp outside window"},
+		{name: "replayed", nonce: now, auth: sign(key, actionDecline, now), wantErr: "request replayed"},
+		{name: "older than nonce", nonce: now, auth: sign(key, actionDecline, now-1), wantErr: "request replayed"},
+		{name: "other signer", auth: sign(other, actionDecline, now), wantErr: "invalid request signature"},
+		{name: "other action", auth: sign(key, actionDelete, now), wantErr: "invalid request signature"},
+		{
+			name:    "other timestamp",
+			auth:    RequestAuth{Timestamp: now + 1, Signature: sign(key, actionDecline, now).Signature},
+			wantErr: "invalid request signature",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := definitionImpl{conf: DefinitionConfig{RequestWindow: test.window}}
+			doc := &definitionDoc{
+				ConfigHash: def.ConfigHash,
+				Definition: def,
+				Nonces:     map[string]int64{strings.ToLower(address): test.nonce},
+			}
+
+			err := d.verifyRequest(doc, address, actionDecline, addressPayload(address), test.auth)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else if nonce := doc.Nonces[strings.ToLower(address)]; nonce != test.auth.Timestamp {
+					t.Fatalf("nonce not recorded: got %d, want %d", nonce, test.auth.Timestamp)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, ErrInvalidRequest) || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("unexpected error: got %v, want %s", err, test.wantErr)
+			} else if nonce := doc.Nonces[strings.ToLower(address)]; nonce != test.nonce {
+				t.Fatalf("nonce recorded on failure: got %d, want %d", nonce, test.nonce)
+			}
+		})
+	}
+}
+
+func TestRequestPayloadHash(t *testing.T) {
+	dashboard := "https://example.com"
+	description := "description"
+
+	tests := []struct {
+		name  string
+		a, b  requestPayload
+		equal bool
+	}{
+		{
+			name:  "address case",
+			a:     addressPayload("0xABCDEF0123456789abcdef0123456789ABCDEF01"),
+			b:     addressPayload("0xabcdef0123456789abcdef0123456789abcdef01"),
+			equal: true,
+		},
+		{
+			name:  "key order",
+			a:     requestPayload{"a": "1", "b": "2"},
+			b:     requestPayload{"b": "2", "a": "1"},
+			equal: true,
+		},
+		{
+			name: "delete revision",
+			a:    deletePayload("0xabcdef0123456789abcdef0123456789abcdef01", 1),
+			b:    deletePayload("0xabcdef0123456789abcdef0123456789abcdef01", 2),
+		},
+		{
+			name: "metadata fields",
+			a:    metadataPayload(MetadataPatch{DashboardURL: &dashboard}),
+			b:    metadataPayload(MetadataPatch{Description: &dashboard}),
+		},
+		{
+			name:  "unchanged metadata omitted",
+			a:     metadataPayload(MetadataPatch{Description: &description}),
+			b:     requestPayload{"description": description},
+			equal: true,
+		},
+		{
+			name: "pin",
+			a:    pinPayload(true),
+			b:    pinPayload(false),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if equal := bytes.Equal(test.a.hash(), test.b.hash()); equal != test.equal {
+				t.Fatalf("unexpected hash equality: got %v, want %v", equal, test.equal)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+	"time"
+)
+
+// notPrimaryCodes are the mongo error codes of operations rejected because the replica set has no primary,
+// e.g., during elections. The operation wasn't applied, so it is safe to retry writes.
+var notPrimaryCodes = []int{
+	91,    // ShutdownInProgress
+	189,   // PrimarySteppedDown
+	10107, // NotWritablePrimary
+	11600, // InterruptedAtShutdown
+	11602, // InterruptedDueToReplStateChange
+	13435, // NotPrimaryNoSecondaryOk
+	13436, // NotPrimaryOrSecondary
+}
+
+// RetryPolicy defines the bounded retries of mongo operations failing with transient errors, avoiding
+// failing requests during replica set elections. These are in addition to the driver's single retry.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts of an operation. Operations are not retried if one or less.
+	Attempts int
+	// Backoff is the delay before the first retry, doubling for each subsequent retry.
+	Backoff time.Duration
+}
+
+// do calls fn until it succeeds, fails with an error that isn't transient, or the attempts are exhausted.
+// Writes are only retried if they were definitely not applied, reads are also retried on network errors.
+func (p RetryPolicy) do(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		// Operations of aborted transactions can't be retried, the whole transaction is retried instead.
+		return fn(ctx)
+	}
+
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= p.Attempts || !isTransient(err, op != opFind) {
+			return err
+		}
+
+		dbRetries.WithLabelValues(op).Inc()
+		log.Debug(ctx, "Retrying transient mongo error", z.Str("op", op), z.Int("attempt", attempt), z.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isTransient returns true if the mongo error is transient, i.e., the operation may succeed if retried.
+func isTransient(err error, write bool) bool {
+	if errors.As(err, new(topology.ServerSelectionError)) {
+		return true // No server was selected, so the operation wasn't sent.
+	}
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		for _, code := range notPrimaryCodes {
+			if serverErr.HasErrorCode(code) {
+				return true
+			}
+		}
+	}
+
+	// Writes may have been applied before network errors, so only retry reads.
+	return !write && mongo.IsNetworkError(err)
+}
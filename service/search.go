@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+)
+
+// SearchResult is a definition matching a search query.
+type SearchResult struct {
+	ConfigHash string      `json:"config_hash"`
+	Name       string      `json:"name"`
+	Status     Status      `json:"status"`
+	Network    string      `json:"network"`
+	Type       ClusterType `json:"type"`
+	// Score is the relevance of the definition to the search query, higher is more relevant.
+	Score float64 `json:"score"`
+}
+
+// SearchPage is a page of search results ordered by descending relevance.
+type SearchPage struct {
+	Results []SearchResult `json:"results"`
+	// NextOffset is the offset of the next page, zero if this is the last page.
+	NextOffset int `json:"next_offset,omitempty"`
+}
+
+func (d definitionImpl) Search(ctx context.Context, query string, offset, limit int) (SearchPage, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return SearchPage{}, errors.Wrap(ErrInvalidRequest, "empty search query")
+	} else if offset < 0 || limit <= 0 {
+		return SearchPage{}, errors.Wrap(ErrInvalidRequest, "invalid search pagination")
+	}
+
+	// Searching requires the mongo text index.
+	m, ok := d.store.(mongoStore)
+	if !ok {
+		return SearchPage{}, errors.Wrap(ErrUnsupported, "search not supported by the storage driver")
+	}
+
+	ctx, cancel := d.conf.DBTimeouts.withTimeout(ctx, opFind)
+	defer cancel()
+
+	score := bson.D{{"score", bson.D{{"$meta", "textScore"}}}}
+
+	// Fetch an additional result to determine whether a next page exists.
+	cursor, err := m.table.Find(ctx,
+		bson.D{
+			{"$text", bson.D{{"$search", query}}},
+			{"status", bson.D{{"$ne", StatusUnpublished}}},
+			tenantMatch(ctx),
+		},
+		options.Find().
+			SetProjection(score).
+			SetSort(score).
+			SetSkip(int64(offset)).
+			SetLimit(int64(limit+1)))
+	if err != nil {
+		return SearchPage{}, wrapDBErr(err, opFind, "failed to search definitions")
+	}
+
+	var docs []struct {
+		definitionDoc `bson:",inline"`
+		Score         float64 `bson:"score"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return SearchPage{}, wrapDBErr(err, opFind, "failed to decode definitions")
+	}
+
+	resp := SearchPage{Results: []SearchResult{}}
+	if len(docs) > limit {
+		docs = docs[:limit]
+		resp.NextOffset = offset + limit
+	}
+
+	for _, doc := range docs {
+		state := d.state(doc.definitionDoc)
+
+		resp.Results = append(resp.Results, SearchResult{
+			ConfigHash: fmt.Sprintf("%#x", doc.ConfigHash),
+			Name:       doc.Definition.Name,
+			Status:     state.Status,
+			Network:    state.Network,
+			Type:       state.Type,
+			Score:      doc.Score,
+		})
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// validatorChunksCollection is the name of the collection of sharded lock validators, in the same database as
+	// the definitions.
+	validatorChunksCollection = "validator_chunks"
+	// validatorChunkSize is the number of validators per chunk. It must never change since chunks are read by index.
+	validatorChunkSize = 256
+)
+
+// ValidatorPage is a page of the distributed validators of a cluster lock in lock order.
+type ValidatorPage struct {
+	Validators []cluster.DistValidator `json:"distributed_validators"`
+	// Total is the number of validators of the lock.
+	Total int `json:"total"`
+	// NextOffset is the offset of the next page, zero if this is the last page.
+	NextOffset int `json:"next_offset,omitempty"`
+}
+
+// validatorChunkDoc is the mongo document of a chunk of the validators of a sharded lock.
+type validatorChunkDoc struct {
+	ConfigHash []byte                  `bson:"config_hash"`
+	Index      int                     `bson:"index"`
+	Validators []cluster.DistValidator `bson:"validators"`
+}
+
+// validatorChunkIndexes are the validator chunks collection indexes.
+var validatorChunkIndexes = []mongo.IndexModel{
+	{Keys: bson.D{{"config_hash", 1}, {"index", 1}}, Options: options.Index().SetUnique(true)},
+}
+
+// shard stores the validators of the document's lock in chunks if it has at least the configured number of
+// validators, keeping the definition document well under the BSON document limit regardless of the cluster size.
+// Chunks are upserted by index, so chunks of a failed attempt are replaced. A lock's validators never change once
+// sharded, so sharded documents are not sharded again. Chunks are retained when the definition is deleted,
+// since its trash copy and change log reference them.
+func (m mongoStore) shard(ctx context.Context, doc *definitionDoc) error {
+	if m.conf.ShardValidators == 0 || doc.ValidatorChunks > 0 || doc.Lock == nil || len(doc.Lock.Validators) < m.conf.ShardValidators {
+		return nil
+	}
+
+	chunks := m.table.Database().Collection(validatorChunksCollection)
+
+	var index int
+	for start := 0; start < len(doc.Lock.Validators); start += validatorChunkSize {
+		end := start + validatorChunkSize
+		if end > len(doc.Lock.Validators) {
+			end = len(doc.Lock.Validators)
+		}
+
+		chunk := validatorChunkDoc{
+			ConfigHash: doc.ConfigHash,
+			Index:      index,
+			Validators: doc.Lock.Validators[start:end],
+		}
+
+		err := m.conf.Retry.do(ctx, opUpdate, func(ctx context.Context) error {
+			ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opUpdate)
+			defer cancel()
+
+			_, err := chunks.ReplaceOne(ctx, bson.D{{"config_hash", chunk.ConfigHash}, {"index", chunk.Index}}, chunk,
+				options.Replace().SetUpsert(true))
+
+			return err
+		})
+		if err != nil {
+			return wrapDBErr(err, opUpdate, "failed to store validator chunk")
+		}
+
+		index++
+	}
+
+	doc.ValidatorChunks = index
+
+	return nil
+}
+
+// stripSharded returns the document to store without the validators of its sharded lock, retaining their public
+// keys as a separate queryable field.
+func stripSharded(doc definitionDoc) definitionDoc {
+	if doc.ValidatorChunks == 0 || doc.Lock == nil || len(doc.Lock.Validators) == 0 {
+		return doc
+	}
+
+	doc.ValidatorPubkeys = nil
+	for _, val := range doc.Lock.Validators {
+		doc.ValidatorPubkeys = append(doc.ValidatorPubkeys, val.PubKey)
+	}
+
+	lock := *doc.Lock // Copy the lock, leaving the caller's validators intact.
+	lock.Validators = nil
+	doc.Lock = &lock
+
+	return doc
+}
+
+// loadValidators populates the validators of the document's sharded lock from its chunks.
+func (m mongoStore) loadValidators(ctx context.Context, doc *definitionDoc) error {
+	if doc.ValidatorChunks == 0 || doc.Lock == nil || len(doc.Lock.Validators) > 0 {
+		return nil
+	}
+
+	chunks, err := m.findChunks(ctx, doc.ConfigHash, 0, doc.ValidatorChunks)
+	if err != nil {
+		return err
+	} else if len(chunks) != doc.ValidatorChunks {
+		return errors.New("missing validator chunks", z.Hex("config_hash", doc.ConfigHash),
+			z.Int("expected", doc.ValidatorChunks), z.Int("actual", len(chunks)))
+	}
+
+	for _, chunk := range chunks {
+		doc.Lock.Validators = append(doc.Lock.Validators, chunk.Validators...)
+	}
+
+	return nil
+}
+
+// findChunks returns the validator chunks of the lock with indexes from the inclusive start to the exclusive end
+// ordered by index.
+func (m mongoStore) findChunks(ctx context.Context, configHash []byte, start, end int) ([]validatorChunkDoc, error) {
+	ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opFind)
+	defer cancel()
+
+	cursor, err := m.table.Database().Collection(validatorChunksCollection).Find(ctx,
+		bson.D{{"config_hash", configHash}, {"index", bson.D{{"$gte", start}, {"$lt", end}}}},
+		options.Find().SetSort(bson.D{{"index", 1}}))
+	if err != nil {
+		return nil, wrapDBErr(err, opFind, "failed to find validator chunks")
+	}
+
+	var resp []validatorChunkDoc
+	if err := cursor.All(ctx, &resp); err != nil {
+		return nil, wrapDBErr(err, opFind, "failed to decode validator chunks")
+	}
+
+	return resp, nil
+}
+
+func (d definitionImpl) Validators(ctx context.Context, configHash []byte, offset, limit int) (ValidatorPage, error) {
+	if offset < 0 || limit <= 0 {
+		return ValidatorPage{}, errors.Wrap(ErrInvalidRequest, "invalid validator pagination")
+	}
+
+	if lock, ok := d.lockCache.Get(cacheKey(ctx, configHash)); ok {
+		return validatorPage(lock.(cluster.Lock).Validators, offset, limit), nil
+	}
+
+	return d.store.validators(ctx, configHash, offset, limit)
+}
+
+// validators returns the page of the lock's validators, only reading the chunks containing the page if sharded.
+func (m mongoStore) validators(ctx context.Context, configHash []byte, offset, limit int) (ValidatorPage, error) {
+	doc, err := m.findStored(ctx, configHash)
+	if err != nil {
+		return ValidatorPage{}, err
+	} else if doc.Lock == nil {
+		return ValidatorPage{}, errors.Wrap(ErrNotFound, "lock not found")
+	} else if doc.ValidatorChunks == 0 {
+		return validatorPage(doc.Lock.Validators, offset, limit), nil
+	}
+
+	// Only read the chunks containing the page.
+	total := len(doc.ValidatorPubkeys)
+	if offset >= total {
+		return ValidatorPage{Validators: []cluster.DistValidator{}, Total: total}, nil
+	}
+
+	first := offset / validatorChunkSize
+	last := (offset + limit - 1) / validatorChunkSize
+	chunks, err := m.findChunks(ctx, configHash, first, last+1)
+	if err != nil {
+		return ValidatorPage{}, err
+	}
+
+	var vals []cluster.DistValidator
+	for _, chunk := range chunks {
+		vals = append(vals, chunk.Validators...)
+	}
+
+	resp := validatorPage(vals, offset-first*validatorChunkSize, limit)
+	resp.Total = total
+	resp.NextOffset = 0
+	if next := offset + len(resp.Validators); next < total {
+		resp.NextOffset = next
+	}
+
+	return resp, nil
+}
+
+// validatorPage returns the page of validators starting at the offset.
+func validatorPage(vals []cluster.DistValidator, offset, limit int) ValidatorPage {
+	resp := ValidatorPage{Validators: []cluster.DistValidator{}, Total: len(vals)}
+	if offset >= len(vals) {
+		return resp
+	}
+
+	end := offset + limit
+	if end < len(vals) {
+		resp.NextOffset = end
+	} else {
+		end = len(vals)
+	}
+	resp.Validators = vals[offset:end]
+
+	return resp
+}
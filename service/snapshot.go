@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"github.com/obolnetwork/charon/app/z"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// archiveTimeout is the maximum duration of archiving a snapshot to object storage.
+const archiveTimeout = 2 * time.Minute
+
+// ArchiveLocation are the object storage locations of the cluster's immutable snapshots.
+type ArchiveLocation struct {
+	// Definition is the location of the completed definition's snapshot, empty if not archived.
+	Definition string `json:"definition,omitempty"`
+	// Lock is the location of the lock's snapshot, empty if not archived.
+	Lock string `json:"lock,omitempty"`
+}
+
+func (d definitionImpl) ArchiveLocation(ctx context.Context, configHash []byte) (ArchiveLocation, error) {
+	doc, err := d.getDoc(ctx, configHash)
+	if err != nil {
+		return ArchiveLocation{}, err
+	} else if doc.DefinitionArchive == "" && doc.LockArchive == "" {
+		return ArchiveLocation{}, errors.Wrap(ErrNotFound, "definition not archived")
+	}
+
+	return ArchiveLocation{
+		Definition: doc.DefinitionArchive,
+		Lock:       doc.LockArchive,
+	}, nil
+}
+
+// archiveAsync archives the value's json to object storage in the background and stores its location in the field
+// of the definition document, continuing the trace of the request without its cancellation. It does nothing if
+// archiving is disabled.
+func (d definitionImpl) archiveAsync(ctx context.Context, configHash []byte, field string, v interface{}) {
+	if d.conf.Archiver == nil {
+		return
+	}
+
+	archiveCtx := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+	go func() {
+		ctx := log.WithTopic(archiveCtx, "objstore")
+		if err := d.archive(ctx, configHash, field, v); err != nil {
+			log.Warn(ctx, "Failed archiving to object storage", err, z.Str("field", field), z.Hex("config_hash", configHash))
+		}
+	}()
+}
+
+// archive writes the value's json to object storage, keyed by the field and config hash, and stores its location
+// in the field of the definition document. Snapshots are immutable, so storing the location doesn't change
+// the definition revision.
+func (d definitionImpl) archive(ctx context.Context, configHash []byte, field string, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, archiveTimeout)
+	defer cancel()
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "marshal archived json")
+	}
+
+	key := fmt.Sprintf("%ss/%#x.json", field, configHash)
+	location, err := d.conf.Archiver.Put(ctx, key, b)
+	if err != nil {
+		return err
+	}
+
+	if err := d.store.setRef(ctx, configHash, field+"_archive", location); err != nil {
+		return err
+	}
+
+	log.Info(ctx, "Archived to object storage", z.Str("field", field), z.Str("location", location), z.Hex("config_hash", configHash))
+
+	return nil
+}
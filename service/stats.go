@@ -0,0 +1,107 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"github.com/obolnetwork/charon/cluster"
+	"go.mongodb.org/mongo-driver/bson"
+	"strings"
+)
+
+// ClusterType is the type of cluster a definition creates.
+type ClusterType string
+
+const (
+	// ClusterTypeSolo indicates a solo-staker cluster where a single address operates all nodes.
+	ClusterTypeSolo ClusterType = "solo"
+	// ClusterTypeGroup indicates a multi-party cluster operated by different addresses.
+	ClusterTypeGroup ClusterType = "group"
+)
+
+// clusterType returns the type of cluster the definition creates.
+func clusterType(def cluster.Definition) ClusterType {
+	for _, op := range def.Operators {
+		if !strings.EqualFold(op.Address, def.Operators[0].Address) {
+			return ClusterTypeGroup
+		}
+	}
+
+	return ClusterTypeSolo
+}
+
+// StatsFilter filters the definitions included in statistics. Empty fields match all definitions.
+type StatsFilter struct {
+	Type        ClusterType
+	ForkVersion []byte
+}
+
+// Stats are the definition counts by cluster type and status.
+type Stats struct {
+	Total    int                 `json:"total"`
+	ByType   map[ClusterType]int `json:"by_type"`
+	ByStatus map[Status]int      `json:"by_status"`
+}
+
+func (d definitionImpl) Stats(ctx context.Context, filter StatsFilter) (Stats, error) {
+	return d.store.stats(ctx, filter)
+}
+
+// add adds the definition document to the stats if it matches the filter.
+func (s *Stats) add(doc definitionDoc, filter StatsFilter) {
+	if filter.Type != "" && doc.Type != filter.Type {
+		return
+	} else if len(filter.ForkVersion) > 0 && !bytes.Equal(doc.Definition.ForkVersion, filter.ForkVersion) {
+		return
+	}
+
+	s.Total++
+	s.ByType[doc.Type]++
+	s.ByStatus[doc.Status]++
+}
+
+func (m mongoStore) stats(ctx context.Context, filter StatsFilter) (Stats, error) {
+	match := bson.D{tenantMatch(ctx)}
+	if filter.Type != "" {
+		match = append(match, bson.E{Key: "type", Value: filter.Type})
+	}
+	if len(filter.ForkVersion) > 0 {
+		match = append(match, bson.E{Key: "definition.forkversion", Value: filter.ForkVersion})
+	}
+
+	ctx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opFind)
+	defer cancel()
+
+	cursor, err := m.table.Aggregate(ctx, bson.A{
+		bson.D{{"$match", match}},
+		bson.D{{"$group", bson.D{
+			{"_id", bson.D{{"type", "$type"}, {"status", "$status"}}},
+			{"count", bson.D{{"$sum", 1}}},
+		}}},
+	})
+	if err != nil {
+		return Stats{}, wrapDBErr(err, opFind, "failed to aggregate stats")
+	}
+
+	var groups []struct {
+		ID struct {
+			Type   ClusterType `bson:"type"`
+			Status Status      `bson:"status"`
+		} `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return Stats{}, wrapDBErr(err, opFind, "failed to decode stats")
+	}
+
+	resp := Stats{
+		ByType:   make(map[ClusterType]int),
+		ByStatus: make(map[Status]int),
+	}
+	for _, group := range groups {
+		resp.Total += group.Count
+		resp.ByType[group.ID.Type] += group.Count
+		resp.ByStatus[group.ID.Status] += group.Count
+	}
+
+	return resp, nil
+}
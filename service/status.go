@@ -0,0 +1,77 @@
+package service
+
+import "time"
+
+// Status is the DKG ceremony status of a cluster definition.
+type Status string
+
+const (
+	// StatusUnpublished indicates the definition was saved by the creator without signature verification
+	// and is being iterated on. It is invisible to operators until published.
+	StatusUnpublished Status = "unpublished"
+	// StatusDraft indicates the definition was published by the creator and is awaiting operators to accept.
+	StatusDraft Status = "draft"
+	// StatusReady indicates all operators accepted and the DKG ceremony can start.
+	StatusReady Status = "ready"
+	// StatusLocked indicates the DKG ceremony completed and the resulting cluster lock was stored.
+	StatusLocked Status = "locked"
+	// StatusCancelled indicates operators didn't all join before the creator's join deadline.
+	// Operators may no longer join.
+	StatusCancelled Status = "cancelled"
+	// StatusExpired indicates a draft definition older than the configured expiry.
+	// It is derived from the definition timestamp and never stored.
+	StatusExpired Status = "expired"
+)
+
+// transitions defines the valid status transitions.
+var transitions = map[Status][]Status{
+	StatusUnpublished: {StatusDraft},
+	StatusDraft:       {StatusReady, StatusCancelled},
+	StatusReady:       {StatusLocked},
+}
+
+// CanTransition returns true if transitioning from this status to the target status is allowed.
+func (s Status) CanTransition(target Status) bool {
+	for _, t := range transitions[s] {
+		if t == target {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Final returns true if definitions with this status can no longer change.
+func (s Status) Final() bool {
+	return s == StatusReady || s == StatusLocked
+}
+
+// State is the DKG ceremony state of a cluster definition.
+type State struct {
+	Status Status `json:"status"`
+	// Revision is the revision of the definition, incremented by every change. Zero if the storage driver doesn't
+	// track revisions.
+	Revision int `json:"revision"`
+	// Network is the name of the definition's network, e.g. "goerli".
+	Network string `json:"network"`
+	// Version is the definition version, e.g. "v1.4.0".
+	Version string `json:"version"`
+	// Type is the cluster type, either "solo" or "group".
+	Type ClusterType `json:"type"`
+	// Owner is the verified creator address of the definition, empty if the definition has no creator.
+	Owner string `json:"owner,omitempty"`
+	// Declined are the addresses of operators that declined to join the cluster.
+	Declined []string `json:"declined,omitempty"`
+	// JoinBy is the deadline by which all operators must join, nil if the definition has no deadline.
+	JoinBy *time.Time `json:"join_by,omitempty"`
+	// Pinned is true if the definition is protected from deletion and expiry.
+	Pinned bool `json:"pinned,omitempty"`
+	// PendingDeletion is the deletion request awaiting approval, nil if none.
+	PendingDeletion *Deletion `json:"pending_deletion,omitempty"`
+	// Metadata is the creator-editable metadata, nil if none.
+	Metadata *Metadata `json:"metadata,omitempty"`
+	// DefinitionCID is the IPFS CID of the completed definition, empty if not published.
+	DefinitionCID string `json:"definition_cid,omitempty"`
+	// LockCID is the IPFS CID of the lock, empty if not published.
+	LockCID string `json:"lock_cid,omitempty"`
+}
@@ -0,0 +1,54 @@
+package service
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		want     bool
+	}{
+		{from: StatusUnpublished, to: StatusDraft, want: true},
+		{from: StatusDraft, to: StatusReady, want: true},
+		{from: StatusDraft, to: StatusCancelled, want: true},
+		{from: StatusReady, to: StatusLocked, want: true},
+		{from: StatusUnpublished, to: StatusReady},
+		{from: StatusDraft, to: StatusUnpublished},
+		{from: StatusDraft, to: StatusLocked},
+		{from: StatusReady, to: StatusDraft},
+		{from: StatusReady, to: StatusCancelled},
+		{from: StatusLocked, to: StatusReady},
+		{from: StatusLocked, to: StatusLocked},
+		{from: StatusCancelled, to: StatusDraft},
+		{from: StatusExpired, to: StatusReady},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.from)+"_"+string(test.to), func(t *testing.T) {
+			if got := test.from.CanTransition(test.to); got != test.want {
+				t.Fatalf("unexpected transition: got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFinal(t *testing.T) {
+	tests := []struct {
+		status Status
+		want   bool
+	}{
+		{status: StatusUnpublished},
+		{status: StatusDraft},
+		{status: StatusReady, want: true},
+		{status: StatusLocked, want: true},
+		{status: StatusCancelled},
+		{status: StatusExpired},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.status), func(t *testing.T) {
+			if got := test.status.Final(); got != test.want {
+				t.Fatalf("unexpected final: got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// definitionsCollection is the mongo collection of definition documents.
+const definitionsCollection = "definitions"
+
+// StorageDriver is the name of a storage backend.
+type StorageDriver string
+
+const (
+	// StorageMongo stores all data in mongo and supports all functionality.
+	StorageMongo StorageDriver = "mongo"
+	// StorageMemory stores definitions and templates in memory, for small deployments and tests without mongo.
+	// Data is lost on restart. Searching, the admin service and auditing are not supported.
+	StorageMemory StorageDriver = "memory"
+	// StoragePostgres stores definitions and templates in postgres, supporting the same functionality as the
+	// in-memory storage.
+	StoragePostgres StorageDriver = "postgres"
+)
+
+// Storage is a storage driver providing the storage-backed services.
+type Storage interface {
+	// Definition returns the definition service. All drivers verify and authorize requests and apply the service's
+	// policies the same way, but may not support the mongo storage options, e.g., transactions or compression.
+	Definition(conf DefinitionConfig) Definition
+	// Template returns the template service.
+	Template() Template
+	// Health returns the health of the storage.
+	Health() Health
+	// Admin returns the admin service or nil if the driver doesn't support it.
+	Admin(conf DefinitionConfig) Admin
+	// Audit returns the audit service or nil if the driver doesn't support it.
+	Audit() Audit
+	// Close releases the storage's resources.
+	Close(ctx context.Context) error
+}
+
+// NewMongoStorage returns the storage driver of the mongo database. The database must be migrated via Migrate.
+func NewMongoStorage(db *mongo.Database) Storage {
+	return mongoStorage{db: db}
+}
+
+type mongoStorage struct {
+	db *mongo.Database
+}
+
+func (s mongoStorage) Definition(conf DefinitionConfig) Definition {
+	return NewDefinition(s.db.Collection(definitionsCollection), conf)
+}
+
+func (s mongoStorage) Template() Template {
+	return NewTemplate(s.db.Collection("templates"))
+}
+
+func (s mongoStorage) Health() Health {
+	return NewHealth(s.db.Client())
+}
+
+func (s mongoStorage) Admin(conf DefinitionConfig) Admin {
+	return NewAdmin(s.db.Collection(definitionsCollection), conf)
+}
+
+func (s mongoStorage) Audit() Audit {
+	return NewAudit(s.db)
+}
+
+func (s mongoStorage) Close(ctx context.Context) error {
+	return s.db.Client().Disconnect(ctx)
+}
+
+// NewMemoryStorage returns a new empty in-memory storage driver. It ignores the mongo storage options of the
+// definition configuration and doesn't support the admin and audit services.
+func NewMemoryStorage() Storage {
+	return memStorage{
+		store: newMemStore(),
+		tmpls: NewMemTemplate(),
+	}
+}
+
+type memStorage struct {
+	store *memStore
+	tmpls Template
+}
+
+func (s memStorage) Definition(conf DefinitionConfig) Definition {
+	conf.Transactions = false // Events are appended best-effort like without mongo transactions.
+
+	return newDefinition(s.store, conf)
+}
+
+func (s memStorage) Template() Template {
+	return s.tmpls
+}
+
+func (memStorage) Health() Health {
+	return memHealth{}
+}
+
+func (memStorage) Admin(DefinitionConfig) Admin {
+	return nil
+}
+
+func (memStorage) Audit() Audit {
+	return nil
+}
+
+func (memStorage) Close(context.Context) error {
+	return nil
+}
+
+// NewPostgresStorage returns the storage driver of the postgres database. Its tables must be created via
+// MigratePostgres. It ignores the mongo storage options of the definition configuration and doesn't support
+// the admin and audit services.
+func NewPostgresStorage(db *sql.DB) Storage {
+	return pgStorage{db: db}
+}
+
+type pgStorage struct {
+	db *sql.DB
+}
+
+func (s pgStorage) Definition(conf DefinitionConfig) Definition {
+	conf.Transactions = false // Mutations and their events are always applied in postgres transactions.
+
+	return newDefinition(newPGStore(s.db, conf.DBTimeouts), conf)
+}
+
+func (s pgStorage) Template() Template {
+	return NewPGTemplate(s.db)
+}
+
+func (s pgStorage) Health() Health {
+	return pgHealth{db: s.db}
+}
+
+func (pgStorage) Admin(DefinitionConfig) Admin {
+	return nil
+}
+
+func (pgStorage) Audit() Audit {
+	return nil
+}
+
+func (s pgStorage) Close(context.Context) error {
+	return s.db.Close()
+}
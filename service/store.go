@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"strings"
+)
+
+// errDuplicate is returned by document stores when inserting a document whose config hash already exists.
+var errDuplicate = errors.New("duplicate config hash")
+
+// docStore persists definition documents and their change log. It is the storage driver of the definition service,
+// which verifies, authorizes and applies the configured policies to every mutation before storing its result,
+// so drivers only store and query documents. Documents are scoped to the context's tenant unless stated otherwise.
+type docStore interface {
+	// find returns the document with the config hash, including the validators of its sharded lock, or ErrNotFound.
+	find(ctx context.Context, configHash []byte) (definitionDoc, error)
+	// findMany returns the published documents of the config hashes that exist.
+	findMany(ctx context.Context, configHashes [][]byte) ([]definitionDoc, error)
+	// findRange returns up to limit published documents with config hashes in the inclusive range, ordered by
+	// config hash.
+	findRange(ctx context.Context, from, to []byte, limit int) ([]definitionDoc, error)
+	// findValidator returns the locked document containing the distributed validator public key, including the
+	// validators of its sharded lock, or ErrNotFound.
+	findValidator(ctx context.Context, pubkey []byte) (definitionDoc, error)
+	// findChildren returns the config hashes of the documents resizing or resharing the parent.
+	findChildren(ctx context.Context, parent []byte) ([][]byte, error)
+	// list returns up to limit published documents matching the filter with config hashes after the exclusive
+	// cursor, ordered by config hash. The cursor is ignored if empty.
+	list(ctx context.Context, filter ListFilter, after []byte, limit int) ([]definitionDoc, error)
+	// validators returns the page of validators of the document's lock, or ErrNotFound if not locked.
+	validators(ctx context.Context, configHash []byte, offset, limit int) (ValidatorPage, error)
+	// count returns the number of documents of all tenants matching the filter.
+	count(ctx context.Context, filter countFilter) (int, error)
+	// stats returns the document counts matching the filter.
+	stats(ctx context.Context, filter StatsFilter) (Stats, error)
+	// insert inserts the document, returning errDuplicate if its config hash exists in any tenant.
+	// The store may update the document's storage fields, e.g., its validator chunks.
+	insert(ctx context.Context, doc *definitionDoc) error
+	// insertMany inserts the documents like insert, returning the error of each document by index, nil if inserted.
+	insertMany(ctx context.Context, docs []*definitionDoc) ([]error, error)
+	// replace replaces the document with the config hash if its revision matches, returning false if it doesn't.
+	// The store may update the document's storage fields, e.g., its validator chunks.
+	replace(ctx context.Context, configHash []byte, revision int, doc *definitionDoc) (bool, error)
+	// setRef stores the reference to the document's external copy in the ref field without changing its revision.
+	setRef(ctx context.Context, configHash []byte, field string, value string) error
+	// remove deletes the document with the config hash of doc if its revision matches, returning false if it doesn't.
+	// If trash is true, doc is retained in the trash as deleted by the principal.
+	remove(ctx context.Context, doc definitionDoc, revision int, principal string, trash bool) (bool, error)
+	// restore moves the latest deleted document with the config hash from the trash back and returns it.
+	// It returns ErrNotFound if it isn't in the trash and ErrConflict if the document exists.
+	restore(ctx context.Context, configHash []byte) (definitionDoc, error)
+	// insertEvent appends the event to the change log.
+	insertEvent(ctx context.Context, event eventDoc) error
+	// findEvents returns the document's events ordered by time, excluding their resulting documents.
+	findEvents(ctx context.Context, configHash []byte) ([]eventDoc, error)
+	// findRevision returns the document resulting from the latest event of the revision, including the validators
+	// of its sharded lock, or ErrNotFound.
+	findRevision(ctx context.Context, configHash []byte, revision int) (definitionDoc, error)
+	// watch calls opened once watching and then fn with the tenant and config hash of every event appended by any
+	// replica, until watching fails or the context is cancelled.
+	watch(ctx context.Context, opened func(), fn func(tenant string, configHash []byte)) error
+	// transact calls fn atomically with the events it appends if the store supports it, otherwise directly.
+	transact(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Ref fields of definition documents referencing their external copies, see docStore.setRef.
+const (
+	refDefinitionCID     = "definition_cid"
+	refLockCID           = "lock_cid"
+	refDefinitionArchive = "definition_archive"
+	refLockArchive       = "lock_archive"
+)
+
+// setRef sets the reference field of the document.
+func (doc *definitionDoc) setRef(field string, value string) error {
+	switch field {
+	case refDefinitionCID:
+		doc.DefinitionCID = value
+	case refLockCID:
+		doc.LockCID = value
+	case refDefinitionArchive:
+		doc.DefinitionArchive = value
+	case refLockArchive:
+		doc.LockArchive = value
+	default:
+		return errors.New("unknown reference field")
+	}
+
+	return nil
+}
+
+// countFilter filters counted documents of all tenants, empty fields don't filter.
+type countFilter struct {
+	// Owner is the creator address of the documents in any case.
+	Owner string
+	// Tenant is the tenant of the documents, it can't filter the default tenant.
+	Tenant string
+	// Statuses are the statuses of the documents.
+	Statuses []Status
+}
+
+// matches returns true if the document matches the filter.
+func (f countFilter) matches(doc definitionDoc) bool {
+	if f.Owner != "" && !strings.EqualFold(doc.Owner, f.Owner) {
+		return false
+	} else if f.Tenant != "" && doc.Tenant != f.Tenant {
+		return false
+	} else if len(f.Statuses) == 0 {
+		return true
+	}
+
+	for _, status := range f.Statuses {
+		if doc.Status == status {
+			return true
+		}
+	}
+
+	return false
+}
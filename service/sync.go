@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// syncBackoff is the delay before reopening a failed definition events watch.
+const syncBackoff = 5 * time.Second
+
+// Sync keeps the definition and lock caches consistent with mutations by other replicas, evicting the cached
+// definition and lock of every event appended to the change log, until the context is cancelled.
+// Failed watches are reopened, purging the caches since events may have been missed in between.
+func (d definitionImpl) Sync(ctx context.Context) error {
+	if d.defCache == nil {
+		return nil // Nothing is cached.
+	}
+
+	for {
+		err := d.watchEvents(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		log.Warn(ctx, "Watching definition events failed, reopening", err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(syncBackoff):
+		}
+	}
+}
+
+// watchEvents evicts the cached definition and lock of each event appended to the change log until watching fails
+// or the context is cancelled. The caches are purged once watching, so no mutation is missed between watches.
+func (d definitionImpl) watchEvents(ctx context.Context) error {
+	return d.store.watch(ctx,
+		func() {
+			d.defCache.Purge()
+			d.lockCache.Purge()
+		},
+		func(tenant string, configHash []byte) {
+			key := cacheKey(WithTenant(ctx, tenant), configHash)
+			d.defCache.Remove(key)
+			d.lockCache.Remove(key)
+		})
+}
+
+// watch watches the events inserted into the definition events collection via a change stream.
+// It requires change streams, i.e., a replica set or sharded cluster.
+func (m mongoStore) watch(ctx context.Context, opened func(), fn func(tenant string, configHash []byte)) error {
+	stream, err := m.events.Watch(ctx, mongo.Pipeline{bson.D{{"$match", bson.D{{"operationType", "insert"}}}}})
+	if err != nil {
+		return errors.Wrap(err, "failed to watch definition events")
+	}
+	defer stream.Close(context.Background())
+
+	opened()
+
+	for stream.Next(ctx) {
+		var change struct {
+			Event struct {
+				ConfigHash []byte `bson:"config_hash"`
+				Tenant     string `bson:"tenant"`
+			} `bson:"fullDocument"`
+		}
+		if err := stream.Decode(&change); err != nil {
+			return errors.Wrap(err, "failed to decode definition event")
+		}
+
+		fn(change.Event.Tenant, change.Event.ConfigHash)
+	}
+
+	if err := stream.Err(); err != nil {
+		return errors.Wrap(err, "definition events change stream")
+	}
+
+	return errors.New("definition events change stream closed")
+}
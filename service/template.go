@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"github.com/obolnetwork/charon/eth2util"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type Template interface {
+	Get(ctx context.Context, id string) (TemplateDoc, error)
+	// List returns an iterator over all templates.
+	List(ctx context.Context) (*Iterator, error)
+	// Create stores the template and returns it populated with its ID.
+	Create(ctx context.Context, tmpl TemplateDoc) (TemplateDoc, error)
+	Update(ctx context.Context, tmpl TemplateDoc) error
+	Delete(ctx context.Context, id string) error
+	// Stamp returns a new definition populated from the template defaults and the provided fields.
+	Stamp(ctx context.Context, id string, fields StampFields) (cluster.Definition, error)
+}
+
+// TemplateDoc is a reusable set of cluster definition defaults.
+type TemplateDoc struct {
+	ID                  string `json:"id" bson:"_id"`
+	Name                string `json:"name" bson:"name"`
+	NumOperators        int    `json:"num_operators" bson:"num_operators"`
+	Threshold           int    `json:"threshold" bson:"threshold"`
+	Network             string `json:"network" bson:"network"`
+	FeeRecipientAddress string `json:"fee_recipient_address,omitempty" bson:"fee_recipient_address"`
+	WithdrawalAddress   string `json:"withdrawal_address,omitempty" bson:"withdrawal_address"`
+}
+
+// StampFields are the per-definition fields of a definition stamped from a template.
+// Empty addresses default to the template's addresses.
+type StampFields struct {
+	Name                string
+	NumValidators       int
+	Operators           []string
+	FeeRecipientAddress string
+	WithdrawalAddress   string
+}
+
+func NewTemplate(table *mongo.Collection) Template {
+	return &templateImpl{
+		table: table,
+	}
+}
+
+type templateImpl struct {
+	table *mongo.Collection
+}
+
+func (t templateImpl) Get(ctx context.Context, id string) (TemplateDoc, error) {
+	res := t.table.FindOne(ctx, bson.D{{"_id", id}})
+	if errors.Is(res.Err(), mongo.ErrNoDocuments) {
+		return TemplateDoc{}, errors.Wrap(ErrNotFound, "template not found")
+	} else if res.Err() != nil {
+		return TemplateDoc{}, errors.Wrap(res.Err(), "failed to get template")
+	}
+
+	var tmpl TemplateDoc
+	if err := res.Decode(&tmpl); err != nil {
+		return TemplateDoc{}, errors.Wrap(err, "failed to decode template")
+	}
+
+	return tmpl, nil
+}
+
+func (t templateImpl) List(ctx context.Context) (*Iterator, error) {
+	cursor, err := t.table.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list templates")
+	}
+
+	return newIterator(cursor, func(cursor *mongo.Cursor) (interface{}, error) {
+		var tmpl TemplateDoc
+		if err := cursor.Decode(&tmpl); err != nil {
+			return nil, errors.Wrap(err, "failed to decode template")
+		}
+
+		return tmpl, nil
+	}), nil
+}
+
+func (t templateImpl) Create(ctx context.Context, tmpl TemplateDoc) (TemplateDoc, error) {
+	if err := verifyTemplate(tmpl); err != nil {
+		return TemplateDoc{}, err
+	}
+
+	tmpl.ID = primitive.NewObjectID().Hex()
+
+	_, err := t.table.InsertOne(ctx, tmpl)
+	if err != nil {
+		return TemplateDoc{}, errors.Wrap(err, "failed to create template")
+	}
+
+	return tmpl, nil
+}
+
+func (t templateImpl) Update(ctx context.Context, tmpl TemplateDoc) error {
+	if err := verifyTemplate(tmpl); err != nil {
+		return err
+	}
+
+	res, err := t.table.ReplaceOne(ctx, bson.D{{"_id", tmpl.ID}}, tmpl)
+	if err != nil {
+		return errors.Wrap(err, "failed to update template")
+	} else if res.MatchedCount == 0 {
+		return errors.Wrap(ErrNotFound, "template not found")
+	}
+
+	return nil
+}
+
+func (t templateImpl) Delete(ctx context.Context, id string) error {
+	res, err := t.table.DeleteOne(ctx, bson.D{{"_id", id}})
+	if err != nil {
+		return errors.Wrap(err, "failed to delete template")
+	} else if res.DeletedCount == 0 {
+		return errors.Wrap(ErrNotFound, "template not found")
+	}
+
+	return nil
+}
+
+func (t templateImpl) Stamp(ctx context.Context, id string, fields StampFields) (cluster.Definition, error) {
+	tmpl, err := t.Get(ctx, id)
+	if err != nil {
+		return cluster.Definition{}, err
+	}
+
+	return stamp(tmpl, fields)
+}
+
+// stamp returns a new definition populated from the template defaults and the provided fields.
+func stamp(tmpl TemplateDoc, fields StampFields) (cluster.Definition, error) {
+	if len(fields.Operators) != tmpl.NumOperators {
+		return cluster.Definition{}, errors.Wrap(ErrInvalidRequest, "operator count mismatch",
+			z.Int("expected", tmpl.NumOperators), z.Int("actual", len(fields.Operators)))
+	} else if fields.NumValidators <= 0 {
+		return cluster.Definition{}, errors.Wrap(ErrInvalidRequest, "invalid number of validators")
+	}
+
+	if fields.FeeRecipientAddress == "" {
+		fields.FeeRecipientAddress = tmpl.FeeRecipientAddress
+	}
+	if fields.WithdrawalAddress == "" {
+		fields.WithdrawalAddress = tmpl.WithdrawalAddress
+	}
+
+	forkVersion, err := eth2util.NetworkToForkVersion(tmpl.Network)
+	if err != nil {
+		return cluster.Definition{}, errors.Wrap(err, "template network")
+	}
+
+	var operators []cluster.Operator
+	for _, address := range fields.Operators {
+		operators = append(operators, cluster.Operator{Address: address})
+	}
+
+	def, err := cluster.NewDefinition(fields.Name, fields.NumValidators, tmpl.Threshold,
+		fields.FeeRecipientAddress, fields.WithdrawalAddress, forkVersion, cluster.Creator{}, operators, rand.Reader)
+	if err != nil {
+		return cluster.Definition{}, errors.Wrap(ErrInvalidRequest, "invalid definition", z.Err(err))
+	}
+
+	return def, nil
+}
+
+// verifyTemplate returns an error if the template defaults cannot produce a valid definition.
+func verifyTemplate(tmpl TemplateDoc) error {
+	if tmpl.NumOperators <= 0 {
+		return errors.Wrap(ErrInvalidRequest, "invalid number of operators")
+	} else if tmpl.Threshold <= 0 || tmpl.Threshold > tmpl.NumOperators {
+		return errors.Wrap(ErrInvalidRequest, "invalid threshold", z.Int("threshold", tmpl.Threshold))
+	} else if _, err := eth2util.NetworkToForkVersion(tmpl.Network); err != nil {
+		return errors.Wrap(ErrInvalidRequest, fmt.Sprintf("unknown network %s", tmpl.Network))
+	}
+
+	return nil
+}
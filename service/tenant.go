@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// defaultTenant is the metrics label of the default tenant, i.e., of requests not scoped to a tenant.
+const defaultTenant = "default"
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of the context scoped to the tenant. Definitions are only visible to the tenant
+// that created them, isolating organizations sharing an instance. Contexts without a tenant are scoped to the
+// default tenant, i.e., definitions created without a tenant.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// tenantFromCtx returns the context's tenant, empty for the default tenant.
+func tenantFromCtx(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// tenantLabel returns the metrics label of the context's tenant.
+func tenantLabel(ctx context.Context) string {
+	if tenant := tenantFromCtx(ctx); tenant != "" {
+		return tenant
+	}
+
+	return defaultTenant
+}
+
+// tenantMatch returns the filter element matching the definition documents of the context's tenant.
+// Documents of the default tenant have no tenant field, which a null filter matches.
+func tenantMatch(ctx context.Context) bson.E {
+	if tenant := tenantFromCtx(ctx); tenant != "" {
+		return bson.E{Key: "tenant", Value: tenant}
+	}
+
+	return bson.E{Key: "tenant", Value: nil}
+}
+
+// cacheKey returns the key of the config hash in the definition and lock caches, scoped to the context's tenant.
+func cacheKey(ctx context.Context, configHash []byte) string {
+	return tenantFromCtx(ctx) + "/" + string(configHash)
+}
+
+// verifyTenantQuota returns ErrInvalidState if the context's tenant reached its definition quota,
+// including the pending definitions being created concurrently in a batch. The default tenant has no quota.
+func (d definitionImpl) verifyTenantQuota(ctx context.Context, pending int) error {
+	tenant := tenantFromCtx(ctx)
+	if d.conf.TenantQuota == 0 || tenant == "" {
+		return nil
+	}
+
+	count, err := d.store.count(ctx, countFilter{Tenant: tenant})
+	if err != nil {
+		return err
+	} else if count+pending >= d.conf.TenantQuota {
+		return errors.Wrap(ErrInvalidState, "tenant definition quota exceeded",
+			z.Str("tenant", tenant), z.Int("quota", d.conf.TenantQuota))
+	}
+
+	return nil
+}
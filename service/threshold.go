@@ -0,0 +1,28 @@
+package service
+
+import (
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"math"
+)
+
+// verifyThreshold returns an error if the definition threshold violates the configured threshold policy.
+func (d definitionImpl) verifyThreshold(def cluster.Definition) error {
+	nodes := len(def.Operators)
+
+	if d.conf.BFTThreshold && def.Threshold != cluster.Threshold(nodes) {
+		return errors.Wrap(ErrInvalidRequest, "threshold not byzantine fault tolerant",
+			z.Int("threshold", def.Threshold), z.Int("expected", cluster.Threshold(nodes)))
+	}
+
+	if d.conf.MinThresholdRatio > 0 {
+		min := int(math.Ceil(d.conf.MinThresholdRatio * float64(nodes)))
+		if def.Threshold < min {
+			return errors.Wrap(ErrInvalidRequest, "threshold below minimum",
+				z.Int("threshold", def.Threshold), z.Int("min", min))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// Mongo operation types with distinct deadlines.
+const (
+	opFind   = "find"
+	opInsert = "insert"
+	opUpdate = "update"
+)
+
+// DBTimeouts are the deadlines of individual mongo operations by type, distinct from the request deadline,
+// so a single slow query doesn't consume the whole request budget. Operations are only bound by the request
+// deadline if zero.
+type DBTimeouts struct {
+	Find   time.Duration
+	Insert time.Duration
+	// Update is the deadline of update and delete operations.
+	Update time.Duration
+}
+
+// withTimeout returns a copy of the context with the deadline of the operation type.
+func (t DBTimeouts) withTimeout(ctx context.Context, op string) (context.Context, context.CancelFunc) {
+	timeout := map[string]time.Duration{
+		opFind:   t.Find,
+		opInsert: t.Insert,
+		opUpdate: t.Update,
+	}[op]
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// wrapDBErr wraps the mongo operation error, converting timeouts to ErrTimeout.
+func wrapDBErr(err error, op string, msg string, fields ...z.Field) error {
+	if errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err) {
+		dbTimeouts.WithLabelValues(op).Inc()
+		return errors.Wrap(ErrTimeout, msg, append(fields, z.Str("op", op))...)
+	}
+
+	return errors.Wrap(err, msg, fields...)
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// transact calls fn in a mongo transaction if transactions are enabled, so the definition mutation, its change log
+// event and audit entry are applied atomically. Transient transaction errors, e.g., write conflicts or replica set
+// elections, are retried by calling fn again. Without transactions fn is called directly.
+func (m mongoStore) transact(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !m.conf.Transactions {
+		return fn(ctx)
+	}
+
+	session, err := m.table.Database().Client().StartSession()
+	if err != nil {
+		return wrapDBErr(err, opUpdate, "failed to start session")
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+
+	return err
+}
+
+// recordEvent appends the mutation to the definition's change log and records it in the audit log.
+// Within transactions failures abort the mutation, otherwise they are logged rather than failing
+// the already applied mutation.
+func (d definitionImpl) recordEvent(ctx context.Context, typ EventType, configHash []byte, revision int, doc *definitionDoc) error {
+	if !d.conf.Transactions {
+		d.appendEvent(ctx, typ, configHash, revision, doc)
+		return nil
+	}
+
+	if err := d.insertEvent(ctx, typ, configHash, revision, time.Now(), doc); err != nil {
+		return err
+	}
+
+	return d.recordAudit(ctx, typ, configHash, revision)
+}
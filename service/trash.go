@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// trashCollection is the name of the collection of deleted definitions, in the same database as the definitions.
+const trashCollection = "definitions_trash"
+
+// trashDoc is the mongo document of a deleted definition retained for restoring.
+type trashDoc struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	ConfigHash []byte             `bson:"config_hash"`
+	DeletedAt  time.Time          `bson:"deleted_at"`
+	DeletedBy  string             `bson:"deleted_by"`
+	// Document is the stored definition document as is, so compressed documents are restored compressed.
+	Document bson.Raw `bson:"document"`
+}
+
+// trashIndexes are the trash collection indexes.
+var trashIndexes = []mongo.IndexModel{
+	{Keys: bson.D{{"config_hash", 1}, {"deleted_at", -1}}},
+	{Keys: bson.D{{"deleted_at", 1}}},
+}
+
+// trashDefinition moves the definition document with the config hash of doc and the revision to the trash,
+// retaining doc as its final state. It returns false if no document matches, e.g., it was concurrently modified.
+func (m mongoStore) trashDefinition(ctx context.Context, doc definitionDoc, revision int, principal string) (bool, error) {
+	filter := bson.D{{"config_hash", doc.ConfigHash}, {"revision", revision}}
+
+	var ref struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	err := m.table.FindOne(ctx, filter, options.FindOne().SetProjection(bson.D{{"_id", 1}})).Decode(&ref)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	} else if err != nil {
+		return false, wrapDBErr(err, opFind, "failed to get definition")
+	}
+
+	stored, err := m.compress(doc)
+	if err != nil {
+		return false, err
+	}
+
+	// The trash copy retains the document's id, i.e., its creation time, and concurrent restores conflict.
+	raw, err := bson.Marshal(struct {
+		ID            primitive.ObjectID `bson:"_id"`
+		definitionDoc `bson:",inline"`
+	}{ID: ref.ID, definitionDoc: stored})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to encode definition")
+	}
+
+	// Insert the trash copy first, so a failure never loses the definition.
+	trashed := trashDoc{
+		ID:         primitive.NewObjectID(),
+		ConfigHash: doc.ConfigHash,
+		DeletedAt:  time.Now().UTC(),
+		DeletedBy:  principal,
+		Document:   raw,
+	}
+	if _, err := m.trash.InsertOne(ctx, trashed); err != nil {
+		return false, wrapDBErr(err, opInsert, "failed to trash definition")
+	}
+
+	res, err := m.table.DeleteOne(ctx, bson.D{{"_id", ref.ID}, {"revision", revision}})
+	if err == nil && res.DeletedCount > 0 {
+		return true, nil
+	}
+
+	// Concurrently modified or failed, so the definition remains.
+	if _, derr := m.trash.DeleteOne(ctx, bson.D{{"_id", trashed.ID}}); derr != nil {
+		return false, wrapDBErr(derr, opUpdate, "failed to remove trash copy")
+	} else if err != nil {
+		return false, wrapDBErr(err, opUpdate, "failed to delete definition")
+	}
+
+	return false, nil
+}
+
+// Restore restores the latest deleted definition from the trash.
+func (d definitionImpl) Restore(ctx context.Context, configHash []byte) error {
+	doc, err := d.store.restore(ctx, configHash)
+	if err != nil {
+		return err
+	}
+	d.appendEvent(ctx, EventRestored, configHash, doc.Revision, &doc)
+
+	return nil
+}
+
+func (m mongoStore) restore(ctx context.Context, configHash []byte) (definitionDoc, error) {
+	dbCtx, cancel := m.conf.DBTimeouts.withTimeout(ctx, opFind)
+	defer cancel()
+
+	var trashed trashDoc
+	err := m.trash.FindOne(dbCtx, bson.D{{"config_hash", configHash}},
+		options.FindOne().SetSort(bson.D{{"deleted_at", -1}})).Decode(&trashed)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return definitionDoc{}, errors.Wrap(ErrNotFound, "deleted definition not found")
+	} else if err != nil {
+		return definitionDoc{}, wrapDBErr(err, opFind, "failed to get deleted definition")
+	}
+
+	if _, err := m.findStored(ctx, configHash); err == nil {
+		return definitionDoc{}, errors.Wrap(ErrConflict, "definition already exists")
+	} else if !errors.Is(err, ErrNotFound) {
+		return definitionDoc{}, err
+	}
+
+	var doc definitionDoc
+	if err := bson.Unmarshal(trashed.Document, &doc); err != nil {
+		return definitionDoc{}, errors.Wrap(err, "failed to decode definition")
+	} else if doc.Tenant != tenantFromCtx(ctx) {
+		return definitionDoc{}, errors.Wrap(ErrNotFound, "deleted definition not found")
+	}
+
+	dbCtx, cancel = m.conf.DBTimeouts.withTimeout(ctx, opInsert)
+	defer cancel()
+
+	// The document retains its id, so concurrent restores conflict.
+	if _, err := m.table.InsertOne(dbCtx, trashed.Document); mongo.IsDuplicateKeyError(err) {
+		return definitionDoc{}, errors.Wrap(ErrConflict, "definition concurrently restored")
+	} else if err != nil {
+		return definitionDoc{}, wrapDBErr(err, opInsert, "failed to restore definition")
+	}
+
+	if _, err := m.trash.DeleteOne(dbCtx, bson.D{{"_id", trashed.ID}}); err != nil {
+		return definitionDoc{}, wrapDBErr(err, opUpdate, "failed to remove restored definition from trash")
+	}
+
+	return doc, nil
+}
+
+// PurgeTrash permanently deletes the definitions deleted longer than the trash retention ago and returns their count.
+func (a *adminImpl) PurgeTrash(ctx context.Context) (int, error) {
+	if a.defs.conf.TrashRetention == 0 {
+		return 0, nil
+	}
+
+	cutoff := time.Now().Add(-a.defs.conf.TrashRetention)
+	res, err := a.store.trash.DeleteMany(ctx, bson.D{{"deleted_at", bson.D{{"$lt", cutoff}}}})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to purge trash", z.Any("cutoff", cutoff))
+	}
+
+	return int(res.DeletedCount), nil
+}
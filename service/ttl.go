@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// ttlPrincipal is the principal of deletions of definitions exceeding the definition TTL, recorded in the trash.
+const ttlPrincipal = "definition-ttl"
+
+// ExpireStale deletes the unpinned unpublished and draft definitions created longer than the definition TTL ago
+// and returns their config hashes. Deleted definitions are retained in the trash if enabled.
+func (a *adminImpl) ExpireStale(ctx context.Context) ([]string, error) {
+	if a.defs.conf.DefinitionTTL == 0 {
+		return []string{}, nil
+	}
+
+	// Object ids start with their creation timestamp, so documents created before the cutoff have smaller ids.
+	cutoff := primitive.NewObjectIDFromTimestamp(time.Now().Add(-a.defs.conf.DefinitionTTL))
+	cursor, err := a.table.Find(ctx, bson.D{
+		{"_id", bson.D{{"$lt", cutoff}}},
+		{"status", bson.D{{"$in", bson.A{StatusUnpublished, StatusDraft}}}},
+		{"pinned", bson.D{{"$ne", true}}},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find stale definitions")
+	}
+	defer cursor.Close(ctx)
+
+	resp := []string{}
+	for cursor.Next(ctx) {
+		var doc definitionDoc
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, errors.Wrap(err, "failed to decode definition")
+		}
+
+		// Only delete the same revision, i.e., not if concurrently joined or pinned.
+		removed, err := a.store.remove(ctx, doc, doc.Revision, ttlPrincipal, a.defs.conf.TrashRetention > 0)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to delete stale definition")
+		} else if !removed {
+			continue
+		}
+
+		a.defs.appendEvent(ctx, EventDeleted, doc.ConfigHash, doc.Revision+1, nil)
+		expiredDefinitions.WithLabelValues(string(doc.Status)).Inc()
+
+		resp = append(resp, fmt.Sprintf("%#x", doc.ConfigHash))
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to iterate stale definitions")
+	}
+
+	return resp, nil
+}
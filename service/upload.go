@@ -0,0 +1,295 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const (
+	// MaxUploadPartSize is the maximum size in bytes of a chunked upload part.
+	MaxUploadPartSize = 8 << 20
+	// MaxUploadParts is the maximum number of parts of a chunked upload.
+	MaxUploadParts = 128
+	// uploadIDLen is the number of random bytes in an upload id.
+	uploadIDLen = 16
+)
+
+// UploadKind is the type of payload of a chunked upload.
+type UploadKind string
+
+const (
+	UploadDefinition UploadKind = "definition"
+	UploadLock       UploadKind = "lock"
+)
+
+type Uploads interface {
+	// Init starts a new chunked upload of the kind by the principal, expiring after the configured TTL.
+	// The config hash is the definition being locked by lock uploads.
+	Init(ctx context.Context, kind UploadKind, configHash []byte, principal string) (Upload, error)
+	// Get returns the upload of the principal including its received parts, allowing clients to resume.
+	Get(ctx context.Context, id string, principal string) (Upload, error)
+	// Append stores the part at the index, replacing any previously received part at the index.
+	Append(ctx context.Context, id string, principal string, index int, data []byte) error
+	// Assemble returns the payload of the received parts in index order, verifying the parts are contiguous and
+	// the payload's sha256 hash.
+	Assemble(ctx context.Context, id string, principal string, hash []byte) ([]byte, Upload, error)
+	// Delete deletes the upload and its parts.
+	Delete(ctx context.Context, id string, principal string) error
+}
+
+// Upload is a chunked upload of a payload exceeding practical single request sizes.
+type Upload struct {
+	ID   string     `json:"id"`
+	Kind UploadKind `json:"kind"`
+	// ConfigHash is the 0x-hex config hash of the definition being locked, empty for definition uploads.
+	ConfigHash string    `json:"config_hash,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Parts are the indexes of the received parts in ascending order.
+	Parts []int `json:"parts"`
+	// Size is the total size in bytes of the received parts.
+	Size int64 `json:"size"`
+}
+
+// uploadDoc is the mongo document of a chunked upload.
+type uploadDoc struct {
+	ID         string     `bson:"_id"`
+	Kind       UploadKind `bson:"kind"`
+	ConfigHash []byte     `bson:"config_hash,omitempty"`
+	Principal  string     `bson:"principal"`
+	ExpiresAt  time.Time  `bson:"expires_at"`
+}
+
+// uploadPartDoc is the mongo document of a chunked upload part.
+type uploadPartDoc struct {
+	UploadID  string    `bson:"upload_id"`
+	Index     int       `bson:"index"`
+	Data      []byte    `bson:"data"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// NewUploads returns the chunked uploads stored in the collection and its parts collection of the same database,
+// creating their indexes. Abandoned uploads and their parts are deleted by mongo after the TTL.
+func NewUploads(ctx context.Context, table *mongo.Collection, ttl time.Duration) (Uploads, error) {
+	parts := table.Database().Collection(table.Name() + "_parts")
+
+	ttlIndex := mongo.IndexModel{Keys: bson.D{{"expires_at", 1}}, Options: options.Index().SetExpireAfterSeconds(0)}
+
+	if _, err := table.Indexes().CreateOne(ctx, ttlIndex); err != nil {
+		return nil, errors.Wrap(err, "failed to create upload indexes")
+	}
+
+	_, err := parts.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		ttlIndex,
+		{Keys: bson.D{{"upload_id", 1}, {"index", 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create upload part indexes")
+	}
+
+	return uploadsImpl{table: table, parts: parts, ttl: ttl}, nil
+}
+
+type uploadsImpl struct {
+	table *mongo.Collection
+	parts *mongo.Collection
+	ttl   time.Duration
+}
+
+func (u uploadsImpl) Init(ctx context.Context, kind UploadKind, configHash []byte, principal string) (Upload, error) {
+	switch kind {
+	case UploadDefinition:
+		configHash = nil
+	case UploadLock:
+		if len(configHash) == 0 {
+			return Upload{}, errors.Wrap(ErrInvalidRequest, "missing lock upload config hash")
+		}
+	default:
+		return Upload{}, errors.Wrap(ErrInvalidRequest, "invalid upload kind", z.Str("kind", string(kind)))
+	}
+
+	id := make([]byte, uploadIDLen)
+	if _, err := rand.Read(id); err != nil {
+		return Upload{}, errors.Wrap(err, "generate upload id")
+	}
+
+	doc := uploadDoc{
+		ID:         hex.EncodeToString(id),
+		Kind:       kind,
+		ConfigHash: configHash,
+		Principal:  principal,
+		ExpiresAt:  time.Now().Add(u.ttl).UTC().Truncate(time.Millisecond), // Mongo dates have millisecond precision.
+	}
+
+	if _, err := u.table.InsertOne(ctx, doc); err != nil {
+		return Upload{}, errors.Wrap(err, "failed to insert upload")
+	}
+
+	return doc.upload(nil), nil
+}
+
+func (u uploadsImpl) Get(ctx context.Context, id string, principal string) (Upload, error) {
+	doc, err := u.getDoc(ctx, id, principal)
+	if err != nil {
+		return Upload{}, err
+	}
+
+	cursor, err := u.parts.Find(ctx, bson.D{{"upload_id", id}}, options.Find().
+		SetSort(bson.D{{"index", 1}}).
+		SetProjection(bson.D{{"index", 1}, {"size", bson.D{{"$binarySize", "$data"}}}}))
+	if err != nil {
+		return Upload{}, errors.Wrap(err, "failed to find upload parts")
+	}
+
+	var parts []struct {
+		Index int   `bson:"index"`
+		Size  int64 `bson:"size"`
+	}
+	if err := cursor.All(ctx, &parts); err != nil {
+		return Upload{}, errors.Wrap(err, "failed to decode upload parts")
+	}
+
+	resp := doc.upload(nil)
+	for _, part := range parts {
+		resp.Parts = append(resp.Parts, part.Index)
+		resp.Size += part.Size
+	}
+
+	return resp, nil
+}
+
+func (u uploadsImpl) Append(ctx context.Context, id string, principal string, index int, data []byte) error {
+	if index < 0 || index >= MaxUploadParts {
+		return errors.Wrap(ErrInvalidRequest, "invalid upload part index", z.Int("index", index), z.Int("max", MaxUploadParts-1))
+	} else if len(data) == 0 {
+		return errors.Wrap(ErrInvalidRequest, "empty upload part")
+	} else if len(data) > MaxUploadPartSize {
+		return errors.Wrap(ErrInvalidRequest, "upload part too large", z.Int("size", len(data)), z.Int("max", MaxUploadPartSize))
+	}
+
+	doc, err := u.getDoc(ctx, id, principal)
+	if err != nil {
+		return err
+	}
+
+	part := uploadPartDoc{
+		UploadID:  id,
+		Index:     index,
+		Data:      data,
+		ExpiresAt: doc.ExpiresAt,
+	}
+
+	_, err = u.parts.ReplaceOne(ctx, bson.D{{"upload_id", id}, {"index", index}}, part, options.Replace().SetUpsert(true))
+	if err != nil {
+		return errors.Wrap(err, "failed to store upload part")
+	}
+
+	return nil
+}
+
+func (u uploadsImpl) Assemble(ctx context.Context, id string, principal string, hash []byte) ([]byte, Upload, error) {
+	doc, err := u.getDoc(ctx, id, principal)
+	if err != nil {
+		return nil, Upload{}, err
+	}
+
+	cursor, err := u.parts.Find(ctx, bson.D{{"upload_id", id}}, options.Find().SetSort(bson.D{{"index", 1}}))
+	if err != nil {
+		return nil, Upload{}, errors.Wrap(err, "failed to find upload parts")
+	}
+	defer cursor.Close(ctx)
+
+	var (
+		payload bytes.Buffer
+		indexes []int
+	)
+	for cursor.Next(ctx) {
+		var part uploadPartDoc
+		if err := cursor.Decode(&part); err != nil {
+			return nil, Upload{}, errors.Wrap(err, "failed to decode upload part")
+		} else if part.Index != len(indexes) {
+			return nil, Upload{}, errors.Wrap(ErrInvalidState, "missing upload part", z.Int("index", len(indexes)))
+		}
+
+		indexes = append(indexes, part.Index)
+		payload.Write(part.Data)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, Upload{}, errors.Wrap(err, "failed to iterate upload parts")
+	} else if len(indexes) == 0 {
+		return nil, Upload{}, errors.Wrap(ErrInvalidState, "no upload parts")
+	}
+
+	actual := sha256.Sum256(payload.Bytes())
+	if !bytes.Equal(actual[:], hash) {
+		return nil, Upload{}, errors.Wrap(ErrInvalidRequest, "upload hash mismatch",
+			z.Str("expected", fmt.Sprintf("%#x", hash)), z.Str("actual", fmt.Sprintf("%#x", actual)))
+	}
+
+	resp := doc.upload(indexes)
+	resp.Size = int64(payload.Len())
+
+	return payload.Bytes(), resp, nil
+}
+
+func (u uploadsImpl) Delete(ctx context.Context, id string, principal string) error {
+	if _, err := u.getDoc(ctx, id, principal); err != nil {
+		return err
+	}
+
+	if _, err := u.parts.DeleteMany(ctx, bson.D{{"upload_id", id}}); err != nil {
+		return errors.Wrap(err, "failed to delete upload parts")
+	}
+
+	if _, err := u.table.DeleteOne(ctx, bson.D{{"_id", id}}); err != nil {
+		return errors.Wrap(err, "failed to delete upload")
+	}
+
+	return nil
+}
+
+// getDoc returns the unexpired upload of the principal. Uploads of other principals are not found.
+func (u uploadsImpl) getDoc(ctx context.Context, id string, principal string) (uploadDoc, error) {
+	var doc uploadDoc
+	err := u.table.FindOne(ctx, bson.D{{"_id", id}}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return uploadDoc{}, errors.Wrap(ErrNotFound, "upload not found")
+	} else if err != nil {
+		return uploadDoc{}, errors.Wrap(err, "failed to get upload")
+	} else if doc.Principal != principal {
+		return uploadDoc{}, errors.Wrap(ErrNotFound, "upload not found")
+	} else if time.Now().After(doc.ExpiresAt) {
+		// Mongo only deletes expired documents periodically.
+		return uploadDoc{}, errors.Wrap(ErrNotFound, "upload expired")
+	}
+
+	return doc, nil
+}
+
+// upload returns the upload response with the received part indexes.
+func (d uploadDoc) upload(parts []int) Upload {
+	resp := Upload{
+		ID:        d.ID,
+		Kind:      d.Kind,
+		ExpiresAt: d.ExpiresAt,
+		Parts:     parts,
+	}
+	if resp.Parts == nil {
+		resp.Parts = []int{}
+	}
+	if len(d.ConfigHash) > 0 {
+		resp.ConfigHash = fmt.Sprintf("%#x", d.ConfigHash)
+	}
+
+	return resp
+}
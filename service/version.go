@@ -0,0 +1,51 @@
+package service
+
+import (
+	"github.com/obolnetwork/charon/app/errors"
+	"github.com/obolnetwork/charon/app/z"
+	"github.com/obolnetwork/charon/cluster"
+	"strings"
+)
+
+// DefinitionVersions are the supported definition versions, oldest first.
+var DefinitionVersions = []string{"v1.0.0", "v1.1.0", "v1.2.0", "v1.3.0", "v1.4.0"}
+
+// versionIndex returns the index of the version in DefinitionVersions or -1 if it isn't supported.
+func versionIndex(version string) int {
+	for i, v := range DefinitionVersions {
+		if v == version {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ConvertDefinition returns the definition upconverted to the version, allowing clients of different charon
+// releases to share definitions. The definition and config hashes change with the version, so only definitions
+// without creator or operator signatures can be converted. Definitions are never downconverted since older
+// versions can't represent all fields.
+func ConvertDefinition(def cluster.Definition, version string) (cluster.Definition, error) {
+	if version == def.Version {
+		return def, nil
+	}
+
+	to := versionIndex(version)
+	if to < 0 {
+		return cluster.Definition{}, errors.Wrap(ErrInvalidRequest, "unsupported definition version",
+			z.Str("version", version), z.Str("supported", strings.Join(DefinitionVersions, ",")))
+	} else if from := versionIndex(def.Version); from < 0 || to < from {
+		return cluster.Definition{}, errors.Wrap(ErrInvalidRequest, "definition can't be downconverted",
+			z.Str("version", def.Version), z.Str("requested", version))
+	} else if operatorSigned(def) || len(def.Creator.ConfigSignature) > 0 {
+		return cluster.Definition{}, errors.Wrap(ErrInvalidState, "signed definition can't be converted, signatures commit to its version")
+	}
+
+	def.Version = version
+	def, err := def.SetDefinitionHashes()
+	if err != nil {
+		return cluster.Definition{}, errors.Wrap(ErrInvalidState, "failed to hash converted definition", z.Err(err))
+	}
+
+	return def, nil
+}
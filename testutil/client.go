@@ -0,0 +1,241 @@
+package testutil
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"github.com/corverroos/dvstore/service"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/obolnetwork/charon/cluster"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Client is a dvstore API client of a test server that fails the test on unexpected responses.
+type Client struct {
+	t   *testing.T
+	url string
+	// lastTimestamp is the latest signed request timestamp, since timestamps must increase per address.
+	lastTimestamp *int64
+}
+
+// NewClient returns a new client of the test server.
+func NewClient(t *testing.T, srv *httptest.Server) Client {
+	t.Helper()
+
+	return Client{t: t, url: srv.URL + "/v1", lastTimestamp: new(int64)}
+}
+
+// requestTimestamp returns the timestamp of a signed request, the current time unless a request was already
+// signed in the same second.
+func (c Client) requestTimestamp() int64 {
+	ts := time.Now().Unix()
+	if ts <= *c.lastTimestamp {
+		ts = *c.lastTimestamp + 1
+	}
+	*c.lastTimestamp = ts
+
+	return ts
+}
+
+// Do sends the request with the json encoded body, if not nil, to the versioned API path and returns the response
+// status code and body. It fails the test on transport errors only, so callers can assert error responses.
+func (c Client) Do(method, path string, body interface{}) (int, []byte) {
+	c.t.Helper()
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatalf("marshal request body: %v", err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.url+path, reqBody)
+	if err != nil {
+		c.t.Fatalf("new request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatalf("read response body: %v", err)
+	}
+
+	return resp.StatusCode, respBody
+}
+
+// mustDo sends the request and decodes the json response body into the response, if not nil.
+// It fails the test if the response status isn't 200 OK.
+func (c Client) mustDo(method, path string, body interface{}, response interface{}) {
+	c.t.Helper()
+
+	status, respBody := c.Do(method, path, body)
+	if status != http.StatusOK {
+		c.t.Fatalf("%s %s: unexpected status %d: %s", method, path, status, respBody)
+	}
+
+	if response == nil {
+		return
+	}
+
+	if err := json.Unmarshal(respBody, response); err != nil {
+		c.t.Fatalf("%s %s: decode response body: %v", method, path, err)
+	}
+}
+
+// CreateDefinition creates the definition.
+func (c Client) CreateDefinition(def cluster.Definition) {
+	c.t.Helper()
+	c.mustDo(http.MethodPost, "/dv", def, nil)
+}
+
+// AddOperator adds the operator, with its ENR and signatures populated, to the definition.
+// The request is signed by the operator's key.
+func (c Client) AddOperator(def cluster.Definition, operator cluster.Operator, key *ecdsa.PrivateKey) {
+	c.t.Helper()
+
+	path := fmt.Sprintf("/dv/%#x", def.ConfigHash)
+	if status, body := c.TryAddOperator(def, operator, key); status != http.StatusOK {
+		c.t.Fatalf("%s %s: unexpected status %d: %s", http.MethodPut, path, status, body)
+	}
+}
+
+// TryAddOperator sends the request adding the operator to the definition signed by the key, which needn't be the
+// operator's, and returns the response status code and body, so callers can assert rejections.
+func (c Client) TryAddOperator(def cluster.Definition, operator cluster.Operator, key *ecdsa.PrivateKey) (int, []byte) {
+	c.t.Helper()
+
+	auth, err := service.SignAddOperator(key, def, operator, c.requestTimestamp())
+	if err != nil {
+		c.t.Fatalf("sign add operator: %v", err)
+	}
+
+	req := struct {
+		Address          string `json:"address"`
+		ENR              string `json:"enr"`
+		ConfigSignature  string `json:"config_signature"`
+		ENRSignature     string `json:"enr_signature"`
+		ForkVersion      string `json:"fork_version"`
+		Version          string `json:"version"`
+		RequestTimestamp string `json:"request_timestamp"`
+		RequestSignature string `json:"request_signature"`
+	}{
+		Address:          operator.Address,
+		ENR:              operator.ENR,
+		ConfigSignature:  fmt.Sprintf("%#x", operator.ConfigSignature),
+		ENRSignature:     fmt.Sprintf("%#x", operator.ENRSignature),
+		ForkVersion:      fmt.Sprintf("%#x", def.ForkVersion),
+		Version:          def.Version,
+		RequestTimestamp: strconv.FormatInt(auth.Timestamp, 10),
+		RequestSignature: fmt.Sprintf("%#x", auth.Signature),
+	}
+
+	return c.Do(http.MethodPut, fmt.Sprintf("/dv/%#x", def.ConfigHash), req)
+}
+
+// Finalize finalizes the definition with the config hash once all operators joined.
+func (c Client) Finalize(configHash []byte) {
+	c.t.Helper()
+	c.mustDo(http.MethodPost, fmt.Sprintf("/dv/%#x/finalize", configHash), nil, nil)
+}
+
+// Delete deletes the definition, signing the request of its current revision by the creator's key.
+func (c Client) Delete(def cluster.Definition, key *ecdsa.PrivateKey) {
+	c.t.Helper()
+
+	state := c.GetState(def.ConfigHash)
+
+	auth, err := service.SignDelete(key, def, state.Revision, c.requestTimestamp())
+	if err != nil {
+		c.t.Fatalf("sign delete: %v", err)
+	}
+
+	req := struct {
+		Address          string `json:"address"`
+		RequestTimestamp string `json:"request_timestamp"`
+		RequestSignature string `json:"request_signature"`
+	}{
+		Address:          crypto.PubkeyToAddress(key.PublicKey).Hex(),
+		RequestTimestamp: strconv.FormatInt(auth.Timestamp, 10),
+		RequestSignature: fmt.Sprintf("%#x", auth.Signature),
+	}
+
+	c.mustDo(http.MethodDelete, fmt.Sprintf("/dv/%#x", def.ConfigHash), req, nil)
+}
+
+// Lock stores the lock of the definition.
+func (c Client) Lock(lock cluster.Lock) {
+	c.t.Helper()
+	c.mustDo(http.MethodPost, fmt.Sprintf("/dv/%#x/lock", lock.Definition.ConfigHash), lock, nil)
+}
+
+// GetDefinition returns the definition with the config hash.
+func (c Client) GetDefinition(configHash []byte) cluster.Definition {
+	c.t.Helper()
+
+	var def cluster.Definition
+	c.mustDo(http.MethodGet, fmt.Sprintf("/dv/%#x", configHash), nil, &def)
+
+	return def
+}
+
+// GetState returns the ceremony state of the definition with the config hash.
+func (c Client) GetState(configHash []byte) service.State {
+	c.t.Helper()
+
+	var state service.State
+	c.mustDo(http.MethodGet, fmt.Sprintf("/dv/%#x/state", configHash), nil, &state)
+
+	return state
+}
+
+// GetLock returns the lock of the definition with the config hash.
+func (c Client) GetLock(configHash []byte) cluster.Lock {
+	c.t.Helper()
+
+	var lock cluster.Lock
+	c.mustDo(http.MethodGet, fmt.Sprintf("/dv/%#x/lock", configHash), nil, &lock)
+
+	return lock
+}
+
+// RunLifecycle drives the fixture's cluster through its lifecycle against the server: it creates the draft,
+// adds all operators, verifies the completed definition, finalizes it, stores the lock and verifies the stored lock.
+func RunLifecycle(t *testing.T, c Client, f Fixture) {
+	t.Helper()
+
+	c.CreateDefinition(f.Draft)
+
+	for i := range f.Draft.Operators {
+		c.AddOperator(f.Draft, f.Operator(i), f.OperatorKeys[i])
+	}
+
+	complete := c.GetDefinition(f.Draft.ConfigHash)
+	if !bytes.Equal(complete.DefinitionHash, f.Complete().DefinitionHash) {
+		t.Fatalf("completed definition hash mismatch: got %#x, want %#x",
+			complete.DefinitionHash, f.Complete().DefinitionHash)
+	}
+
+	c.Finalize(f.Draft.ConfigHash)
+	c.Lock(f.Lock)
+
+	lock := c.GetLock(f.Draft.ConfigHash)
+	if !bytes.Equal(lock.LockHash, f.Lock.LockHash) {
+		t.Fatalf("lock hash mismatch: got %#x, want %#x", lock.LockHash, f.Lock.LockHash)
+	}
+}
@@ -0,0 +1,11 @@
+// Package testutil provides an in-memory definition service, an HTTP test server, fixtures of valid signed
+// definitions and a client driving them through their lifecycle, allowing integration tests against the dvstore API
+// without Mongo.
+package testutil
+
+import "github.com/corverroos/dvstore/service"
+
+// NewDefinition returns the definition service of a new empty in-memory storage driver with the configuration.
+func NewDefinition(conf service.DefinitionConfig) service.Definition {
+	return service.NewMemoryStorage().Definition(conf)
+}
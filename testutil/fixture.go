@@ -0,0 +1,52 @@
+package testutil
+
+import (
+	"crypto/ecdsa"
+	"github.com/obolnetwork/charon/cluster"
+	"testing"
+)
+
+// Fixture is a valid signed cluster at each stage of its lifecycle.
+type Fixture struct {
+	// Draft is the signed definition created by the creator before any operators joined.
+	Draft cluster.Definition
+	// Lock is the cluster lock of the DKG ceremony after all operators joined.
+	Lock cluster.Lock
+	// OperatorKeys are the operators' secp256k1 private keys, the first operator is also the creator.
+	OperatorKeys []*ecdsa.PrivateKey
+}
+
+// Operator returns the operator at the index with its ENR and signatures populated, as submitted when joining.
+func (f Fixture) Operator(i int) cluster.Operator {
+	return f.Lock.Definition.Operators[i]
+}
+
+// Complete returns the definition after all operators joined.
+func (f Fixture) Complete() cluster.Definition {
+	return f.Lock.Definition
+}
+
+// NewFixture returns a new deterministic fixture of a mainnet cluster with the provided number of validators,
+// threshold and operators. A zero seed results in a random fixture.
+func NewFixture(t *testing.T, numValidators, threshold, numOperators, seed int) Fixture {
+	t.Helper()
+
+	lock, keys, _ := cluster.NewForT(t, numValidators, threshold, numOperators, seed)
+
+	draft := lock.Definition
+	draft.Operators = make([]cluster.Operator, 0, len(lock.Definition.Operators))
+	for _, op := range lock.Definition.Operators {
+		draft.Operators = append(draft.Operators, cluster.Operator{Address: op.Address})
+	}
+
+	draft, err := draft.SetDefinitionHashes()
+	if err != nil {
+		t.Fatalf("set definition hashes: %v", err)
+	}
+
+	return Fixture{
+		Draft:        draft,
+		Lock:         lock,
+		OperatorKeys: keys,
+	}
+}
@@ -0,0 +1,101 @@
+package testutil_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/corverroos/dvstore/service"
+	"github.com/corverroos/dvstore/testutil"
+	"net/http"
+	"testing"
+)
+
+func TestLifecycle(t *testing.T) {
+	testLifecycle(t, testutil.NewDefinition(service.DefinitionConfig{}))
+}
+
+func TestDeletion(t *testing.T) {
+	testDeletion(t, testutil.NewDefinition(service.DefinitionConfig{}))
+}
+
+func TestRejections(t *testing.T) {
+	testRejections(t, testutil.NewDefinition(service.DefinitionConfig{}))
+}
+
+// testLifecycle drives a cluster through its lifecycle up to the lock against the definition service.
+func testLifecycle(t *testing.T, defs service.Definition) {
+	t.Helper()
+
+	c := testutil.NewClient(t, testutil.NewServer(t, defs))
+	testutil.RunLifecycle(t, c, testutil.NewFixture(t, 1, 3, 4, 1))
+}
+
+// testDeletion creates a cluster, adds all operators, finalizes it and deletes it against the definition service.
+func testDeletion(t *testing.T, defs service.Definition) {
+	t.Helper()
+
+	c := testutil.NewClient(t, testutil.NewServer(t, defs))
+	f := testutil.NewFixture(t, 1, 3, 4, 2)
+
+	c.CreateDefinition(f.Draft)
+	for i := range f.Draft.Operators {
+		c.AddOperator(f.Draft, f.Operator(i), f.OperatorKeys[i])
+	}
+	c.Finalize(f.Draft.ConfigHash)
+
+	if state := c.GetState(f.Draft.ConfigHash); state.Status != service.StatusReady {
+		t.Fatalf("unexpected status: got %s, want %s", state.Status, service.StatusReady)
+	}
+
+	c.Delete(f.Draft, f.OperatorKeys[0])
+
+	if status, body := c.Do(http.MethodGet, fmt.Sprintf("/dv/%#x", f.Draft.ConfigHash), nil); status != http.StatusNotFound {
+		t.Fatalf("deleted definition: unexpected status %d: %s", status, body)
+	}
+}
+
+// testRejections verifies the definition service rejects operators joining with a request signed by another key
+// or with an invalid ENR, and unsigned deletions, without changing the definition.
+func testRejections(t *testing.T, defs service.Definition) {
+	t.Helper()
+
+	c := testutil.NewClient(t, testutil.NewServer(t, defs))
+	f := testutil.NewFixture(t, 1, 3, 4, 3)
+	path := fmt.Sprintf("/dv/%#x", f.Draft.ConfigHash)
+
+	c.CreateDefinition(f.Draft)
+
+	badENR := f.Operator(0)
+	badENR.ENR = "enr:-invalid"
+
+	tests := []struct {
+		name string
+		do   func() (int, []byte)
+	}{
+		{
+			name: "bad request signature",
+			do:   func() (int, []byte) { return c.TryAddOperator(f.Draft, f.Operator(0), f.OperatorKeys[1]) },
+		},
+		{
+			name: "bad enr",
+			do:   func() (int, []byte) { return c.TryAddOperator(f.Draft, badENR, f.OperatorKeys[0]) },
+		},
+		{
+			name: "unsigned delete",
+			do:   func() (int, []byte) { return c.Do(http.MethodDelete, path, nil) },
+		},
+	}
+
+	for _, test := range tests {
+		if status, body := test.do(); status != http.StatusBadRequest {
+			t.Fatalf("%s: unexpected status %d: %s", test.name, status, body)
+		}
+	}
+
+	def := c.GetDefinition(f.Draft.ConfigHash)
+	if !bytes.Equal(def.DefinitionHash, f.Draft.DefinitionHash) {
+		t.Fatalf("rejected requests changed the definition: got %#x, want %#x", def.DefinitionHash, f.Draft.DefinitionHash)
+	}
+
+	// The operator can still join after the rejected requests.
+	c.AddOperator(f.Draft, f.Operator(0), f.OperatorKeys[0])
+}
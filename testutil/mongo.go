@@ -0,0 +1,105 @@
+//go:build integration
+
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"github.com/corverroos/dvstore/service"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	// mongoImage is the docker image of disposable Mongo instances.
+	mongoImage = "mongo:6"
+	// mongoURLEnv is the environment variable of an existing Mongo used instead of starting a container.
+	mongoURLEnv = "DVSTORE_TEST_MONGO_URL"
+	// mongoStartTimeout is the maximum duration to wait for Mongo to accept connections.
+	mongoStartTimeout = time.Minute
+)
+
+// NewMongoDefinition returns a definition service backed by a disposable Mongo with indexes created.
+// It starts a Mongo docker container removed when the test completes, unless DVSTORE_TEST_MONGO_URL
+// is set in which case a uniquely named database of that Mongo is used and dropped instead.
+func NewMongoDefinition(t *testing.T, conf service.DefinitionConfig) service.Definition {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoStartTimeout)
+	defer cancel()
+
+	url := os.Getenv(mongoURLEnv)
+	if url == "" {
+		url = startMongo(t)
+	}
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(url))
+	if err != nil {
+		t.Fatalf("connect mongo: %v", err)
+	}
+
+	for {
+		err := client.Ping(ctx, nil)
+		if err == nil {
+			break
+		} else if ctx.Err() != nil {
+			t.Fatalf("mongo not ready: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	db := client.Database(fmt.Sprintf("dvstore_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		_ = db.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+
+	if _, err := service.Migrate(ctx, db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return service.NewDefinition(db.Collection("definitions"), conf)
+}
+
+// startMongo starts a Mongo docker container on a random host port, removed when the test completes,
+// and returns its connection URL.
+func startMongo(t *testing.T) string {
+	t.Helper()
+
+	return "mongodb://" + startContainer(t, mongoImage, "27017")
+}
+
+// startContainer starts a detached docker container of the image with the environment variables, publishing the
+// container port on a random host port. It returns the host address of the port and removes the container
+// when the test completes.
+func startContainer(t *testing.T, image string, port string, env ...string) string {
+	t.Helper()
+
+	args := []string{"run", "--detach", "--rm", "--publish", "127.0.0.1::" + port}
+	for _, e := range env {
+		args = append(args, "--env", e)
+	}
+
+	out, err := exec.Command("docker", append(args, image)...).Output()
+	if err != nil {
+		t.Fatalf("start %s container: %v", image, err)
+	}
+	id := strings.TrimSpace(string(out))
+
+	t.Cleanup(func() {
+		_ = exec.Command("docker", "rm", "--force", id).Run()
+	})
+
+	out, err = exec.Command("docker", "port", id, port+"/tcp").Output()
+	if err != nil {
+		t.Fatalf("get %s container port: %v", image, err)
+	}
+
+	// Use the first mapping if docker reports multiple.
+	return strings.TrimSpace(strings.Split(string(out), "\n")[0])
+}
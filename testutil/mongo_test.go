@@ -0,0 +1,54 @@
+//go:build integration
+
+package testutil_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/corverroos/dvstore/service"
+	"github.com/corverroos/dvstore/testutil"
+	"testing"
+)
+
+func TestMongoDefinition(t *testing.T) {
+	ctx := context.Background()
+	defs := testutil.NewMongoDefinition(t, service.DefinitionConfig{})
+	f := testutil.NewFixture(t, 1, 3, 4, 1)
+
+	if _, err := defs.Create(ctx, f.Draft, service.CreateOptions{}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// Creating the identical definition again is idempotent.
+	if _, err := defs.Create(ctx, f.Draft, service.CreateOptions{}); err != nil {
+		t.Fatalf("create again: %v", err)
+	}
+
+	def, final, _, err := defs.Get(ctx, f.Draft.ConfigHash)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	} else if final {
+		t.Fatalf("draft definition is final")
+	} else if !bytes.Equal(def.DefinitionHash, f.Draft.DefinitionHash) {
+		t.Fatalf("definition hash mismatch: got %#x, want %#x", def.DefinitionHash, f.Draft.DefinitionHash)
+	}
+
+	state, err := defs.State(ctx, f.Draft.ConfigHash)
+	if err != nil {
+		t.Fatalf("state: %v", err)
+	} else if state.Status != service.StatusDraft {
+		t.Fatalf("unexpected status: got %s, want %s", state.Status, service.StatusDraft)
+	}
+}
+
+func TestMongoLifecycle(t *testing.T) {
+	testLifecycle(t, testutil.NewMongoDefinition(t, service.DefinitionConfig{}))
+}
+
+func TestMongoDeletion(t *testing.T) {
+	testDeletion(t, testutil.NewMongoDefinition(t, service.DefinitionConfig{}))
+}
+
+func TestMongoRejections(t *testing.T) {
+	testRejections(t, testutil.NewMongoDefinition(t, service.DefinitionConfig{}))
+}
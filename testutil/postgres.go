@@ -0,0 +1,92 @@
+//go:build integration
+
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/corverroos/dvstore/service"
+	_ "github.com/lib/pq" // Registers the postgres sql driver.
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+const (
+	// postgresImage is the docker image of disposable Postgres instances.
+	postgresImage = "postgres:15"
+	// postgresURLEnv is the environment variable of an existing Postgres used instead of starting a container.
+	postgresURLEnv = "DVSTORE_TEST_POSTGRES_URL"
+	// postgresStartTimeout is the maximum duration to wait for Postgres to accept connections.
+	postgresStartTimeout = time.Minute
+)
+
+// NewPGDefinition returns a definition service backed by a disposable Postgres with tables created.
+// It starts a Postgres docker container removed when the test completes, unless DVSTORE_TEST_POSTGRES_URL
+// is set in which case a uniquely named database of that Postgres is created and dropped instead.
+func NewPGDefinition(t *testing.T, conf service.DefinitionConfig) service.Definition {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), postgresStartTimeout)
+	defer cancel()
+
+	rawURL := os.Getenv(postgresURLEnv)
+	if rawURL == "" {
+		addr := startContainer(t, postgresImage, "5432", "POSTGRES_PASSWORD=postgres")
+		rawURL = "postgres://postgres:postgres@" + addr + "/postgres?sslmode=disable"
+	}
+
+	admin := openPostgres(ctx, t, rawURL)
+	defer admin.Close()
+
+	name := fmt.Sprintf("dvstore_test_%d", time.Now().UnixNano())
+	if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+name); err != nil {
+		t.Fatalf("create database: %v", err)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parse postgres url: %v", err)
+	}
+	u.Path = "/" + name
+
+	db := openPostgres(ctx, t, u.String())
+	t.Cleanup(func() {
+		_ = db.Close()
+
+		admin, err := sql.Open("postgres", rawURL)
+		if err != nil {
+			return
+		}
+		defer admin.Close()
+		_, _ = admin.Exec("DROP DATABASE IF EXISTS " + name)
+	})
+
+	if err := service.MigratePostgres(ctx, db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return service.NewPostgresStorage(db).Definition(conf)
+}
+
+// openPostgres returns a connection pool of the Postgres url, waiting until it accepts connections.
+func openPostgres(ctx context.Context, t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+
+	for {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return db
+		} else if ctx.Err() != nil {
+			t.Fatalf("postgres not ready: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
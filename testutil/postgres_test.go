@@ -0,0 +1,21 @@
+//go:build integration
+
+package testutil_test
+
+import (
+	"github.com/corverroos/dvstore/service"
+	"github.com/corverroos/dvstore/testutil"
+	"testing"
+)
+
+func TestPGLifecycle(t *testing.T) {
+	testLifecycle(t, testutil.NewPGDefinition(t, service.DefinitionConfig{}))
+}
+
+func TestPGDeletion(t *testing.T) {
+	testDeletion(t, testutil.NewPGDefinition(t, service.DefinitionConfig{}))
+}
+
+func TestPGRejections(t *testing.T) {
+	testRejections(t, testutil.NewPGDefinition(t, service.DefinitionConfig{}))
+}
@@ -0,0 +1,24 @@
+package testutil
+
+import (
+	"github.com/corverroos/dvstore/router"
+	"github.com/corverroos/dvstore/service"
+	"net/http/httptest"
+	"testing"
+)
+
+// NewServer returns a started dvstore API test server backed by the definition service and in-memory templates,
+// it is closed when the test completes.
+func NewServer(t *testing.T, defSvc service.Definition) *httptest.Server {
+	t.Helper()
+
+	r, err := router.NewRouter(defSvc, service.NewMemTemplate(), nil, nil, nil, nil, nil, nil, nil, router.Config{})
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	return srv
+}